@@ -6,21 +6,167 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/user"
+	"path"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 
+	client "github.com/itszeeshan/reposync/client"
 	colors "github.com/itszeeshan/reposync/constants/colors"
 	models "github.com/itszeeshan/reposync/constants/models"
 	helpers "github.com/itszeeshan/reposync/helpers"
+	mock "github.com/itszeeshan/reposync/mock"
 	services "github.com/itszeeshan/reposync/services"
 )
 
+// Build information, set by release builds with -ldflags "-X main.version=v1.2.3 -X main.commit=... -X main.date=...".
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+/*
+buildInfo returns the version, commit and build date of the binary.
+Release builds carry them in ldflags; for go install and go build the module
+version and the VCS stamp embedded by the Go toolchain fill in the gaps.
+*/
+func buildInfo() (string, string, string) {
+	v, c, d := version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && c == "":
+				c = setting.Value
+			case setting.Key == "vcs.time" && d == "":
+				d = setting.Value
+			}
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	return v, c, d
+}
+
+/*
+handleVersion implements the version subcommand.
+*/
+func handleVersion(args []string) {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	short := flags.Bool("short", false, "Print only the version number")
+	flags.Parse(args)
+
+	v, c, d := buildInfo()
+	if *short {
+		fmt.Println(v)
+		return
+	}
+	fmt.Printf("reposync %s\n", v)
+	if c != "" {
+		fmt.Printf("  commit:   %s\n", c)
+	}
+	if d != "" {
+		fmt.Printf("  built:    %s\n", d)
+	}
+	fmt.Printf("  go:       %s\n", runtime.Version())
+	fmt.Printf("  platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+}
+
+/*
+handleSelfUpdate implements the self-update subcommand.
+*/
+func handleSelfUpdate(args []string) error {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError)
+	check := flags.Bool("check", false, "Only report whether a newer release is available")
+	flags.Parse(args)
+
+	v, _, _ := buildInfo()
+	return services.SelfUpdate(v, *check)
+}
+
+/*
+handleDoctor implements the doctor subcommand.
+Checks git, the config file and a workspace (current directory by default);
+with --bundle, also writes them and the runs recorded with --diagnostics to a
+report that can be attached to bug reports. Tokens are only reported as set or not.
+*/
+func handleDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	bundlePath := flags.String("bundle", "", "Write an anonymized diagnostics report to this file")
+	flags.Parse(args)
+
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+	workspace, err := helpers.ExpandPath(workspace)
+	if err != nil {
+		return err
+	}
+
+	configCheck := models.DiagnosticsCheck{Name: "config file", OK: true}
+	config, err := readConfig()
+	switch {
+	case os.IsNotExist(err):
+		configCheck.OK, configCheck.Detail = false, "not found: run reposync config"
+		config = &models.Config{}
+	case err != nil:
+		configCheck.OK, configCheck.Detail = false, "unreadable or invalid"
+		config = &models.Config{}
+	default:
+		configCheck.Detail = "readable"
+	}
+	applyEnvConfig(config)
+
+	var tokens []string
+	for provider, token := range map[string]string{"gitlab": config.GitLabToken, "github": config.GitHubToken, "bitbucket-server": config.BitbucketServerToken} {
+		if token != "" {
+			tokens = append(tokens, provider)
+		}
+	}
+	sort.Strings(tokens)
+	tokenCheck := models.DiagnosticsCheck{Name: "tokens", OK: len(tokens) > 0, Detail: "set for " + strings.Join(tokens, ", ")}
+	if len(tokens) == 0 {
+		tokenCheck.Detail = "none configured"
+	}
+
+	v, _, _ := buildInfo()
+	return services.Doctor(workspace, *bundlePath, v, []models.DiagnosticsCheck{configCheck, tokenCheck})
+}
+
+/*
+stringListFlag collects the values of a repeatable command-line flag.
+*/
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 /*
 getSecureInput reads sensitive input without displaying it on screen.
 Uses terminal.ReadPassword to hide input from terminal history and process lists.
@@ -36,48 +182,124 @@ func getSecureInput(prompt string) (string, error) {
 }
 
 /*
-handleConfig implements interactive token configuration workflow.
-Prompts user for both GitLab and GitHub tokens using secure input,
-then saves them to encrypted config file in user's home directory for future use.
+readTokenSource reads a token from a file, or from standard input when path is "-".
+Surrounding whitespace (e.g. the trailing newline of `echo`) is removed.
 */
-func handleConfig() error {
-	fmt.Print("Enter GitLab Personal Access Token: ")
-	gitlabToken, err := getSecureInput("")
+func readTokenSource(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read GitLab token: %w", err)
+		return "", err
 	}
+	return strings.TrimSpace(string(data)), nil
+}
 
-	fmt.Print("Enter GitHub Personal Access Token: ")
-	githubToken, err := getSecureInput("")
-	if err != nil {
-		return fmt.Errorf("failed to read GitHub token: %w", err)
+/*
+handleConfig implements the token configuration workflow.
+Without flags, prompts for both GitLab and GitHub tokens using secure input.
+For provisioning tools, tokens can be read from files or standard input instead;
+only the tokens given are replaced, other settings in the config file are kept.
+The file can be encrypted at rest with a passphrase or a machine key.
+*/
+func handleConfig(args []string) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	gitlabStdin := flags.Bool("gitlab-token-stdin", false, "Read the GitLab token from standard input")
+	githubStdin := flags.Bool("github-token-stdin", false, "Read the GitHub token from standard input")
+	gitlabFile := flags.String("gitlab-token-file", "", "Read the GitLab token from this file")
+	githubFile := flags.String("github-token-file", "", "Read the GitHub token from this file")
+	bitbucketStdin := flags.Bool("bitbucket-server-token-stdin", false, "Read the Bitbucket Server token from standard input")
+	bitbucketFile := flags.String("bitbucket-server-token-file", "", "Read the Bitbucket Server token from this file")
+	encrypt := flags.Bool("encrypt", false, "Encrypt the config file with a passphrase")
+	machineKey := flags.Bool("machine-key", false, "With --encrypt: use a generated machine key in the OS keyring (or REPOSYNC_KEY_FILE) instead of a passphrase")
+	decrypt := flags.Bool("decrypt", false, "Store the config file unencrypted again")
+	flags.Parse(args)
+
+	if *encrypt && *decrypt {
+		return fmt.Errorf("--encrypt and --decrypt cannot be combined")
 	}
 
-	// Validate tokens
-	if err := helpers.ValidateToken(gitlabToken); err != nil {
-		return fmt.Errorf("invalid GitLab token: %w", err)
+	if (*gitlabStdin && *githubStdin) || (*bitbucketStdin && (*gitlabStdin || *githubStdin)) {
+		return fmt.Errorf("only one token can be read from standard input")
+	}
+	if *gitlabStdin {
+		*gitlabFile = "-"
+	}
+	if *githubStdin {
+		*githubFile = "-"
 	}
-	if err := helpers.ValidateToken(githubToken); err != nil {
-		return fmt.Errorf("invalid GitHub token: %w", err)
+	if *bitbucketStdin {
+		*bitbucketFile = "-"
 	}
 
-	config := models.Config{
-		GitLabToken: gitlabToken,
-		GitHubToken: githubToken,
+	// Keep settings that are not changed here (URLs, defaults, rate limits)
+	config, keySource, err := readConfigFile()
+	configExists := err == nil
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read existing config: %w", err)
+		}
+		config = &models.Config{}
 	}
 
-	configPath := getConfigPath()
-	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+	// --encrypt and --decrypt alone only convert an existing config file
+	interactive := *gitlabFile == "" && *githubFile == "" && *bitbucketFile == "" && (!configExists || !(*encrypt || *decrypt))
+	if interactive {
+		fmt.Print("Enter GitLab Personal Access Token: ")
+		if config.GitLabToken, err = getSecureInput(""); err != nil {
+			return fmt.Errorf("failed to read GitLab token: %w", err)
+		}
+
+		fmt.Print("Enter GitHub Personal Access Token: ")
+		if config.GitHubToken, err = getSecureInput(""); err != nil {
+			return fmt.Errorf("failed to read GitHub token: %w", err)
+		}
+	}
+	if *gitlabFile != "" {
+		if config.GitLabToken, err = readTokenSource(*gitlabFile); err != nil {
+			return fmt.Errorf("failed to read GitLab token: %w", err)
+		}
+	}
+	if *githubFile != "" {
+		if config.GitHubToken, err = readTokenSource(*githubFile); err != nil {
+			return fmt.Errorf("failed to read GitHub token: %w", err)
+		}
+	}
+	if *bitbucketFile != "" {
+		if config.BitbucketServerToken, err = readTokenSource(*bitbucketFile); err != nil {
+			return fmt.Errorf("failed to read Bitbucket Server token: %w", err)
+		}
+		if err := helpers.ValidateToken(config.BitbucketServerToken); err != nil {
+			return fmt.Errorf("invalid Bitbucket Server token: %w", err)
+		}
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	// Validate the tokens that were entered
+	if interactive || *gitlabFile != "" {
+		if err := helpers.ValidateToken(config.GitLabToken); err != nil {
+			return fmt.Errorf("invalid GitLab token: %w", err)
+		}
+	}
+	if interactive || *githubFile != "" {
+		if err := helpers.ValidateToken(config.GitHubToken); err != nil {
+			return fmt.Errorf("invalid GitHub token: %w", err)
+		}
 	}
 
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	switch {
+	case *decrypt:
+		keySource = ""
+	case *encrypt && *machineKey:
+		keySource = "machine"
+	case *encrypt:
+		keySource = "passphrase"
+	}
+	if err := writeConfig(config, keySource); err != nil {
+		return err
 	}
 
 	fmt.Println(colors.Green + "Configuration saved successfully!" + colors.Reset)
@@ -86,142 +308,2123 @@ func handleConfig() error {
 
 /*
 getConfigPath determines OS-appropriate location for config file.
-Uses platform-independent path construction to store configuration
-in ~/.reposync/config.json while ensuring proper permissions.
+Follows the XDG base directory spec: $XDG_CONFIG_HOME/reposync/config.json when
+XDG_CONFIG_HOME is set or ~/.config/reposync/config.json already exists,
+and the traditional ~/.reposync/config.json otherwise.
 */
 func getConfigPath() string {
+	if xdgHome := os.Getenv("XDG_CONFIG_HOME"); xdgHome != "" {
+		return filepath.Join(xdgHome, "reposync", "config.json")
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(colors.Red + "Failed to get user home directory: " + err.Error() + colors.Reset)
 	}
+	xdgPath := filepath.Join(home, ".config", "reposync", "config.json")
+	if _, err := os.Stat(xdgPath); err == nil {
+		return xdgPath
+	}
 	return filepath.Join(home, ".reposync", "config.json")
 }
 
+/*
+getSystemConfigPath returns the machine-wide config file that admins use
+to preconfigure base URLs and policies on shared build machines.
+REPOSYNC_SYSTEM_CONFIG overrides the default /etc/reposync/config.yaml.
+*/
+func getSystemConfigPath() string {
+	if path := os.Getenv("REPOSYNC_SYSTEM_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(string(filepath.Separator), "etc", "reposync", "config.yaml")
+}
+
+/*
+parseSystemConfig decodes the system config into config. The file is YAML, of which
+JSON is a subset, with the same keys as the user's config.json.
+*/
+func parseSystemConfig(data []byte, config *models.Config) error {
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
 /*
 readConfig loads persisted authentication tokens from disk.
-Handles both file existence checks and JSON parsing errors,
+Handles both file existence checks and parsing errors,
 providing clear guidance if configuration is missing or corrupted.
+The system config is loaded first and the user's config merged on top:
+settings present in the user file win, maps are merged key by key.
 */
 func readConfig() (*models.Config, error) {
-	configPath := getConfigPath()
-	data, err := os.ReadFile(configPath)
+	var config models.Config
+
+	systemData, systemErr := os.ReadFile(getSystemConfigPath())
+	if systemErr == nil {
+		if err := parseSystemConfig(systemData, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", getSystemConfigPath(), err)
+		}
+	} else if !os.IsNotExist(systemErr) {
+		return nil, systemErr
+	}
+
+	userData, _, err := readUserConfigData()
 	if err != nil {
+		if os.IsNotExist(err) && systemErr == nil {
+			return &config, nil
+		}
 		return nil, err
 	}
+	err = json.Unmarshal(userData, &config)
+	return &config, err
+}
+
+/*
+readConfigFile loads the user's config file on its own, without the system config.
+Also returns the key source of an encrypted file ("" for plain JSON),
+so rewriting the file can keep it encrypted the same way.
+*/
+func readConfigFile() (*models.Config, string, error) {
+	data, keySource, err := readUserConfigData()
+	if err != nil {
+		return nil, "", err
+	}
 
 	var config models.Config
 	err = json.Unmarshal(data, &config)
-	return &config, err
+	return &config, keySource, err
 }
 
 /*
-main coordinates command execution flow and argument parsing.
-Implements dual-mode operation:
-1. Configuration mode (reposync config)
-2. Sync mode (reposync -p ...)
-Validates inputs and initiates appropriate synchronization workflow.
+readUserConfigData reads the user's config file, decrypting it in memory if it is encrypted.
 */
-func main() {
-	if len(os.Args) >= 2 && os.Args[1] == "config" {
-		if err := handleConfig(); err != nil {
-			log.Fatal(colors.Red + "Failed to configure tokens: " + err.Error() + colors.Reset)
-		}
-		os.Exit(0)
+func readUserConfigData() ([]byte, string, error) {
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		return nil, "", err
 	}
 
-	provider := flag.String("p", "", "Provider: gitlab or github")
-	groupID := flag.String("g", "", "Group/Organization ID")
-	cloneMethod := flag.String("m", "https", "Clone method: https or ssh")
-	help := flag.Bool("h", false, "Show help message")
+	var envelope struct {
+		Encrypted *models.EncryptedConfig `json:"encrypted"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, "", err
+	}
 
-	flag.Parse()
+	keySource := ""
+	if envelope.Encrypted != nil {
+		keySource = envelope.Encrypted.KeySource
+		secret, err := configSecret(keySource, false)
+		if err != nil {
+			return nil, "", err
+		}
+		if data, err = helpers.DecryptConfig(envelope.Encrypted, secret); err != nil {
+			return nil, "", err
+		}
+	}
+	return data, keySource, nil
+}
 
-	if *help || flag.NFlag() == 0 {
-		fmt.Println(`reposync - Sync repositories from GitHub or GitLab
+/*
+writeConfig saves the config file with owner-only permissions.
+With a key source ("passphrase" or "machine") the tokens are encrypted at rest.
+*/
+func writeConfig(config *models.Config, keySource string) error {
+	configPath := getConfigPath()
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
 
-Usage:
-  reposync config               Configure personal access tokens
-  reposync -p <gitlab|github> -g <GROUP_ID> [-m <https|ssh>]
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
 
-Flags:
-  -p  Provider: gitlab or github
-  -g  Group/Organization ID
-  -m  Clone method: https or ssh (default: https)
-  -h  Show help message`)
-		os.Exit(0)
+	if keySource != "" {
+		secret, err := configSecret(keySource, true)
+		if err != nil {
+			return err
+		}
+		encrypted, err := helpers.EncryptConfig(data, secret, keySource)
+		if err != nil {
+			return err
+		}
+		if data, err = json.MarshalIndent(map[string]any{"encrypted": encrypted}, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
 	}
 
-	// Validate provider
-	if *provider != "gitlab" && *provider != "github" {
-		fmt.Println(colors.Red + "Unsupported provider. Use 'gitlab' or 'github'." + colors.Reset)
-		os.Exit(1)
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	return nil
+}
 
-	// Validate group ID/organization name
-	if *provider == "gitlab" {
-		if err := helpers.ValidateGroupID(*groupID); err != nil {
-			fmt.Printf(colors.Red+"Invalid group ID: %v\n"+colors.Reset, err)
-			os.Exit(1)
+/*
+configSecret returns the secret an encrypted config file is sealed with.
+The machine key comes from the OS keyring or REPOSYNC_KEY_FILE (created when encrypting);
+a passphrase comes from REPOSYNC_PASSPHRASE for unattended runs or is prompted for.
+*/
+func configSecret(keySource string, encrypting bool) ([]byte, error) {
+	if keySource == "machine" {
+		return helpers.LoadMachineKey(encrypting)
+	}
+	if passphrase := os.Getenv("REPOSYNC_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	passphrase, err := getSecureInput("Config passphrase: ")
+	if err != nil {
+		return nil, fmt.Errorf("the config file is encrypted, set REPOSYNC_PASSPHRASE for non-interactive use: %w", err)
+	}
+	if encrypting {
+		confirmation, err := getSecureInput("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		if err := helpers.ValidateOrganizationName(*groupID); err != nil {
-			fmt.Printf(colors.Red+"Invalid organization name: %v\n"+colors.Reset, err)
-			os.Exit(1)
+		if confirmation != passphrase {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+		if len(passphrase) < 8 {
+			return nil, fmt.Errorf("passphrase must be at least 8 characters")
 		}
 	}
+	return []byte(passphrase), nil
+}
 
-	// Validate clone method
+/*
+handleStats implements the stats subcommand.
+Reports aggregate statistics over a workspace (current directory by default)
+from its state file and the clones on disk, as a table or as JSON.
+*/
+func handleStats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print statistics as JSON")
+	staleAfter := flags.String("stale", "180d", "Report repositories without a commit for this long")
+	flags.Parse(args)
+
+	threshold, err := helpers.ParseAge(*staleAfter)
+	if err != nil {
+		return err
+	}
+
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+
+	stats, err := services.ComputeWorkspaceStats(workspace, threshold)
+	if err != nil {
+		return err
+	}
+	return services.PrintWorkspaceStats(os.Stdout, stats, *asJSON)
+}
+
+/*
+handleRestore implements the restore subcommand.
+Pushes the repositories of a backup set (a workspace synced by reposync, the current
+directory by default) to a GitLab group or GitHub organization and recreates their
+settings. The target instance and token come from the config file or the environment.
+*/
+func handleRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	provider := flags.String("p", "", "Provider of the target: gitlab or github")
+	target := flags.String("g", "", "Group ID or path, or organization name to restore into")
+	cloneMethod := flags.String("m", "https", "Push method: https or ssh")
+	dryRun := flags.Bool("dry-run", false, "Show what would be restored without changing anything")
+	flags.Parse(args)
+
+	if *target == "" || (*provider != "gitlab" && *provider != "github") {
+		return fmt.Errorf("usage: reposync restore -p <gitlab|github> -g <group|organization> [--dry-run] [backup directory]")
+	}
 	if *cloneMethod != "https" && *cloneMethod != "ssh" {
-		fmt.Println(colors.Red + "Invalid clone method. Use 'https' or 'ssh'." + colors.Reset)
-		os.Exit(1)
+		return fmt.Errorf("invalid push method %q: use https or ssh", *cloneMethod)
+	}
+	backupDir := "."
+	if flags.NArg() > 0 {
+		backupDir = flags.Arg(0)
+	}
+	// Clones are pushed without their origin/HEAD, which takes a negative refspec
+	version, err := helpers.GitVersion(helpers.ExecGitRunner{})
+	if err != nil {
+		return err
+	}
+	if err := helpers.RequireGitVersion(version, helpers.NegativeRefspecGitVersion, "reposync restore"); err != nil {
+		return err
 	}
 
 	config, err := readConfig()
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println(colors.Red + "No configuration found. Please run 'reposync config' to configure your tokens." + colors.Reset)
-		} else {
-			fmt.Println(colors.Red + "Failed to read configuration: " + err.Error() + colors.Reset)
+		return err
+	}
+	applyEnvConfig(config)
+	helpers.RegisterSecret(config.GitLabToken)
+	helpers.RegisterSecret(config.GitHubToken)
+
+	options := models.SyncOptions{CloneMethod: *cloneMethod, BaseDir: backupDir, NoWrite: *dryRun, TokenType: models.TokenTypePersonal}
+	if *provider == "gitlab" {
+		options.Token, options.BaseURL = config.GitLabToken, config.GitLabURL
+	} else {
+		options.Token, options.BaseURL = config.GitHubToken, config.GitHubURL
+	}
+	if options.Token == "" {
+		return fmt.Errorf("no %s token configured: run reposync config or set REPOSYNC_%s_TOKEN", *provider, strings.ToUpper(*provider))
+	}
+
+	if *provider == "gitlab" {
+		groupID, err := services.ResolveGitLabGroup(*target, options)
+		if err != nil {
+			return err
 		}
-		os.Exit(1)
+		return services.RestoreGitLabGroup(groupID, options)
 	}
+	return services.RestoreGitHubOrganization(*target, options)
+}
 
-	var token string
-	switch *provider {
-	case "gitlab":
-		token = config.GitLabToken
-	case "github":
-		token = config.GitHubToken
+/*
+handleView implements the view subcommand.
+"view create" links the clones of a workspace that match the filters into a
+view directory, so teams can have their own layout over one set of clones.
+*/
+func handleView(args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("usage: reposync view create [-d workspace] [--filter key=value]... [--flat] <view directory>")
 	}
 
-	if token == "" {
-		fmt.Printf(colors.Red+"No token found for provider %s. Please run 'reposync config' to configure your tokens.\n"+colors.Reset, *provider)
-		os.Exit(1)
+	flags := flag.NewFlagSet("view create", flag.ExitOnError)
+	workspace := flags.String("d", ".", "Workspace whose clones are linked")
+	flat := flags.Bool("flat", false, "Name links after the repository instead of mirroring the workspace layout")
+	var filters stringListFlag
+	flags.Var(&filters, "filter", "Only link repositories matching key=value (team, topic, language, visibility, provider or path; repeatable)")
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: reposync view create [-d workspace] [--filter key=value]... [--flat] <view directory>")
+	}
+	workspaceDir, err := helpers.ExpandPath(*workspace)
+	if err != nil {
+		return err
+	}
+	viewDir, err := helpers.ExpandPath(flags.Arg(0))
+	if err != nil {
+		return err
 	}
+	return services.CreateView(workspaceDir, viewDir, filters, *flat)
+}
 
-	// Validate token
-	if err := helpers.ValidateToken(token); err != nil {
-		fmt.Printf(colors.Red+"Invalid token for provider %s: %v\n"+colors.Reset, *provider, err)
-		os.Exit(1)
+/*
+handleMeta implements the meta subcommand.
+"meta init" makes a workspace (current directory by default) a git repository
+with every clone pinned as a submodule at its checked-out commit.
+*/
+func handleMeta(args []string) error {
+	if len(args) == 0 || args[0] != "init" {
+		return fmt.Errorf("usage: reposync meta init [-m message] [workspace]")
 	}
 
-	fmt.Println(colors.Blue + "Starting repository cloning process..." + colors.Reset)
+	flags := flag.NewFlagSet("meta init", flag.ExitOnError)
+	message := flags.String("m", "", "Commit message (default: a snapshot message with the date)")
+	flags.Parse(args[1:])
 
-	var syncErr error
-	if *provider == "gitlab" {
-		groupIDInt := helpers.ParseStringToInt(*groupID)
-		// The service will create the proper root directory structure
-		syncErr = services.CloneGitLabRepositories(token, groupIDInt, *cloneMethod, ".")
-	} else {
-		// Create root directory with organization name
-		rootDir := *groupID
-		syncErr = services.CloneGitHubRepositories(token, *groupID, *cloneMethod, rootDir)
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+	workspace, err := helpers.ExpandPath(workspace)
+	if err != nil {
+		return err
 	}
+	if _, err := helpers.GitVersion(helpers.ExecGitRunner{}); err != nil {
+		return err
+	}
+	return services.InitMetaRepository(workspace, *message)
+}
 
-	if syncErr != nil {
-		fmt.Printf(colors.Red+"Repository synchronization failed: %v\n"+colors.Reset, syncErr)
-		os.Exit(1)
+/*
+handleState implements the state subcommand.
+Lists the repositories of the workspace state, shows the entry of one repository
+or removes it, e.g. before moving a clone by hand.
+*/
+func handleState(args []string) error {
+	usage := fmt.Errorf("usage: reposync state list [--json] [-d workspace] | state <show|rm> [-d workspace] <repository>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("state "+args[0], flag.ExitOnError)
+	dir := flags.String("d", ".", "Workspace directory")
+	asJSON := flags.Bool("json", false, "list only: print the entries as JSON")
+	flags.Parse(args[1:])
+
+	workspace, err := helpers.ExpandPath(*dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "list" && flags.NArg() == 0:
+		return services.ListState(os.Stdout, workspace, *asJSON)
+	case args[0] == "show" && flags.NArg() == 1:
+		return services.ShowState(os.Stdout, workspace, flags.Arg(0))
+	case args[0] == "rm" && flags.NArg() == 1:
+		return services.RemoveState(workspace, flags.Arg(0))
+	}
+	return usage
+}
+
+/*
+handleRepair implements the repair subcommand.
+Checks the clones recorded in the state of a workspace (current directory by default)
+and re-clones broken ones with the configured tokens or fixes their remotes, see services.RepairWorkspace.
+*/
+func handleRepair(args []string) error {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	noWrite := flags.Bool("no-write", false, "Show what would be repaired without modifying anything")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: reposync repair [--no-write] [workspace]")
 	}
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+	workspace, err := helpers.ExpandPath(workspace)
+	if err != nil {
+		return err
+	}
+	if _, err := helpers.GitVersion(helpers.ExecGitRunner{}); err != nil {
+		return err
+	}
+	if *noWrite {
+		helpers.SetReadOnly(true)
+	}
+	return services.RepairWorkspace(workspace, *noWrite, repairCredentials())
+}
+
+/*
+repairCredentials returns the tokens broken clones are cloned again with, by provider.
+Without a config file, only the environment is used, and public repositories and
+configured git credential helpers still work.
+*/
+func repairCredentials() map[string]models.SourceCredentials {
+	config, err := readConfig()
+	if err != nil {
+		config = &models.Config{}
+	}
+	applyEnvConfig(config)
 
-	fmt.Println(colors.Green + "Repository synchronization completed successfully!" + colors.Reset)
+	gitHubToken, _, actions := gitHubCredentials(config)
+	gitHubUser := "oauth2"
+	if actions {
+		gitHubUser = "x-access-token"
+	}
+	credentials := map[string]models.SourceCredentials{
+		"gitlab":           {Token: config.GitLabToken, CloneUsername: "oauth2"},
+		"github":           {Token: gitHubToken, CloneUsername: gitHubUser},
+		"bitbucket-server": {Token: config.BitbucketServerToken, CloneUsername: config.BitbucketServerUser},
+	}
+	for _, creds := range credentials {
+		helpers.RegisterSecret(creds.Token)
+	}
+	return credentials
+}
+
+/*
+handleExport implements the export subcommand.
+Writes the clones of a workspace (current directory by default), pinned at their
+commits, as a manifest of Google's repo tool or a gitman.yml, or each clone as a
+git bundle, optionally without the email addresses of its authors.
+*/
+func handleExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "Export format: repo-manifest, gitman or bundle")
+	output := flags.String("o", "", "Write the manifest to this file instead of stdout, or the bundles to this directory")
+	anonymize := flags.String("anonymize-emails", "", "bundle only: strip or hash the email addresses of authors, committers and taggers")
+	flags.Parse(args)
+
+	if *format == "" {
+		return fmt.Errorf("usage: reposync export --format <repo-manifest|gitman> [-o file] [workspace]\n       reposync export --format bundle -o <directory> [--anonymize-emails strip|hash] [workspace]")
+	}
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+	workspace, err := helpers.ExpandPath(workspace)
+	if err != nil {
+		return err
+	}
+
+	if *format == services.ExportFormatBundle {
+		if *output == "" {
+			return fmt.Errorf("--format bundle needs an output directory (-o)")
+		}
+		outputDir, err := helpers.ExpandPath(*output)
+		if err != nil {
+			return err
+		}
+		return services.ExportBundles(workspace, outputDir, *anonymize)
+	}
+	if *anonymize != "" {
+		return fmt.Errorf("--anonymize-emails is only supported with --format bundle")
+	}
+	if *output == "" {
+		// The manifest takes stdout, so skipped repositories are reported on stderr
+		services.SetRenderer(services.NewTextRenderer(os.Stderr))
+		return services.ExportWorkspace(os.Stdout, workspace, *format)
+	}
+	var manifest strings.Builder
+	if err := services.ExportWorkspace(&manifest, workspace, *format); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, []byte(manifest.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Printf(colors.Green+"Wrote %s manifest to %s\n"+colors.Reset, *format, *output)
+	return nil
+}
+
+/*
+handleLock implements the lock subcommand.
+Records the commit every clone of a workspace (current directory by default)
+has checked out in reposync.lock, for reposync sync --locked to check out again.
+"lock diff" compares two lockfiles.
+*/
+func handleLock(args []string) error {
+	if len(args) > 0 && args[0] == "diff" {
+		return handleLockDiff(args[1:])
+	}
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	output := flags.String("o", "", "Write the lockfile here instead of reposync.lock in the workspace")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: reposync lock [-o file] [workspace]")
+	}
+	workspace := "."
+	if flags.NArg() > 0 {
+		workspace = flags.Arg(0)
+	}
+	workspace, err := helpers.ExpandPath(workspace)
+	if err != nil {
+		return err
+	}
+	lockPath := ""
+	if *output != "" {
+		if lockPath, err = helpers.ExpandPath(*output); err != nil {
+			return err
+		}
+	}
+	if _, err := helpers.GitVersion(helpers.ExecGitRunner{}); err != nil {
+		return err
+	}
+	return services.LockWorkspace(workspace, lockPath)
+}
+
+/*
+handleLockDiff implements "lock diff": the repositories locked at other commits in
+the newer of two lockfiles, with their number of commits and compare links.
+*/
+func handleLockDiff(args []string) error {
+	flags := flag.NewFlagSet("lock diff", flag.ExitOnError)
+	workspace := flags.String("d", ".", "Workspace whose clones the commits are counted in")
+	asJSON := flags.Bool("json", false, "Print the comparison as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: reposync lock diff [-d workspace] [--json] <old lockfile> <new lockfile>")
+	}
+	workspaceDir, err := helpers.ExpandPath(*workspace)
+	if err != nil {
+		return err
+	}
+	diff, err := services.DiffLockfiles(flags.Arg(0), flags.Arg(1), workspaceDir)
+	if err != nil {
+		return err
+	}
+	return services.PrintLockDiff(os.Stdout, diff, *asJSON)
+}
+
+/*
+handleWorkspaces implements the workspaces subcommand.
+"workspaces list" shows the workspaces synced on this machine, as recorded in the
+machine-wide registry; "workspaces sync-all" syncs all of them again.
+*/
+func handleWorkspaces(args []string) error {
+	usage := fmt.Errorf("usage: reposync workspaces list [--json] | workspaces sync-all")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("workspaces "+args[0], flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "list only: print the workspaces as JSON")
+	flags.Parse(args[1:])
+	if flags.NArg() > 0 {
+		return usage
+	}
+
+	registryPath, err := helpers.GetRegistryPath()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		return services.ListWorkspaces(os.Stdout, registryPath, *asJSON)
+	case "sync-all":
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the reposync binary: %w", err)
+		}
+		return services.SyncAllWorkspaces(registryPath, executable)
+	}
+	return usage
+}
+
+/*
+handleBench implements the bench subcommand.
+Clones synthetic repositories, or the first repositories of a group or organization,
+at several concurrency levels and reports the throughput of each.
+*/
+func handleBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	provider := flags.String("p", "", "Benchmark against this provider instead of synthetic repositories: gitlab, github or bitbucket-server")
+	groupID := flags.String("g", "", "Group ID, organization name or Bitbucket Server project key to clone from")
+	cloneMethod := flags.String("m", "https", "Clone method: https or ssh")
+	count := flags.Int("repos", 20, "Number of repositories cloned at every level")
+	sizeMB := flags.Int("size-mb", 5, "Size of each synthetic repository in MB")
+	levels := flags.String("levels", "1,2,4,8", "Comma-separated concurrency levels to measure")
+	asJSON := flags.Bool("json", false, "Print the results as JSON")
+	flags.Parse(args)
+
+	var concurrencies []int
+	for _, value := range strings.Split(*levels, ",") {
+		level, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || level < 1 {
+			return fmt.Errorf("invalid concurrency level %q: use positive numbers such as 1,2,4,8", value)
+		}
+		concurrencies = append(concurrencies, level)
+	}
+	if *count < 1 || *sizeMB < 1 {
+		return fmt.Errorf("--repos and --size-mb must be at least 1")
+	}
+	if *cloneMethod != "https" && *cloneMethod != "ssh" {
+		return fmt.Errorf("invalid clone method %q: use https or ssh", *cloneMethod)
+	}
+
+	if _, err := helpers.GitVersion(helpers.ExecGitRunner{}); err != nil {
+		return err
+	}
+
+	options := models.SyncOptions{CloneMethod: *cloneMethod, TokenType: models.TokenTypePersonal}
+	source := models.ManifestSource{Provider: *provider, Group: *groupID}
+	if *provider != "" {
+		if err := services.ValidateSources([]models.ManifestSource{source}); err != nil || *provider == "urls" {
+			return fmt.Errorf("usage: reposync bench [-p <gitlab|github|bitbucket-server> -g <GROUP>] [--repos N] [--levels 1,2,4,8]")
+		}
+		config, err := readConfig()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if config == nil {
+			config = &models.Config{}
+		}
+		applyEnvConfig(config)
+		credentials, err := sourceCredentials(config, []models.ManifestSource{source}, *cloneMethod)
+		if err != nil {
+			return err
+		}
+		creds := credentials[*provider]
+		helpers.RegisterSecret(creds.Token)
+		helpers.SetCloneUsername(creds.CloneUsername)
+		options.Token, options.BaseURL = creds.Token, creds.BaseURL
+	}
+
+	results, err := services.RunBenchmark(source, options, concurrencies, *count, int64(*sizeMB)<<20)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	return services.PrintBenchmark(os.Stdout, results, *asJSON)
+}
+
+/*
+requireGit checks that git is installed and new enough before anything is synced
+and returns its version.
+*/
+func requireGit() string {
+	version, err := helpers.GitVersion(helpers.ExecGitRunner{})
+	if err != nil {
+		services.Notify(services.LevelError, "%s", err)
+		os.Exit(1)
+	}
+	return version
+}
+
+/*
+supportedCloneArgs returns the clone arguments the installed git supports.
+The others are dropped with a warning, so the sync still runs,
+e.g. with full instead of partial clones.
+*/
+func supportedCloneArgs(version string, cloneArgs []string) []string {
+	supported, dropped := helpers.SupportedCloneArgs(version, cloneArgs)
+	for _, arg := range dropped {
+		services.Notify(services.LevelWarning, "git %s does not support %s, cloning without it", version, arg)
+	}
+	return supported
+}
+
+/*
+handleGenerate implements the generate subcommand.
+Emits deployment files for scheduled syncs; the sync arguments follow "--".
+*/
+func handleGenerate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: reposync generate <k8s-cronjob|systemd> [flags] -- <sync flags>")
+	}
+	switch args[0] {
+	case "k8s-cronjob":
+		return generateCronJob(args[1:])
+	case "systemd":
+		return generateSystemd(args[1:])
+	}
+	return fmt.Errorf("unknown generator %q: use k8s-cronjob or systemd", args[0])
+}
+
+/*
+generateCronJob prints a Kubernetes CronJob and Secret for a scheduled sync.
+Tokens and instance URLs of the current config end up in the Secret, so the
+output can be applied as it is; the workspace lives on a PersistentVolumeClaim.
+*/
+func generateCronJob(args []string) error {
+	flags := flag.NewFlagSet("generate k8s-cronjob", flag.ExitOnError)
+	name := flags.String("name", "reposync", "Name of the CronJob and prefix of the Secret")
+	namespace := flags.String("namespace", "", "Namespace of the resources (default: the current namespace)")
+	schedule := flags.String("schedule", "0 2 * * *", "Cron schedule of the sync")
+	image := flags.String("image", "reposync:latest", "Container image providing the reposync binary")
+	volume := flags.String("pvc", "", "PersistentVolumeClaim holding the workspace (default: <name>-workspace)")
+	flags.Parse(args)
+
+	config, err := readConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if config == nil {
+		config = &models.Config{}
+	}
+	applyEnvConfig(config)
+
+	spec := helpers.CronJobSpec{
+		Name:      *name,
+		Namespace: *namespace,
+		Schedule:  *schedule,
+		Image:     *image,
+		Volume:    *volume,
+		Args:      flags.Args(),
+		Env:       generatorEnv(config),
+	}
+	if spec.Volume == "" {
+		spec.Volume = spec.Name + "-workspace"
+	}
+
+	if len(spec.Env) > 0 {
+		fmt.Fprintln(os.Stderr, "Note: the generated Secret contains your tokens in plain text.")
+	}
+	return helpers.WriteCronJob(os.Stdout, spec)
+}
+
+/*
+generateSystemd writes a systemd service, timer and environment file for scheduled syncs.
+The service runs the current reposync binary in the workspace directory; tokens
+of the current config go into the environment file instead of the unit.
+*/
+func generateSystemd(args []string) error {
+	flags := flag.NewFlagSet("generate systemd", flag.ExitOnError)
+	name := flags.String("name", "reposync", "Name of the unit files")
+	onCalendar := flags.String("on-calendar", "daily", "systemd calendar expression of the sync (e.g. \"*-*-* 02:00:00\")")
+	workspace := flags.String("workspace", ".", "Workspace directory the service syncs")
+	unitUser := flags.String("user", "", "User the service runs as (default: the current user)")
+	binary := flags.String("binary", "", "Path of the reposync binary (default: this executable)")
+	envFile := flags.String("env-file", "", "Location the environment file is installed to (default: /etc/reposync/<name>.env)")
+	outputDir := flags.String("output-dir", ".", "Directory the unit files are written to")
+	flags.Parse(args)
+
+	config, err := readConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if config == nil {
+		config = &models.Config{}
+	}
+	applyEnvConfig(config)
+
+	spec := helpers.SystemdSpec{
+		Name:            *name,
+		User:            *unitUser,
+		OnCalendar:      *onCalendar,
+		EnvironmentFile: *envFile,
+		Args:            flags.Args(),
+		Env:             generatorEnv(config),
+	}
+	if spec.WorkingDirectory, err = helpers.ExpandPath(*workspace); err != nil {
+		return err
+	}
+	if spec.WorkingDirectory, err = filepath.Abs(spec.WorkingDirectory); err != nil {
+		return err
+	}
+	if spec.Binary = *binary; spec.Binary == "" {
+		if spec.Binary, err = os.Executable(); err != nil {
+			return fmt.Errorf("failed to locate the reposync binary, pass --binary: %w", err)
+		}
+	}
+	if spec.User == "" {
+		if current, err := user.Current(); err == nil {
+			spec.User = current.Username
+		}
+	}
+	if spec.EnvironmentFile == "" {
+		spec.EnvironmentFile = "/etc/reposync/" + spec.Name + ".env"
+	}
+
+	paths, err := helpers.WriteSystemdUnits(*outputDir, spec)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Println("Wrote " + path)
+	}
+	fmt.Printf(`
+Install with:
+  sudo install -D -m 600 %[1]s.env %[2]s
+  sudo install -m 644 %[1]s.service %[1]s.timer /etc/systemd/system/
+  sudo systemctl daemon-reload
+  sudo systemctl enable --now %[3]s.timer
+`, filepath.Join(*outputDir, spec.Name), spec.EnvironmentFile, spec.Name)
+	return nil
+}
+
+/*
+generatorEnv returns the REPOSYNC_* variables carrying the tokens and instance URLs of a config.
+*/
+func generatorEnv(config *models.Config) map[string]string {
+	env := map[string]string{}
+	for name, value := range map[string]string{
+		"REPOSYNC_GITLAB_TOKEN": config.GitLabToken,
+		"REPOSYNC_GITHUB_TOKEN": config.GitHubToken,
+		"REPOSYNC_GITLAB_URL":   config.GitLabURL,
+		"REPOSYNC_GITHUB_URL":   config.GitHubURL,
+
+		"REPOSYNC_BITBUCKET_SERVER_TOKEN": config.BitbucketServerToken,
+		"REPOSYNC_BITBUCKET_SERVER_URL":   config.BitbucketServerURL,
+		"REPOSYNC_BITBUCKET_SERVER_USER":  config.BitbucketServerUser,
+	} {
+		if value != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+/*
+explicitFlags returns the names of the flags given on the command line.
+*/
+func explicitFlags() map[string]bool {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+/*
+oneShotArgs removes the flags that keep a sync running (--every, --health-listen)
+from its arguments, so reposync workspaces sync-all can run it once.
+*/
+func oneShotArgs(args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if strings.HasPrefix(args[i], "-") && (name == "every" || name == "health-listen") {
+			if !hasValue {
+				i++ // The value is the next argument
+			}
+			continue
+		}
+		kept = append(kept, args[i])
+	}
+	return kept
+}
+
+/*
+applyWorkspaceConfig fills in flags the user did not pass from the workspace's .reposync/config.
+Takes precedence over the global config file, but not over the command line.
+*/
+func applyWorkspaceConfig(workspace *models.WorkspaceConfig, provider, groupID *string, allProjects *bool, visibility, cloneMethod *string, concurrency *int, groupBy *string, fullPaths *bool, rootName *string) {
+	explicit := explicitFlags()
+
+	if !explicit["p"] && workspace.Provider != "" {
+		*provider = workspace.Provider
+	}
+	if !explicit["g"] && workspace.Group != "" {
+		*groupID = workspace.Group
+	}
+	if !explicit["all-projects"] && workspace.AllProjects {
+		*allProjects = true
+	}
+	if !explicit["visibility"] && len(workspace.Visibility) > 0 {
+		*visibility = strings.Join(workspace.Visibility, ",")
+	}
+	if !explicit["m"] && workspace.CloneMethod != "" {
+		*cloneMethod = workspace.CloneMethod
+	}
+	if !explicit["j"] && workspace.Concurrency > 0 {
+		*concurrency = workspace.Concurrency
+	}
+	if !explicit["group-by"] && workspace.GroupBy != "" {
+		*groupBy = workspace.GroupBy
+	}
+	if !explicit["full-paths"] && workspace.FullPaths {
+		*fullPaths = true
+	}
+	if !explicit["root-name"] && workspace.RootName != "" {
+		*rootName = workspace.RootName
+	}
+}
+
+// envFlagNames names the environment variables of flags whose own name is not descriptive.
+// An empty name means the flag has no variable (-d is covered by REPOSYNC_DEST).
+var envFlagNames = map[string]string{
+	"p": "PROVIDER",
+	"g": "GROUP",
+	"m": "CLONE_METHOD",
+	"j": "CONCURRENCY",
+	"d": "",
+	"h": "",
+}
+
+/*
+applyEnvFlags sets the flags not given on the command line from REPOSYNC_* environment variables.
+The variable of a flag is its name in upper case with dashes replaced by underscores
+(REPOSYNC_CLONE_TIMEOUT for --clone-timeout), see envFlagNames for the short flags.
+Lets containers be configured entirely through their environment;
+values from the environment take precedence over the config files.
+*/
+func applyEnvFlags() error {
+	explicit := explicitFlags()
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || (f.Name == "dest" && explicit["d"]) {
+			return
+		}
+		name, ok := envFlagNames[f.Name]
+		if !ok {
+			name = strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		}
+		if name == "" {
+			return
+		}
+		if value, set := os.LookupEnv("REPOSYNC_" + name); set {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid REPOSYNC_%s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+/*
+applyEnvConfig overrides tokens and instance URLs of the config file with
+REPOSYNC_GITLAB_TOKEN, REPOSYNC_GITHUB_TOKEN, REPOSYNC_GITLAB_URL and REPOSYNC_GITHUB_URL
+(and the REPOSYNC_BITBUCKET_SERVER_TOKEN, _URL and _USER of Bitbucket Server),
+so containers can receive them as secrets without a config file.
+*/
+func applyEnvConfig(config *models.Config) {
+	for name, field := range map[string]*string{
+		"REPOSYNC_GITLAB_TOKEN": &config.GitLabToken,
+		"REPOSYNC_GITHUB_TOKEN": &config.GitHubToken,
+		"REPOSYNC_GITLAB_URL":   &config.GitLabURL,
+		"REPOSYNC_GITHUB_URL":   &config.GitHubURL,
+
+		"REPOSYNC_BITBUCKET_SERVER_TOKEN": &config.BitbucketServerToken,
+		"REPOSYNC_BITBUCKET_SERVER_URL":   &config.BitbucketServerURL,
+		"REPOSYNC_BITBUCKET_SERVER_USER":  &config.BitbucketServerUser,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*field = value
+		}
+	}
+}
+
+// Exit codes of a sync, so schedulers can tell partial from complete failures.
+const (
+	exitOK          = 0
+	exitFailure     = 1 // Invalid usage, configuration, or the sync could not run
+	exitPartialSync = 2 // The sync ran but some repositories failed
+)
+
+// adaptiveMaxConcurrency bounds the worker pool of --adaptive when -j is not given.
+const adaptiveMaxConcurrency = 16
+
+/*
+reportSyncResult prints the outcome of a sync and returns the matching exit code.
+Failures are written to out, success is reported like the messages of the sync.
+*/
+func reportSyncResult(out io.Writer, err error) int {
+	switch {
+	case err == nil:
+		services.Notify(services.LevelSuccess, "Repository synchronization completed successfully!")
+		return exitOK
+	case errors.Is(err, services.ErrPartialSync):
+		fmt.Fprintf(out, colors.Yellow+"Repository synchronization completed with failures: %s\n"+colors.Reset, helpers.Redact(err.Error()))
+		return exitPartialSync
+	}
+	fmt.Fprintf(out, colors.Red+"Repository synchronization failed: %s\n"+colors.Reset, helpers.Redact(err.Error()))
+	return exitFailure
+}
+
+/*
+configureOutput sets up the output format of syncs (--output) and returns
+where failures are reported. JSON events take stdout over, so failures
+go to stderr; quiet mode renders nothing but the failures. Text output
+in CI mode (--ci) is printed as timestamped CI log lines.
+It runs before the checks that precede a sync, so their warnings follow the format too.
+*/
+func configureOutput(output string, ci bool) io.Writer {
+	switch {
+	case output == "json":
+		services.SetRenderer(services.NewJSONRenderer(os.Stdout))
+		return os.Stderr
+	case output == "quiet":
+		services.SetRenderer(services.NewQuietRenderer(os.Stdout))
+	case ci:
+		services.SetRenderer(services.NewCIRenderer(os.Stdout))
+	}
+	return os.Stdout
+}
+
+/*
+runSync runs a sync and exits with its exit code.
+With an interval (--every) it keeps running instead and repeats the sync,
+serving the latest result on healthAddr (/healthz) when one is given;
+this serve mode suits a long-running container instead of a scheduled job.
+Failures are reported to the writer configureOutput returned.
+*/
+func runSync(sync func() error, every time.Duration, healthAddr string, failures io.Writer) {
+	services.Notify(services.LevelNotice, "Starting repository cloning process...")
+	if every <= 0 {
+		os.Exit(reportSyncResult(failures, sync()))
+	}
+
+	status := &helpers.HealthStatus{}
+	if healthAddr != "" {
+		helpers.ServeHealth(healthAddr, status)
+	}
+	for {
+		err := sync()
+		reportSyncResult(failures, err)
+		status.Record(time.Now(), err)
+		services.Notify(services.LevelInfo, "Next sync in %s", every)
+		time.Sleep(every)
+	}
+}
+
+/*
+preflight prepares the sync of one provider: it waits for an unavailable instance
+with --wait-for-provider, then checks that the instance can be reached with the token
+and prints its version, so a broken setup fails before anything is synced.
+*/
+func preflight(provider string, options models.SyncOptions, wait time.Duration) error {
+	if wait > 0 {
+		if err := services.WaitForProvider(provider, options.BaseURL, wait); err != nil {
+			return err
+		}
+	}
+	instance, err := services.CheckProvider(provider, options)
+	if err != nil {
+		return err
+	}
+	if instance != "" {
+		services.Notify(services.LevelNotice, "Connected to %s", instance)
+	}
+	return nil
+}
+
+/*
+manifestSources returns the sources declared in the manifest of a workspace.
+A missing or unreadable manifest has none; errors are reported when it is loaded for the sync.
+*/
+func manifestSources(dest string) []models.ManifestSource {
+	workspace, err := helpers.ExpandPath(dest)
+	if err != nil {
+		return nil
+	}
+	manifest, err := helpers.LoadManifest(workspace)
+	if err != nil {
+		return nil
+	}
+	return manifest.Sources
+}
+
+/*
+sourceCredentials determines the token, instance URL and clone username of every
+provider used by the sources of a workspace manifest, and applies their request budgets.
+*/
+func sourceCredentials(config *models.Config, sources []models.ManifestSource, cloneMethod string) (map[string]models.SourceCredentials, error) {
+	credentials := map[string]models.SourceCredentials{}
+	for _, source := range sources {
+		if _, done := credentials[source.Provider]; done {
+			continue
+		}
+		if source.Provider == "urls" || source.Provider == "repo-manifest" || source.Provider == "gitmodules" {
+			continue // Cloned without a token
+		}
+
+		var creds models.SourceCredentials
+		var apiURL string
+		switch source.Provider {
+		case "gitlab":
+			creds = models.SourceCredentials{Token: config.GitLabToken, BaseURL: config.GitLabURL, CloneUsername: "oauth2"}
+			apiURL = helpers.GetGitLabAPIURL(creds.BaseURL, "")
+		case "github":
+			token, baseURL, actions := gitHubCredentials(config)
+			creds = models.SourceCredentials{Token: token, BaseURL: baseURL, CloneUsername: "oauth2"}
+			if actions {
+				creds.CloneUsername = "x-access-token"
+			}
+			apiURL = helpers.GetGitHubAPIURL(creds.BaseURL, "")
+		case "bitbucket-server":
+			creds = models.SourceCredentials{Token: config.BitbucketServerToken, BaseURL: config.BitbucketServerURL, CloneUsername: config.BitbucketServerUser}
+			if creds.BaseURL == "" {
+				return nil, fmt.Errorf("bitbucket-server sources require bitbucket_server_url in the config file or REPOSYNC_BITBUCKET_SERVER_URL")
+			}
+			if creds.CloneUsername == "" && cloneMethod == "https" {
+				return nil, fmt.Errorf("HTTPS clones of bitbucket-server sources require bitbucket_server_user in the config file or REPOSYNC_BITBUCKET_SERVER_USER")
+			}
+			apiURL = helpers.GetBitbucketServerAPIURL(creds.BaseURL, "")
+		default:
+			continue // Reported when the sources are validated
+		}
+		if err := helpers.ValidateToken(creds.Token); err != nil {
+			return nil, fmt.Errorf("invalid token for provider %s: %w", source.Provider, err)
+		}
+
+		if rps, ok := config.RequestsPerSecond[source.Provider]; ok {
+			client.SetRateLimit(apiURL, rps, config.MaxConcurrentRequests)
+		} else if config.MaxConcurrentRequests > 0 {
+			client.SetRateLimit(apiURL, client.DefaultRequestsPerSecond, config.MaxConcurrentRequests)
+		}
+		credentials[source.Provider] = creds
+	}
+	return credentials, nil
+}
+
+/*
+runDiff prints how the provider's repositories changed since a state snapshot.
+Progress messages are rendered to stderr, so the report on stdout can be piped or redirected.
+*/
+func runDiff(provider, groupID string, allProjects bool, options models.SyncOptions, since string, asJSON bool) error {
+	if since == "" {
+		since = helpers.GetStatePath(options.BaseDir)
+	}
+
+	services.SetRenderer(services.NewTextRenderer(os.Stderr))
+
+	var report *models.DeltaReport
+	var err error
+	switch {
+	case allProjects:
+		report, err = services.DiffAllGitLabProjects(options, since)
+	case provider == "gitlab":
+		var id int
+		if id, err = services.ResolveGitLabGroup(groupID, options); err == nil {
+			report, err = services.DiffGitLabGroup(id, options, since)
+		}
+	case provider == "bitbucket-server":
+		report, err = services.DiffBitbucketServerProject(groupID, options, since)
+	default:
+		report, err = services.DiffGitHubOrganization(groupID, options, since)
+	}
+	if err != nil {
+		return err
+	}
+	return services.PrintDeltaReport(os.Stdout, report, asJSON)
+}
+
+/*
+gitLabCredentials determines the GitLab token, instance URL and token type of a run.
+The type comes from --gitlab-token-type or the config file; inside a GitLab CI job
+without a configured token, the job's CI_JOB_TOKEN is used automatically.
+Job tokens are read from CI_JOB_TOKEN and default to the pipeline's instance (CI_SERVER_URL).
+*/
+func gitLabCredentials(config *models.Config, tokenType string) (token, baseURL, resolvedType string, err error) {
+	token, baseURL = config.GitLabToken, config.GitLabURL
+	if tokenType == "" {
+		tokenType = config.GitLabTokenType
+	}
+	if tokenType == "" && token == "" && os.Getenv("GITLAB_CI") == "true" && os.Getenv("CI_JOB_TOKEN") != "" {
+		tokenType = "job"
+	}
+
+	switch tokenType {
+	case "", "personal":
+		return token, baseURL, models.TokenTypePersonal, nil
+	case "job":
+		if jobToken := os.Getenv("CI_JOB_TOKEN"); jobToken != "" {
+			token = jobToken
+		}
+		if baseURL == "" {
+			baseURL = os.Getenv("CI_SERVER_URL")
+		}
+		return token, baseURL, models.TokenTypeJob, nil
+	case "deploy":
+		return token, baseURL, models.TokenTypeDeploy, nil
+	}
+	return "", "", "", fmt.Errorf("invalid GitLab token type %q: use personal, job or deploy", tokenType)
+}
+
+/*
+gitHubCredentials determines the GitHub token and instance URL of a run.
+Inside a GitHub Actions workflow without a configured GitHub token, the
+workflow's GITHUB_TOKEN and server (GITHUB_SERVER_URL) are used; actions
+reports whether the run authenticates with the workflow token.
+*/
+func gitHubCredentials(config *models.Config) (token, baseURL string, actions bool) {
+	token, baseURL = config.GitHubToken, config.GitHubURL
+	workflowToken := os.Getenv("GITHUB_TOKEN")
+	if os.Getenv("GITHUB_ACTIONS") != "true" || workflowToken == "" {
+		return token, baseURL, false
+	}
+	if token == "" {
+		token = workflowToken
+		if baseURL == "" {
+			baseURL = os.Getenv("GITHUB_SERVER_URL")
+		}
+	}
+	return token, baseURL, token == workflowToken
+}
+
+/*
+warnGitHubActionsToken explains that the GITHUB_TOKEN of a workflow
+only grants access to the workflow's own repository.
+*/
+func warnGitHubActionsToken() {
+	services.Notify(services.LevelWarning, "Using the GITHUB_TOKEN of this workflow: it can only read the workflow's repository and public repositories.")
+	services.Notify(services.LevelWarning, "Private and internal repositories of the organization are skipped; use a personal access token or GitHub App token for org-wide syncs.")
+}
+
+/*
+applyConfigDefaults fills in flags the user did not pass with values from the config file.
+Precedence: command-line flags, then the config file, then the built-in defaults.
+A nil dest leaves the destination alone (the workspace was found in place).
+*/
+func applyConfigDefaults(config *models.Config, cloneMethod *string, concurrency *int, groupBy, skipTopic *string, dest *string) {
+	explicit := explicitFlags()
+
+	if !explicit["m"] && config.CloneMethod != "" {
+		*cloneMethod = config.CloneMethod
+	}
+	if !explicit["j"] && config.Concurrency > 0 {
+		*concurrency = config.Concurrency
+	}
+	if !explicit["group-by"] && config.GroupBy != "" {
+		*groupBy = config.GroupBy
+	}
+	if !explicit["skip-topic"] && config.SkipTopic != "" {
+		*skipTopic = config.SkipTopic
+	}
+	if dest != nil && !explicit["d"] && !explicit["dest"] && config.Destination != "" {
+		*dest = config.Destination
+	}
+	if config.MaxRetries > 0 {
+		helpers.SetCloneAttempts(config.MaxRetries)
+	}
+}
+
+/*
+runMockSync syncs the built-in mock provider into the workspace directory.
+Serves a generated fixture from local bare repositories, so the complete
+sync flow can be tried out and developed on without network access or tokens.
+*/
+func runMockSync(options models.SyncOptions) error {
+	services.Notify(services.LevelNotice, "Starting mock provider...")
+	server, err := mock.StartDefault()
+	if err != nil {
+		return fmt.Errorf("failed to start mock provider: %w", err)
+	}
+	defer server.Close()
+
+	options.Token = "mock-token-not-secret"
+	options.BaseURL = server.URL()
+	return services.CloneGitLabRepositoriesWithOptions(mock.DefaultFixture().Groups[0].ID, options)
+}
+
+// subcommand runs a reposync subcommand; failure prefixes the errors it returns.
+type subcommand struct {
+	run     func(args []string) error
+	failure string
+}
+
+// subcommands maps the first argument to the subcommands that are not syncs.
+var subcommands = map[string]subcommand{
+	"config":      {handleConfig, "Failed to configure tokens"},
+	"stats":       {handleStats, "Failed to compute statistics"},
+	"generate":    {handleGenerate, "Failed to generate"},
+	"restore":     {handleRestore, "Failed to restore"},
+	"view":        {handleView, "Failed to create view"},
+	"bench":       {handleBench, "Failed to run benchmark"},
+	"version":     {func(args []string) error { handleVersion(args); return nil }, ""},
+	"self-update": {handleSelfUpdate, "Failed to update"},
+	"doctor":      {handleDoctor, "Failed to run diagnostics"},
+	"meta":        {handleMeta, "Failed to update meta repository"},
+	"export":      {handleExport, "Failed to export"},
+	"state":       {handleState, "Failed to inspect state"},
+	"repair":      {handleRepair, "Failed to repair"},
+	"lock":        {handleLock, "Failed to lock"},
+	"workspaces":  {handleWorkspaces, "Failed to manage workspaces"},
+}
+
+/*
+main coordinates command execution flow and argument parsing.
+Runs one of the subcommands, or otherwise syncs (reposync -p ...) or diffs
+(reposync diff -p ...) a workspace.
+Validates inputs and initiates appropriate synchronization workflow.
+*/
+func main() {
+	// Retries of instances in maintenance are reported as warnings, in the output format of syncs
+	client.SetRetryHandler(func(message string) { services.Notify(services.LevelWarning, "%s", message) })
+
+	if len(os.Args) >= 2 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			colors.Configure("auto", os.Stdout)
+			err := command.run(os.Args[2:])
+			switch {
+			case errors.Is(err, services.ErrPartialRestore):
+				fmt.Printf(colors.Yellow+"Restore completed with failures: %s\n"+colors.Reset, helpers.Redact(err.Error()))
+				os.Exit(exitPartialSync)
+			case errors.Is(err, services.ErrWorkspacesFailed):
+				fmt.Printf(colors.Yellow+"%s\n"+colors.Reset, err)
+				os.Exit(exitPartialSync)
+			case err != nil:
+				log.Fatal(colors.Red + command.failure + ": " + helpers.Redact(err.Error()) + colors.Reset)
+			}
+			os.Exit(0)
+		}
+	}
+
+	provider := flag.String("p", "", "Provider: gitlab, github, bitbucket-server or mock (local fixture for development)")
+	groupID := flag.String("g", "", "Group ID, organization name or Bitbucket Server project key")
+	cloneMethod := flag.String("m", "https", "Clone method: https or ssh")
+	allProjects := flag.Bool("all-projects", false, "GitLab only: clone every project on the instance (requires an admin token)")
+	concurrency := flag.Int("j", 1, "Number of repositories to sync in parallel")
+	adaptive := flag.Bool("adaptive", false, "Adjust the number of parallel syncs to failures and rate-limit headroom, up to -j (default 16)")
+	maxFailures := flag.String("max-failures", "", "Abort the run once this many repositories failed, as a count (10) or a percentage (5%)")
+	order := flag.String("order", "", "Order repositories are synced in: size-asc, size-desc, name or activity (default: as listed)")
+	debugHTTP := flag.Bool("debug-http", false, "Log every API request (tokens redacted) to stderr")
+	auditLog := flag.String("audit-log", "", "Append-only JSONL log of filesystem changes (default: .reposync/audit.jsonl)")
+	noWrite := flag.Bool("no-write", false, "Show what a sync would change without modifying anything")
+	indexPath := flag.String("index", "", "Write a catalog of the synced repositories to this file (e.g. INDEX.md)")
+	indexTemplate := flag.String("index-template", "", "Go template for --index (.html templates are HTML-escaped)")
+	reportStale := flag.String("report-stale", "", "Report repositories without upstream activity for this long (e.g. 180d)")
+	ownershipReport := flag.String("ownership-report", "", "Write an org-wide JSON report of CODEOWNERS and approval rules to this file")
+	scanReport := flag.String("scan-report", "", "Scan every clone for secrets (gitleaks by default) and write the findings to this file")
+	harvest := flag.String("harvest", "", "Collect data from every clone after syncing: deps (dependency manifests)")
+	exportCommits := flag.String("export-commits", "", "Export the commits every repository received since the last sync to this NDJSON file")
+	withSettings := flag.Bool("with-settings", false, "Snapshot repository settings (branch protection, merge settings, webhooks, members) and org teams into .reposync")
+	withCIConfig := flag.Bool("with-ci-config", false, "GitLab only: snapshot CI/CD variables, pipeline schedules and runners into .reposync/ci")
+	includeVariableValues := flag.Bool("include-variable-values", false, "With --with-ci-config, store CI/CD variable values instead of masking them")
+	harvestOutput := flag.String("harvest-output", "dependencies.json", "File the --harvest inventory is written to")
+	visibility := flag.String("visibility", "", "Only sync repositories with these visibilities (comma-separated: public,internal,private)")
+	locked := flag.Bool("locked", false, "Check out every repository at the commit recorded in reposync.lock, skipping repositories it doesn't list")
+	remapFile := flag.String("remap", "", "File overriding where repositories are cloned (default: .reposync/remap.json)")
+	search := flag.String("search", "", "Only sync repositories found by the provider's search for this query (GitHub search syntax on GitHub)")
+	codeSearch := flag.String("code-search", "", "GitHub only: only sync repositories with files found by the code search for this query")
+	withDependency := flag.String("with-dependency", "", "Only sync repositories declaring this dependency in the --harvest-output inventory")
+	topics := flag.String("topic", "", "Only sync repositories with all of these topics (comma-separated; filtered by the server on GitLab)")
+	var properties stringListFlag
+	flag.Var(&properties, "property", "GitHub only: only sync repositories whose custom property has this value, as name=value (repeatable)")
+	language := flag.String("language", "", "Only sync repositories in this programming language (filtered by the server on GitLab)")
+	activeSince := flag.String("active-since", "", "Only sync repositories with upstream activity within this period (e.g. 90d)")
+	skipTopic := flag.String("skip-topic", "reposync-skip", "Skip repositories with this topic, so their owners can opt out of mirroring (empty: none)")
+	includeInactive := flag.Bool("include-inactive", false, "Also sync archived repositories and projects pending deletion (skipped by default)")
+	groupBy := flag.String("group-by", "", "Organize clones into subdirectories by topic, language or visibility instead of the provider hierarchy")
+	skipEmptyGroups := flag.Bool("skip-empty-groups", false, "GitLab only: create no directories for groups without repositories and remove those left empty")
+	maxDepth := flag.Int("max-depth", 0, "GitLab only: number of group levels to sync, 1 being the synced group alone (default: all)")
+	fullPaths := flag.Bool("full-paths", false, "GitLab only: name directories by the full namespace path, including the parent groups of the synced group")
+	rootName := flag.String("root-name", "", "GitHub and Bitbucket Server: name of the root directory (default: the organization or project key)")
+	var dest string
+	flag.StringVar(&dest, "d", ".", "Workspace directory the repositories are synced into")
+	flag.StringVar(&dest, "dest", ".", "Workspace directory the repositories are synced into")
+	var gitArgs stringListFlag
+	flag.Var(&gitArgs, "git-arg", "Extra argument appended to git clone (repeatable)")
+	shareObjects := flag.Bool("share-objects", false, "Clone repositories already cloned elsewhere on this machine with the objects of that clone")
+	shallowSince := flag.String("shallow-since", "", "Clone only the history after this date (YYYY-MM-DD) or within this period (e.g. 365d)")
+	waitForProvider := flag.Duration("wait-for-provider", 0, "Wait up to this long for an unavailable instance (e.g. in maintenance) before syncing (e.g. 1h)")
+	cloneTimeout := flag.Duration("clone-timeout", 0, "Kill git commands running longer than this (e.g. 10m, default: no limit)")
+	acceptNewHostKeys := flag.Bool("accept-new-hostkeys", false, "Pin the SSH host keys of new hosts in .reposync/known_hosts instead of prompting")
+	diagnostics := flag.Bool("diagnostics", false, "Record anonymized error categories and timings in .reposync/diagnostics.jsonl (see reposync doctor)")
+	allowGitPrompts := flag.Bool("allow-git-prompts", false, "Let git prompt for credentials (disabled so unattended syncs fail fast)")
+	pruneRefs := flag.Bool("prune-refs", false, "Fetch existing clones with --prune --prune-tags, deleting branches and tags deleted upstream")
+	trackAllBranches := flag.Bool("track-all-branches", false, "Create a local tracking branch for every remote branch, e.g. to browse the history of backup mirrors")
+	showGitOutput := flag.Bool("show-git-output", false, "Stream git's output to the console (always logged to .reposync/logs/)")
+	gitlabTokenType := flag.String("gitlab-token-type", "", "Kind of GitLab token: personal (default), job (CI_JOB_TOKEN) or deploy")
+	gitlabDeployUser := flag.String("gitlab-deploy-user", "", "Username of the GitLab deploy token (with --gitlab-token-type deploy)")
+	container := flag.Bool("container", false, "Container mode: plain timestamped output for log collectors, no colors")
+	every := flag.Duration("every", 0, "Keep running and repeat the sync at this interval (e.g. 24h)")
+	healthListen := flag.String("health-listen", "", "With --every, serve the latest sync result on this address (e.g. :8080, path /healthz)")
+	ciMode := flag.Bool("ci", false, "Print timestamped progress lines in collapsible sections (GitHub Actions, GitLab CI)")
+	output := flag.String("output", "text", "Output of syncs: text, json (one event per line on stdout) or quiet (failures only)")
+	colorMode := flag.String("color", "auto", "Colored output: auto (only on a terminal without NO_COLOR), always or never")
+	since := flag.String("since", "", "diff only: state snapshot to compare with (default: the workspace's .reposync/state.json)")
+	asJSON := flag.Bool("json", false, "diff only: print the report as JSON")
+	help := flag.Bool("h", false, "Show help message")
+
+	// "reposync sync" is the explicit form of the default sync mode,
+	// "reposync diff" takes the same flags but only compares
+	args := os.Args[1:]
+	diffMode := len(args) > 0 && args[0] == "diff"
+	if len(args) > 0 && (args[0] == "sync" || diffMode) {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	if err := applyEnvFlags(); err != nil {
+		fmt.Println(err)
+		os.Exit(exitFailure)
+	}
+	if *container {
+		if !explicitFlags()["color"] {
+			*colorMode = "never"
+		}
+		*ciMode = true
+	}
+
+	if err := colors.Configure(*colorMode, os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// A workspace config makes "cd workspace && reposync sync" work without flags
+	workspaceConfig, err := helpers.LoadWorkspaceConfig(dest)
+	if err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *help || (flag.NFlag() == 0 && workspaceConfig == nil && len(manifestSources(dest)) == 0) {
+		fmt.Println(`reposync - Sync repositories from GitHub or GitLab
+
+Usage:
+  reposync config               Configure personal access tokens
+  reposync config [--gitlab-token-file F|--gitlab-token-stdin] [--github-token-file F|--github-token-stdin]
+                  [--bitbucket-server-token-file F|--bitbucket-server-token-stdin]
+                                Configure tokens non-interactively
+  reposync stats [--json] [--stale 180d] [DIR]
+                                Show statistics about a synced workspace
+  reposync generate k8s-cronjob [--schedule S] [--image I] [--namespace N] -- <sync flags>
+                                Print a Kubernetes CronJob and Secret for scheduled syncs
+  reposync generate systemd [--on-calendar daily] [--workspace DIR] -- <sync flags>
+                                Write a systemd service, timer and token environment file
+  reposync [sync] -p <gitlab|github> -g <GROUP_ID> [-m <https|ssh>]
+  reposync [sync] -p bitbucket-server -g <PROJECT_KEY> [-m <https|ssh>]
+  reposync sync                 Sync the workspace described by .reposync/config,
+                                or all sources of its .reposync/manifest.json
+  reposync diff [--since STATE] [--json] -p <gitlab|github> -g <GROUP_ID>
+                                Report new, deleted and updated repositories since a state snapshot
+  reposync restore [--dry-run] -p <gitlab|github> -g <GROUP|ORG> [BACKUP_DIR]
+                                Push a backup set to a group or organization and recreate its settings
+  reposync view create [-d DIR] [--filter KEY=VALUE]... [--flat] VIEW_DIR
+                                Link the matching clones of a workspace into a view directory
+  reposync meta init [-m MESSAGE] [DIR]
+                                Commit every clone of a workspace as a submodule pinned at its current commit
+  reposync export --format <repo-manifest|gitman> [-o FILE] [DIR]
+  reposync export --format bundle -o OUTDIR [--anonymize-emails strip|hash] [DIR]
+                                Write the clones of a workspace as a repo tool manifest or gitman.yml,
+                                or as git bundles, optionally without author email addresses
+  reposync state list [--json] [-d DIR]
+  reposync state show|rm [-d DIR] REPO
+                                Inspect the workspace state or remove a repository from it
+  reposync repair [--no-write] [DIR]
+                                Re-clone broken clones and fix wrong remote URLs
+  reposync lock [-o FILE] [DIR]
+                                Record the commit of every clone in reposync.lock
+  reposync lock diff [-d DIR] [--json] OLD_LOCK NEW_LOCK
+                                List the repositories that changed between two lockfiles
+  reposync workspaces list [--json]
+  reposync workspaces sync-all  List the workspaces synced on this machine, or sync all of them again
+  reposync bench [-p <gitlab|github|bitbucket-server> -g <GROUP>] [--repos 20] [--levels 1,2,4,8] [--json]
+                                Measure clone throughput at several concurrency levels
+  reposync doctor [--bundle FILE] [DIR]
+                                Check the environment; --bundle writes a report for bug reports
+  reposync version [--short]    Show the version, commit and build date
+  reposync self-update [--check]
+                                Replace the binary with the latest release (checksum verified)
+  reposync -p gitlab --all-projects [-m <https|ssh>]
+
+Flags:
+  -p  Provider: gitlab, github or bitbucket-server (mock serves local fixtures for development)
+  -g  Group ID or subgroup path (parent/child), organization name or Bitbucket Server project key
+  -m  Clone method: https or ssh (default: https)
+  -j  Number of repositories to sync in parallel (default: 1)
+  --adaptive      Adjust the parallel syncs to failures and rate-limit headroom, up to -j (default 16)
+  -d  Workspace directory, also --dest (default: current directory)
+  -h  Show help message
+
+  --all-projects  GitLab only: clone every project on the instance (admin token)
+  --debug-http    Log every API request (tokens redacted) to stderr
+  --audit-log     Path of the JSONL audit log (default: .reposync/audit.jsonl)
+  --color         Colored output: auto, always or never (default: auto)
+  --gitlab-token-type  Kind of GitLab token: personal, job (CI_JOB_TOKEN) or deploy
+  --gitlab-deploy-user  Username of the GitLab deploy token
+  --ci            Timestamped progress lines in collapsible CI log sections
+  --output        Output of syncs: text, json (JSON lines on stdout) or quiet (failures only)
+  --container     Container mode: plain timestamped output, no colors
+  --every         Keep running and repeat the sync at this interval (e.g. 24h)
+  --health-listen With --every, serve the latest result on this address (/healthz)
+  --wait-for-provider  Wait up to this long for an unavailable instance before syncing (e.g. 1h)
+  --clone-timeout Kill git commands running longer than this (e.g. 10m)
+  --allow-git-prompts  Let git prompt for credentials (disabled by default)
+  --accept-new-hostkeys  Pin the SSH host keys of new hosts in .reposync/known_hosts
+  --diagnostics   Record anonymized error categories and timings for reposync doctor --bundle
+  --prune-refs    Fetch existing clones, deleting branches and tags deleted upstream
+  --track-all-branches  Create a local tracking branch for every remote branch
+  --show-git-output  Stream git's output to the console (always logged to .reposync/logs/)
+  --no-write      Show what a sync would change without modifying anything
+  --git-arg       Extra argument appended to git clone (repeatable)
+  --share-objects  Clone repositories already cloned in another workspace with that clone's objects
+  --shallow-since  Clone only the history after this date (YYYY-MM-DD) or within this period (e.g. 365d)
+  --index         Write a catalog of the synced repositories to this file (e.g. INDEX.md)
+  --index-template  Go template used for --index instead of the Markdown table
+  --report-stale  Report repositories without upstream activity for this long (e.g. 180d)
+  --ownership-report  Write an org-wide JSON report of CODEOWNERS and approval rules
+  --scan-report   Scan every clone for secrets and write the findings to this file
+  --harvest deps  Collect dependency manifests of every clone into one inventory
+  --harvest-output  File the --harvest inventory is written to (default: dependencies.json)
+  --export-commits  Export the commits received since the last sync to this NDJSON file
+  --with-settings Snapshot repository settings, members and teams into .reposync/settings
+  --with-ci-config  GitLab only: snapshot CI/CD variables, pipeline schedules and runners
+  --include-variable-values  Store CI/CD variable values in the snapshot instead of masking them
+  --visibility    Only sync repositories with these visibilities (public,internal,private)
+  --search        Only sync repositories found by the provider's search for this query
+  --code-search   GitHub only: only sync repositories with files found by the code search
+  --with-dependency  Only sync repositories declaring this dependency in the --harvest-output inventory
+  --topic         Only sync repositories with all of these topics (comma-separated)
+  --property      GitHub only: only sync repositories with this custom property value, as name=value (repeatable)
+  --language      Only sync repositories in this programming language
+  --active-since  Only sync repositories with upstream activity within this period (e.g. 90d)
+  --group-by      Organize clones into subdirectories by topic, language or visibility
+  --skip-empty-groups  GitLab only: create no directories for groups without repositories
+  --max-depth     GitLab only: number of group levels to sync, 1 being the synced group alone
+  --full-paths    GitLab only: name directories by the full namespace path of the projects
+  --root-name     GitHub and Bitbucket Server: name of the root directory (default: organization)
+  --skip-topic    Skip repositories with this topic, so owners can opt out (default: reposync-skip)
+  --include-inactive  Also sync archived repositories and projects pending deletion
+  --remap         File overriding where repositories are cloned (default: .reposync/remap.json)
+  --locked        Check out every repository at its commit in reposync.lock, skipping unlisted ones
+  --since         diff only: state snapshot to compare with (default: .reposync/state.json)
+  --json          diff only: print the report as JSON
+
+Every flag can also be set through a REPOSYNC_* environment variable, e.g.
+REPOSYNC_CLONE_TIMEOUT=10m; -p, -g, -m and -j use REPOSYNC_PROVIDER,
+REPOSYNC_GROUP, REPOSYNC_CLONE_METHOD and REPOSYNC_CONCURRENCY. Tokens can be
+passed as REPOSYNC_GITLAB_TOKEN, REPOSYNC_GITHUB_TOKEN and REPOSYNC_BITBUCKET_SERVER_TOKEN.
+
+Exit codes: 0 all repositories synced, 1 error, 2 some repositories failed`)
+		os.Exit(0)
+	}
+
+	// Settings from the config files are defaults, flags given on the command line win.
+	// The workspace config overrides the global one; a workspace found in the
+	// current directory also takes precedence over the configured destination.
+	config, configErr := readConfig()
+	if configErr != nil {
+		if !os.IsNotExist(configErr) {
+			fmt.Println(colors.Red + "Failed to read configuration: " + configErr.Error() + colors.Reset)
+			os.Exit(1)
+		}
+		config = &models.Config{}
+	}
+	applyEnvConfig(config)
+	if err := helpers.ValidateSSHOverrides(config.SSHHosts); err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+	for _, pattern := range config.Priority {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fmt.Printf(colors.Red+"Invalid priority pattern %q in the config file: %v\n"+colors.Reset, pattern, err)
+			os.Exit(1)
+		}
+	}
+	if _, err := helpers.CompileURLRewrites(config.URLRewrites); err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+	defaultDest := &dest
+	if workspaceConfig != nil {
+		defaultDest = nil
+	}
+	applyConfigDefaults(config, cloneMethod, concurrency, groupBy, skipTopic, defaultDest)
+	if workspaceConfig == nil && dest != "." {
+		if workspaceConfig, err = helpers.LoadWorkspaceConfig(dest); err != nil {
+			fmt.Println(colors.Red + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+	}
+	if workspaceConfig != nil {
+		applyWorkspaceConfig(workspaceConfig, provider, groupID, allProjects, visibility, cloneMethod, concurrency, groupBy, fullPaths, rootName)
+	}
+
+	// Without a provider, the sources of the workspace manifest are synced together
+	multiSource := *provider == "" && !*allProjects && len(manifestSources(dest)) > 0
+
+	// Validate provider
+	if !multiSource && *provider != "gitlab" && *provider != "github" && *provider != "bitbucket-server" && *provider != "mock" {
+		fmt.Println(colors.Red + "Unsupported provider. Use 'gitlab', 'github' or 'bitbucket-server'." + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *fullPaths && !multiSource && *provider != "gitlab" {
+		fmt.Println(colors.Red + "--full-paths is only supported for the gitlab provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if *skipEmptyGroups && !multiSource && *provider != "gitlab" {
+		fmt.Println(colors.Red + "--skip-empty-groups is only supported for the gitlab provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if *maxDepth < 0 || (*maxDepth > 0 && !multiSource && *provider != "gitlab") {
+		fmt.Println(colors.Red + "--max-depth takes a positive number of group levels and is only supported for the gitlab provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if *rootName != "" {
+		if *provider != "github" && *provider != "bitbucket-server" {
+			fmt.Println(colors.Red + "--root-name is only supported for the github and bitbucket-server providers." + colors.Reset)
+			os.Exit(1)
+		}
+		if err := helpers.ValidateRootName(*rootName); err != nil {
+			fmt.Printf(colors.Red+"Invalid --root-name: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate group ID/organization name
+	if *allProjects {
+		if *provider != "gitlab" {
+			fmt.Println(colors.Red + "--all-projects is only supported for the gitlab provider." + colors.Reset)
+			os.Exit(1)
+		}
+	} else if *provider == "gitlab" {
+		if err := helpers.ValidateGroupID(*groupID); err != nil {
+			fmt.Printf(colors.Red+"Invalid group ID: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+	} else if *provider == "github" {
+		if err := helpers.ValidateOrganizationName(*groupID); err != nil {
+			fmt.Printf(colors.Red+"Invalid organization name: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+	} else if *provider == "bitbucket-server" {
+		if err := helpers.ValidateProjectKey(*groupID); err != nil {
+			fmt.Printf(colors.Red+"Invalid project key: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate clone method
+	if *cloneMethod != "https" && *cloneMethod != "ssh" {
+		fmt.Println(colors.Red + "Invalid clone method. Use 'https' or 'ssh'." + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *indexTemplate != "" && *indexPath == "" {
+		fmt.Println(colors.Red + "--index-template requires --index." + colors.Reset)
+		os.Exit(1)
+	}
+
+	var staleAfter time.Duration
+	if *reportStale != "" {
+		var err error
+		if staleAfter, err = helpers.ParseAge(*reportStale); err != nil {
+			fmt.Printf(colors.Red+"Invalid --report-stale: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+	}
+
+	if !diffMode && (*since != "" || *asJSON) {
+		fmt.Println(colors.Red + "--since and --json are only supported by reposync diff." + colors.Reset)
+		os.Exit(1)
+	}
+	if diffMode && *provider == "mock" {
+		fmt.Println(colors.Red + "reposync diff does not support the mock provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if diffMode && *output != "text" {
+		fmt.Println(colors.Red + "--output is only supported by syncs; use --json for a machine-readable diff." + colors.Reset)
+		os.Exit(1)
+	}
+	if diffMode && multiSource {
+		fmt.Println(colors.Red + "reposync diff compares a single source: pass -p and -g." + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *withSettings && *provider == "bitbucket-server" {
+		fmt.Println(colors.Red + "--with-settings is not supported for the bitbucket-server provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if *withCIConfig && (*provider == "github" || *provider == "bitbucket-server") {
+		fmt.Println(colors.Red + "--with-ci-config is only supported for the gitlab provider." + colors.Reset)
+		os.Exit(1)
+	}
+	if *includeVariableValues && !*withCIConfig {
+		fmt.Println(colors.Red + "--include-variable-values requires --with-ci-config." + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *harvest != "" && *harvest != "deps" {
+		fmt.Println(colors.Red + "Unsupported --harvest mode. Use 'deps'." + colors.Reset)
+		os.Exit(1)
+	}
+
+	var visibilities []string
+	if *visibility != "" {
+		for _, value := range strings.Split(*visibility, ",") {
+			value = strings.TrimSpace(value)
+			if value != "public" && value != "internal" && value != "private" {
+				fmt.Printf(colors.Red+"Invalid visibility %q. Use public, internal or private.\n"+colors.Reset, value)
+				os.Exit(1)
+			}
+			visibilities = append(visibilities, value)
+		}
+	}
+
+	var topicList []string
+	for _, topic := range strings.Split(*topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topicList = append(topicList, topic)
+		}
+	}
+	var propertyValues map[string][]string
+	for _, property := range properties {
+		name, value, ok := strings.Cut(property, "=")
+		if name, value = strings.TrimSpace(name), strings.TrimSpace(value); !ok || name == "" || value == "" {
+			fmt.Printf(colors.Red+"Invalid --property %q. Use name=value, e.g. team=payments.\n"+colors.Reset, property)
+			os.Exit(1)
+		}
+		if propertyValues == nil {
+			propertyValues = map[string][]string{}
+		}
+		propertyValues[name] = append(propertyValues[name], value)
+	}
+	if len(propertyValues) > 0 && ((!multiSource && *provider != "github") || diffMode) {
+		fmt.Println(colors.Red + "--property is only supported for syncs of the github provider." + colors.Reset)
+		os.Exit(1)
+	}
+	var activeAfter time.Time
+	if *activeSince != "" {
+		age, err := helpers.ParseAge(*activeSince)
+		if err != nil {
+			fmt.Printf(colors.Red+"Invalid --active-since: %v\n"+colors.Reset, err)
+			os.Exit(1)
+		}
+		activeAfter = time.Now().Add(-age)
+	}
+	if *search != "" || len(topicList) > 0 || *language != "" || *activeSince != "" {
+		if *provider == "bitbucket-server" {
+			fmt.Println(colors.Red + "--search, --topic, --language and --active-since are not supported for the bitbucket-server provider." + colors.Reset)
+			os.Exit(1)
+		}
+		if diffMode {
+			fmt.Println(colors.Red + "--search, --topic, --language and --active-since are not supported by reposync diff." + colors.Reset)
+			os.Exit(1)
+		}
+	}
+
+	if *codeSearch != "" && (*provider != "github" || *search != "" || diffMode) {
+		fmt.Println(colors.Red + "--code-search is only supported for syncs of the github provider and cannot be combined with --search." + colors.Reset)
+		os.Exit(1)
+	}
+	if *withDependency != "" && (*harvest != "" || diffMode) {
+		fmt.Println(colors.Red + "--with-dependency reads the inventory of an earlier --harvest deps run and cannot be combined with --harvest or reposync diff." + colors.Reset)
+		os.Exit(1)
+	}
+
+	if *groupBy != "" && *groupBy != "topic" && *groupBy != "language" && *groupBy != "visibility" {
+		fmt.Printf(colors.Red+"Invalid --group-by %q. Use topic, language or visibility.\n"+colors.Reset, *groupBy)
+		os.Exit(1)
+	}
+
+	if *output != "text" && *output != "json" && *output != "quiet" {
+		fmt.Printf(colors.Red+"Invalid --output %q. Use text, json or quiet.\n"+colors.Reset, *output)
+		os.Exit(1)
+	}
+	failures := configureOutput(*output, *ciMode)
+	if *order != "" && *order != "size-asc" && *order != "size-desc" && *order != "name" && *order != "activity" {
+		fmt.Printf(colors.Red+"Invalid --order %q. Use size-asc, size-desc, name or activity.\n"+colors.Reset, *order)
+		os.Exit(1)
+	}
+
+	var failureBudget, failurePercent int
+	if *maxFailures != "" {
+		var err error
+		if failureBudget, failurePercent, err = helpers.ParseFailureBudget(*maxFailures); err != nil {
+			fmt.Println(colors.Red + "Invalid --max-failures: " + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+	}
+
+	if *concurrency < 1 {
+		fmt.Println(colors.Red + "Invalid concurrency. -j must be at least 1." + colors.Reset)
+		os.Exit(1)
+	}
+	if *adaptive && *concurrency == 1 && !explicitFlags()["j"] {
+		*concurrency = adaptiveMaxConcurrency
+	}
+
+	if *noWrite || diffMode {
+		// Enforced in the helpers as well, so no code path can modify the workspace
+		helpers.SetReadOnly(true)
+	}
+
+	if *cloneTimeout < 0 {
+		fmt.Println(colors.Red + "Invalid --clone-timeout. It must not be negative." + colors.Reset)
+		os.Exit(1)
+	}
+	helpers.SetGitTimeout(*cloneTimeout)
+
+	if *every < 0 {
+		fmt.Println(colors.Red + "Invalid --every. It must not be negative." + colors.Reset)
+		os.Exit(1)
+	}
+	if *healthListen != "" && *every == 0 {
+		fmt.Println(colors.Red + "--health-listen requires --every." + colors.Reset)
+		os.Exit(1)
+	}
+	helpers.SetAllowGitPrompts(*allowGitPrompts)
+
+	if *debugHTTP {
+		client.SetDebugOutput(helpers.NewRedactingWriter(os.Stderr))
+	}
+
+	workspace, err := helpers.ExpandPath(dest)
+	if err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+	if err := helpers.PrepareWorkspace(workspace); err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+
+	manifest, err := helpers.LoadManifest(workspace)
+	if err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+	if *acceptNewHostKeys {
+		helpers.SetKnownHostsFile(helpers.GetKnownHostsPath(workspace))
+	}
+	pins := manifest.Pins
+	if *locked {
+		lock, err := helpers.LoadLockfile(helpers.GetLockfilePath(workspace))
+		if err != nil {
+			fmt.Println(colors.Red + err.Error() + ": run reposync lock first" + colors.Reset)
+			os.Exit(1)
+		}
+		// The lockfile has the last word over the manifest's pins
+		pins = services.LockedPins(lock)
+	}
+
+	remapPath := helpers.GetRemapPath(workspace)
+	if *remapFile != "" {
+		if remapPath, err = helpers.ExpandPath(*remapFile); err == nil {
+			_, err = os.Stat(remapPath)
+		}
+		if err != nil {
+			fmt.Println(colors.Red + "Cannot read --remap file: " + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+	}
+	remap, err := helpers.LoadRemap(remapPath)
+	if err != nil {
+		fmt.Println(colors.Red + err.Error() + colors.Reset)
+		os.Exit(1)
+	}
+
+	options := models.SyncOptions{
+		CloneMethod:   *cloneMethod,
+		BaseDir:       workspace,
+		Concurrency:   *concurrency,
+		AuditLogPath:  *auditLog,
+		NoWrite:       *noWrite,
+		GitConfig:     manifest.GitConfig,
+		Pins:          pins,
+		PinnedOnly:    *locked,
+		IndexPath:     *indexPath,
+		IndexTemplate: *indexTemplate,
+		StaleAfter:    staleAfter,
+
+		OwnershipReportPath: *ownershipReport,
+		ScanReportPath:      *scanReport,
+		Visibility:          visibilities,
+		GroupBy:             *groupBy,
+		FullPaths:           *fullPaths || (*provider == "gitlab" && helpers.IsGroupPath(*groupID)), // A subgroup path keeps its parents
+		SkipEmptyGroups:     *skipEmptyGroups,
+		MaxDepth:            *maxDepth,
+		TrackAllBranches:    *trackAllBranches,
+		PruneRefs:           *pruneRefs,
+		Remap:               remap,
+		Adaptive:            *adaptive,
+		Order:               *order,
+		Priority:            config.Priority,
+		MaxFailures:         failureBudget,
+		MaxFailuresPercent:  failurePercent,
+		SkipTopic:           strings.TrimSpace(*skipTopic),
+		IncludeInactive:     *includeInactive,
+		AcceptNewHostKeys:   *acceptNewHostKeys,
+		Diagnostics:         *diagnostics,
+		SSHHosts:            config.SSHHosts,
+		URLRewrites:         config.URLRewrites,
+		CI:                  *ciMode,
+		ShowGitOutput:       *showGitOutput,
+		CommitExportPath:    *exportCommits,
+		ExportSettings:      *withSettings,
+		ExportCIConfig:      *withCIConfig,
+
+		IncludeVariableValues: *includeVariableValues,
+
+		Topics:      topicList,
+		Properties:  propertyValues,
+		Language:    *language,
+		ActiveAfter: activeAfter,
+		Search:      *search,
+		CodeSearch:  *codeSearch,
+	}
+	if *harvest == "deps" {
+		options.DependencyInventoryPath = *harvestOutput
+	}
+	if *withDependency != "" {
+		if options.DependencyPaths, err = services.DependentRepositories(*harvestOutput, *withDependency); err != nil {
+			fmt.Println(colors.Red + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+	}
+	cloneArgs := append(append([]string{}, config.GitArgs...), gitArgs...)
+	if *shallowSince != "" {
+		since, err := time.Parse("2006-01-02", *shallowSince)
+		if err != nil {
+			age, ageErr := helpers.ParseAge(*shallowSince)
+			if ageErr != nil {
+				fmt.Printf(colors.Red+"Invalid --shallow-since %q: use a date (2023-01-01) or a period (365d)\n"+colors.Reset, *shallowSince)
+				os.Exit(1)
+			}
+			since = time.Now().Add(-age)
+		}
+		cloneArgs = append(cloneArgs, "--shallow-since="+since.Format("2006-01-02"))
+	}
+	gitVersion := requireGit()
+	options.GitArgs = supportedCloneArgs(gitVersion, cloneArgs)
+	if *pruneRefs {
+		if err := helpers.RequireGitVersion(gitVersion, helpers.PruneTagsGitVersion, "--prune-refs"); err != nil {
+			services.Notify(services.LevelError, "%s", err)
+			os.Exit(1)
+		}
+	}
+	// git clone --dissociate is what keeps a clone independent of the one it borrowed from
+	options.ShareObjects = *shareObjects && helpers.SupportsCloneOption(gitVersion, "--dissociate")
+	if *shareObjects && !options.ShareObjects {
+		services.Notify(services.LevelWarning, "git %s does not support --dissociate (git 2.3), ignoring --share-objects", gitVersion)
+	}
+	if registryPath, err := helpers.GetRegistryPath(); err == nil {
+		options.RegistryPath = registryPath
+	}
+
+	if *provider == "mock" {
+		runSync(func() error { return runMockSync(options) }, *every, *healthListen, failures)
+	}
+
+	// Tokens must never show up in output, not even in git's error messages
+	helpers.RegisterSecret(config.GitLabToken)
+	helpers.RegisterSecret(config.GitHubToken)
+	helpers.RegisterSecret(config.BitbucketServerToken)
+	helpers.RegisterSecret(os.Getenv("CI_JOB_TOKEN"))
+	helpers.RegisterSecret(os.Getenv("GITHUB_TOKEN"))
+
+	// Every sync is recorded in the machine-wide registry, for reposync workspaces
+	syncDir, _ := os.Getwd()
+	registered := func(sources []string, sync func() error) func() error {
+		return func() error {
+			err := sync()
+			if options.NoWrite || options.RegistryPath == "" {
+				return err
+			}
+			entry := models.RegisteredWorkspace{Path: workspace, Sources: sources, LastSync: time.Now().UTC(), Args: oneShotArgs(args), Dir: syncDir}
+			if err != nil {
+				entry.LastError = helpers.Redact(err.Error())
+			}
+			if regErr := services.RegisterWorkspace(options.RegistryPath, entry); regErr != nil {
+				services.Notify(services.LevelWarning, "Failed to update the workspace registry: %v", regErr)
+			}
+			return err
+		}
+	}
+
+	if multiSource {
+		options.ScanCommand = config.ScanCommand
+		credentials, err := sourceCredentials(config, manifest.Sources, *cloneMethod)
+		if err != nil {
+			fmt.Println(colors.Red + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+		var sourceNames []string
+		for _, source := range manifest.Sources {
+			name := source.Provider
+			if source.Group != "" {
+				name += ":" + source.Group
+			}
+			sourceNames = append(sourceNames, name)
+		}
+		runSync(registered(sourceNames, func() error {
+			for provider, creds := range credentials {
+				sourceOptions := options
+				sourceOptions.Token, sourceOptions.BaseURL = creds.Token, creds.BaseURL
+				if err := preflight(provider, sourceOptions, *waitForProvider); err != nil {
+					return err
+				}
+			}
+			return services.SyncWorkspaceSources(manifest.Sources, manifest.Prune, options, credentials)
+		}), *every, *healthListen, failures)
+	}
+
+	var token, baseURL, apiURL, tokenType string
+	var actionsToken bool
+	switch *provider {
+	case "gitlab":
+		if token, baseURL, tokenType, err = gitLabCredentials(config, *gitlabTokenType); err != nil {
+			fmt.Println(colors.Red + err.Error() + colors.Reset)
+			os.Exit(1)
+		}
+		apiURL = helpers.GetGitLabAPIURL(baseURL, "")
+	case "github":
+		token, baseURL, actionsToken = gitHubCredentials(config)
+		apiURL = helpers.GetGitHubAPIURL(baseURL, "")
+	case "bitbucket-server":
+		token, baseURL = config.BitbucketServerToken, config.BitbucketServerURL
+		if baseURL == "" {
+			fmt.Println(colors.Red + "Bitbucket Server requires bitbucket_server_url in the config file or REPOSYNC_BITBUCKET_SERVER_URL." + colors.Reset)
+			os.Exit(1)
+		}
+		apiURL = helpers.GetBitbucketServerAPIURL(baseURL, "")
+	}
+
+	if token == "" && configErr != nil {
+		fmt.Println(colors.Red + "No configuration found. Please run 'reposync config' to configure your tokens." + colors.Reset)
+		os.Exit(1)
+	}
+	if token == "" {
+		fmt.Printf(colors.Red+"No token found for provider %s. Please run 'reposync config' to configure your tokens.\n"+colors.Reset, *provider)
+		os.Exit(1)
+	}
+
+	// Workflow, job and deploy tokens authenticate HTTPS clones under their own username
+	if actionsToken {
+		helpers.SetCloneUsername("x-access-token")
+		warnGitHubActionsToken()
+	}
+	switch tokenType {
+	case models.TokenTypeJob:
+		helpers.SetCloneUsername("gitlab-ci-token")
+	case models.TokenTypeDeploy:
+		deployUser := *gitlabDeployUser
+		if deployUser == "" {
+			deployUser = config.GitLabDeployUser
+		}
+		if deployUser == "" {
+			fmt.Println(colors.Red + "Deploy tokens require --gitlab-deploy-user or gitlab_deploy_user in the config file." + colors.Reset)
+			os.Exit(1)
+		}
+		helpers.SetCloneUsername(deployUser)
+	}
+	// Bitbucket Server accepts access tokens for HTTPS clones only with the owner's username
+	if *provider == "bitbucket-server" && *cloneMethod == "https" {
+		if config.BitbucketServerUser == "" {
+			fmt.Println(colors.Red + "HTTPS clones from Bitbucket Server require bitbucket_server_user in the config file or REPOSYNC_BITBUCKET_SERVER_USER." + colors.Reset)
+			os.Exit(1)
+		}
+		helpers.SetCloneUsername(config.BitbucketServerUser)
+	}
+	if tokenType != models.TokenTypePersonal && *cloneMethod != "https" {
+		fmt.Printf(colors.Red+"GitLab %s tokens only work with -m https.\n"+colors.Reset, tokenType)
+		os.Exit(1)
+	}
+
+	// Validate token
+	if err := helpers.ValidateToken(token); err != nil {
+		fmt.Printf(colors.Red+"Invalid token for provider %s: %v\n"+colors.Reset, *provider, err)
+		os.Exit(1)
+	}
+
+	// All workers share one request budget per API host
+	if rps, ok := config.RequestsPerSecond[*provider]; ok {
+		client.SetRateLimit(apiURL, rps, config.MaxConcurrentRequests)
+	} else if actionsToken {
+		client.SetRateLimit(apiURL, client.GitHubActionsRequestsPerSecond, config.MaxConcurrentRequests)
+	} else if config.MaxConcurrentRequests > 0 {
+		client.SetRateLimit(apiURL, client.DefaultRequestsPerSecond, config.MaxConcurrentRequests)
+	}
+
+	options.Token = token
+	options.TokenType = tokenType
+	options.BaseURL = baseURL
+	options.ScanCommand = config.ScanCommand
+
+	if !*allProjects && (*provider == "github" || *provider == "bitbucket-server") {
+		// Create root directory with organization name or project key
+		root := *rootName
+		if root == "" {
+			root = *groupID
+			if *provider == "github" {
+				// A failed lookup is reported by the sync itself
+				if login, err := services.GitHubOrganizationLogin(*groupID, options); err == nil {
+					root = login
+				}
+			}
+			root = helpers.RootDirectory(workspace, root)
+		}
+		options.BaseDir = filepath.Join(workspace, root)
+	}
+	if diffMode {
+		if err := runDiff(*provider, *groupID, *allProjects, options, *since, *asJSON); err != nil {
+			fmt.Println(colors.Red + "Failed to compute diff: " + helpers.Redact(err.Error()) + colors.Reset)
+			os.Exit(exitFailure)
+		}
+		os.Exit(exitOK)
+	}
+
+	source := *provider
+	if !*allProjects {
+		source += ":" + *groupID
+	}
+	runSync(registered([]string{source}, func() error {
+		if err := preflight(*provider, options, *waitForProvider); err != nil {
+			return err
+		}
+		if *allProjects {
+			// Every project is placed under its full namespace path
+			return services.CloneAllGitLabProjects(options)
+		}
+		if *provider == "gitlab" {
+			// The service will create the proper root directory structure
+			id, err := services.ResolveGitLabGroup(*groupID, options)
+			if err != nil {
+				return err
+			}
+			return services.CloneGitLabRepositoriesWithOptions(id, options)
+		}
+		if *provider == "bitbucket-server" {
+			return services.CloneBitbucketServerProjectWithOptions(*groupID, options)
+		}
+		return services.CloneGitHubRepositoriesWithOptions(*groupID, options)
+	}), *every, *healthListen, failures)
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	services "github.com/itszeeshan/reposync/services"
+)
+
+/*
+TestMain runs reposync itself when the test binary is started with REPOSYNC_TEST_MAIN,
+so tests can check the complete output of a command.
+*/
+func TestMain(m *testing.M) {
+	if os.Getenv("REPOSYNC_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestJSONOutputOfSyncWithUnsupportedCloneArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake git is a shell script")
+	}
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// git 2.18 predates partial clones, so --filter is dropped with a warning
+	bin := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = --version ]; then echo 'git version 2.18.0'; exit 0; fi\nexec " + realGit + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-p", "mock", "-d", filepath.Join(home, "workspace"), "--output", "json", "--git-arg=--filter=blob:none")
+	cmd.Env = append(os.Environ(),
+		"REPOSYNC_TEST_MAIN=1",
+		"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"REPOSYNC_SYSTEM_CONFIG="+filepath.Join(home, "system.yaml"),
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("sync failed: %v\nstdout:\n%s\nstderr:\n%s", err, stdout.String(), stderr.String())
+	}
+
+	warned := false
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		var event services.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Type == "" {
+			t.Fatalf("stdout line %q is not an event: %v", scanner.Text(), err)
+		}
+		if event.Level == services.LevelWarning && strings.Contains(event.Message, "--filter=blob:none") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("no warning event about the dropped --filter, stdout:\n%s", stdout.String())
+	}
+}
@@ -1,37 +1,367 @@
 package client
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 /*
-Request executes authenticated API requests to GitLab/GitHub.
-Adds Bearer token authentication header and handles HTTP errors:
+HTTPDoer sends HTTP requests.
+Satisfied by *http.Client; services accept it so tests can substitute
+an httptest server client or a fake without touching real APIs.
+*/
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+/*
+Request executes authenticated API requests to GitLab/GitHub
+using the default HTTP client. See RequestWith.
+*/
+func Request(method, url, token string) (*http.Response, error) {
+	return RequestWith(http.DefaultClient, method, url, token)
+}
+
+/*
+RequestWith executes authenticated API requests to GitLab/GitHub.
+Adds Bearer token authentication header, waits for the shared rate budget
+of the API host (see SetRateLimit) and handles HTTP errors:
 - 401 Unauthorized: Returns permission denied error
+- 403 Forbidden by GitHub's SAML SSO enforcement: Returns an SSOError
 - 429 Too Many Requests: Returns rate limit error
 - Other errors: Returns appropriate error with status code
 */
-func Request(method, url, token string) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+func RequestWith(doer HTTPDoer, method, url, token string) (*http.Response, error) {
+	return RequestWithHeader(doer, method, url, "Authorization", fmt.Sprintf("Bearer %s", token))
+}
+
+// ErrUnauthorized is returned for 401 Unauthorized responses.
+var ErrUnauthorized = errors.New("permission denied - check if your token is valid")
+
+/*
+StatusError is returned for responses with an unexpected HTTP status code.
+Callers use errors.As to tell e.g. a missing resource (404) from other failures.
+Message is the error the API explained the status with, when it sent one.
+*/
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("request failed with status code: %d (%s)", e.Code, e.Message)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Set("User-Agent", "RepoSync/1.0")
+	return fmt.Sprintf("request failed with status code: %d", e.Code)
+}
+
+// maxErrorMessage limits how much of an API error message ends up in errors.
+const maxErrorMessage = 300
 
-	resp, err := http.DefaultClient.Do(req)
+/*
+errorMessage extracts the message of a JSON error body. GitHub sends "message"
+and, for validation failures (422), "errors" with a message or field and code each;
+GitLab sends "message" as text, as a map of fields to their problems or as a list,
+or "error" and "error_description" for OAuth errors. Other bodies yield "".
+*/
+func errorMessage(body []byte) string {
+	var payload struct {
+		Message          any    `json:"message"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		Errors           []any  `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+
+	var parts []string
+	if message := describeErrorValue(payload.Message); message != "" {
+		parts = append(parts, message)
+	}
+	if payload.Error != "" {
+		parts = append(parts, strings.TrimSpace(payload.Error+" "+payload.ErrorDescription))
+	}
+	for _, detail := range payload.Errors {
+		if item, ok := detail.(map[string]any); ok && item["message"] == nil {
+			// GitHub validation errors without a message: {"resource":"Repository","field":"name","code":"already_exists"}
+			detail = strings.TrimSpace(fmt.Sprintf("%v %v", valueOrEmpty(item["field"]), valueOrEmpty(item["code"])))
+		} else if ok {
+			detail = item["message"]
+		}
+		if message := describeErrorValue(detail); message != "" {
+			parts = append(parts, message)
+		}
+	}
+
+	message := strings.Join(parts, ": ")
+	if len(message) > maxErrorMessage {
+		message = message[:maxErrorMessage] + "..."
+	}
+	return message
+}
+
+/*
+describeErrorValue renders the message of an error body, which is text, a list of
+messages or a map of fields to their messages (e.g. {"name": ["has already been taken"]}).
+*/
+func describeErrorValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []any:
+		var messages []string
+		for _, item := range value {
+			if message := describeErrorValue(item); message != "" {
+				messages = append(messages, message)
+			}
+		}
+		return strings.Join(messages, ", ")
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var messages []string
+		for _, key := range keys {
+			if message := describeErrorValue(value[key]); message != "" {
+				messages = append(messages, key+" "+message)
+			}
+		}
+		return strings.Join(messages, ", ")
+	}
+	return ""
+}
+
+/*
+valueOrEmpty formats a JSON value, with nothing for a missing one.
+*/
+func valueOrEmpty(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+/*
+SSOError is returned when GitHub refuses a token that is not authorized for the
+SAML single sign-on of an organization. URL is the page on which the token can be
+authorized, when GitHub sent one. Unwraps to the 403 StatusError.
+*/
+type SSOError struct {
+	URL string
+}
+
+func (e *SSOError) Error() string {
+	message := "the organization enforces SAML single sign-on and the token is not authorized for it: "
+	if e.URL != "" {
+		message += "open " + e.URL + " while signed in to GitHub to authorize it, or "
+	}
+	return message + "authorize it under Settings > Developer settings > Personal access tokens > Configure SSO, then run the sync again"
+}
+
+func (e *SSOError) Unwrap() error {
+	return &StatusError{Code: http.StatusForbidden}
+}
+
+/*
+ssoError detects GitHub's SAML enforcement from the X-GitHub-SSO header of a 403 response,
+e.g. "required; url=https://github.com/orgs/acme/sso?authorization_request=...".
+Returns nil for other 403 responses.
+*/
+func ssoError(header http.Header) error {
+	sso := header.Get("X-GitHub-SSO")
+	if !strings.HasPrefix(sso, "required") {
+		return nil
+	}
+	_, url, _ := strings.Cut(sso, "url=")
+	return &SSOError{URL: strings.TrimSpace(url)}
+}
+
+/*
+IsNotFound reports whether err was caused by a 404 Not Found response.
+*/
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
+
+/*
+RequestWithHeader executes an API request authenticated by the given header.
+Used for credentials that are not Bearer tokens, such as GitLab's JOB-TOKEN;
+rate limiting and error handling are the same as in RequestWith.
+*/
+func RequestWithHeader(doer HTTPDoer, method, url, name, value string) (*http.Response, error) {
+	return send(doer, method, url, name, value, nil)
+}
+
+/*
+RequestAnonymous executes an API request without credentials.
+Used to tell what the public can see from what a token can see,
+e.g. whether a group the token cannot find exists at all.
+*/
+func RequestAnonymous(doer HTTPDoer, method, url string) (*http.Response, error) {
+	return send(doer, method, url, "", "", nil)
+}
+
+/*
+RequestWithBody executes an authenticated API request with a JSON body.
+Used for the write requests of restores (creating projects, applying settings);
+any 2xx status counts as success, like for the other requests.
+*/
+func RequestWithBody(doer HTTPDoer, method, url, token string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data: %w", err)
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+	return send(doer, method, url, "Authorization", fmt.Sprintf("Bearer %s", token), data)
+}
+
+// Requests answered with 502 or 503, as self-hosted instances do during maintenance,
+// are retried this often in total. The delay before a retry doubles every time,
+// unless the response names one in Retry-After.
+var (
+	unavailableAttempts = 5
+	unavailableDelay    = 5 * time.Second
+)
+
+// maxRetryAfter caps the delay a Retry-After header can ask for.
+const maxRetryAfter = 5 * time.Minute
+
+var (
+	retryMu      sync.Mutex
+	retryHandler func(message string)
+)
+
+/*
+SetRetryHandler reports every retry of an unavailable instance to handler,
+e.g. to show it among the messages of a sync. Pass nil to retry silently.
+*/
+func SetRetryHandler(handler func(message string)) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryHandler = handler
+}
+
+/*
+reportRetry passes a retry notice to the handler set with SetRetryHandler, if any.
+*/
+func reportRetry(format string, args ...any) {
+	retryMu.Lock()
+	handler := retryHandler
+	retryMu.Unlock()
+	if handler != nil {
+		handler(fmt.Sprintf(format, args...))
+	}
+}
+
+/*
+send implements the requests of this package: authentication, rate limiting, retries
+of unavailable instances and error handling.
+*/
+func send(doer HTTPDoer, method, url, name, value string, body []byte) (*http.Response, error) {
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if name != "" {
+			req.Header.Set(name, value)
+		}
+		req.Header.Set("User-Agent", "RepoSync/1.0")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		limiter := limiterFor(url)
+		limiter.acquire()
+		start := time.Now()
+		resp, err = doer.Do(req)
+		limiter.release()
+		logRequest(req, resp, time.Since(start), err)
+		recordUsage(url, resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch data: %w", err)
+		}
+
+		unavailable := resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable
+		if !unavailable || attempt >= unavailableAttempts {
+			break
+		}
+		resp.Body.Close()
+		delay := retryDelay(resp.Header, attempt)
+		reportRetry("%s answered %s, retrying in %s (attempt %d of %d)", hostOf(url), resp.Status, delay, attempt+1, unavailableAttempts)
+		time.Sleep(delay)
 	}
 
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	var message string
+	if !success {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		message = errorMessage(data)
+	}
 	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("permission denied - check if your token is valid")
+		return nil, ErrUnauthorized
+	} else if resp.StatusCode == http.StatusForbidden && ssoError(resp.Header) != nil {
+		return nil, ssoError(resp.Header)
 	} else if resp.StatusCode == http.StatusTooManyRequests {
 		return nil, fmt.Errorf("rate limit exceeded - please wait and try again")
-	} else if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+	} else if !success {
+		return nil, &StatusError{Code: resp.StatusCode, Message: message}
 	}
 
 	return resp, nil
 }
+
+/*
+retryDelay returns how long to wait before retrying the attempt-th request to an
+unavailable instance: the Retry-After header in seconds, if the instance sent one,
+otherwise a delay that doubles with every attempt.
+*/
+func retryDelay(header http.Header, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+	}
+	return unavailableDelay << (attempt - 1)
+}
+
+/*
+CheckAvailable sends an anonymous GET request to an instance and reports whether it serves requests.
+Any response below 500, including 401 and 403, means the instance is up; connection
+errors and 5xx responses are returned as errors. Unavailable instances are not retried.
+*/
+func CheckAvailable(doer HTTPDoer, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "RepoSync/1.0")
+
+	start := time.Now()
+	resp, err := doer.Do(req)
+	logRequest(req, resp, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
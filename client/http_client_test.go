@@ -0,0 +1,171 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestSSORequired(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		wantSSO bool
+		wantURL string
+	}{
+		{"sso required", "required; url=https://github.com/orgs/acme/sso?authorization_request=abc", true, "https://github.com/orgs/acme/sso?authorization_request=abc"},
+		{"sso without url", "required", true, ""},
+		{"plain forbidden", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("X-GitHub-SSO", tt.header)
+				}
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			defer server.Close()
+
+			_, err := RequestWith(server.Client(), "GET", server.URL+"/orgs/acme/repos", "ghp_testtoken1234")
+			var ssoErr *SSOError
+			if errors.As(err, &ssoErr) != tt.wantSSO {
+				t.Fatalf("RequestWith() error = %v, want SSO error %v", err, tt.wantSSO)
+			}
+			if tt.wantSSO && (ssoErr.URL != tt.wantURL || !strings.Contains(err.Error(), "Configure SSO")) {
+				t.Errorf("RequestWith() error = %v, want authorization URL %q and instructions", err, tt.wantURL)
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.Code != http.StatusForbidden {
+				t.Errorf("RequestWith() error = %v, want it to unwrap to status 403", err)
+			}
+		})
+	}
+}
+
+func TestRequestErrorMessage(t *testing.T) {
+	unavailableDelay = 0
+	t.Cleanup(func() { unavailableDelay = 5 * time.Second })
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"github rate limit", http.StatusForbidden, `{"message":"API rate limit exceeded for installation ID 42.","documentation_url":"https://docs.github.com/rest"}`, "(API rate limit exceeded for installation ID 42.)"},
+		{"gitlab not found", http.StatusNotFound, `{"message":"404 Group Not Found"}`, "status code: 404 (404 Group Not Found)"},
+		{"gitlab validation", http.StatusBadRequest, `{"message":{"name":["has already been taken"],"path":["is too long"]}}`, "(name has already been taken, path is too long)"},
+		{"github validation", http.StatusUnprocessableEntity, `{"message":"Validation Failed","errors":[{"resource":"Repository","field":"name","code":"already_exists"}]}`, "(Validation Failed: name already_exists)"},
+		{"gitlab oauth", http.StatusForbidden, `{"error":"insufficient_scope","error_description":"The request requires higher privileges than provided by the access token."}`, "(insufficient_scope The request requires"},
+		{"html", http.StatusBadGateway, `<html><body>Bad Gateway</body></html>`, "request failed with status code: 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := RequestWith(server.Client(), "GET", server.URL+"/groups/42", "glpat-testtoken")
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.Code != tt.status {
+				t.Fatalf("RequestWith() error = %v, want status %d", err, tt.status)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RequestWith() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestRetriesUnavailable(t *testing.T) {
+	unavailableDelay = 0
+	t.Cleanup(func() { unavailableDelay = 5 * time.Second })
+
+	var notices []string
+	SetRetryHandler(func(message string) { notices = append(notices, message) })
+	t.Cleanup(func() { SetRetryHandler(nil) })
+
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int // Status of the final response
+		calls    int
+	}{
+		{"maintenance ends", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, http.StatusOK, 3},
+		{"still down", []int{503, 503, 503, 503, 503, 503}, http.StatusServiceUnavailable, 5},
+		{"server error not retried", []int{http.StatusInternalServerError, http.StatusOK}, http.StatusInternalServerError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notices = nil
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+
+			_, err := RequestWith(server.Client(), "GET", server.URL+"/version", "glpat-testtoken")
+			var statusErr *StatusError
+			switch {
+			case tt.want == http.StatusOK && err != nil:
+				t.Errorf("RequestWith() error = %v, want success", err)
+			case tt.want != http.StatusOK && (!errors.As(err, &statusErr) || statusErr.Code != tt.want):
+				t.Errorf("RequestWith() error = %v, want status %d", err, tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("requests = %d, want %d", calls, tt.calls)
+			}
+			if len(notices) != tt.calls-1 {
+				t.Errorf("retry notices = %q, want %d", notices, tt.calls-1)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		attempt    int
+		want       time.Duration
+	}{
+		{"", 1, 5 * time.Second},
+		{"", 3, 20 * time.Second},
+		{"120", 1, 2 * time.Minute},
+		{"86400", 1, maxRetryAfter},
+		{"Wed, 21 Oct 2026 07:28:00 GMT", 2, 10 * time.Second}, // Dates fall back to the backoff
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.retryAfter != "" {
+			header.Set("Retry-After", tt.retryAfter)
+		}
+		if got := retryDelay(header, tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%q, %d) = %s, want %s", tt.retryAfter, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAvailable(t *testing.T) {
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	if err := CheckAvailable(server.Client(), server.URL+"/api/v4/version"); err != nil {
+		t.Errorf("CheckAvailable() error = %v, want an instance that rejects anonymous requests to count as up", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := CheckAvailable(server.Client(), server.URL+"/api/v4/version"); err == nil {
+		t.Errorf("CheckAvailable() succeeded for an instance answering 503")
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Defaults applied to every API host without an explicit limit.
+// They match the pacing of the previous fixed 100ms delay between calls.
+const (
+	DefaultRequestsPerSecond     = 10.0
+	DefaultMaxConcurrentRequests = 4
+)
+
+// GitHubActionsRequestsPerSecond paces the GITHUB_TOKEN of a workflow run,
+// which may only make 1,000 REST API requests per hour and repository.
+const GitHubActionsRequestsPerSecond = 1000.0 / 3600
+
+/*
+rateLimiter is a token bucket combined with a cap on in-flight requests.
+A single limiter is shared by all goroutines talking to the same API host,
+so raising repository concurrency never raises the request rate beyond the budget.
+*/
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	next     time.Time
+	slots    chan struct{}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*rateLimiter{}
+)
+
+/*
+newRateLimiter creates a limiter allowing requestsPerSecond on average,
+bursts of up to one second worth of requests, and maxConcurrent requests in flight.
+*/
+func newRateLimiter(requestsPerSecond float64, maxConcurrent int) *rateLimiter {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultRequestsPerSecond
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrentRequests
+	}
+	burst := int(requestsPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		burst:    burst,
+		slots:    make(chan struct{}, maxConcurrent),
+	}
+}
+
+/*
+SetRateLimit configures the request budget for the API host of the given URL.
+Applies to all subsequent requests to that host from any goroutine.
+Non-positive values fall back to the defaults.
+*/
+func SetRateLimit(apiURL string, requestsPerSecond float64, maxConcurrent int) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiters[hostOf(apiURL)] = newRateLimiter(requestsPerSecond, maxConcurrent)
+}
+
+/*
+limiterFor returns the shared limiter of a URL's host, creating a default one on first use.
+*/
+func limiterFor(rawURL string) *rateLimiter {
+	host := hostOf(rawURL)
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiter, ok := limiters[host]
+	if !ok {
+		limiter = newRateLimiter(DefaultRequestsPerSecond, DefaultMaxConcurrentRequests)
+		limiters[host] = limiter
+	}
+	return limiter
+}
+
+/*
+hostOf extracts the host (including port) from a URL, falling back to the raw string.
+*/
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return parsed.Host
+}
+
+/*
+acquire blocks until a request may be sent: a concurrency slot is free
+and the token bucket allows another request.
+*/
+func (l *rateLimiter) acquire() {
+	l.slots <- struct{}{}
+
+	l.mu.Lock()
+	now := time.Now()
+	earliest := now.Add(-time.Duration(l.burst-1) * l.interval)
+	if l.next.Before(earliest) {
+		l.next = earliest
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+/*
+release frees the concurrency slot taken by acquire.
+*/
+func (l *rateLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+HostUsage is the API usage of one host during a run.
+Limit, Remaining and Reset come from the rate-limit headers of the responses
+(GitHub's X-RateLimit-*, GitLab's RateLimit-*); Limit is 0 when the host sent none.
+*/
+type HostUsage struct {
+	Host      string
+	Requests  int
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+var (
+	usageMu sync.Mutex
+	usage   = map[string]*HostUsage{}
+)
+
+/*
+recordUsage counts a request to the host of a URL and keeps its rate-limit budget.
+Within a rate-limit window the lowest remaining budget is kept, as responses of
+concurrent requests arrive in any order; a later reset starts a new window.
+*/
+func recordUsage(rawURL string, resp *http.Response) {
+	host := hostOf(rawURL)
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	entry, ok := usage[host]
+	if !ok {
+		entry = &HostUsage{Host: host}
+		usage[host] = entry
+	}
+	entry.Requests++
+
+	if resp == nil {
+		return
+	}
+	limit, remaining, reset, ok := parseRateLimit(resp.Header)
+	if !ok {
+		return
+	}
+	if entry.Limit == 0 || reset.After(entry.Reset) || remaining < entry.Remaining {
+		entry.Limit, entry.Remaining = limit, remaining
+		entry.Reset = reset
+	}
+}
+
+/*
+parseRateLimit reads the rate-limit headers of a response.
+The reset is either a Unix timestamp (GitHub, GitLab) or a number of seconds.
+*/
+func parseRateLimit(header http.Header) (limit, remaining int, reset time.Time, ok bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		limit, err := strconv.Atoi(header.Get(prefix + "Limit"))
+		if err != nil {
+			continue
+		}
+		remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			if seconds > 1_000_000_000 {
+				reset = time.Unix(seconds, 0)
+			} else {
+				reset = time.Now().Add(time.Duration(seconds) * time.Second).Truncate(time.Second)
+			}
+		}
+		return limit, remaining, reset, true
+	}
+	return 0, 0, time.Time{}, false
+}
+
+/*
+Headroom returns the smallest share of a rate limit left on any host during the
+current run, between 0 and 1. Hosts without rate-limit headers do not count;
+without any, the headroom is 1.
+*/
+func Headroom() float64 {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	headroom := 1.0
+	for _, entry := range usage {
+		if entry.Limit > 0 {
+			headroom = min(headroom, float64(entry.Remaining)/float64(entry.Limit))
+		}
+	}
+	return headroom
+}
+
+/*
+TakeUsage returns the API usage per host since the previous call, ordered by host,
+and starts counting anew. Called at the end of a run to report on it.
+*/
+func TakeUsage() []HostUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	hosts := make([]HostUsage, 0, len(usage))
+	for _, entry := range usage {
+		hosts = append(hosts, *entry)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	usage = map[string]*HostUsage{}
+	return hosts
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        http.Header
+		wantLimit     int
+		wantRemaining int
+		wantReset     time.Time
+		wantOK        bool
+	}{
+		{"github", http.Header{"X-Ratelimit-Limit": {"5000"}, "X-Ratelimit-Remaining": {"4990"}, "X-Ratelimit-Reset": {"1893456000"}}, 5000, 4990, time.Unix(1893456000, 0), true},
+		{"gitlab", http.Header{"Ratelimit-Limit": {"2000"}, "Ratelimit-Remaining": {"1999"}, "Ratelimit-Reset": {"1893456000"}}, 2000, 1999, time.Unix(1893456000, 0), true},
+		{"without reset", http.Header{"X-Ratelimit-Limit": {"60"}, "X-Ratelimit-Remaining": {"59"}}, 60, 59, time.Time{}, true},
+		{"none", http.Header{}, 0, 0, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, remaining, reset, ok := parseRateLimit(tt.header)
+			if limit != tt.wantLimit || remaining != tt.wantRemaining || !reset.Equal(tt.wantReset) || ok != tt.wantOK {
+				t.Errorf("parseRateLimit() = %d, %d, %v, %v, want %d, %d, %v, %v", limit, remaining, reset, ok, tt.wantLimit, tt.wantRemaining, tt.wantReset, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTakeUsage(t *testing.T) {
+	TakeUsage()
+	header := func(remaining string) *http.Response {
+		return &http.Response{Header: http.Header{"X-Ratelimit-Limit": {"5000"}, "X-Ratelimit-Remaining": {remaining}, "X-Ratelimit-Reset": {"1893456000"}}}
+	}
+	// Responses of concurrent requests arrive out of order
+	recordUsage("https://api.github.com/orgs/acme/repos", header("4998"))
+	recordUsage("https://api.github.com/orgs/acme/repos?page=2", header("4999"))
+	recordUsage("https://gitlab.example.com/api/v4/groups/1", nil)
+
+	usage := TakeUsage()
+	if len(usage) != 2 {
+		t.Fatalf("TakeUsage() returned %d hosts, want 2", len(usage))
+	}
+	if usage[0].Host != "api.github.com" || usage[0].Requests != 2 || usage[0].Remaining != 4998 {
+		t.Errorf("usage of api.github.com = %+v, want 2 requests and 4998 remaining", usage[0])
+	}
+	if usage[1].Requests != 1 || usage[1].Limit != 0 {
+		t.Errorf("usage of gitlab.example.com = %+v, want 1 request without limit", usage[1])
+	}
+	if len(TakeUsage()) != 0 {
+		t.Errorf("TakeUsage() did not start counting anew")
+	}
+}
@@ -1,11 +1,19 @@
 package constants
 
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
 // ANSI escape codes for terminal text coloring
-// These constants provide consistent color formatting for different message types:
+// These values provide consistent color formatting for different message types:
 // - Reset returns to default terminal colors
 // - Colors are used for success (Green), warnings (Yellow), errors (Red), and information (Blue/Cyan)
+// They are variables so Configure can switch them off for plain output.
 
-const (
+var (
 	Reset  = "\033[0m"
 	Red    = "\033[31m"
 	Green  = "\033[32m"
@@ -13,3 +21,32 @@ const (
 	Blue   = "\033[34m"
 	Cyan   = "\033[36m"
 )
+
+/*
+Configure decides whether output is colored.
+mode is one of auto, always or never. auto colors only when out is a terminal
+and NO_COLOR (https://no-color.org) is not set, so logs piped to files
+stay free of escape codes; always forces colors even then.
+*/
+func Configure(mode string, out *os.File) error {
+	switch mode {
+	case "always":
+		return nil
+	case "never":
+		disable()
+		return nil
+	case "auto", "":
+		if os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(out.Fd())) {
+			disable()
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid color mode %q: use auto, always or never", mode)
+}
+
+/*
+disable turns all colors into empty strings.
+*/
+func disable() {
+	Reset, Red, Green, Yellow, Blue, Cyan = "", "", "", "", "", ""
+}
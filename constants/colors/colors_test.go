@@ -0,0 +1,45 @@
+package constants
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		noColor string
+		colored bool
+		wantErr bool
+	}{
+		{name: "always", mode: "always", colored: true},
+		{name: "always ignores NO_COLOR", mode: "always", noColor: "1", colored: true},
+		{name: "never", mode: "never", colored: false},
+		{name: "auto without terminal", mode: "auto", colored: false},
+		{name: "auto with NO_COLOR", mode: "auto", noColor: "1", colored: false},
+		{name: "invalid", mode: "rainbow", colored: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset, Red = "\033[0m", "\033[31m"
+			t.Setenv("NO_COLOR", tt.noColor)
+
+			// A regular file is never a terminal
+			out, err := os.CreateTemp(t.TempDir(), "out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+
+			err = Configure(tt.mode, out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if colored := Red != ""; colored != tt.colored {
+				t.Errorf("Configure(%q) colored = %v, want %v", tt.mode, colored, tt.colored)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+/*
+AuditEntry is a single line of the workspace audit log.
+Every filesystem mutation performed by reposync (clone, move, branch switch,
+remote rewrite, ...) is appended as one JSON object per line.
+*/
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Path    string    `json:"path"`
+	Detail  string    `json:"detail,omitempty"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+}
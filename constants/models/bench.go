@@ -0,0 +1,15 @@
+package models
+
+/*
+BenchmarkResult is the clone throughput measured at one concurrency level
+by `reposync bench`.
+*/
+type BenchmarkResult struct {
+	Concurrency           int     `json:"concurrency"`
+	Repositories          int     `json:"repositories"`
+	Failed                int     `json:"failed"`
+	SizeBytes             int64   `json:"size_bytes"`
+	DurationMS            int64   `json:"duration_ms"`
+	RepositoriesPerMinute float64 `json:"repositories_per_minute"`
+	BytesPerSecond        float64 `json:"bytes_per_second"`
+}
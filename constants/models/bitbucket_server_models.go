@@ -0,0 +1,40 @@
+package models
+
+/*
+BitbucketServerRepository represents a repository of a Bitbucket Server or
+Data Center project. Clone URLs are not fields of their own but links,
+named "http" and "ssh".
+*/
+type BitbucketServerRepository struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Project     struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	} `json:"project"`
+	Links struct {
+		Clone []BitbucketServerLink `json:"clone"`
+		Self  []BitbucketServerLink `json:"self"`
+	} `json:"links"`
+}
+
+/*
+BitbucketServerLink is an entry of the links of a Bitbucket Server resource.
+*/
+type BitbucketServerLink struct {
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+/*
+BitbucketServerPage holds the paging fields of a Bitbucket Server list response.
+Pages are requested by start offset; NextPageStart is the offset of the next one.
+The items of the page (values) are decoded one by one as they are read.
+*/
+type BitbucketServerPage struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
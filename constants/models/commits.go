@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+/*
+CommitRecord is a single line of the commit activity export.
+Repository is the clone's path relative to the workspace, RemotePath
+its provider-side location, so records of all repositories can be
+consolidated into one newline-delimited JSON file.
+*/
+type CommitRecord struct {
+	Repository  string    `json:"repository"`
+	RemotePath  string    `json:"remote_path"`
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Date        time.Time `json:"date"`
+	Message     string    `json:"message"`
+}
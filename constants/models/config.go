@@ -1,7 +1,7 @@
 package models
 
 /*
-Config stores persisted authentication tokens and configuration for GitLab and GitHub.
+Config stores persisted authentication tokens and configuration for GitLab, GitHub and Bitbucket Server.
 Saved in JSON format in the user's home directory to avoid requiring
 tokens in CLI parameters for subsequent runs.
 Supports both cloud and self-hosted instances.
@@ -9,8 +9,61 @@ Supports both cloud and self-hosted instances.
 type Config struct {
 	GitLabToken string `json:"gitlab"`
 	GitHubToken string `json:"github"`
-	GitLabURL   string `json:"gitlab_url,omitempty"` // Support self-hosted GitLab
-	GitHubURL   string `json:"github_url,omitempty"` // Support GitHub Enterprise
-	CloneMethod string `json:"clone_method,omitempty"`
-	MaxRetries  int    `json:"max_retries,omitempty"`
+	GitLabURL   string `json:"gitlab_url,omitempty"`   // Support self-hosted GitLab
+	GitHubURL   string `json:"github_url,omitempty"`   // Support GitHub Enterprise
+	CloneMethod string `json:"clone_method,omitempty"` // Default for -m
+	MaxRetries  int    `json:"max_retries,omitempty"`  // Clone attempts per repository (default: 3)
+	Concurrency int    `json:"concurrency,omitempty"`  // Default for -j
+	Destination string `json:"destination,omitempty"`  // Default for -d/--dest
+	GroupBy     string `json:"group_by,omitempty"`     // Default for --group-by
+	SkipTopic   string `json:"skip_topic,omitempty"`   // Default for --skip-topic
+
+	// Request budget per provider ("github", "gitlab"), shared by all parallel workers
+	RequestsPerSecond     map[string]float64 `json:"requests_per_second,omitempty"`
+	MaxConcurrentRequests int                `json:"max_concurrent_requests,omitempty"`
+
+	// Extra arguments appended to every git clone, before any --git-arg flags
+	GitArgs []string `json:"git_args,omitempty"`
+
+	// Secret scanner run by --scan-report, with {path} and {report} placeholders
+	ScanCommand []string `json:"scan_command,omitempty"`
+
+	// Kind of GitLab token ("personal", "job" or "deploy") and the username of a deploy token
+	GitLabTokenType  string `json:"gitlab_token_type,omitempty"`
+	GitLabDeployUser string `json:"gitlab_deploy_user,omitempty"`
+
+	// Bitbucket Server / Data Center: personal access token, instance URL
+	// and the username HTTPS clones authenticate as together with the token
+	BitbucketServerToken string `json:"bitbucket_server,omitempty"`
+	BitbucketServerURL   string `json:"bitbucket_server_url,omitempty"`
+	BitbucketServerUser  string `json:"bitbucket_server_user,omitempty"`
+
+	// SSH host and port overrides per provider ("gitlab", "github", "bitbucket-server")
+	SSHHosts map[string]SSHOverride `json:"ssh_hosts,omitempty"`
+
+	// Rewrites of clone URLs, e.g. to an internal mirror; the first matching rule applies
+	URLRewrites []URLRewrite `json:"url_rewrites,omitempty"`
+
+	// Remote paths or patterns (acme/platform/*) of repositories synced before all
+	// others; the run is aborted as soon as one of them fails
+	Priority []string `json:"priority,omitempty"`
+}
+
+/*
+SSHOverride replaces the host and port of the SSH clone URLs a provider returns,
+e.g. for instances that serve SSH on another port or host than their web interface.
+Host may also be an alias from ~/.ssh/config. Empty fields keep the original value.
+*/
+type SSHOverride struct {
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+}
+
+/*
+URLRewrite replaces the part of a clone URL matched by a regular expression.
+Replace may refer to capture groups as $1 or ${name}.
+*/
+type URLRewrite struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
 }
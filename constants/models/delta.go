@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+/*
+DeltaReport lists how the repositories on a provider changed since a state snapshot.
+Produced by `reposync diff` for change-audit workflows.
+*/
+type DeltaReport struct {
+	New     []DeltaRepository `json:"new"`
+	Deleted []DeltaRepository `json:"deleted"`
+	Updated []DeltaRepository `json:"updated"` // Repositories with activity after the snapshot
+}
+
+/*
+DeltaRepository is a repository in a delta report, identified by its provider-side path.
+*/
+type DeltaRepository struct {
+	Path             string    `json:"path"`
+	LastActivity     time.Time `json:"last_activity,omitzero"`
+	PreviousActivity time.Time `json:"previous_activity,omitzero"`
+}
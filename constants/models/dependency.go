@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+/*
+DependencyInventory consolidates the dependency manifests found in all repositories of a run.
+*/
+type DependencyInventory struct {
+	Generated    time.Time              `json:"generated"`
+	Repositories []RepositoryDependency `json:"repositories"`
+}
+
+/*
+RepositoryDependency lists the dependency manifests of a single repository.
+*/
+type RepositoryDependency struct {
+	Path      string               `json:"path"`
+	Manifests []DependencyManifest `json:"manifests"`
+}
+
+/*
+DependencyManifest is one manifest file (e.g. go.mod, package.json) and its declared dependencies.
+Path is relative to the repository root.
+*/
+type DependencyManifest struct {
+	Path         string       `json:"path"`
+	Ecosystem    string       `json:"ecosystem"`
+	Dependencies []Dependency `json:"dependencies"`
+	Error        string       `json:"error,omitempty"`
+}
+
+/*
+Dependency is a declared dependency with its version constraint, if any.
+*/
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Scope   string `json:"scope,omitempty"` // e.g. dev, test, indirect
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+/*
+DiagnosticsRecord is one line of the opt-in diagnostics log of a workspace.
+Anonymized by design: it holds counts, error categories and timings only,
+never repository names, paths, URLs, hosts or error messages.
+*/
+type DiagnosticsRecord struct {
+	Time         time.Time      `json:"time"`
+	Provider     string         `json:"provider"`
+	CloneMethod  string         `json:"clone_method"`
+	Concurrency  int            `json:"concurrency"`
+	Repositories int            `json:"repositories"`
+	Failed       int            `json:"failed"`
+	Errors       map[string]int `json:"errors,omitempty"` // Failures by category, e.g. auth or timeout
+	DurationMS   int64          `json:"duration_ms"`
+	MedianRepoMS int64          `json:"median_repository_ms"`
+	SlowestMS    int64          `json:"slowest_repository_ms"`
+}
+
+/*
+DiagnosticsBundle is the report written by "reposync doctor --bundle" for
+attaching to bug reports: the environment reposync runs in and the recent
+diagnostics records of the workspace.
+*/
+type DiagnosticsBundle struct {
+	Created    time.Time           `json:"created"`
+	Version    string              `json:"version"`
+	GoVersion  string              `json:"go_version"`
+	Platform   string              `json:"platform"`
+	GitVersion string              `json:"git_version,omitempty"`
+	Checks     []DiagnosticsCheck  `json:"checks"`
+	Runs       []DiagnosticsRecord `json:"runs"`
+}
+
+/*
+DiagnosticsCheck is the result of one environment check of "reposync doctor".
+*/
+type DiagnosticsCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+/*
+EncryptedConfig is the at-rest form of an encrypted config file.
+The config JSON is an age file, encrypted to a passphrase (scrypt) or to the
+X25519 machine key (KeySource).
+*/
+type EncryptedConfig struct {
+	KeySource  string `json:"key_source"` // passphrase or machine
+	Format     string `json:"format"`     // age
+	Ciphertext string `json:"ciphertext"` // base64
+}
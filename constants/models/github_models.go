@@ -1,12 +1,87 @@
 package models
 
+import "time"
+
 /*
 GitHubRepository represents a GitHub repository with clone information.
 Similar to GitLabRepository but matches GitHub's API response structure,
 providing both clone URLs and repository name for organization.
 */
 type GitHubRepository struct {
-	HTTPSURL string `json:"clone_url"`
-	SSHURL   string `json:"ssh_url"`
-	Name     string `json:"name"`
+	ID            int64     `json:"id"`
+	HTTPSURL      string    `json:"clone_url"`
+	SSHURL        string    `json:"ssh_url"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	DefaultBranch string    `json:"default_branch"`
+	Description   string    `json:"description"`
+	Language      string    `json:"language"`
+	WebURL        string    `json:"html_url"`
+	PushedAt      time.Time `json:"pushed_at"`
+	Visibility    string    `json:"visibility"` // public, private or internal (Enterprise Cloud/Server)
+	Topics        []string  `json:"topics"`
+	Size          int64     `json:"size"` // In KiB, 0 for empty repositories
+	Archived      bool      `json:"archived"`
+	Fork          bool      `json:"fork"`
+	Disabled      bool      `json:"disabled"` // Locked by GitHub, e.g. for a billing or policy issue; cannot be cloned
+}
+
+/*
+GitHubInstallationRepositories is a page of /installation/repositories,
+the repositories a GitHub App installation token was granted.
+*/
+type GitHubInstallationRepositories struct {
+	TotalCount   int                `json:"total_count"`
+	Repositories []GitHubRepository `json:"repositories"`
+}
+
+/*
+GitHubSearchResult is a page of the repository search API.
+GitHub returns at most 1000 results per query; IncompleteResults is set when
+the search timed out on GitHub's side and results may be missing.
+*/
+type GitHubSearchResult struct {
+	TotalCount        int                `json:"total_count"`
+	IncompleteResults bool               `json:"incomplete_results"`
+	Items             []GitHubRepository `json:"items"`
+}
+
+/*
+GitHubCodeSearchResult is a page of the code search API: files matching a query,
+each with the repository it was found in. Like the repository search, it returns
+at most 1000 results per query.
+*/
+type GitHubCodeSearchResult struct {
+	TotalCount        int                    `json:"total_count"`
+	IncompleteResults bool                   `json:"incomplete_results"`
+	Items             []GitHubCodeSearchItem `json:"items"`
+}
+
+/*
+GitHubCodeSearchItem is a file found by the code search.
+*/
+type GitHubCodeSearchItem struct {
+	Path       string `json:"path"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+/*
+GitHubPropertyValues holds the custom property values of one repository of an organization,
+as listed by /orgs/{org}/properties/values.
+*/
+type GitHubPropertyValues struct {
+	RepositoryID       int64                 `json:"repository_id"`
+	RepositoryFullName string                `json:"repository_full_name"`
+	Properties         []GitHubPropertyValue `json:"properties"`
+}
+
+/*
+GitHubPropertyValue is the value of one custom property: a string, a list of
+strings for multi-select properties, or null when the property is not set.
+*/
+type GitHubPropertyValue struct {
+	PropertyName string `json:"property_name"`
+	Value        any    `json:"value"`
 }
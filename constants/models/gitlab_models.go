@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 /*
 GitLabRepository represents a GitLab project with its clone URLs.
 Contains both HTTPS and SSH URLs for cloning, and the repository name
@@ -7,10 +9,31 @@ to maintain directory structure during cloning operations.
 */
 
 type GitLabRepository struct {
-	HTTPSURL string `json:"http_url_to_repo"`
-	SSHURL   string `json:"ssh_url_to_repo"`
-	Name     string `json:"name"`
-	Path     string `json:"path"`
+	ID                  int64                   `json:"id"`
+	HTTPSURL            string                  `json:"http_url_to_repo"`
+	SSHURL              string                  `json:"ssh_url_to_repo"`
+	Name                string                  `json:"name"`
+	Path                string                  `json:"path"`
+	PathWithNamespace   string                  `json:"path_with_namespace"`
+	DefaultBranch       string                  `json:"default_branch"`
+	Description         string                  `json:"description"`
+	WebURL              string                  `json:"web_url"`
+	LastActivityAt      time.Time               `json:"last_activity_at"`
+	Visibility          string                  `json:"visibility"` // public, internal or private
+	Topics              []string                `json:"topics"`
+	EmptyRepo           bool                    `json:"empty_repo"`
+	Archived            bool                    `json:"archived"`
+	ForkedFromProject   *GitLabProjectReference `json:"forked_from_project"`    // Set for forks
+	MarkedForDeletionOn string                  `json:"marked_for_deletion_on"` // Set once the project is scheduled for deletion
+	MarkedForDeletionAt string                  `json:"marked_for_deletion_at"` // Same, before GitLab 16.0
+}
+
+/*
+GitLabProjectReference identifies another project, such as the parent of a fork.
+*/
+type GitLabProjectReference struct {
+	ID                int64  `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
 }
 
 /*
@@ -23,3 +46,15 @@ type GitLabSubgroup struct {
 	Name     string `json:"name"`
 	FullPath string `json:"full_path"`
 }
+
+/*
+GitLabGroup represents the details of a single GitLab group.
+Path names the group's directory while FullPath identifies its place
+in the namespace hierarchy (e.g. parent/child).
+*/
+type GitLabGroup struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	FullPath string `json:"full_path"`
+}
@@ -0,0 +1,52 @@
+package models
+
+/*
+Lockfile records the commit every repository of a workspace has checked out.
+Written by reposync lock as reposync.lock in the workspace root, where it can be
+committed and reviewed; reposync sync --locked checks the same commits out again.
+Repositories are ordered by path, so lockfiles of the same workspace diff cleanly.
+*/
+type Lockfile struct {
+	Repositories []LockedRepository `json:"repositories"`
+}
+
+/*
+LockedRepository is the commit of one repository in a lockfile. Provider and
+RemotePath identify the repository across workspaces, Path is its clone there.
+Branch is the branch that was checked out, empty for a detached HEAD.
+*/
+type LockedRepository struct {
+	Path       string `json:"path"`
+	Provider   string `json:"provider"`
+	RemotePath string `json:"remote_path,omitempty"`
+	WebURL     string `json:"web_url,omitempty"`
+	Commit     string `json:"commit"`
+	Branch     string `json:"branch,omitempty"`
+}
+
+/*
+LockDiff lists how the repositories of two lockfiles differ.
+Produced by reposync lock diff, e.g. for release notes spanning many repositories.
+*/
+type LockDiff struct {
+	Changed []LockChange       `json:"changed"`
+	Added   []LockedRepository `json:"added"`
+	Removed []LockedRepository `json:"removed"`
+}
+
+/*
+LockChange is a repository locked at another commit in the newer lockfile.
+Commits counts the commits only the new commit has, Dropped those only the old one
+has (after a rewind or a force push); both are -1 when no clone has both commits.
+CompareURL is the provider's view of the changes, empty for providers without one.
+*/
+type LockChange struct {
+	Path       string `json:"path"`
+	Provider   string `json:"provider"`
+	RemotePath string `json:"remote_path,omitempty"`
+	OldCommit  string `json:"old_commit"`
+	NewCommit  string `json:"new_commit"`
+	Commits    int    `json:"commits"`
+	Dropped    int    `json:"dropped"`
+	CompareURL string `json:"compare_url,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+/*
+Manifest describes the desired contents of a workspace.
+Stored as .reposync/manifest.json in the workspace root and
+applied to every repository synchronized into that workspace.
+*/
+type Manifest struct {
+	// Git config values (e.g. user.email, commit.gpgsign) set in every clone
+	GitConfig map[string]string `json:"git_config,omitempty"`
+
+	// Repositories of several providers synced together into the workspace
+	Sources []ManifestSource `json:"sources,omitempty"`
+
+	// Delete clones of repositories no source lists anymore (default: only report them)
+	Prune bool `json:"prune,omitempty"`
+
+	// Revisions repositories are checked out at, by remote path (acme/api, or github:acme/api to pick a provider)
+	Pins map[string]ManifestPin `json:"pins,omitempty"`
+}
+
+/*
+ManifestPin pins a repository at a tag, branch or commit. The clone is left at a
+detached HEAD, or with Branch set, on a local branch of that name reset to the revision.
+*/
+type ManifestPin struct {
+	Revision string `json:"revision"`
+	Branch   string `json:"branch,omitempty"`
+}
+
+/*
+ManifestSource is one origin of the repositories of a multi-source workspace:
+a GitLab group or instance, a GitHub organization, a Bitbucket Server project,
+a list of clone URLs (provider "urls"), or the repositories pinned by a repo tool
+manifest (provider "repo-manifest") or a superproject's .gitmodules (provider "gitmodules").
+*/
+type ManifestSource struct {
+	Provider    string   `json:"provider"`
+	Group       string   `json:"group,omitempty"` // GitLab group ID, GitHub organization or Bitbucket Server project key
+	AllProjects bool     `json:"all_projects,omitempty"`
+	Path        string   `json:"path,omitempty"` // Directory of the source in the workspace
+	URLs        []string `json:"urls,omitempty"`
+	File        string   `json:"file,omitempty"` // Repo manifest or .gitmodules, relative to the workspace
+}
+
+/*
+SourceCredentials are the token and instance of a provider in a multi-source sync.
+CloneUsername is the username HTTPS clones pair with the token.
+*/
+type SourceCredentials struct {
+	Token         string
+	BaseURL       string
+	CloneUsername string
+}
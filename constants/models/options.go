@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Kinds of GitLab tokens. Job and deploy tokens can clone but not list groups.
+const (
+	TokenTypePersonal = ""
+	TokenTypeJob      = "job"
+	TokenTypeDeploy   = "deploy"
+)
+
+/*
+SyncOptions holds the settings of a single synchronization run.
+Built from command-line flags and the config file, then handed to the
+provider services so new settings don't require changing every signature.
+*/
+type SyncOptions struct {
+	Token               string
+	TokenType           string // GitLab token kind: TokenTypePersonal (empty), TokenTypeJob or TokenTypeDeploy
+	CloneMethod         string
+	BaseDir             string
+	BaseURL             string
+	Concurrency         int
+	AuditLogPath        string            // Defaults to .reposync/audit.jsonl in the workspace
+	RegistryPath        string            // Machine-wide registry of clones, ~/.reposync/registry.json (empty: none)
+	ShareObjects        bool              // Clone repositories cloned elsewhere on the machine with the objects of that clone
+	NoWrite             bool              // Only report what would change, never touch the filesystem
+	GitArgs             []string          // Extra arguments appended to every git clone
+	GitConfig           map[string]string // Local git config set in every clone (workspace manifest)
+	IndexPath           string            // Write a catalog of the workspace here after the sync (empty: disabled)
+	IndexTemplate       string            // Go template used for the catalog (empty: built-in Markdown table)
+	StaleAfter          time.Duration     // Report repositories without upstream activity for this long (0: disabled)
+	OwnershipReportPath string            // Write a JSON report of CODEOWNERS and approval rules here (empty: disabled)
+	ScanReportPath      string            // Scan every clone for secrets and write the findings here (empty: disabled)
+	ScanCommand         []string          // Scanner invocation with {path} and {report} placeholders (empty: gitleaks)
+
+	DependencyInventoryPath string                 // Collect dependency manifests into this JSON inventory (empty: disabled)
+	Visibility              []string               // Only sync repositories with these visibilities (empty: all)
+	CI                      bool                   // Print timestamped progress lines in collapsible CI log sections
+	ShowGitOutput           bool                   // Stream git's output to the console instead of only the repository logs
+	CommitExportPath        string                 // Export the commits each clone received since the last sync as NDJSON here (empty: disabled)
+	ExportSettings          bool                   // Snapshot repository settings, members and teams into .reposync/settings and .reposync/access.json
+	ExportCIConfig          bool                   // GitLab: snapshot CI/CD variables, pipeline schedules and runners into .reposync/ci
+	IncludeVariableValues   bool                   // Store CI/CD variable values in the snapshots instead of masking them
+	GroupBy                 string                 // Place clones in subdirectories by topic, language or visibility (empty: provider layout)
+	FullPaths               bool                   // GitLab: place projects by path_with_namespace, including the parents of the synced group
+	SkipEmptyGroups         bool                   // GitLab: create no directories for groups without repositories and remove those left empty
+	Properties              map[string][]string    // GitHub: only sync repositories with these custom property values, any of the values per property
+	MaxDepth                int                    // GitLab: number of group levels synced, the synced group being the first (0: all)
+	TrackAllBranches        bool                   // Create a local tracking branch for every remote branch of each clone
+	PruneRefs               bool                   // Fetch existing clones with --prune --prune-tags, deleting branches and tags deleted upstream
+	Pins                    map[string]ManifestPin // Revisions pinned by the workspace manifest, by remote path (nil: none)
+	PinnedOnly              bool                   // Skip repositories without a pin (sync --locked)
+	Remap                   *Remap                 // Overrides of clone locations, consulted while planning (nil: none)
+	Adaptive                bool                   // Resize the worker pool during the run, with Concurrency as the upper bound
+	Order                   string                 // Clone order: size-asc, size-desc, name or activity (empty: as listed)
+	Priority                []string               // Remote paths or path.Match patterns synced first; a failure among them aborts the run
+	MaxFailures             int                    // Abort the run once this many repositories failed (0: never)
+	MaxFailuresPercent      int                    // Abort once this percentage of the repositories being synced failed (0: never)
+	SkipTopic               string                 // Skip repositories with this topic, so owners can opt out of mirroring (empty: none)
+	IncludeInactive         bool                   // Also sync archived repositories and projects pending deletion
+	AcceptNewHostKeys       bool                   // Pin the SSH host keys of new hosts in .reposync/known_hosts before cloning
+	Diagnostics             bool                   // Append anonymized error categories and timings to .reposync/diagnostics.jsonl
+
+	SSHHosts    map[string]SSHOverride // SSH host and port overrides per provider, applied to SSH clone URLs
+	URLRewrites []URLRewrite           // Rewrites of HTTPS and SSH clone URLs, applied after SSHHosts
+
+	Topics      []string  // Only sync repositories with all of these topics (empty: all)
+	Language    string    // Only sync repositories in this programming language (empty: all)
+	ActiveAfter time.Time // Only sync repositories with upstream activity after this time (zero: all)
+	Search      string    // Only sync repositories found by the provider's search for this query (empty: all)
+	CodeSearch  string    // GitHub: only sync repositories with files found by the code search for this query (empty: all)
+
+	DependencyPaths map[string]bool // Only sync the clones at these paths, found in a dependency inventory (nil: all)
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+/*
+OwnershipReport aggregates code ownership across all synchronized repositories.
+Owners maps every owner (user, team or email) to the repositories naming them.
+*/
+type OwnershipReport struct {
+	Generated    time.Time             `json:"generated"`
+	Repositories []RepositoryOwnership `json:"repositories"`
+	Owners       map[string][]string   `json:"owners"`
+}
+
+/*
+RepositoryOwnership lists the ownership rules found in a single repository.
+Source names where they came from (a CODEOWNERS file or GitLab approval rules).
+*/
+type RepositoryOwnership struct {
+	Path   string          `json:"path"`
+	Source string          `json:"source"`
+	Rules  []OwnershipRule `json:"rules"`
+}
+
+/*
+OwnershipRule assigns owners to a file pattern (or a named approval rule).
+*/
+type OwnershipRule struct {
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+}
+
+/*
+GitLabApprovalRule represents a project-level merge request approval rule.
+*/
+type GitLabApprovalRule struct {
+	Name              string `json:"name"`
+	EligibleApprovers []struct {
+		Username string `json:"username"`
+	} `json:"eligible_approvers"`
+	Groups []struct {
+		FullPath string `json:"full_path"`
+	} `json:"groups"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+/*
+Registry is the machine-wide record of what reposync synced on a machine, kept in
+~/.reposync/registry.json so one workspace can find the clones of another and
+reposync workspaces can list and refresh every workspace.
+Clones maps a repository, identified by the host and path of its clone URL
+(e.g. github.com/acme/api), to the clones of it, by absolute path.
+*/
+type Registry struct {
+	Clones     map[string][]string   `json:"clones,omitempty"`
+	Workspaces []RegisteredWorkspace `json:"workspaces,omitempty"`
+}
+
+/*
+RegisteredWorkspace is a sync of a workspace, as last run on the machine.
+A workspace synced from several organizations has one entry per organization.
+Args are the arguments of the sync, relative to Dir, the directory it ran in,
+so reposync workspaces sync-all can run it again exactly.
+*/
+type RegisteredWorkspace struct {
+	Path      string    `json:"path"`
+	Sources   []string  `json:"sources"` // provider:group, e.g. github:acme, or the provider alone
+	LastSync  time.Time `json:"last_sync"`
+	LastError string    `json:"last_error,omitempty"`
+	Args      []string  `json:"args"`
+	Dir       string    `json:"dir"`
+}
@@ -0,0 +1,17 @@
+package models
+
+/*
+Release is a published reposync release as reported by the GitHub releases API.
+*/
+type Release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+/*
+ReleaseAsset is a file attached to a release, such as a binary or the checksums file.
+*/
+type ReleaseAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
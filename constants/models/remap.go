@@ -0,0 +1,14 @@
+package models
+
+/*
+Remap overrides where repositories are cloned in a workspace.
+Stored as .reposync/remap.json in the workspace root and consulted while a sync is
+planned, so long-lived workspaces keep their local layout when upstream changes.
+Repositories maps a remote path (e.g. acme/api, or github:acme/api to pick a provider)
+to a directory; Paths maps a directory prefix to the one used instead
+(e.g. platform/backend → backend). Directories are relative to the sync's base directory.
+*/
+type Remap struct {
+	Repositories map[string]string `json:"repositories,omitempty"`
+	Paths        map[string]string `json:"paths,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "encoding/xml"
+
+/*
+RepoManifest is a manifest of Google's repo tool (default.xml): the remotes
+repositories are fetched from and the projects checked out into a workspace.
+Only the elements and attributes reposync reads or writes are modeled.
+*/
+type RepoManifest struct {
+	XMLName  xml.Name      `xml:"manifest"`
+	Remotes  []RepoRemote  `xml:"remote"`
+	Default  *RepoDefault  `xml:"default"`
+	Projects []RepoProject `xml:"project"`
+}
+
+/*
+RepoRemote is a remote of a repo manifest. Project URLs are Fetch + "/" + project name;
+a relative Fetch (e.g. "..") is resolved against the URL the manifest was fetched from.
+*/
+type RepoRemote struct {
+	Name     string `xml:"name,attr"`
+	Fetch    string `xml:"fetch,attr"`
+	Revision string `xml:"revision,attr,omitempty"` // Default revision of the remote's projects
+}
+
+/*
+RepoDefault holds the remote and revision of projects that don't name their own.
+*/
+type RepoDefault struct {
+	Remote   string `xml:"remote,attr,omitempty"`
+	Revision string `xml:"revision,attr,omitempty"`
+}
+
+/*
+RepoProject is a repository of a repo manifest. Path defaults to Name; Revision is a
+branch, tag or commit, and Upstream names the branch a pinned commit was taken from.
+*/
+type RepoProject struct {
+	Name     string `xml:"name,attr"`
+	Path     string `xml:"path,attr,omitempty"`
+	Remote   string `xml:"remote,attr,omitempty"`
+	Revision string `xml:"revision,attr,omitempty"`
+	Upstream string `xml:"upstream,attr,omitempty"`
+}
+
+/*
+Submodule is a submodule declared in the .gitmodules file of a superproject.
+Commit is the commit the superproject pins it at, empty when it is not known.
+*/
+type Submodule struct {
+	Name   string
+	Path   string
+	URL    string
+	Commit string
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+/*
+Repository is a repository as reposync sees it, whatever the provider.
+Provider listings are converted into it once, so filtering, layout, state
+and reporting work on a single shape instead of one per provider API.
+Path is the last segment of the repository's URL path and Namespace the
+group, organization or project key holding it.
+*/
+type Repository struct {
+	ID              int64
+	Name            string
+	Path            string
+	Namespace       string
+	HTTPSURL        string
+	SSHURL          string
+	WebURL          string
+	DefaultBranch   string
+	Description     string
+	Language        string
+	LastActivity    time.Time
+	Size            int64 // In bytes, 0 when unknown
+	Empty           bool  // The provider reports no commits
+	Archived        bool
+	Disabled        bool // Locked by the provider, cannot be cloned
+	PendingDeletion bool
+	Fork            bool
+	Topics          []string
+	Visibility      string // public, internal or private
+}
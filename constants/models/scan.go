@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+/*
+ScanReport aggregates the findings of the secret scanner over all repositories of a run.
+*/
+type ScanReport struct {
+	Generated    time.Time        `json:"generated"`
+	Command      []string         `json:"command"`
+	Repositories []RepositoryScan `json:"repositories"`
+}
+
+/*
+RepositoryScan is the scanner result for a single repository.
+Findings holds the scanner's own JSON records unchanged.
+*/
+type RepositoryScan struct {
+	Path     string            `json:"path"`
+	ExitCode int               `json:"exit_code"`
+	Findings []json.RawMessage `json:"findings"`
+	Error    string            `json:"error,omitempty"`
+}
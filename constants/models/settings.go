@@ -0,0 +1,76 @@
+package models
+
+/*
+RepositorySettings is the configuration snapshot of a single repository.
+Sections hold the provider's API responses with volatile fields (counters,
+timestamps) removed, so snapshots of successive runs can be diffed.
+Sections that could not be read, usually for lack of admin rights, are
+left empty and the reason is recorded in Errors.
+*/
+type RepositorySettings struct {
+	Provider      string `json:"provider"`
+	RemotePath    string `json:"remote_path"`
+	DefaultBranch string `json:"default_branch"`
+
+	Repository       map[string]any    `json:"repository"`        // Repository or project settings, including merge settings
+	BranchProtection map[string]any    `json:"branch_protection"` // Protection rules by branch name
+	Webhooks         []map[string]any  `json:"webhooks"`
+	Members          []AccessMember    `json:"members"`          // Direct collaborators or project members
+	Errors           map[string]string `json:"errors,omitempty"` // Section name → why it is missing
+}
+
+/*
+AccessSnapshot records who has access to a GitHub organization or GitLab group.
+Teams are GitHub teams or GitLab subgroups; access through them complements the
+direct members recorded in every repository's settings snapshot.
+*/
+type AccessSnapshot struct {
+	Provider  string            `json:"provider"`
+	Namespace string            `json:"namespace"` // Organization or root group path
+	Members   []AccessMember    `json:"members"`
+	Teams     []AccessTeam      `json:"teams"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+/*
+AccessMember is a user with their role, e.g. admin or member on GitHub
+and the access level (developer, maintainer, ...) on GitLab.
+*/
+type AccessMember struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+/*
+AccessTeam is a team or subgroup with its members and the repositories it can access.
+*/
+type AccessTeam struct {
+	Name         string                 `json:"name"`
+	Path         string                 `json:"path"` // GitHub team slug or GitLab subgroup full path
+	Parent       string                 `json:"parent,omitempty"`
+	Members      []AccessMember         `json:"members"`
+	Repositories []RepositoryPermission `json:"repositories,omitempty"`
+}
+
+/*
+RepositoryPermission grants a team a permission on a repository.
+*/
+type RepositoryPermission struct {
+	Repository string `json:"repository"`
+	Permission string `json:"permission"`
+}
+
+/*
+CIConfigSnapshot is the CI/CD configuration of a GitLab project or group.
+Variable values are masked unless ValuesIncluded is set; pipeline schedules
+only exist on projects. Sections that could not be read are left empty and
+the reason is recorded in Errors.
+*/
+type CIConfigSnapshot struct {
+	Path              string            `json:"path"` // Project or group full path
+	ValuesIncluded    bool              `json:"values_included"`
+	Variables         []map[string]any  `json:"variables"`
+	PipelineSchedules []map[string]any  `json:"pipeline_schedules,omitempty"`
+	Runners           []map[string]any  `json:"runners"`
+	Errors            map[string]string `json:"errors,omitempty"`
+}
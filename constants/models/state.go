@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+/*
+State records what reposync knows about a workspace between runs.
+Stored as .reposync/state.json in the workspace root and keyed by
+provider repository ID, so renamed or moved repositories can be
+recognised on later runs instead of being cloned a second time.
+*/
+type State struct {
+	Repositories map[string]RepositoryState `json:"repositories"`
+}
+
+/*
+RepositoryState describes a single synchronized repository.
+Path is relative to the workspace root to keep the state file portable,
+while RemotePath is the provider-side location (e.g. group/subgroup/project).
+*/
+type RepositoryState struct {
+	Provider   string    `json:"provider"`
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	RemotePath string    `json:"remote_path,omitempty"`
+	LastSynced time.Time `json:"last_synced"`
+
+	// Descriptive metadata as reported by the provider, used for the workspace index
+	Description  string    `json:"description,omitempty"`
+	Language     string    `json:"language,omitempty"`
+	WebURL       string    `json:"web_url,omitempty"`
+	LastActivity time.Time `json:"last_activity,omitzero"`
+	Visibility   string    `json:"visibility,omitempty"`
+	Topics       []string  `json:"topics,omitempty"`
+
+	// Cost of the last sync, kept to spot repositories that got slower across runs
+	SyncDurationMS int64 `json:"sync_duration_ms,omitempty"`
+	SizeBytes      int64 `json:"size_bytes,omitempty"`
+
+	// Newest commit written to the commit activity export, the next export starts after it
+	ExportedCommit string `json:"exported_commit,omitempty"`
+}
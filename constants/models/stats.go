@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+/*
+WorkspaceStats holds aggregate statistics over the repositories of a workspace.
+Computed by `reposync stats` from the state file and the clones on disk.
+*/
+type WorkspaceStats struct {
+	TotalRepositories int               `json:"total_repositories"`
+	MissingClones     int               `json:"missing_clones"`
+	TotalSizeBytes    int64             `json:"total_size_bytes"`
+	Languages         map[string]int    `json:"languages"`
+	StaleAfterDays    int               `json:"stale_after_days"`
+	Stale             []StaleRepository `json:"stale"`
+}
+
+/*
+StaleRepository is a clone whose last commit is older than the stale threshold.
+*/
+type StaleRepository struct {
+	Path       string    `json:"path"`
+	LastCommit time.Time `json:"last_commit"`
+}
@@ -0,0 +1,18 @@
+package models
+
+/*
+WorkspaceConfig holds per-workspace settings stored in .reposync/config.
+Overrides the global config so a workspace remembers what it mirrors
+and can be synced without flags; command-line flags still win.
+*/
+type WorkspaceConfig struct {
+	Provider    string   `json:"provider,omitempty"`
+	Group       string   `json:"group,omitempty"` // GitLab group ID or GitHub organization
+	AllProjects bool     `json:"all_projects,omitempty"`
+	Visibility  []string `json:"visibility,omitempty"`
+	CloneMethod string   `json:"clone_method,omitempty"`
+	Concurrency int      `json:"concurrency,omitempty"`
+	GroupBy     string   `json:"group_by,omitempty"`
+	FullPaths   bool     `json:"full_paths,omitempty"`
+	RootName    string   `json:"root_name,omitempty"`
+}
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+AuditLog appends records of filesystem mutations to a JSONL file.
+The file is opened in append-only mode and never rewritten, so it can serve
+as a trail on shared backup servers. A nil *AuditLog discards all records.
+*/
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+/*
+GetAuditLogPath returns the default audit log location for a workspace root.
+*/
+func GetAuditLogPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "audit.jsonl")
+}
+
+/*
+OpenAuditLog opens (or creates) the audit log at the given path for appending.
+*/
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := ensureWritable(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{file: file}, nil
+}
+
+/*
+Record appends one mutation with its outcome.
+A non-nil err marks the entry as failed and stores the error message;
+secrets are redacted from details and errors.
+Write failures are reported on stderr but never interrupt the sync.
+*/
+func (a *AuditLog) Record(action, path, detail string, err error) {
+	if a == nil {
+		return
+	}
+
+	entry := models.AuditEntry{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Path:    path,
+		Detail:  Redact(detail),
+		Outcome: "success",
+	}
+	if err != nil {
+		entry.Outcome = "failure"
+		entry.Error = Redact(err.Error())
+	}
+
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, writeErr := a.file.Write(append(data, '\n')); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to write audit log: %v\n", writeErr)
+	}
+}
+
+/*
+Close flushes and closes the audit log file.
+*/
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ways AnonymizeEmails rewrites the email addresses in a repository's history.
+const (
+	AnonymizeStrip = "strip" // Remove the addresses, leaving "Name <>"
+	AnonymizeHash  = "hash"  // Replace each address by a hash of it
+)
+
+// Hashed addresses are <first hex digits of the hash>@anonymized.invalid; .invalid never resolves.
+const (
+	anonymizedHashLength = 16
+	anonymizedDomain     = "anonymized.invalid"
+)
+
+/*
+CreateBundle writes the branches, tags and remote-tracking branches of a repository,
+and its HEAD, to a git bundle that can be cloned like a remote (git clone <file>).
+Other refs, such as the originals git filter-branch keeps, are left out.
+*/
+func CreateBundle(runner GitRunner, repoPath, bundlePath string) error {
+	if err := EnsureDirectory(filepath.Dir(bundlePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", bundlePath, err)
+	}
+	return gitRun(runner, repoPath, "bundle", "create", bundlePath, "HEAD", "--branches", "--tags", "--remotes")
+}
+
+/*
+AnonymizeEmails rewrites the history of a repository so it no longer holds the email
+addresses of authors, committers and taggers. AnonymizeStrip removes them; AnonymizeHash
+replaces each by a hash of the lower-cased address, the same in every repository, so the
+commits of one person can still be told apart. Names are kept.
+Meant for a scratch copy (git clone --mirror) that is bundled afterwards: commit and tag
+hashes change, signatures are dropped, and the refs to the original history are deleted.
+*/
+func AnonymizeEmails(runner GitRunner, repoPath, mode string) error {
+	if err := ensureWritable(repoPath); err != nil {
+		return err
+	}
+	var filter string
+	switch mode {
+	case AnonymizeStrip:
+		filter = "GIT_AUTHOR_EMAIL=; GIT_COMMITTER_EMAIL="
+	case AnonymizeHash:
+		// Must compute the same value as anonymizedEmail
+		hash := func(variable string) string {
+			return fmt.Sprintf(`%s=$(printf %%s "$%s" | tr A-Z a-z | git hash-object --stdin | cut -c1-%d)@%s`,
+				variable, variable, anonymizedHashLength, anonymizedDomain)
+		}
+		filter = hash("GIT_AUTHOR_EMAIL") + "; " + hash("GIT_COMMITTER_EMAIL")
+	default:
+		return fmt.Errorf("unknown anonymization %q: use %s or %s", mode, AnonymizeStrip, AnonymizeHash)
+	}
+
+	if err := gitRun(runner, repoPath, "filter-branch", "-f", "--env-filter", filter, "--tag-name-filter", "cat", "--", "--branches", "--tags", "--remotes"); err != nil {
+		return err
+	}
+	originals, err := gitOutput(runner, repoPath, "for-each-ref", "--format=%(refname)", "refs/original/")
+	if err != nil {
+		return fmt.Errorf("failed to list the refs of the original history: %w", err)
+	}
+	for _, ref := range strings.Fields(originals) {
+		if err := gitRun(runner, repoPath, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return anonymizeTaggers(runner, repoPath, mode)
+}
+
+/*
+anonymizeTaggers rewrites the annotated tags of a repository with anonymized tagger addresses.
+git filter-branch points them at the rewritten commits but copies the tagger line as is.
+*/
+func anonymizeTaggers(runner GitRunner, repoPath, mode string) error {
+	tags, err := gitOutput(runner, repoPath, "for-each-ref", "--format=%(objecttype) %(refname)", "refs/tags/")
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	for _, line := range strings.Split(tags, "\n") {
+		objectType, ref, _ := strings.Cut(line, " ")
+		if objectType != "tag" {
+			continue
+		}
+		content, err := gitOutput(runner, repoPath, "cat-file", "tag", ref)
+		if err != nil {
+			return fmt.Errorf("failed to read tag %s: %w", ref, err)
+		}
+
+		file, err := os.CreateTemp("", "reposync-tag-")
+		if err != nil {
+			return err
+		}
+		_, err = file.WriteString(anonymizeTagObject(content, mode))
+		file.Close()
+		var hash string
+		if err == nil {
+			hash, err = gitOutput(runner, repoPath, "hash-object", "-t", "tag", "-w", file.Name())
+		}
+		os.Remove(file.Name())
+		if err != nil {
+			return fmt.Errorf("failed to write tag %s: %w", ref, err)
+		}
+		if err := gitRun(runner, repoPath, "update-ref", ref, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+anonymizeTagObject anonymizes the tagger address of a tag object as printed by
+git cat-file (without its trailing newline) and drops its signature, which the
+change invalidates.
+*/
+func anonymizeTagObject(content, mode string) string {
+	header, message, hasMessage := strings.Cut(content, "\n\n")
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "tagger ") {
+			continue
+		}
+		start, end := strings.Index(line, "<"), strings.Index(line, ">")
+		if start >= 0 && end > start {
+			lines[i] = line[:start+1] + anonymizedEmail(line[start+1:end], mode) + line[end:]
+		}
+	}
+	for _, marker := range []string{"-----BEGIN PGP SIGNATURE-----", "-----BEGIN SSH SIGNATURE-----"} {
+		if i := strings.Index(message, marker); i >= 0 {
+			message = message[:i]
+		}
+	}
+	if !hasMessage {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	return strings.Join(lines, "\n") + "\n\n" + strings.TrimRight(message, "\n") + "\n"
+}
+
+/*
+anonymizedEmail returns the address an email address is replaced with: nothing for
+AnonymizeStrip, and for AnonymizeHash the blob hash git hash-object computes for the
+address lower-cased, like the filter of AnonymizeEmails does with tr A-Z a-z.
+*/
+func anonymizedEmail(email, mode string) string {
+	if mode == AnonymizeStrip {
+		return ""
+	}
+	email = strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, email)
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(email), email)))
+	return hex.EncodeToString(sum[:])[:anonymizedHashLength] + "@" + anonymizedDomain
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import "testing"
+
+func TestAnonymizeTagObject(t *testing.T) {
+	header := "object 6f25113ffc520445bed6f80df35539bed27c9243\ntype commit\ntag v1.0\n"
+	tests := []struct {
+		name    string
+		content string
+		mode    string
+		want    string
+	}{
+		{
+			"strip",
+			header + "tagger Jane Doe <Jane@Example.com> 1792182822 +0200\n\nRelease 1.0",
+			AnonymizeStrip,
+			header + "tagger Jane Doe <> 1792182822 +0200\n\nRelease 1.0\n",
+		},
+		{
+			"hash of the lower-cased address",
+			header + "tagger Jane Doe <Jane@Example.com> 1792182822 +0200\n\nRelease 1.0",
+			AnonymizeHash,
+			header + "tagger Jane Doe <" + anonymizedEmail("jane@example.com", AnonymizeHash) + "> 1792182822 +0200\n\nRelease 1.0\n",
+		},
+		{
+			"signature dropped",
+			header + "tagger Jane Doe <jane@example.com> 1792182822 +0200\n\nRelease 1.0\n-----BEGIN PGP SIGNATURE-----\n\niQEz\n-----END PGP SIGNATURE-----",
+			AnonymizeStrip,
+			header + "tagger Jane Doe <> 1792182822 +0200\n\nRelease 1.0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anonymizeTagObject(tt.content, tt.mode); got != tt.want {
+				t.Errorf("anonymizeTagObject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+CILog writes timestamped, non-interactive progress lines for CI pipelines.
+Phases of a sync are wrapped in collapsible sections when running on
+GitHub Actions or GitLab CI, so logs of large syncs stay readable.
+A nil *CILog writes nothing.
+*/
+type CILog struct {
+	mu     sync.Mutex
+	out    io.Writer
+	flavor string // "github", "gitlab" or empty when no section markers are known
+	now    func() time.Time
+}
+
+/*
+NewCILog creates a CI log writing to out.
+The CI system is detected from GITHUB_ACTIONS and GITLAB_CI; elsewhere
+lines are timestamped but sections are only marked by a header line.
+*/
+func NewCILog(out io.Writer) *CILog {
+	flavor := ""
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		flavor = "github"
+	case os.Getenv("GITLAB_CI") == "true":
+		flavor = "gitlab"
+	}
+	return &CILog{out: out, flavor: flavor, now: time.Now}
+}
+
+/*
+StartSection opens a collapsible section.
+name identifies the section for GitLab (letters, digits, '_', '.' and '-'),
+title is the header shown in the log.
+*/
+func (l *CILog) StartSection(name, title string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch l.flavor {
+	case "github":
+		fmt.Fprintf(l.out, "::group::%s\n", title)
+	case "gitlab":
+		fmt.Fprintf(l.out, "\033[0Ksection_start:%d:%s\r\033[0K%s\n", l.now().Unix(), name, title)
+	default:
+		fmt.Fprintf(l.out, "%s == %s ==\n", l.timestamp(), title)
+	}
+}
+
+/*
+EndSection closes the section opened with the same name.
+*/
+func (l *CILog) EndSection(name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch l.flavor {
+	case "github":
+		fmt.Fprintln(l.out, "::endgroup::")
+	case "gitlab":
+		fmt.Fprintf(l.out, "\033[0Ksection_end:%d:%s\r\033[0K\n", l.now().Unix(), name)
+	}
+}
+
+/*
+Printf writes a single line prefixed with the current UTC time.
+*/
+func (l *CILog) Printf(format string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "%s %s\n", l.timestamp(), fmt.Sprintf(format, args...))
+}
+
+/*
+timestamp formats the current time for log lines.
+*/
+func (l *CILog) timestamp() string {
+	return "[" + l.now().UTC().Format("15:04:05") + "]"
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCILog(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{"github actions", "GITHUB_ACTIONS", "::group::Syncing 2 repositories\n[09:30:00] [1/2] acme/api\n::endgroup::\n"},
+		{"gitlab ci", "GITLAB_CI", "\033[0Ksection_start:1767259800:sync\r\033[0KSyncing 2 repositories\n[09:30:00] [1/2] acme/api\n\033[0Ksection_end:1767259800:sync\r\033[0K\n"},
+		{"other", "", "[09:30:00] == Syncing 2 repositories ==\n[09:30:00] [1/2] acme/api\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_ACTIONS", "")
+			t.Setenv("GITLAB_CI", "")
+			if tt.env != "" {
+				t.Setenv(tt.env, "true")
+			}
+
+			var out bytes.Buffer
+			log := NewCILog(&out)
+			log.now = func() time.Time { return time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC) }
+
+			log.StartSection("sync", "Syncing 2 repositories")
+			log.Printf("[%d/%d] %s", 1, 2, "acme/api")
+			log.EndSection("sync")
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("CILog wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var nilLog *CILog
+	nilLog.Printf("ignored") // Must not panic
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+RewriteSSHURL applies an SSH host and port override to an SSH clone URL, scp-like
+(git@host:path) or ssh://. URLs with a port other than 22 are written as ssh://,
+since the scp-like form cannot carry a port. Other URLs are returned unchanged.
+*/
+func RewriteSSHURL(sshURL string, override models.SSHOverride) string {
+	if override.Host == "" && override.Port == 0 {
+		return sshURL
+	}
+
+	var user, host, port, repoPath string
+	if parsed, err := url.Parse(sshURL); err == nil && parsed.Scheme != "" {
+		if parsed.Scheme != "ssh" {
+			return sshURL
+		}
+		user, host, port, repoPath = parsed.User.Username(), parsed.Hostname(), parsed.Port(), strings.TrimPrefix(parsed.Path, "/")
+	} else if at, rest, found := strings.Cut(sshURL, "@"); found && !strings.Contains(at, "/") {
+		user = at
+		host, repoPath, _ = strings.Cut(rest, ":")
+	} else {
+		return sshURL
+	}
+
+	if override.Host != "" {
+		host = override.Host
+	}
+	if override.Port != 0 {
+		port = strconv.Itoa(override.Port)
+	}
+	if user != "" {
+		user += "@"
+	}
+	if port == "" || port == "22" {
+		return user + host + ":" + repoPath
+	}
+	return fmt.Sprintf("ssh://%s%s:%s/%s", user, host, port, repoPath)
+}
+
+/*
+CompiledURLRewrite is a URLRewrite with its regular expression compiled.
+*/
+type CompiledURLRewrite struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+/*
+CompileURLRewrites compiles the url_rewrites section of the config file,
+failing on the first invalid regular expression.
+*/
+func CompileURLRewrites(rewrites []models.URLRewrite) ([]CompiledURLRewrite, error) {
+	compiled := make([]CompiledURLRewrite, 0, len(rewrites))
+	for i, rewrite := range rewrites {
+		if rewrite.Match == "" {
+			return nil, fmt.Errorf("invalid url_rewrites rule %d: match is empty", i+1)
+		}
+		pattern, err := regexp.Compile(rewrite.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url_rewrites rule %d: %w", i+1, err)
+		}
+		compiled = append(compiled, CompiledURLRewrite{pattern: pattern, replace: rewrite.Replace})
+	}
+	return compiled, nil
+}
+
+/*
+RewriteURL applies the first rewrite whose pattern matches a clone URL.
+URLs matched by no rule are returned unchanged.
+*/
+func RewriteURL(cloneURL string, rewrites []CompiledURLRewrite) string {
+	for _, rewrite := range rewrites {
+		if rewrite.pattern.MatchString(cloneURL) {
+			return rewrite.pattern.ReplaceAllString(cloneURL, rewrite.replace)
+		}
+	}
+	return cloneURL
+}
+
+/*
+ValidateSSHOverrides checks the ssh_hosts section of the config file.
+*/
+func ValidateSSHOverrides(overrides map[string]models.SSHOverride) error {
+	for provider, override := range overrides {
+		switch provider {
+		case "gitlab", "github", "bitbucket-server":
+		default:
+			return fmt.Errorf("invalid ssh_hosts entry %q: use gitlab, github or bitbucket-server", provider)
+		}
+		if override.Port < 0 || override.Port > 65535 {
+			return fmt.Errorf("invalid ssh_hosts port %d for %s", override.Port, provider)
+		}
+		if strings.ContainsAny(override.Host, "@:/ ") {
+			return fmt.Errorf("invalid ssh_hosts host %q for %s: give the host name or ssh alias only", override.Host, provider)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestRewriteSSHURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		override models.SSHOverride
+		want     string
+	}{
+		{"port", "git@gitlab.example.com:group/api.git", models.SSHOverride{Port: 2222}, "ssh://git@gitlab.example.com:2222/group/api.git"},
+		{"host alias", "git@gitlab.example.com:group/api.git", models.SSHOverride{Host: "gitlab-work"}, "git@gitlab-work:group/api.git"},
+		{"host and port", "ssh://git@bitbucket.example.com:7999/proj/api.git", models.SSHOverride{Host: "ssh.example.com", Port: 7998}, "ssh://git@ssh.example.com:7998/proj/api.git"},
+		{"keeps port", "ssh://git@bitbucket.example.com:7999/proj/api.git", models.SSHOverride{Host: "ssh.example.com"}, "ssh://git@ssh.example.com:7999/proj/api.git"},
+		{"default port", "ssh://git@gitlab.example.com:2222/group/api.git", models.SSHOverride{Port: 22}, "git@gitlab.example.com:group/api.git"},
+		{"no override", "git@gitlab.example.com:group/api.git", models.SSHOverride{}, "git@gitlab.example.com:group/api.git"},
+		{"https", "https://gitlab.example.com/group/api.git", models.SSHOverride{Port: 2222}, "https://gitlab.example.com/group/api.git"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RewriteSSHURL(tt.url, tt.override); got != tt.want {
+				t.Errorf("RewriteSSHURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteURL(t *testing.T) {
+	rewrites, err := CompileURLRewrites([]models.URLRewrite{
+		{Match: `^https://github\.com/`, Replace: "https://mirror.example.com/github/"},
+		{Match: `^git@([^:]+):(.*)$`, Replace: "ssh://git@$1:2222/$2"},
+		{Match: `^https://`, Replace: "https://unused/"},
+	})
+	if err != nil {
+		t.Fatalf("CompileURLRewrites() error = %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/acme/api.git", "https://mirror.example.com/github/acme/api.git"},
+		{"git@gitlab.example.com:group/api.git", "ssh://git@gitlab.example.com:2222/group/api.git"},
+		{"ssh://git@gitlab.example.com/group/api.git", "ssh://git@gitlab.example.com/group/api.git"},
+	}
+	for _, tt := range tests {
+		if got := RewriteURL(tt.url, rewrites); got != tt.want {
+			t.Errorf("RewriteURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []models.URLRewrite{{Match: ""}, {Match: "(unclosed"}} {
+		if _, err := CompileURLRewrites([]models.URLRewrite{invalid}); err == nil {
+			t.Errorf("CompileURLRewrites(%q) succeeded, want an error", invalid.Match)
+		}
+	}
+}
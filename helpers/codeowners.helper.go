@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// codeownersLocations are the places GitHub and GitLab look for a CODEOWNERS file, in order.
+var codeownersLocations = []string{
+	"CODEOWNERS",
+	filepath.Join(".github", "CODEOWNERS"),
+	filepath.Join(".gitlab", "CODEOWNERS"),
+	filepath.Join("docs", "CODEOWNERS"),
+}
+
+/*
+ReadCodeowners finds and parses the CODEOWNERS file of a clone.
+Returns the file's path relative to the clone, or an empty path if there is none.
+*/
+func ReadCodeowners(repoPath string) (string, []models.OwnershipRule, error) {
+	for _, location := range codeownersLocations {
+		file, err := os.Open(filepath.Join(repoPath, location))
+		if err != nil {
+			continue
+		}
+		rules, err := ParseCodeowners(file)
+		file.Close()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse %s: %w", location, err)
+		}
+		return filepath.ToSlash(location), rules, nil
+	}
+	return "", nil, nil
+}
+
+/*
+ParseCodeowners reads CODEOWNERS rules: a file pattern followed by its owners.
+Comments, blank lines and GitLab section headers ([Section]) are skipped;
+patterns without owners are kept, as they explicitly remove ownership.
+*/
+func ParseCodeowners(r io.Reader) ([]models.OwnershipRule, error) {
+	var rules []models.OwnershipRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		if comment := strings.Index(line, " #"); comment >= 0 {
+			line = line[:comment]
+		}
+
+		fields := strings.Fields(line)
+		rules = append(rules, models.OwnershipRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestParseCodeowners(t *testing.T) {
+	input := `# Global owners
+*       @acme/platform
+
+[Frontend]
+/web/   @jane jane@example.com # UI team
+/vendor/
+`
+	want := []models.OwnershipRule{
+		{Pattern: "*", Owners: []string{"@acme/platform"}},
+		{Pattern: "/web/", Owners: []string{"@jane", "jane@example.com"}},
+		{Pattern: "/vendor/", Owners: []string{}},
+	}
+
+	got, err := ParseCodeowners(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCodeowners() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCodeowners() = %v, want %v", got, want)
+	}
+}
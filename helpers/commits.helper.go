@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// Commits are separated by a record separator and fields by a unit separator,
+// so multi-line commit messages can be split reliably.
+const commitLogFormat = "--format=%x1e%H%x1f%an%x1f%ae%x1f%aI%x1f%B"
+
+/*
+FetchOrigin updates the remote-tracking branches of a clone.
+The working tree and local branches are left untouched.
+*/
+func FetchOrigin(runner GitRunner, repoPath string) error {
+	if err := ensureWritable(repoPath); err != nil {
+		return err
+	}
+	return gitRun(runner, repoPath, "fetch", "origin", "--prune", "--quiet")
+}
+
+/*
+ResolveCommit returns the commit hash a revision points to.
+Fails when the revision does not exist in the clone, e.g. after a force push.
+*/
+func ResolveCommit(runner GitRunner, repoPath, revision string) (string, error) {
+	hash, err := gitOutput(runner, repoPath, "rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s in %s", revision, repoPath)
+	}
+	return hash, nil
+}
+
+/*
+ReadCommitLog lists the commits selected by the given git log arguments, newest first.
+Only the metadata is read: hash, author, author date and the full message.
+*/
+func ReadCommitLog(runner GitRunner, repoPath string, args ...string) ([]models.CommitRecord, error) {
+	out, err := gitOutput(runner, repoPath, append([]string{"log", commitLogFormat}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit log of %s: %w", repoPath, err)
+	}
+	return parseCommitLog(out)
+}
+
+/*
+parseCommitLog splits git log output written with commitLogFormat into records.
+*/
+func parseCommitLog(out string) ([]models.CommitRecord, error) {
+	var commits []models.CommitRecord
+	for _, entry := range strings.Split(out, "\x1e") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, "\x1f", 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected git log entry %q", entry)
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected commit date %q: %w", fields[3], err)
+		}
+		commits = append(commits, models.CommitRecord{
+			Hash:        fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Date:        date,
+			Message:     strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
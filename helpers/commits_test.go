@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCommitLog(t *testing.T) {
+	out := "\x1e9fceb02\x1fJane Doe\x1fjane@example.com\x1f2026-10-02T14:03:11+02:00\x1fAdd rate limiting\n\nCloses #12\n" +
+		"\x1e3b18e51\x1fJohn Roe\x1fjohn@example.com\x1f2026-10-01T09:00:00Z\x1fInitial commit\n"
+
+	commits, err := parseCommitLog(out)
+	if err != nil {
+		t.Fatalf("parseCommitLog() error = %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("parseCommitLog() returned %d commits, want 2", len(commits))
+	}
+	if commits[0].Hash != "9fceb02" || commits[0].AuthorEmail != "jane@example.com" {
+		t.Errorf("first commit = %+v", commits[0])
+	}
+	if commits[0].Message != "Add rate limiting\n\nCloses #12" {
+		t.Errorf("message = %q, want the full message without trailing newlines", commits[0].Message)
+	}
+	if want := time.Date(2026, 10, 2, 12, 3, 11, 0, time.UTC); !commits[0].Date.Equal(want) {
+		t.Errorf("date = %s, want %s", commits[0].Date, want)
+	}
+
+	if _, err := parseCommitLog("\x1etruncated\x1fentry"); err == nil {
+		t.Error("parseCommitLog() should fail on a truncated entry")
+	}
+}
@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// configFormat marks config files encrypted with age.
+const configFormat = "age"
+
+// The machine key is kept in the OS keyring under this service and user.
+const (
+	machineKeyService = "reposync"
+	machineKeyUser    = "machine-key"
+)
+
+/*
+EncryptConfig encrypts config file contents with age: to a passphrase (scrypt) for
+the passphrase key source, or to the X25519 identity in secret for the machine key.
+*/
+func EncryptConfig(plaintext, secret []byte, keySource string) (*models.EncryptedConfig, error) {
+	recipient, err := configRecipient(secret, keySource)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed bytes.Buffer
+	w, err := age.Encrypt(&sealed, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt config: %w", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to encrypt config: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt config: %w", err)
+	}
+
+	return &models.EncryptedConfig{
+		KeySource:  keySource,
+		Format:     configFormat,
+		Ciphertext: base64.StdEncoding.EncodeToString(sealed.Bytes()),
+	}, nil
+}
+
+/*
+DecryptConfig opens an encrypted config file in memory.
+A wrong passphrase or key and any tampering with the file fail authentication.
+*/
+func DecryptConfig(encrypted *models.EncryptedConfig, secret []byte) ([]byte, error) {
+	if encrypted.Format != configFormat {
+		return nil, fmt.Errorf("unsupported config encryption %q", encrypted.Format)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	identity, err := configIdentity(secret, encrypted.KeySource)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config: wrong passphrase or key: %w", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config: %w", err)
+	}
+	return plaintext, nil
+}
+
+/*
+configRecipient returns the age recipient a config file is encrypted to.
+*/
+func configRecipient(secret []byte, keySource string) (age.Recipient, error) {
+	if keySource != "machine" {
+		return age.NewScryptRecipient(string(secret))
+	}
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(string(secret)))
+	if err != nil {
+		return nil, fmt.Errorf("the machine key is not an age identity: %w", err)
+	}
+	return identity.Recipient(), nil
+}
+
+/*
+configIdentity returns the age identity that opens a config file.
+*/
+func configIdentity(secret []byte, keySource string) (age.Identity, error) {
+	if keySource != "machine" {
+		return age.NewScryptIdentity(string(secret))
+	}
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(string(secret)))
+	if err != nil {
+		return nil, fmt.Errorf("the machine key is not an age identity: %w", err)
+	}
+	return identity, nil
+}
+
+/*
+LoadMachineKey returns the machine key used for --machine-key encryption, an age X25519
+identity, generating one on first use when create is set. The key is kept in the OS keyring,
+or in the file REPOSYNC_KEY_FILE names, e.g. on a separate volume from the config file;
+it is never written next to the config file.
+*/
+func LoadMachineKey(create bool) ([]byte, error) {
+	if path := os.Getenv("REPOSYNC_KEY_FILE"); path != "" {
+		return loadMachineKeyFile(path, create)
+	}
+
+	key, err := keyring.Get(machineKeyService, machineKeyUser)
+	if err == nil {
+		return []byte(key), nil
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read the machine key from the OS keyring (%v): set REPOSYNC_KEY_FILE to keep it in a file instead", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("no machine key in the OS keyring: set REPOSYNC_KEY_FILE if it is kept in a file")
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate machine key: %w", err)
+	}
+	if err := keyring.Set(machineKeyService, machineKeyUser, identity.String()); err != nil {
+		return nil, fmt.Errorf("failed to store the machine key in the OS keyring (%v): set REPOSYNC_KEY_FILE to keep it in a file instead", err)
+	}
+	return []byte(identity.String()), nil
+}
+
+/*
+loadMachineKeyFile reads the machine key from an explicitly configured file,
+generating a new identity when the file is missing and create is set.
+*/
+func loadMachineKeyFile(path string, create bool) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read machine key %s: %w", path, err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate machine key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write machine key: %w", err)
+	}
+	return []byte(identity.String()), nil
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+)
+
+func TestEncryptConfigRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"gitlab": "glpat-secret"}`)
+	machineKey, otherKey := newTestIdentity(t), newTestIdentity(t)
+
+	tests := []struct {
+		name      string
+		keySource string
+		secret    []byte
+		wrong     []byte
+	}{
+		{"passphrase", "passphrase", []byte("correct horse"), []byte("wrong")},
+		{"machine key", "machine", machineKey, otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := EncryptConfig(plaintext, tt.secret, tt.keySource)
+			if err != nil {
+				t.Fatalf("EncryptConfig() error = %v", err)
+			}
+			if encrypted.Format != "age" {
+				t.Errorf("Format = %q, want age", encrypted.Format)
+			}
+			if bytes.Contains([]byte(encrypted.Ciphertext), []byte("glpat-secret")) {
+				t.Fatal("ciphertext contains the token")
+			}
+
+			decrypted, err := DecryptConfig(encrypted, tt.secret)
+			if err != nil {
+				t.Fatalf("DecryptConfig() error = %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("DecryptConfig() = %s, want %s", decrypted, plaintext)
+			}
+
+			if _, err := DecryptConfig(encrypted, tt.wrong); err == nil {
+				t.Error("DecryptConfig() with a wrong passphrase or key should fail")
+			}
+		})
+	}
+}
+
+func newTestIdentity(t *testing.T) []byte {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(identity.String())
+}
+
+func TestLoadMachineKey(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("HOME", t.TempDir())
+
+	t.Run("keyring", func(t *testing.T) {
+		t.Setenv("REPOSYNC_KEY_FILE", "")
+		if _, err := LoadMachineKey(false); err == nil {
+			t.Fatal("LoadMachineKey(false) without a key should fail")
+		}
+		created, err := LoadMachineKey(true)
+		if err != nil {
+			t.Fatalf("LoadMachineKey(true) error = %v", err)
+		}
+		if !strings.HasPrefix(string(created), "AGE-SECRET-KEY-") {
+			t.Errorf("machine key = %q, want an age identity", created)
+		}
+		loaded, err := LoadMachineKey(false)
+		if err != nil || !bytes.Equal(loaded, created) {
+			t.Errorf("LoadMachineKey(false) = %q, %v, want the created key", loaded, err)
+		}
+		if entries, _ := os.ReadDir(filepath.Join(os.Getenv("HOME"), ".reposync")); len(entries) != 0 {
+			t.Errorf("machine key was written to disk: %v", entries)
+		}
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys", "reposync.key")
+		t.Setenv("REPOSYNC_KEY_FILE", path)
+		created, err := LoadMachineKey(true)
+		if err != nil {
+			t.Fatalf("LoadMachineKey(true) error = %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(data)) != string(created) {
+			t.Errorf("key file = %q, %v, want %q", data, err, created)
+		}
+	})
+}
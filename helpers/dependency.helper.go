@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+dependencyParsers maps manifest file names to their ecosystem and parser.
+*/
+var dependencyParsers = map[string]struct {
+	ecosystem string
+	parse     func(io.Reader) ([]models.Dependency, error)
+}{
+	"go.mod":           {"go", ParseGoMod},
+	"package.json":     {"npm", ParsePackageJSON},
+	"requirements.txt": {"pypi", ParseRequirements},
+	"pom.xml":          {"maven", ParsePom},
+}
+
+// skippedDependencyDirs are never searched for manifests: git data and vendored or installed code.
+var skippedDependencyDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+/*
+HarvestDependencies collects the dependency manifests of a clone.
+Walks the whole working tree, so monorepos report every module;
+manifests that fail to parse are included with their error.
+*/
+func HarvestDependencies(repoPath string) ([]models.DependencyManifest, error) {
+	manifests := []models.DependencyManifest{}
+	err := filepath.WalkDir(repoPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if skippedDependencyDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		parser, ok := dependencyParsers[entry.Name()]
+		if !ok {
+			return nil
+		}
+		relPath, _ := filepath.Rel(repoPath, path)
+		manifest := models.DependencyManifest{Path: filepath.ToSlash(relPath), Ecosystem: parser.ecosystem}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		manifest.Dependencies, err = parser.parse(file)
+		file.Close()
+		if err != nil {
+			manifest.Error = err.Error()
+		}
+		if manifest.Dependencies == nil {
+			manifest.Dependencies = []models.Dependency{}
+		}
+		manifests = append(manifests, manifest)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to harvest dependencies of %s: %w", repoPath, err)
+	}
+	return manifests, nil
+}
+
+/*
+ParseGoMod reads the require directives of a go.mod file.
+*/
+func ParseGoMod(r io.Reader) ([]models.Dependency, error) {
+	var dependencies []models.Dependency
+	inBlock := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		scope := ""
+		if strings.HasSuffix(line, "// indirect") {
+			scope = "indirect"
+		}
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inBlock:
+			continue
+		}
+
+		if fields := strings.Fields(line); len(fields) == 2 {
+			dependencies = append(dependencies, models.Dependency{Name: fields[0], Version: fields[1], Scope: scope})
+		}
+	}
+	return dependencies, scanner.Err()
+}
+
+/*
+ParsePackageJSON reads the dependencies and devDependencies of a package.json file.
+*/
+func ParsePackageJSON(r io.Reader) ([]models.Dependency, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.NewDecoder(r).Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+
+	var dependencies []models.Dependency
+	for _, group := range []struct {
+		entries map[string]string
+		scope   string
+	}{{pkg.Dependencies, ""}, {pkg.DevDependencies, "dev"}} {
+		names := make([]string, 0, len(group.entries))
+		for name := range group.entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dependencies = append(dependencies, models.Dependency{Name: name, Version: group.entries[name], Scope: group.scope})
+		}
+	}
+	return dependencies, nil
+}
+
+// requirementPattern splits a requirement into the package name and its version specifier.
+var requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(.*)$`)
+
+/*
+ParseRequirements reads a pip requirements.txt file.
+Options (-r, -e, --index-url) and comments are skipped.
+*/
+func ParseRequirements(r io.Reader) ([]models.Dependency, error) {
+	var dependencies []models.Dependency
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+		if marker := strings.Index(line, ";"); marker >= 0 {
+			line = strings.TrimSpace(line[:marker]) // Environment markers
+		}
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if match := requirementPattern.FindStringSubmatch(line); match != nil {
+			dependencies = append(dependencies, models.Dependency{Name: match[1], Version: strings.TrimSpace(match[3])})
+		}
+	}
+	return dependencies, scanner.Err()
+}
+
+/*
+ParsePom reads the direct dependencies of a Maven pom.xml file.
+Properties such as ${project.version} are reported unresolved.
+*/
+func ParsePom(r io.Reader) ([]models.Dependency, error) {
+	var pom struct {
+		Dependencies []struct {
+			GroupID    string `xml:"groupId"`
+			ArtifactID string `xml:"artifactId"`
+			Version    string `xml:"version"`
+			Scope      string `xml:"scope"`
+		} `xml:"dependencies>dependency"`
+	}
+	if err := xml.NewDecoder(r).Decode(&pom); err != nil {
+		return nil, fmt.Errorf("invalid pom.xml: %w", err)
+	}
+
+	var dependencies []models.Dependency
+	for _, dependency := range pom.Dependencies {
+		dependencies = append(dependencies, models.Dependency{
+			Name:    dependency.GroupID + ":" + dependency.ArtifactID,
+			Version: dependency.Version,
+			Scope:   dependency.Scope,
+		})
+	}
+	return dependencies, nil
+}
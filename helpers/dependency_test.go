@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestDependencyParsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(io.Reader) ([]models.Dependency, error)
+		input string
+		want  []models.Dependency
+	}{
+		{
+			name:  "go.mod",
+			parse: ParseGoMod,
+			input: "module example.com/app\n\ngo 1.24\n\nrequire golang.org/x/term v0.34.0\n\nrequire (\n\tgithub.com/a/b v1.2.3\n\tgithub.com/c/d v0.1.0 // indirect\n)\n",
+			want: []models.Dependency{
+				{Name: "golang.org/x/term", Version: "v0.34.0"},
+				{Name: "github.com/a/b", Version: "v1.2.3"},
+				{Name: "github.com/c/d", Version: "v0.1.0", Scope: "indirect"},
+			},
+		},
+		{
+			name:  "package.json",
+			parse: ParsePackageJSON,
+			input: `{"dependencies": {"react": "^18.2.0", "axios": "1.6.0"}, "devDependencies": {"jest": "^29.0.0"}}`,
+			want: []models.Dependency{
+				{Name: "axios", Version: "1.6.0"},
+				{Name: "react", Version: "^18.2.0"},
+				{Name: "jest", Version: "^29.0.0", Scope: "dev"},
+			},
+		},
+		{
+			name:  "requirements.txt",
+			parse: ParseRequirements,
+			input: "# web\nDjango==4.2.1\nrequests[socks] >= 2.31 ; python_version > '3.8'\n-r dev.txt\nnumpy\n",
+			want: []models.Dependency{
+				{Name: "Django", Version: "==4.2.1"},
+				{Name: "requests", Version: ">= 2.31"},
+				{Name: "numpy"},
+			},
+		},
+		{
+			name:  "pom.xml",
+			parse: ParsePom,
+			input: `<project><dependencies><dependency><groupId>junit</groupId><artifactId>junit</artifactId><version>4.13.2</version><scope>test</scope></dependency></dependencies></project>`,
+			want: []models.Dependency{
+				{Name: "junit:junit", Version: "4.13.2", Scope: "test"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+GetDiagnosticsPath returns the location of the opt-in diagnostics log for a workspace root.
+*/
+func GetDiagnosticsPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "diagnostics.jsonl")
+}
+
+/*
+AppendDiagnostics appends the record of a run to the diagnostics log.
+The log only ever grows by one line per run, like the audit log.
+*/
+func AppendDiagnostics(path string, record models.DiagnosticsRecord) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal diagnostics: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open diagnostics log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write diagnostics log: %w", err)
+	}
+	return nil
+}
+
+/*
+ReadDiagnostics returns the last limit records of a diagnostics log, oldest first.
+A missing log has no records; lines that cannot be parsed are skipped.
+*/
+func ReadDiagnostics(path string, limit int) ([]models.DiagnosticsRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read diagnostics log: %w", err)
+	}
+	defer file.Close()
+
+	var records []models.DiagnosticsRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record models.DiagnosticsRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		records = append(records, record)
+		if len(records) > limit {
+			records = records[1:]
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,229 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+/*
+CronJobSpec describes a scheduled sync for the Kubernetes manifest generator.
+Args are passed to reposync in the container, Env holds secret environment
+variables (tokens) stored in the generated Secret.
+*/
+type CronJobSpec struct {
+	Name      string
+	Namespace string
+	Schedule  string
+	Image     string
+	Volume    string // PersistentVolumeClaim holding the workspace
+	Args      []string
+	Env       map[string]string
+}
+
+/*
+cronJobTemplate renders a Secret and a CronJob running reposync in container mode.
+Values go through quote, so user input cannot break out of the YAML structure.
+*/
+const cronJobTemplate = `apiVersion: v1
+kind: Secret
+metadata:
+  name: {{ quote (print .Name "-tokens") }}
+{{- if .Namespace }}
+  namespace: {{ quote .Namespace }}
+{{- end }}
+type: Opaque
+stringData:
+{{- range $name := keys .Env }}
+  {{ $name }}: {{ quote (index $.Env $name) }}
+{{- else }} {}
+{{- end }}
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{ quote .Name }}
+{{- if .Namespace }}
+  namespace: {{ quote .Namespace }}
+{{- end }}
+spec:
+  schedule: {{ quote .Schedule }}
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    spec:
+      backoffLimit: 0
+      template:
+        spec:
+          restartPolicy: Never
+          containers:
+            - name: reposync
+              image: {{ quote .Image }}
+              command: ["reposync"]
+              args:
+                - "--container"
+{{- range .Args }}
+                - {{ quote . }}
+{{- end }}
+              env:
+                - name: REPOSYNC_DEST
+                  value: "/workspace"
+              envFrom:
+                - secretRef:
+                    name: {{ quote (print .Name "-tokens") }}
+              volumeMounts:
+                - name: workspace
+                  mountPath: /workspace
+          volumes:
+            - name: workspace
+              persistentVolumeClaim:
+                claimName: {{ quote .Volume }}
+`
+
+/*
+SystemdSpec describes a scheduled sync for the systemd unit generator.
+Binary and WorkingDirectory must be absolute; OnCalendar is a systemd
+calendar expression (e.g. "daily" or "*-*-* 02:00:00"). Env holds the
+secret environment variables (tokens) written to the environment file.
+*/
+type SystemdSpec struct {
+	Name             string
+	Binary           string
+	WorkingDirectory string
+	User             string
+	OnCalendar       string
+	EnvironmentFile  string // Location the environment file is installed to
+	Args             []string
+	Env              map[string]string
+}
+
+/*
+systemdServiceTemplate renders a oneshot service running a single sync.
+Plain output (--container) keeps escape codes out of the journal.
+*/
+const systemdServiceTemplate = `[Unit]
+Description=reposync scheduled sync ({{ .Name }})
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+WorkingDirectory={{ systemdPath .WorkingDirectory }}
+EnvironmentFile={{ systemdPath .EnvironmentFile }}
+ExecStart={{ systemdArg .Binary }} --container{{ range .Args }} {{ systemdArg . }}{{ end }}
+`
+
+/*
+systemdTimerTemplate renders the timer starting the service on its schedule.
+Persistent catches up on runs missed while the machine was off.
+*/
+const systemdTimerTemplate = `[Unit]
+Description=Run reposync scheduled sync ({{ .Name }})
+
+[Timer]
+OnCalendar={{ .OnCalendar }}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+/*
+environmentFileTemplate renders the tokens as a systemd environment file.
+*/
+const environmentFileTemplate = `# Tokens for reposync ({{ .Name }}), keep this file readable by root only
+{{- range $name := keys .Env }}
+{{ $name }}={{ envValue (index $.Env $name) }}
+{{- end }}
+`
+
+/*
+generatorFuncs are the template functions shared by the generators.
+quote renders a JSON string, which is also a valid double-quoted YAML scalar.
+systemdArg quotes a single argument of a unit file command line, escaping
+systemd's specifiers (%) and variable expansion ($); systemdPath escapes the
+specifiers of a path setting; envValue quotes a value of an environment file.
+*/
+var generatorFuncs = template.FuncMap{
+	"quote": func(value string) string {
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	},
+	"systemdArg": func(value string) string {
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(value)
+		return `"` + value + `"`
+	},
+	"systemdPath": func(value string) string {
+		return strings.ReplaceAll(value, "%", "%%")
+	},
+	"envValue": func(value string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	},
+	"keys": func(values map[string]string) []string {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}
+
+/*
+WriteCronJob renders a ready-to-apply Kubernetes Secret and CronJob for a scheduled sync.
+*/
+func WriteCronJob(w io.Writer, spec CronJobSpec) error {
+	tmpl := template.Must(template.New("cronjob").Funcs(generatorFuncs).Parse(cronJobTemplate))
+	return tmpl.Execute(w, spec)
+}
+
+/*
+WriteSystemdUnits writes a service, a timer and an environment file for scheduled syncs into dir.
+Files are named after spec.Name; the environment file holds the tokens
+and is only readable by its owner. Returns the paths of the written files.
+*/
+func WriteSystemdUnits(dir string, spec SystemdSpec) ([]string, error) {
+	files := []struct {
+		name     string
+		template string
+		mode     os.FileMode
+	}{
+		{spec.Name + ".service", systemdServiceTemplate, 0644},
+		{spec.Name + ".timer", systemdTimerTemplate, 0644},
+		{spec.Name + ".env", environmentFileTemplate, 0600},
+	}
+
+	var paths []string
+	for _, file := range files {
+		tmpl, err := template.New(file.name).Funcs(generatorFuncs).Parse(file.template)
+		if err != nil {
+			return nil, err
+		}
+		var content bytes.Buffer
+		if err := tmpl.Execute(&content, spec); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", file.name, err)
+		}
+
+		path := filepath.Join(dir, file.name)
+		if err := ensureWritable(path); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, content.Bytes(), file.mode); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		// WriteFile keeps the mode of an existing file, which must not stay readable for the tokens
+		if err := os.Chmod(path, file.mode); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
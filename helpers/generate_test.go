@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCronJob(t *testing.T) {
+	var out strings.Builder
+	err := WriteCronJob(&out, CronJobSpec{
+		Name:      "backup",
+		Namespace: "tools",
+		Schedule:  "0 2 * * *",
+		Image:     "registry.example.com/reposync:1.0",
+		Volume:    "backup-workspace",
+		Args:      []string{"-p", "gitlab", "-g", "123456"},
+		Env:       map[string]string{"REPOSYNC_GITLAB_TOKEN": "glpat-\"quoted\"\nsecret"},
+	})
+	if err != nil {
+		t.Fatalf("WriteCronJob() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"kind: Secret\nmetadata:\n  name: \"backup-tokens\"\n  namespace: \"tools\"",
+		`REPOSYNC_GITLAB_TOKEN: "glpat-\"quoted\"\nsecret"`,
+		`schedule: "0 2 * * *"`,
+		"- \"--container\"\n                - \"-p\"\n                - \"gitlab\"",
+		`claimName: "backup-workspace"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("manifest is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteSystemdUnits(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := WriteSystemdUnits(dir, SystemdSpec{
+		Name:             "reposync-backup",
+		Binary:           "/usr/local/bin/reposync",
+		WorkingDirectory: "/srv/repos",
+		User:             "backup",
+		OnCalendar:       "*-*-* 02:00:00",
+		EnvironmentFile:  "/etc/reposync/reposync-backup.env",
+		Args:             []string{"-p", "gitlab", "-g", "123456", "--index", "100% $HOME.md"},
+		Env:              map[string]string{"REPOSYNC_GITLAB_TOKEN": `glpat-"secret"`},
+	})
+	if err != nil {
+		t.Fatalf("WriteSystemdUnits() error = %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("wrote %v, want service, timer and environment file", paths)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"reposync-backup.service", `ExecStart="/usr/local/bin/reposync" --container "-p" "gitlab" "-g" "123456" "--index" "100%% $$HOME.md"`},
+		{"reposync-backup.service", "EnvironmentFile=/etc/reposync/reposync-backup.env"},
+		{"reposync-backup.service", "User=backup"},
+		{"reposync-backup.timer", "OnCalendar=*-*-* 02:00:00"},
+		{"reposync-backup.env", `REPOSYNC_GITLAB_TOKEN="glpat-\"secret\""`},
+	}
+	for _, tt := range tests {
+		content, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), tt.want) {
+			t.Errorf("%s is missing %q:\n%s", tt.file, tt.want, content)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "reposync-backup.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("environment file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -1,15 +1,135 @@
 package helpers
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
-
-	colors "github.com/itszeeshan/reposync/constants/colors"
 )
 
+/*
+GitRunner executes git commands.
+All git invocations go through this interface so the services can be
+tested with a fake runner instead of spawning real git processes.
+*/
+type GitRunner interface {
+	Run(stdout, stderr io.Writer, args ...string) error
+}
+
+/*
+ExecGitRunner runs the git binary found in PATH.
+*/
+type ExecGitRunner struct{}
+
+/*
+Run executes git with the given arguments, streaming its output to stdout and stderr.
+Output is passed through Redact, since git echoes authenticated URLs in its errors.
+*/
+func (ExecGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	ctx := context.Background()
+	if gitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gitTimeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = gitEnv()
+	cmd.Stdout = NewRedactingWriter(stdout)
+	cmd.Stderr = NewRedactingWriter(stderr)
+	// Helpers spawned by git (e.g. git-remote-https) may outlive it and keep the pipes open
+	cmd.WaitDelay = 5 * time.Second
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrGitTimeout, gitTimeout)
+	}
+	return err
+}
+
+// allowPrompts lets git ask for credentials interactively (--allow-git-prompts).
+var allowPrompts bool
+
+/*
+SetAllowGitPrompts lets git ask for usernames and passwords on the terminal or through askpass helpers.
+By default prompts are disabled, so unattended syncs fail fast with a clear error
+instead of hanging on a prompt nobody sees. Credential helpers keep working either way.
+*/
+func SetAllowGitPrompts(allow bool) {
+	allowPrompts = allow
+}
+
+/*
+gitEnv returns the environment for spawned git commands.
+An empty GIT_ASKPASS makes git skip core.askPass and SSH_ASKPASS as well,
+GIT_TERMINAL_PROMPT=0 turns the remaining terminal prompt into an error,
+and ssh runs in batch mode so passphrase and host key prompts fail too.
+With a managed known_hosts file, ssh is pointed at it (see SetKnownHostsFile).
+*/
+func gitEnv() []string {
+	// git filter-branch (see AnonymizeEmails) otherwise pauses 10 seconds to recommend other tools
+	env := append(os.Environ(), "FILTER_BRANCH_SQUELCH_WARNING=1")
+	if knownHostsFile != "" || !allowPrompts {
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand())
+	}
+	if allowPrompts {
+		return env
+	}
+	return append(env, "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=")
+}
+
+// ErrGitTimeout is returned when a git command was killed for exceeding the timeout.
+var ErrGitTimeout = errors.New("git command timed out")
+
+// gitTimeout limits how long a single git command may run (0: no limit).
+var gitTimeout time.Duration
+
+/*
+SetGitTimeout limits how long a single git command may run before it is killed (--clone-timeout).
+Guards unattended syncs against git hanging on dead networks or credential prompts.
+Zero disables the limit.
+*/
+func SetGitTimeout(timeout time.Duration) {
+	gitTimeout = timeout
+}
+
+// cloneRetryDelay is the base delay between clone attempts, multiplied by the attempt number.
+var cloneRetryDelay = time.Second
+
+// cloneAttempts is how often a failing clone is tried before giving up.
+var cloneAttempts = 3
+
+/*
+SetCloneAttempts changes how often a failing clone is tried (max_retries in the config).
+Values below one are ignored.
+*/
+func SetCloneAttempts(attempts int) {
+	if attempts > 0 {
+		cloneAttempts = attempts
+	}
+}
+
+// cloneUsername is the username paired with the token in authenticated clone URLs.
+var cloneUsername = "oauth2"
+
+/*
+SetCloneUsername changes the username used with the token in authenticated clone URLs.
+GitLab expects "oauth2" for personal tokens, "gitlab-ci-token" for CI job tokens
+and the token's own username for deploy tokens. Empty values are ignored.
+*/
+func SetCloneUsername(username string) {
+	if username != "" {
+		cloneUsername = username
+	}
+}
+
 /*
 GetPreferredRepositoryURL determines clone URL based on user preference.
 Selects between HTTPS and SSH URLs based on -m flag value,
@@ -22,54 +142,188 @@ func GetPreferredRepositoryURL(httpsURL, sshURL, method string) string {
 	return httpsURL
 }
 
+/*
+CloneStatus receives the status lines of CloneRepository, such as the repository
+being cloned or a retry; warning marks the ones that need attention.
+*/
+type CloneStatus func(warning bool, message string)
+
 /*
 CloneRepository executes git clone command for a single repository.
 Checks local filesystem first to avoid duplicate cloning,
 maintaining existing repositories while synchronizing new ones.
-Includes retry logic for better reliability and token-based authentication as fallback.
+Includes retry logic for better reliability and token-based authentication as fallback;
+clones killed by the git timeout are not retried.
+Extra arguments are passed to git clone before the URL (e.g. --depth=1).
+Git's own output is written to output, status lines are reported to status (nil discards them).
+Repositories are cloned into a temporary directory next to their destination
+(see CloneTempPath) and renamed into place once complete, so an interrupted
+clone never leaves a half-initialized repository behind that looks cloned.
 */
-func CloneRepository(repoURL, baseDir, name, token string) error {
+func CloneRepository(runner GitRunner, output io.Writer, status CloneStatus, repoURL, baseDir, name, token string, extraArgs ...string) error {
 	path := filepath.Join(baseDir, name)
+	if status == nil {
+		status = func(bool, string) {}
+	}
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		fmt.Println(colors.Green + "Cloning: " + name + colors.Reset)
+		if err := ensureWritable(path); err != nil {
+			return err
+		}
+		status(false, "Cloning: "+name)
+
+		// Left behind by an interrupted run
+		tmpPath := CloneTempPath(path)
+		if err := os.RemoveAll(tmpPath); err != nil {
+			return fmt.Errorf("failed to remove the partial clone %s: %w", tmpPath, err)
+		}
 
 		// Add retry logic for better reliability
-		maxRetries := 3
+		maxRetries := cloneAttempts
 		for attempt := 1; attempt <= maxRetries; attempt++ {
-			var cmd *exec.Cmd
-
 			// First try without authentication (works for public repos and configured credentials)
-			if attempt == 1 {
-				cmd = exec.Command("git", "clone", repoURL, path)
-			} else {
+			cloneURL := repoURL
+			withToken := token != "" && isHTTPSURL(repoURL)
+			if attempt > 1 && withToken {
 				// On retry, use token authentication as fallback
-				authenticatedURL := repoURL
-				if token != "" && isHTTPSURL(repoURL) {
-					authenticatedURL = constructAuthenticatedURL(repoURL, token)
-				}
-				cmd = exec.Command("git", "clone", authenticatedURL, path)
+				cloneURL = constructAuthenticatedURL(repoURL, token)
 			}
 
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-
-			if err := cmd.Run(); err != nil {
+			args := append(append([]string{"clone"}, extraArgs...), cloneURL, tmpPath)
+			var stderr bytes.Buffer
+			if err := runner.Run(output, io.MultiWriter(output, &stderr), args...); err != nil {
+				gitErr := newGitError(err, stderr.String())
+				// A killed clone leaves a partial directory behind
+				os.RemoveAll(tmpPath)
+				removeEmptyDirectory(filepath.Dir(tmpPath))
+				if errors.Is(err, ErrGitTimeout) {
+					return fmt.Errorf("git clone failed for %s: %w", name, gitErr)
+				}
+				if latestOnly, ok := withoutShallowSince(extraArgs); ok && strings.Contains(stderr.String(), "no commits selected for shallow requests") {
+					// Nothing was committed since the --shallow-since date, keep the latest commit only
+					status(true, "No commits since --shallow-since in "+name+", cloning its latest commit only")
+					extraArgs = latestOnly
+					attempt--
+					continue
+				}
+				// Rejected credentials only get another chance if the token was not tried yet
+				retryable := gitErr.Transient() || (gitErr.Class == GitErrorAuth && attempt == 1 && withToken)
+				if !retryable {
+					return fmt.Errorf("git clone failed for %s (%s, not retried): %w", name, gitErr.Class, gitErr)
+				}
 				if attempt == maxRetries {
-					return fmt.Errorf("git clone failed for %s after %d attempts: %w", name, maxRetries, err)
+					return fmt.Errorf("git clone failed for %s after %d attempts: %w", name, maxRetries, gitErr)
 				}
-				fmt.Printf(colors.Yellow+"Attempt %d failed, retrying with authentication in %d seconds...\n"+colors.Reset, attempt, attempt)
-				time.Sleep(time.Duration(attempt) * time.Second)
+				status(true, fmt.Sprintf("Attempt %d failed, retrying with authentication in %d seconds...", attempt, attempt))
+				time.Sleep(time.Duration(attempt) * cloneRetryDelay)
 				continue
 			}
 			break
 		}
+
+		if err := os.Rename(tmpPath, path); err != nil {
+			os.RemoveAll(tmpPath)
+			removeEmptyDirectory(filepath.Dir(tmpPath))
+			return fmt.Errorf("failed to move the clone of %s into place: %w", name, err)
+		}
+		removeEmptyDirectory(filepath.Dir(tmpPath))
 	} else {
-		fmt.Println(colors.Yellow + "Skipping: " + name + " (Already cloned)" + colors.Reset)
+		status(false, "Skipping: "+name+" (Already cloned)")
 	}
 	return nil
 }
 
+/*
+withoutShallowSince replaces --shallow-since in clone arguments with --depth=1.
+Reports false if the arguments hold no --shallow-since.
+*/
+func withoutShallowSince(args []string) ([]string, bool) {
+	replaced := make([]string, 0, len(args))
+	found := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "--shallow-since="):
+		case args[i] == "--shallow-since" && i+1 < len(args):
+			i++
+		default:
+			replaced = append(replaced, args[i])
+			continue
+		}
+		if !found {
+			replaced = append(replaced, "--depth=1")
+		}
+		found = true
+	}
+	return replaced, found
+}
+
+// CloneTempDir names the directories clones are made in before they are renamed into place.
+const CloneTempDir = ".reposync-tmp"
+
+/*
+CloneTempPath returns where the repository cloned to path is cloned before it is complete.
+The directory is a sibling of path, so the final rename stays on one file system.
+*/
+func CloneTempPath(path string) string {
+	return filepath.Join(filepath.Dir(path), CloneTempDir, filepath.Base(path))
+}
+
+/*
+removeEmptyDirectory removes a directory unless something is still in it,
+such as the clone another worker is making.
+*/
+func removeEmptyDirectory(path string) {
+	os.Remove(path)
+}
+
+/*
+LoggingGitRunner copies every git invocation and its output to a log.
+Each command is written as a "$ git ..." line with secrets redacted,
+followed by everything git printed to stdout and stderr.
+*/
+type LoggingGitRunner struct {
+	Runner GitRunner
+	Log    io.Writer
+}
+
+/*
+Run logs the command line and runs it, teeing both output streams into the log.
+*/
+func (l LoggingGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	fmt.Fprintf(l.Log, "$ git %s\n", Redact(strings.Join(args, " ")))
+	err := l.Runner.Run(io.MultiWriter(stdout, l.Log), io.MultiWriter(stderr, l.Log), args...)
+	if err != nil {
+		fmt.Fprintf(l.Log, "error: %s\n", Redact(err.Error()))
+	}
+	return err
+}
+
+/*
+GetRepositoryLogPath returns where the git output of a repository is logged.
+relPath is the clone location relative to the workspace root, so
+logs of repositories with the same name in different groups don't collide.
+*/
+func GetRepositoryLogPath(workspace, relPath string) string {
+	return filepath.Join(workspace, ".reposync", "logs", filepath.FromSlash(relPath)+".log")
+}
+
+/*
+CreateRepositoryLog creates (or truncates) a repository log file.
+*/
+func CreateRepositoryLog(path string) (*os.File, error) {
+	if err := ensureWritable(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository log: %w", err)
+	}
+	return file, nil
+}
+
 /*
 isHTTPSURL checks if the given URL is an HTTPS URL.
 */
@@ -82,6 +336,454 @@ constructAuthenticatedURL constructs an authenticated URL for HTTPS cloning.
 Inserts the token into the URL for GitLab/GitHub authentication.
 */
 func constructAuthenticatedURL(originalURL, token string) string {
-	// Replace https:// with https://oauth2:token@
-	return "https://oauth2:" + token + "@" + originalURL[8:]
+	// Replace https:// with https://<clone username>:token@
+	return "https://" + cloneUsername + ":" + token + "@" + originalURL[8:]
+}
+
+/*
+gitOutput runs a git command inside the given repository directory
+and returns its trimmed standard output.
+*/
+func gitOutput(runner GitRunner, repoPath string, args ...string) (string, error) {
+	var out bytes.Buffer
+	if err := runner.Run(&out, io.Discard, append([]string{"-C", repoPath}, args...)...); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+/*
+gitRun runs a git command inside the given repository directory,
+discarding its output and returning a descriptive error on failure.
+*/
+func gitRun(runner GitRunner, repoPath string, args ...string) error {
+	var out bytes.Buffer
+	if err := runner.Run(&out, &out, append([]string{"-C", repoPath}, args...)...); err != nil {
+		return fmt.Errorf("git %s failed: %w: %s", Redact(strings.Join(args, " ")), err, Redact(strings.TrimSpace(out.String())))
+	}
+	return nil
+}
+
+/*
+MigrateDefaultBranch detects an upstream default branch rename for an existing clone.
+Compares the local origin/HEAD with the default branch reported by the provider API and,
+when they differ (e.g. master → main), fetches the new branch, updates origin/HEAD,
+and moves the local branch and its upstream over to the new name.
+Returns the previous branch name when a migration happened, or an empty string otherwise.
+*/
+func MigrateDefaultBranch(runner GitRunner, repoPath, defaultBranch string) (string, error) {
+	if defaultBranch == "" {
+		return "", nil // Empty repositories have no default branch
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return "", nil
+	}
+
+	remoteHead, err := gitOutput(runner, repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", nil // origin/HEAD not set, nothing to compare against
+	}
+	previous := strings.TrimPrefix(remoteHead, "origin/")
+	if previous == defaultBranch {
+		return "", nil
+	}
+	if err := ensureWritable(repoPath); err != nil {
+		return "", err
+	}
+
+	if err := gitRun(runner, repoPath, "fetch", "origin", "--prune"); err != nil {
+		return "", err
+	}
+	if err := gitRun(runner, repoPath, "remote", "set-head", "origin", defaultBranch); err != nil {
+		return "", err
+	}
+
+	hasPrevious := gitRun(runner, repoPath, "show-ref", "--verify", "--quiet", "refs/heads/"+previous) == nil
+	hasDefault := gitRun(runner, repoPath, "show-ref", "--verify", "--quiet", "refs/heads/"+defaultBranch) == nil
+	currentBranch, _ := gitOutput(runner, repoPath, "symbolic-ref", "--short", "HEAD")
+
+	switch {
+	case hasPrevious && !hasDefault:
+		// Renaming keeps HEAD attached if the previous branch was checked out
+		if err := gitRun(runner, repoPath, "branch", "-m", previous, defaultBranch); err != nil {
+			return "", err
+		}
+	case hasDefault && currentBranch == previous:
+		if err := gitRun(runner, repoPath, "checkout", defaultBranch); err != nil {
+			return "", err
+		}
+	case !hasDefault:
+		if err := gitRun(runner, repoPath, "checkout", "-b", defaultBranch, "origin/"+defaultBranch); err != nil {
+			return "", err
+		}
+	}
+
+	if err := gitRun(runner, repoPath, "branch", "--set-upstream-to=origin/"+defaultBranch, defaultBranch); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+/*
+FetchPrune updates a clone from origin, deleting remote-tracking branches and tags that
+were deleted upstream (git fetch --prune --prune-tags), so mirrors stay faithful.
+Local branches are left untouched. Returns the deleted refs as git names them,
+e.g. origin/feature or v1.0.
+*/
+func FetchPrune(runner GitRunner, repoPath string) ([]string, error) {
+	if err := ensureWritable(repoPath); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	args := []string{"fetch", "origin", "--prune", "--prune-tags"}
+	if err := runner.Run(&out, &out, append([]string{"-C", repoPath}, args...)...); err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, Redact(strings.TrimSpace(out.String())))
+	}
+	return parsePrunedRefs(out.String()), nil
+}
+
+/*
+parsePrunedRefs picks the deleted refs from git fetch output.
+Deletions are the lines flagged with "-", e.g. " - [deleted] (none) -> origin/feature";
+the flag is matched instead of "[deleted]", which git translates.
+*/
+func parsePrunedRefs(output string) []string {
+	var refs []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "- ") {
+			continue
+		}
+		if i := strings.LastIndex(line, "-> "); i >= 0 {
+			refs = append(refs, strings.TrimSpace(line[i+3:]))
+		}
+	}
+	return refs
+}
+
+/*
+FixUpstreams points local branches at the origin branch of the same name when their
+upstream is missing or refers to a remote-tracking branch that no longer exists, e.g.
+after a branch rename upstream. Upstreams that still exist are left alone, as are
+branches without a counterpart on origin. Returns the branches that were fixed.
+*/
+func FixUpstreams(runner GitRunner, repoPath string) ([]string, error) {
+	remote, err := originBranches(runner, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := gitOutput(runner, repoPath, "for-each-ref", "--format=%(refname:short)%09%(upstream)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the branches of %s: %w", repoPath, err)
+	}
+
+	var fixed []string
+	for _, line := range strings.Split(out, "\n") {
+		branch, upstream, _ := strings.Cut(line, "\t")
+		if branch == "" || !remote[branch] {
+			continue
+		}
+		if upstream != "" && (!strings.HasPrefix(upstream, "refs/remotes/origin/") || remote[strings.TrimPrefix(upstream, "refs/remotes/origin/")]) {
+			continue
+		}
+		if err := ensureWritable(repoPath); err != nil {
+			return fixed, err
+		}
+		if err := gitRun(runner, repoPath, "branch", "--set-upstream-to=origin/"+branch, branch); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, branch)
+	}
+	return fixed, nil
+}
+
+/*
+TrackAllBranches creates a local branch tracking every origin branch that has none yet,
+so all history can be browsed in a backup mirror without checking out remote branches.
+Existing local branches are never moved. Returns the branches that were created.
+*/
+func TrackAllBranches(runner GitRunner, repoPath string) ([]string, error) {
+	remote, err := originBranches(runner, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := gitOutput(runner, repoPath, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the branches of %s: %w", repoPath, err)
+	}
+	local := map[string]bool{}
+	for _, branch := range strings.Split(out, "\n") {
+		local[branch] = true
+	}
+
+	branches := make([]string, 0, len(remote))
+	for branch := range remote {
+		if !local[branch] {
+			branches = append(branches, branch)
+		}
+	}
+	sort.Strings(branches)
+
+	var created []string
+	for _, branch := range branches {
+		if err := ensureWritable(repoPath); err != nil {
+			return created, err
+		}
+		if err := gitRun(runner, repoPath, "branch", "--track", branch, "refs/remotes/origin/"+branch); err != nil {
+			return created, err
+		}
+		created = append(created, branch)
+	}
+	return created, nil
+}
+
+/*
+originBranches returns the names of the remote-tracking branches of origin, without origin/HEAD.
+*/
+func originBranches(runner GitRunner, repoPath string) (map[string]bool, error) {
+	out, err := gitOutput(runner, repoPath, "for-each-ref", "--format=%(refname)", "refs/remotes/origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the remote branches of %s: %w", repoPath, err)
+	}
+	branches := map[string]bool{}
+	for _, ref := range strings.Split(out, "\n") {
+		if branch := strings.TrimPrefix(ref, "refs/remotes/origin/"); branch != ref && branch != "HEAD" {
+			branches[branch] = true
+		}
+	}
+	return branches, nil
+}
+
+/*
+CheckoutRevision checks out a branch, tag or commit as a detached HEAD, as pinned by
+a repo manifest, superproject or the workspace manifest. With branch set, the revision is
+checked out on a local branch of that name instead, which is created or reset to it.
+Branches and tags are fetched from origin first, as they move; commits only when the
+clone doesn't have them yet. Returns whether HEAD moved.
+*/
+func CheckoutRevision(runner GitRunner, repoPath, revision, branch string) (bool, error) {
+	head, _ := ResolveCommit(runner, repoPath, "HEAD")
+	if branch != "" && CurrentBranch(runner, repoPath) != branch {
+		head = "" // Not on the branch yet
+	}
+	commit, err := ResolveCommit(runner, repoPath, revision)
+	if err != nil || !isCommitHash(revision) {
+		if err := ensureWritable(repoPath); err != nil {
+			return false, err
+		}
+		if err := gitRun(runner, repoPath, "fetch", "origin", revision); err != nil {
+			return false, err
+		}
+		if commit, err = ResolveCommit(runner, repoPath, "FETCH_HEAD"); err != nil {
+			return false, err
+		}
+	}
+	if commit == head {
+		return false, nil
+	}
+	if err := ensureWritable(repoPath); err != nil {
+		return false, err
+	}
+	args := []string{"checkout", "--quiet", "--detach", commit}
+	if branch != "" {
+		args = []string{"checkout", "--quiet", "-B", branch, commit}
+	}
+	if err := gitRun(runner, repoPath, args...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+/*
+isCommitHash reports whether a revision is a full SHA-1 or SHA-256 commit hash.
+*/
+func isCommitHash(revision string) bool {
+	if len(revision) != 40 && len(revision) != 64 {
+		return false
+	}
+	for _, c := range revision {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+CountCommits returns the number of commits reachable from to but not from from (git rev-list --count from..to).
+Fails when the clone lacks either commit.
+*/
+func CountCommits(runner GitRunner, repoPath, from, to string) (int, error) {
+	count, err := gitOutput(runner, repoPath, "rev-list", "--count", from+".."+to)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(count)
+}
+
+/*
+ObjectSource returns the repository git clone --reference can borrow the objects of a
+clone from: its git directory, or for a worktree (git worktree add), the git directory
+of the repository the worktree belongs to.
+*/
+func ObjectSource(runner GitRunner, repoPath string) (string, error) {
+	gitDir, err := gitOutput(runner, repoPath, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(repoPath, gitDir)
+	}
+	return gitDir, nil
+}
+
+/*
+CurrentBranch returns the branch a clone has checked out, or "" for a detached HEAD.
+*/
+func CurrentBranch(runner GitRunner, repoPath string) string {
+	branch, err := gitOutput(runner, repoPath, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return branch
+}
+
+/*
+GetDefaultBranch returns the default branch of origin as recorded in a clone (origin/HEAD).
+*/
+func GetDefaultBranch(runner GitRunner, repoPath string) (string, error) {
+	remoteHead, err := gitOutput(runner, repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the default branch of %s: %w", repoPath, err)
+	}
+	return strings.TrimPrefix(remoteHead, "origin/"), nil
+}
+
+/*
+HasLocalChanges reports whether a clone has uncommitted changes, untracked files
+or commits that are not on any remote-tracking branch, i.e. work that deleting
+the clone would lose.
+*/
+func HasLocalChanges(runner GitRunner, repoPath string) (bool, error) {
+	status, err := gitOutput(runner, repoPath, "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("failed to read the status of %s: %w", repoPath, err)
+	}
+	unpushed, err := gitOutput(runner, repoPath, "log", "--branches", "--not", "--remotes", "--oneline")
+	if err != nil {
+		return false, fmt.Errorf("failed to list unpushed commits of %s: %w", repoPath, err)
+	}
+	return status != "" || unpushed != "", nil
+}
+
+/*
+PushRepository pushes all branches and tags of a clone to another remote.
+The clone's remote-tracking branches are pushed as branches, so everything
+fetched from origin is restored, not only the checked-out branch.
+HTTPS URLs are authenticated with the token like clones are.
+*/
+func PushRepository(runner GitRunner, repoPath, url, token string) error {
+	if token != "" && isHTTPSURL(url) {
+		url = constructAuthenticatedURL(url, token)
+	}
+	return gitRun(runner, repoPath, "push", url,
+		"refs/remotes/origin/*:refs/heads/*", "^refs/remotes/origin/HEAD", "refs/tags/*:refs/tags/*")
+}
+
+/*
+SetRemoteURL points the origin remote of an existing clone at a new URL.
+Used after upstream renames or transfers so later fetches reach the new location.
+*/
+func SetRemoteURL(runner GitRunner, repoPath, url string) error {
+	if err := ensureWritable(repoPath); err != nil {
+		return err
+	}
+	return gitRun(runner, repoPath, "remote", "set-url", "origin", url)
+}
+
+/*
+ApplyGitConfig sets local git config values in a clone.
+Only values that differ from the current local config are written,
+so repeated runs leave untouched repositories alone.
+Returns the keys that were changed.
+*/
+func ApplyGitConfig(runner GitRunner, repoPath string, values map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changed []string
+	for _, key := range keys {
+		current, _ := gitOutput(runner, repoPath, "config", "--local", "--get", key)
+		if current == values[key] {
+			continue
+		}
+		if err := ensureWritable(repoPath); err != nil {
+			return changed, err
+		}
+		if err := gitRun(runner, repoPath, "config", "--local", key, values[key]); err != nil {
+			return changed, err
+		}
+		changed = append(changed, key)
+	}
+	return changed, nil
+}
+
+/*
+IsEmptyRepository reports whether a clone has no commits, as after cloning an empty repository.
+*/
+func IsEmptyRepository(runner GitRunner, repoPath string) bool {
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return false
+	}
+	_, err := gitOutput(runner, repoPath, "rev-parse", "--verify", "--quiet", "HEAD")
+	return err != nil
+}
+
+/*
+CloneProblem tells what makes an existing clone unusable, or returns "" for a healthy clone:
+a .git directory without HEAD, as left behind by interrupted clones of older versions,
+or a repository whose objects fail git fsck. The HEAD check runs first because git
+does not recognize a repository without HEAD and would check a parent repository instead.
+*/
+func CloneProblem(runner GitRunner, repoPath string) string {
+	gitDir := filepath.Join(repoPath, ".git")
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return "missing HEAD"
+	}
+	if err := gitRun(runner, repoPath, "fsck", "--connectivity-only", "--no-progress"); err != nil {
+		return "git fsck failed"
+	}
+	return ""
+}
+
+/*
+ConfiguredOriginURL reads the origin URL from a clone's config file as it is stored,
+including any credentials. The file is read directly, so this works for clones git
+no longer recognizes as repositories.
+*/
+func ConfiguredOriginURL(runner GitRunner, repoPath string) (string, error) {
+	configPath := filepath.Join(repoPath, ".git", "config")
+	var out bytes.Buffer
+	if err := runner.Run(&out, io.Discard, "config", "--file", configPath, "--get", "remote.origin.url"); err != nil {
+		return "", fmt.Errorf("failed to read the origin of %s: %w", repoPath, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+/*
+LastCommitTime returns the committer date of the commit checked out in a clone.
+*/
+func LastCommitTime(runner GitRunner, repoPath string) (time.Time, error) {
+	out, err := gitOutput(runner, repoPath, "log", "-1", "--format=%ct")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last commit of %s: %w", repoPath, err)
+	}
+	seconds, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected commit date %q in %s", out, repoPath)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
 }
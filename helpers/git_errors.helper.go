@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// Classes of failed git commands, as determined by ClassifyGitError.
+const (
+	GitErrorAuth     = "auth"      // Credentials missing, wrong or without access
+	GitErrorNotFound = "not-found" // The repository does not exist (or is hidden from the credentials)
+	GitErrorDiskFull = "disk-full" // No space left for the clone
+	GitErrorNetwork  = "network"   // DNS, connection, TLS or server-side (5xx) problems
+	GitErrorTimeout  = "timeout"   // Killed by --clone-timeout
+	GitErrorOther    = "other"
+)
+
+/*
+gitErrorPatterns maps fragments of git's error output to a class, checked in order:
+HTTP status lines come first, as "unable to access" precedes every one of them.
+*/
+var gitErrorPatterns = []struct {
+	class     string
+	fragments []string
+}{
+	{GitErrorDiskFull, []string{"no space left on device", "disk quota exceeded"}},
+	{GitErrorAuth, []string{"returned error: 401", "returned error: 403", "authentication failed", "could not read username",
+		"could not read password", "terminal prompts disabled", "invalid username or password", "permission denied (publickey",
+		"host key verification failed", "access denied", "http basic: access denied"}},
+	{GitErrorNotFound, []string{"returned error: 404", "repository not found", "does not appear to be a git repository",
+		"project you were looking for could not be found", "does not exist"}},
+	{GitErrorNetwork, []string{"returned error: 5", "could not resolve host", "could not resolve hostname", "connection refused",
+		"connection timed out", "timed out", "connection reset", "network is unreachable", "no route to host", "early eof",
+		"the remote end hung up unexpectedly", "rpc failed", "ssl certificate", "ssl connect error", "tls handshake",
+		"gnutls_handshake", "failed to connect", "unable to access"}},
+}
+
+/*
+GitError is a failed git command together with its class and git's last error line.
+*/
+type GitError struct {
+	Class  string
+	Detail string // Last line git printed to stderr, redacted
+	Err    error
+}
+
+func (e *GitError) Error() string {
+	if e.Detail == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Transient reports whether trying again may help: network problems and failures
+nobody recognized are retried, while missing repositories, rejected credentials,
+full disks and timeouts fail the same way on every attempt.
+*/
+func (e *GitError) Transient() bool {
+	return e.Class == GitErrorNetwork || e.Class == GitErrorOther
+}
+
+/*
+ClassifyGitError determines the class of a failed git command from its error
+and what it printed to stderr.
+*/
+func ClassifyGitError(err error, stderr string) string {
+	switch {
+	case errors.Is(err, ErrGitTimeout):
+		return GitErrorTimeout
+	case errors.Is(err, syscall.ENOSPC):
+		return GitErrorDiskFull
+	}
+	// Progress lines name the repository, which must not be mistaken for an error
+	var output strings.Builder
+	for _, line := range strings.Split(strings.ToLower(stderr), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "cloning into") {
+			output.WriteString(line + "\n")
+		}
+	}
+	for _, pattern := range gitErrorPatterns {
+		for _, fragment := range pattern.fragments {
+			if strings.Contains(output.String(), fragment) {
+				return pattern.class
+			}
+		}
+	}
+	return GitErrorOther
+}
+
+/*
+GitErrorClass returns the class of a git failure, or an empty string for other errors.
+*/
+func GitErrorClass(err error) string {
+	var gitErr *GitError
+	if errors.As(err, &gitErr) {
+		return gitErr.Class
+	}
+	return ""
+}
+
+/*
+newGitError wraps a failed git command with its class and the last line of its stderr.
+*/
+func newGitError(err error, stderr string) *GitError {
+	detail := ""
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for i := len(lines) - 1; i >= 0 && detail == ""; i-- {
+		detail = strings.TrimSpace(lines[i])
+	}
+	return &GitError{Class: ClassifyGitError(err, stderr), Detail: Redact(detail), Err: err}
+}
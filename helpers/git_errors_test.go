@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestClassifyGitError(t *testing.T) {
+	exitErr := errors.New("exit status 128")
+	tests := []struct {
+		name   string
+		err    error
+		stderr string
+		want   string
+	}{
+		{"https auth", exitErr, "fatal: Authentication failed for 'https://gitlab.com/acme/api.git/'", GitErrorAuth},
+		{"no prompt", exitErr, "fatal: could not read Username for 'https://github.com': terminal prompts disabled", GitErrorAuth},
+		{"forbidden", exitErr, "fatal: unable to access 'https://github.com/acme/api.git/': The requested URL returned error: 403", GitErrorAuth},
+		{"ssh key", exitErr, "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", GitErrorAuth},
+		{"github not found", exitErr, "remote: Repository not found.\nfatal: repository 'https://github.com/acme/gone.git/' not found", GitErrorNotFound},
+		{"disk full", exitErr, "fatal: write error: No space left on device", GitErrorDiskFull},
+		{"dns", exitErr, "fatal: unable to access 'https://gitlab.com/acme/api.git/': Could not resolve host: gitlab.com", GitErrorNetwork},
+		{"server error", exitErr, "fatal: unable to access 'https://gitlab.com/acme/api.git/': The requested URL returned error: 502", GitErrorNetwork},
+		{"hung up", exitErr, "error: RPC failed; curl 18 transfer closed\nfatal: early EOF", GitErrorNetwork},
+		{"repository named like an error", exitErr, "Cloning into 'connection-refused-handler'...\nfatal: something else", GitErrorOther},
+		{"timeout", fmt.Errorf("%w after 10m", ErrGitTimeout), "", GitErrorTimeout},
+		{"unknown", exitErr, "", GitErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyGitError(tt.err, tt.stderr); got != tt.want {
+				t.Errorf("ClassifyGitError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+/*
+stderrGitRunner fails every invocation, printing the same error output each time.
+*/
+type stderrGitRunner struct {
+	stderr string
+	calls  int
+}
+
+func (s *stderrGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	s.calls++
+	io.WriteString(stderr, s.stderr)
+	return errors.New("exit status 128")
+}
+
+func TestCloneRepositoryRetriesTransientErrorsOnly(t *testing.T) {
+	cloneRetryDelay = 0
+	t.Cleanup(func() { cloneRetryDelay = time.Second })
+
+	tests := []struct {
+		name      string
+		stderr    string
+		token     string
+		wantCalls int
+		wantClass string
+	}{
+		{"not found", "remote: Repository not found.", "glpat-secret", 1, GitErrorNotFound},
+		{"auth retried with the token once", "fatal: Authentication failed", "glpat-secret", 2, GitErrorAuth},
+		{"auth without a token", "fatal: Authentication failed", "", 1, GitErrorAuth},
+		{"network", "fatal: unable to access: Connection refused", "", 3, GitErrorNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &stderrGitRunner{stderr: tt.stderr}
+			err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", t.TempDir(), "repo", tt.token)
+			if runner.calls != tt.wantCalls {
+				t.Errorf("git ran %d times, want %d", runner.calls, tt.wantCalls)
+			}
+			if got := GitErrorClass(err); got != tt.wantClass {
+				t.Errorf("GitErrorClass() = %q, want %q (error %v)", got, tt.wantClass, err)
+			}
+		})
+	}
+}
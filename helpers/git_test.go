@@ -0,0 +1,370 @@
+package helpers
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+scriptedGitRunner fails the first failCount invocations and records all arguments.
+Successful clones create their destination, like git.
+*/
+type scriptedGitRunner struct {
+	failCount int
+	calls     [][]string
+}
+
+func (s *scriptedGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	s.calls = append(s.calls, args)
+	if len(s.calls) <= s.failCount {
+		return errors.New("scripted failure")
+	}
+	if args[0] == "clone" {
+		return os.MkdirAll(filepath.Join(args[len(args)-1], ".git"), 0755)
+	}
+	return nil
+}
+
+func TestCloneRepositoryRetries(t *testing.T) {
+	cloneRetryDelay = 0
+	t.Cleanup(func() { cloneRetryDelay = time.Second })
+
+	tests := []struct {
+		name      string
+		failCount int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first attempt succeeds", 0, 1, false},
+		{"retry with token", 1, 2, false},
+		{"all attempts fail", 3, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &scriptedGitRunner{failCount: tt.failCount}
+			err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", t.TempDir(), "repo", "glpat-secret")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CloneRepository() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(runner.calls) != tt.wantCalls {
+				t.Fatalf("git ran %d times, want %d", len(runner.calls), tt.wantCalls)
+			}
+			if strings.Contains(runner.calls[0][1], "glpat-secret") {
+				t.Errorf("first attempt must not embed the token: %v", runner.calls[0])
+			}
+			if tt.wantCalls > 1 && !strings.Contains(runner.calls[1][1], "oauth2:glpat-secret@") {
+				t.Errorf("retry should use the authenticated URL: %v", runner.calls[1])
+			}
+		})
+	}
+}
+
+func TestSetCloneAttempts(t *testing.T) {
+	cloneRetryDelay = 0
+	t.Cleanup(func() {
+		cloneRetryDelay = time.Second
+		cloneAttempts = 3
+	})
+
+	SetCloneAttempts(5)
+	SetCloneAttempts(0) // Ignored
+
+	runner := &scriptedGitRunner{failCount: 10}
+	if err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", t.TempDir(), "repo", ""); err == nil {
+		t.Fatal("CloneRepository() should fail")
+	}
+	if len(runner.calls) != 5 {
+		t.Errorf("git ran %d times, want 5", len(runner.calls))
+	}
+}
+
+func TestCloneRepositorySkipsExisting(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "repo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &scriptedGitRunner{}
+	if err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", baseDir, "repo", ""); err != nil {
+		t.Fatalf("CloneRepository() error = %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("git ran %d times for an existing clone, want 0", len(runner.calls))
+	}
+}
+
+func TestCloneRepositoryTimeout(t *testing.T) {
+	baseDir := t.TempDir()
+	runner := &timeoutGitRunner{}
+
+	err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", baseDir, "repo", "glpat-secret")
+	if !errors.Is(err, ErrGitTimeout) {
+		t.Fatalf("CloneRepository() error = %v, want ErrGitTimeout", err)
+	}
+	if runner.calls != 1 {
+		t.Errorf("git ran %d times, a timed out clone must not be retried", runner.calls)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "repo")); !os.IsNotExist(err) {
+		t.Errorf("partial clone should be removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, CloneTempDir)); !os.IsNotExist(err) {
+		t.Errorf("temporary clone directory should be removed, stat error = %v", err)
+	}
+}
+
+func TestCloneRepositoryRenamesIntoPlace(t *testing.T) {
+	baseDir := t.TempDir()
+	// An interrupted run left a partial clone behind
+	if err := os.MkdirAll(filepath.Join(CloneTempPath(filepath.Join(baseDir, "repo")), "stale"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &scriptedGitRunner{}
+	if err := CloneRepository(runner, io.Discard, nil, "https://gitlab.com/group/repo.git", baseDir, "repo", ""); err != nil {
+		t.Fatalf("CloneRepository() error = %v", err)
+	}
+	if got := runner.calls[0][len(runner.calls[0])-1]; got != filepath.Join(baseDir, CloneTempDir, "repo") {
+		t.Errorf("cloned into %s, want the temporary directory", got)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "repo", ".git")); err != nil {
+		t.Errorf("clone was not renamed into place: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "repo", "stale")); !os.IsNotExist(err) {
+		t.Errorf("the partial clone of the interrupted run was kept, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, CloneTempDir)); !os.IsNotExist(err) {
+		t.Errorf("temporary clone directory should be removed, stat error = %v", err)
+	}
+}
+
+/*
+shallowGitRunner rejects clones with --shallow-since like git does for
+repositories without commits after the date.
+*/
+type shallowGitRunner struct {
+	calls [][]string
+}
+
+func (s *shallowGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	s.calls = append(s.calls, args)
+	if slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--shallow-since") }) {
+		io.WriteString(stderr, "fatal: no commits selected for shallow requests\n")
+		return errors.New("exit status 128")
+	}
+	return os.MkdirAll(filepath.Join(args[len(args)-1], ".git"), 0755)
+}
+
+func TestCloneRepositoryShallowSinceWithoutCommits(t *testing.T) {
+	runner := &shallowGitRunner{}
+	args := []string{"--shallow-since=2023-01-01", "--no-tags"}
+	var warnings []string
+	status := func(warning bool, message string) {
+		if warning {
+			warnings = append(warnings, message)
+		}
+	}
+	if err := CloneRepository(runner, io.Discard, status, "https://gitlab.com/group/repo.git", t.TempDir(), "repo", "", args...); err != nil {
+		t.Fatalf("CloneRepository() error = %v", err)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("git ran %d times, want 2", len(runner.calls))
+	}
+	if got := runner.calls[1][:3]; !slices.Equal(got, []string{"clone", "--depth=1", "--no-tags"}) {
+		t.Errorf("second clone = %v, want --shallow-since replaced by --depth=1", runner.calls[1])
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "No commits since --shallow-since") {
+		t.Errorf("status warnings = %q, want the fallback to the latest commit", warnings)
+	}
+	if args[0] != "--shallow-since=2023-01-01" {
+		t.Errorf("the caller's arguments were changed: %v", args)
+	}
+}
+
+func TestWithoutShallowSince(t *testing.T) {
+	tests := []struct {
+		args      []string
+		want      []string
+		wantFound bool
+	}{
+		{[]string{"--shallow-since=2023-01-01"}, []string{"--depth=1"}, true},
+		{[]string{"--shallow-since", "2023-01-01", "--no-tags"}, []string{"--depth=1", "--no-tags"}, true},
+		{[]string{"--filter=blob:none"}, []string{"--filter=blob:none"}, false},
+	}
+	for _, tt := range tests {
+		got, found := withoutShallowSince(tt.args)
+		if !slices.Equal(got, tt.want) || found != tt.wantFound {
+			t.Errorf("withoutShallowSince(%v) = %v, %v, want %v, %v", tt.args, got, found, tt.want, tt.wantFound)
+		}
+	}
+}
+
+func TestGitEnvDisablesPrompts(t *testing.T) {
+	t.Cleanup(func() { SetAllowGitPrompts(false) })
+	t.Setenv("GIT_TERMINAL_PROMPT", "1")
+
+	// Later entries win when git reads its environment
+	env := gitEnv()
+	if got := env[len(env)-2:]; !slices.Equal(got, []string{"GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS="}) {
+		t.Errorf("gitEnv() ends with %v, want prompts disabled", got)
+	}
+
+	t.Setenv("GIT_SSH_COMMAND", "ssh -i ~/.ssh/deploy")
+	SetKnownHostsFile("/srv/workspace/.reposync/known_hosts")
+	t.Cleanup(func() { SetKnownHostsFile("") })
+	want := "GIT_SSH_COMMAND=ssh -i ~/.ssh/deploy -o BatchMode=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile='/srv/workspace/.reposync/known_hosts'"
+	if env := gitEnv(); !slices.Contains(env, want) {
+		t.Errorf("gitEnv() = %v, want %q", env, want)
+	}
+
+	SetAllowGitPrompts(true)
+	SetKnownHostsFile("")
+	if env := gitEnv(); slices.Contains(env, "GIT_TERMINAL_PROMPT=0") || slices.ContainsFunc(env, func(entry string) bool { return strings.Contains(entry, "BatchMode") }) {
+		t.Errorf("gitEnv() = %v, prompts should be allowed", env)
+	}
+}
+
+/*
+timeoutGitRunner leaves a partial clone behind and reports a timeout, like a killed git clone.
+*/
+type timeoutGitRunner struct {
+	calls int
+}
+
+func (r *timeoutGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	r.calls++
+	if err := os.MkdirAll(filepath.Join(args[len(args)-1], ".git"), 0755); err != nil {
+		return err
+	}
+	return ErrGitTimeout
+}
+
+/*
+configGitRunner answers "config --get" from a fixed local config and records writes.
+*/
+type configGitRunner struct {
+	config map[string]string
+	writes [][]string
+}
+
+func (c *configGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	// args: -C <path> config ...
+	if len(args) >= 6 && args[4] == "--get" {
+		value, ok := c.config[args[5]]
+		if !ok {
+			return errors.New("key not set")
+		}
+		io.WriteString(stdout, value+"\n")
+		return nil
+	}
+	c.writes = append(c.writes, args[2:])
+	return nil
+}
+
+func TestApplyGitConfig(t *testing.T) {
+	runner := &configGitRunner{config: map[string]string{"user.email": "dev@example.com"}}
+	values := map[string]string{
+		"user.email":     "dev@example.com",
+		"commit.gpgsign": "true",
+	}
+
+	changed, err := ApplyGitConfig(runner, t.TempDir(), values)
+	if err != nil {
+		t.Fatalf("ApplyGitConfig() error = %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "commit.gpgsign" {
+		t.Errorf("changed = %v, want [commit.gpgsign]", changed)
+	}
+	if len(runner.writes) != 1 || strings.Join(runner.writes[0], " ") != "config --local commit.gpgsign true" {
+		t.Errorf("writes = %v", runner.writes)
+	}
+}
+
+/*
+branchGitRunner serves for-each-ref listings of local and origin branches
+and records the branch commands run against them.
+*/
+type branchGitRunner struct {
+	local  string // "branch<TAB>upstream" lines
+	remote string // refs/remotes/origin/... lines
+	writes []string
+}
+
+func (b *branchGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	command := strings.Join(args[2:], " ")
+	switch {
+	case strings.HasSuffix(command, "refs/remotes/origin"):
+		io.WriteString(stdout, b.remote)
+	case strings.HasPrefix(command, "for-each-ref --format=%(refname:short)%09"):
+		io.WriteString(stdout, b.local)
+	case strings.HasPrefix(command, "for-each-ref"):
+		for _, line := range strings.Split(b.local, "\n") {
+			branch, _, _ := strings.Cut(line, "\t")
+			io.WriteString(stdout, branch+"\n")
+		}
+	default:
+		b.writes = append(b.writes, command)
+	}
+	return nil
+}
+
+func TestFixUpstreams(t *testing.T) {
+	runner := &branchGitRunner{
+		local: "main\trefs/remotes/origin/master\n" + // Renamed upstream
+			"develop\t\n" + // Upstream lost
+			"release\trefs/remotes/origin/release\n" +
+			"topic\trefs/remotes/origin/main\n" + // Deliberately tracks another branch
+			"scratch\t\n", // Local only
+		remote: "refs/remotes/origin/HEAD\nrefs/remotes/origin/main\nrefs/remotes/origin/develop\nrefs/remotes/origin/release\nrefs/remotes/origin/topic\n",
+	}
+
+	fixed, err := FixUpstreams(runner, t.TempDir())
+	if err != nil {
+		t.Fatalf("FixUpstreams() error = %v", err)
+	}
+	if strings.Join(fixed, ",") != "main,develop" {
+		t.Errorf("fixed = %v, want [main develop]", fixed)
+	}
+	want := []string{"branch --set-upstream-to=origin/main main", "branch --set-upstream-to=origin/develop develop"}
+	if strings.Join(runner.writes, "|") != strings.Join(want, "|") {
+		t.Errorf("writes = %v, want %v", runner.writes, want)
+	}
+}
+
+func TestTrackAllBranches(t *testing.T) {
+	runner := &branchGitRunner{
+		local:  "main\trefs/remotes/origin/main\n",
+		remote: "refs/remotes/origin/HEAD\nrefs/remotes/origin/main\nrefs/remotes/origin/release/1.0\nrefs/remotes/origin/develop\n",
+	}
+
+	created, err := TrackAllBranches(runner, t.TempDir())
+	if err != nil {
+		t.Fatalf("TrackAllBranches() error = %v", err)
+	}
+	if strings.Join(created, ",") != "develop,release/1.0" {
+		t.Errorf("created = %v, want [develop release/1.0]", created)
+	}
+	want := []string{"branch --track develop refs/remotes/origin/develop", "branch --track release/1.0 refs/remotes/origin/release/1.0"}
+	if strings.Join(runner.writes, "|") != strings.Join(want, "|") {
+		t.Errorf("writes = %v, want %v", runner.writes, want)
+	}
+}
+
+func TestParsePrunedRefs(t *testing.T) {
+	output := "From https://github.com/acme/api\n" +
+		" - [deleted]         (none)     -> origin/feature/login\n" +
+		" - [gelöscht]        (nichts)   -> v1.0\n" + // Translated by git
+		"   3b18e51..9fceb02  main       -> origin/main\n" +
+		" * [new branch]      develop    -> origin/develop\n"
+
+	want := []string{"origin/feature/login", "v1.0"}
+	if got := parsePrunedRefs(output); !slices.Equal(got, want) {
+		t.Errorf("parsePrunedRefs() = %v, want %v", got, want)
+	}
+}
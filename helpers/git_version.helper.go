@@ -0,0 +1,140 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MinGitVersion is the oldest git release reposync works with (git -C, branch --set-upstream-to).
+const MinGitVersion = "2.0"
+
+/*
+cloneOptionVersions lists git clone options that users commonly pass through
+git_args or --git-arg, with the git release that introduced them.
+*/
+var cloneOptionVersions = []struct {
+	option     string
+	feature    string
+	version    string
+	takesValue bool
+}{
+	{"--dissociate", "sharing objects with other clones", "2.3", false},
+	{"--shallow-since", "shallow clone by date", "2.11", true},
+	{"--filter", "partial clone", "2.19", true},
+	{"--sparse", "sparse checkout", "2.25", false},
+	{"--reject-shallow", "rejecting shallow sources", "2.34", false},
+	{"--also-filter-submodules", "partial clone of submodules", "2.36", false},
+}
+
+// Git releases needed by features that cannot fall back to an older git like clone options do.
+const (
+	PruneTagsGitVersion       = "2.17" // git fetch --prune-tags, used by --prune-refs
+	NegativeRefspecGitVersion = "2.29" // Negative refspecs (^refs/remotes/origin/HEAD), used by restore
+)
+
+var gitVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+/*
+GitVersion returns the version of the installed git, e.g. "2.39.2".
+Fails with an actionable message when git is missing or older than MinGitVersion,
+so a sync stops before the first repository instead of failing every one of them.
+*/
+func GitVersion(runner GitRunner) (string, error) {
+	var out bytes.Buffer
+	if err := runner.Run(&out, io.Discard, "--version"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("git was not found in PATH: install git (https://git-scm.com/downloads) and run reposync again")
+		}
+		return "", fmt.Errorf("failed to run git --version: %w", err)
+	}
+
+	version := gitVersionPattern.FindString(out.String())
+	if version == "" {
+		return "", fmt.Errorf("unexpected output of git --version: %q", strings.TrimSpace(out.String()))
+	}
+	if compareVersions(version, MinGitVersion) < 0 {
+		return version, fmt.Errorf("git %s is too old: reposync needs git %s or newer", version, MinGitVersion)
+	}
+	return version, nil
+}
+
+/*
+SupportedCloneArgs splits clone arguments into those the given git version supports
+and descriptions of those it does not, such as "--filter=blob:none (partial clone, git 2.19)".
+Options given with a separate value ("--filter", "blob:none") are dropped together with it.
+*/
+func SupportedCloneArgs(version string, args []string) (supported, dropped []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		unsupported := false
+		for _, option := range cloneOptionVersions {
+			if arg != option.option && !strings.HasPrefix(arg, option.option+"=") {
+				continue
+			}
+			if compareVersions(version, option.version) >= 0 {
+				break
+			}
+			unsupported = true
+			if arg == option.option && option.takesValue && i+1 < len(args) {
+				i++
+				arg += " " + args[i]
+			}
+			dropped = append(dropped, fmt.Sprintf("%s (%s, git %s)", arg, option.feature, option.version))
+			break
+		}
+		if !unsupported {
+			supported = append(supported, arg)
+		}
+	}
+	return supported, dropped
+}
+
+/*
+RequireGitVersion checks that the given git version is at least minimum,
+the release the feature needs, so it fails up front instead of per repository.
+*/
+func RequireGitVersion(version, minimum, feature string) error {
+	if compareVersions(version, minimum) < 0 {
+		return fmt.Errorf("%s needs git %s or newer, the installed git is %s", feature, minimum, version)
+	}
+	return nil
+}
+
+/*
+SupportsCloneOption reports whether the given git version knows a git clone option,
+e.g. "--dissociate". Options missing from cloneOptionVersions are assumed to be supported.
+*/
+func SupportsCloneOption(version, option string) bool {
+	_, dropped := SupportedCloneArgs(version, []string{option})
+	return len(dropped) == 0
+}
+
+/*
+compareVersions compares two dotted version numbers, returning -1, 0 or 1.
+Missing components count as 0.
+*/
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// versionRunner answers git --version with fixed output.
+type versionRunner struct {
+	output string
+	err    error
+}
+
+func (v versionRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	if v.err != nil {
+		return v.err
+	}
+	_, err := io.WriteString(stdout, v.output)
+	return err
+}
+
+func TestGitVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		runner  versionRunner
+		want    string
+		wantErr string
+	}{
+		{"linux", versionRunner{output: "git version 2.39.2\n"}, "2.39.2", ""},
+		{"apple", versionRunner{output: "git version 2.37.1 (Apple Git-137.1)\n"}, "2.37.1", ""},
+		{"windows", versionRunner{output: "git version 2.45.1.windows.1\n"}, "2.45.1", ""},
+		{"too old", versionRunner{output: "git version 1.8.3.1\n"}, "1.8.3", "too old"},
+		{"missing", versionRunner{err: &exec.Error{Name: "git", Err: exec.ErrNotFound}}, "", "not found in PATH"},
+		{"broken", versionRunner{err: errors.New("exit status 1")}, "", "failed to run"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GitVersion(tt.runner)
+			if got != tt.want || (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("GitVersion() = %q, %v, want %q and an error containing %q", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportedCloneArgs(t *testing.T) {
+	args := []string{"--filter", "blob:none", "--sparse", "--depth=1", "--filter=tree:0"}
+	tests := []struct {
+		version       string
+		wantSupported []string
+		wantDropped   int
+	}{
+		{"2.45.1", args, 0},
+		{"2.20.0", []string{"--filter", "blob:none", "--depth=1", "--filter=tree:0"}, 1},
+		{"2.17.1", []string{"--depth=1"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			supported, dropped := SupportedCloneArgs(tt.version, args)
+			if !reflect.DeepEqual(supported, tt.wantSupported) || len(dropped) != tt.wantDropped {
+				t.Errorf("SupportedCloneArgs() = %v, %v, want %v and %d dropped", supported, dropped, tt.wantSupported, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestSupportsCloneOption(t *testing.T) {
+	if !SupportsCloneOption("2.3.0", "--dissociate") || SupportsCloneOption("2.2.1", "--dissociate") {
+		t.Error("--dissociate should be supported from git 2.3 on")
+	}
+	if !SupportsCloneOption("2.0.0", "--depth=1") {
+		t.Error("options without a known minimum version should be supported")
+	}
+}
+
+func TestRequireGitVersion(t *testing.T) {
+	if err := RequireGitVersion("2.29.0", NegativeRefspecGitVersion, "reposync restore"); err != nil {
+		t.Errorf("RequireGitVersion(2.29.0) error = %v", err)
+	}
+	if err := RequireGitVersion("2.25.1", NegativeRefspecGitVersion, "reposync restore"); err == nil || !strings.Contains(err.Error(), "needs git 2.29") {
+		t.Errorf("RequireGitVersion(2.25.1) error = %v, want it to name git 2.29", err)
+	}
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+HealthStatus tracks the outcome of the latest sync of a long-running reposync.
+Served on /healthz so container orchestrators can probe scheduled syncs.
+Safe for concurrent use by the sync loop and the HTTP server.
+*/
+type HealthStatus struct {
+	mu       sync.Mutex
+	lastRun  time.Time
+	lastErr  string
+	finished bool
+}
+
+/*
+Record stores the result of a completed sync.
+*/
+func (h *HealthStatus) Record(finishedAt time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRun = finishedAt.UTC()
+	h.finished = true
+	h.lastErr = ""
+	if err != nil {
+		h.lastErr = Redact(err.Error())
+	}
+}
+
+/*
+ServeHTTP answers health probes with the result of the latest sync as JSON.
+Responds 200 while the first sync is running and after successful syncs,
+503 after a failed one, so a stuck or broken deployment shows up as unhealthy.
+*/
+func (h *HealthStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	body := struct {
+		Status  string    `json:"status"`
+		LastRun time.Time `json:"last_run,omitzero"`
+		Error   string    `json:"error,omitempty"`
+	}{Status: "starting", LastRun: h.lastRun, Error: h.lastErr}
+	finished := h.finished
+	h.mu.Unlock()
+
+	status := http.StatusOK
+	switch {
+	case finished && body.Error != "":
+		body.Status = "failing"
+		status = http.StatusServiceUnavailable
+	case finished:
+		body.Status = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+/*
+ServeHealth starts an HTTP server answering /healthz from status in the background.
+*/
+func ServeHealth(addr string, status *HealthStatus) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", status)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.ListenAndServe()
+	return server
+}
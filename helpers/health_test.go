@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthStatus(t *testing.T) {
+	status := &HealthStatus{}
+	probe := func() (int, string) {
+		recorder := httptest.NewRecorder()
+		status.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+		return recorder.Code, recorder.Body.String()
+	}
+
+	if code, body := probe(); code != http.StatusOK || !strings.Contains(body, `"starting"`) {
+		t.Errorf("before the first sync: %d %s", code, body)
+	}
+
+	status.Record(time.Now(), errors.New("failed to fetch group info"))
+	if code, body := probe(); code != http.StatusServiceUnavailable || !strings.Contains(body, "failed to fetch group info") {
+		t.Errorf("after a failed sync: %d %s", code, body)
+	}
+
+	status.Record(time.Now(), nil)
+	if code, body := probe(); code != http.StatusOK || !strings.Contains(body, `"ok"`) {
+		t.Errorf("after a successful sync: %d %s", code, body)
+	}
+}
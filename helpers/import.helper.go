@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+ReadRepoManifest reads a manifest of Google's repo tool, e.g. a default.xml.
+*/
+func ReadRepoManifest(path string) (*models.RepoManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo manifest: %w", err)
+	}
+	manifest := &models.RepoManifest{}
+	if err := xml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse repo manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+/*
+ReadSubmodules reads the submodules declared in the .gitmodules file of a superproject,
+in the order they are declared. When the file is part of a checkout of the superproject,
+every submodule also gets the commit the superproject pins it at, and relative URLs
+(../other.git) are resolved against the superproject's origin.
+*/
+func ReadSubmodules(runner GitRunner, gitmodulesPath string) ([]models.Submodule, error) {
+	gitmodulesPath, err := filepath.Abs(gitmodulesPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(gitmodulesPath); err != nil {
+		return nil, fmt.Errorf("failed to read .gitmodules: %w", err)
+	}
+	superproject := filepath.Dir(gitmodulesPath)
+
+	// git parses its own config format, including quoting and includes
+	out, err := gitOutput(runner, superproject, "config", "--file", gitmodulesPath, "--null", "--get-regexp", `^submodule\.`)
+	if err != nil {
+		return nil, fmt.Errorf("no submodules declared in %s", gitmodulesPath)
+	}
+
+	var submodules []models.Submodule
+	index := map[string]int{}
+	for _, entry := range strings.Split(out, "\x00") {
+		key, value, _ := strings.Cut(entry, "\n")
+		dot := strings.LastIndex(key, ".")
+		if !strings.HasPrefix(key, "submodule.") || dot <= len("submodule.") {
+			continue
+		}
+		name, field := key[len("submodule."):dot], key[dot+1:]
+		i, ok := index[name]
+		if !ok {
+			i, index[name] = len(submodules), len(submodules)
+			submodules = append(submodules, models.Submodule{Name: name})
+		}
+		switch field {
+		case "path":
+			submodules[i].Path = value
+		case "url":
+			submodules[i].URL = value
+		}
+	}
+
+	for i := range submodules {
+		submodule := &submodules[i]
+		if submodule.Path == "" || submodule.URL == "" {
+			return nil, fmt.Errorf("submodule %q in %s has no path or url", submodule.Name, gitmodulesPath)
+		}
+		if strings.HasPrefix(submodule.URL, "./") || strings.HasPrefix(submodule.URL, "../") {
+			origin, err := OriginURL(runner, superproject)
+			if err != nil {
+				return nil, fmt.Errorf("submodule %q has the relative url %s: %w", submodule.Name, submodule.URL, err)
+			}
+			if submodule.URL, err = ResolveRelativeURL(origin, submodule.URL); err != nil {
+				return nil, err
+			}
+		}
+		// Outside a checkout there are no pins, the submodule follows its default branch
+		tree, err := gitOutput(runner, superproject, "ls-tree", "HEAD", "--", submodule.Path)
+		if fields := strings.Fields(tree); err == nil && len(fields) >= 3 && fields[1] == "commit" {
+			submodule.Commit = fields[2]
+		}
+	}
+	return submodules, nil
+}
+
+/*
+ResolveRelativeURL resolves a relative URL such as ../other.git against a base URL
+the way git resolves relative submodule URLs: the base is treated as a directory.
+Works for https://, ssh://, scp-like (git@host:group/repo.git) URLs and local paths.
+*/
+func ResolveRelativeURL(base, relative string) (string, error) {
+	base = strings.TrimSuffix(base, "/")
+	if parsed, err := url.Parse(base); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		parsed.Path = path.Join("/", parsed.Path, relative)
+		parsed.RawPath = ""
+		return parsed.String(), nil
+	}
+
+	prefix, repoPath := "", base
+	if user, rest, ok := strings.Cut(base, "@"); ok && !strings.Contains(user, "/") {
+		if host, hostPath, ok := strings.Cut(rest, ":"); ok {
+			prefix, repoPath = user+"@"+host+":", hostPath
+		}
+	}
+	resolved := path.Join(repoPath, relative)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", fmt.Errorf("relative url %s leads outside of %s", relative, base)
+	}
+	return prefix + resolved, nil
+}
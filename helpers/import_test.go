@@ -0,0 +1,25 @@
+package helpers
+
+import "testing"
+
+func TestResolveRelativeURL(t *testing.T) {
+	tests := []struct {
+		base, relative string
+		want           string
+		wantErr        bool
+	}{
+		{"https://gitlab.com/acme/super.git", "../lib.git", "https://gitlab.com/acme/lib.git", false},
+		{"https://android.googlesource.com/platform/manifest", "../..", "https://android.googlesource.com/", false},
+		{"git@github.com:acme/super.git", "../lib.git", "git@github.com:acme/lib.git", false},
+		{"git@github.com:acme/super.git", "../../../lib.git", "", true},
+		{"/srv/git/super.git", "../lib.git", "/srv/git/lib.git", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.base+" "+tt.relative, func(t *testing.T) {
+			got, err := ResolveRelativeURL(tt.base, tt.relative)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("ResolveRelativeURL() = %q, %v, want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+IndexData is the data passed to workspace index templates.
+Repositories are sorted by their path in the workspace.
+*/
+type IndexData struct {
+	Generated    time.Time
+	Repositories []models.RepositoryState
+}
+
+/*
+defaultIndexTemplate renders the workspace catalog as a Markdown table.
+*/
+const defaultIndexTemplate = `# Repository Index
+
+Generated by reposync on {{ .Generated.Format "2006-01-02 15:04 MST" }} ({{ len .Repositories }} repositories).
+
+| Repository | Description | Language | Last activity |
+| --- | --- | --- | --- |
+{{- range .Repositories }}
+| {{ if .WebURL }}[{{ .Path }}]({{ .WebURL }}){{ else }}{{ .Path }}{{ end }} | {{ cell .Description }} | {{ .Language }} | {{ if not .LastActivity.IsZero }}{{ .LastActivity.Format "2006-01-02" }}{{ end }} |
+{{- end }}
+`
+
+/*
+indexExecutor is satisfied by both text/template and html/template templates.
+*/
+type indexExecutor interface {
+	Execute(w io.Writer, data any) error
+}
+
+/*
+WriteIndex renders a browsable catalog of the repositories in the state.
+Uses the built-in Markdown template unless templatePath names a Go template;
+templates ending in .html are rendered with html/template so descriptions are escaped.
+The output path is relative to the workspace root.
+*/
+func WriteIndex(workspace, outputPath, templatePath string, state *models.State) error {
+	if !filepath.IsAbs(outputPath) {
+		outputPath = filepath.Join(workspace, outputPath)
+	}
+	if err := ensureWritable(outputPath); err != nil {
+		return err
+	}
+
+	tmpl, err := parseIndexTemplate(templatePath)
+	if err != nil {
+		return err
+	}
+
+	data := IndexData{Generated: time.Now()}
+	for _, repository := range state.Repositories {
+		data.Repositories = append(data.Repositories, repository)
+	}
+	sort.Slice(data.Repositories, func(i, j int) bool {
+		return data.Repositories[i].Path < data.Repositories[j].Path
+	})
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to render index: %w", err)
+	}
+	return nil
+}
+
+/*
+parseIndexTemplate loads the index template, falling back to the built-in one.
+*/
+func parseIndexTemplate(templatePath string) (indexExecutor, error) {
+	funcs := map[string]any{
+		// Keeps free-form descriptions from breaking Markdown table rows
+		"cell": func(s string) string {
+			return strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "|", "\\|")
+		},
+	}
+
+	if templatePath == "" {
+		return template.New("index").Funcs(funcs).Parse(defaultIndexTemplate)
+	}
+
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index template: %w", err)
+	}
+
+	var tmpl indexExecutor
+	if strings.HasSuffix(templatePath, ".html") || strings.HasSuffix(templatePath, ".html.tmpl") {
+		tmpl, err = htmltemplate.New("index").Funcs(funcs).Parse(string(content))
+	} else {
+		tmpl, err = template.New("index").Funcs(funcs).Parse(string(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestWriteIndex(t *testing.T) {
+	workspace := t.TempDir()
+	state := &models.State{Repositories: map[string]models.RepositoryState{
+		"github:2": {Path: "web", Description: "Frontend | app", WebURL: "https://github.com/org/web"},
+		"github:1": {Path: "api", Description: "Backend", Language: "Go"},
+	}}
+
+	if err := WriteIndex(workspace, "INDEX.md", "", state); err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(workspace, "INDEX.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(data)
+
+	for _, want := range []string{
+		"| api | Backend | Go |",
+		"| [web](https://github.com/org/web) | Frontend \\| app |",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index is missing %q:\n%s", want, index)
+		}
+	}
+	if strings.Index(index, "| api") > strings.Index(index, "[web]") {
+		t.Errorf("repositories are not sorted by path:\n%s", index)
+	}
+}
@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+GetKnownHostsPath returns the location of the reposync-managed known_hosts file for a workspace root.
+*/
+func GetKnownHostsPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "known_hosts")
+}
+
+// knownHostsFile is the known_hosts file SSH clones trust (empty: ssh's own configuration).
+var knownHostsFile string
+
+/*
+SetKnownHostsFile makes SSH connections of git trust the host keys pinned in a
+reposync-managed known_hosts file (--accept-new-hostkeys) instead of prompting.
+Keys of hosts that were not pinned beforehand are accepted and added on first
+contact; a key that differs from the pinned one is still rejected.
+*/
+func SetKnownHostsFile(path string) {
+	knownHostsFile = path
+}
+
+/*
+sshCommand returns GIT_SSH_COMMAND for the managed known_hosts file and for batch mode
+unless prompts are allowed, extending a GIT_SSH_COMMAND of the user's environment if there is one.
+*/
+func sshCommand() string {
+	command := os.Getenv("GIT_SSH_COMMAND")
+	if command == "" {
+		command = "ssh"
+	}
+	if !allowPrompts {
+		command += " -o BatchMode=yes"
+	}
+	if knownHostsFile != "" {
+		command += " -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=" + shellQuote(knownHostsFile)
+	}
+	return command
+}
+
+/*
+shellQuote quotes a value for the shell that runs GIT_SSH_COMMAND.
+*/
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+/*
+SSHHost returns the known_hosts name of the host of an SSH clone URL, scp-like
+(git@host:path) or ssh://, with the port when it is not 22: "host" or "[host]:port".
+*/
+func SSHHost(cloneURL string) (string, bool) {
+	if parsed, err := url.Parse(cloneURL); err == nil && parsed.Scheme != "" {
+		if parsed.Scheme != "ssh" || parsed.Hostname() == "" {
+			return "", false
+		}
+		if port := parsed.Port(); port != "" && port != "22" {
+			return fmt.Sprintf("[%s]:%s", parsed.Hostname(), port), true
+		}
+		return parsed.Hostname(), true
+	}
+	at, rest, found := strings.Cut(cloneURL, "@")
+	host, _, hasPath := strings.Cut(rest, ":")
+	if !found || !hasPath || host == "" || strings.Contains(at, "/") {
+		return "", false
+	}
+	return host, true
+}
+
+// keyscan fetches the public host keys of an SSH server, in known_hosts format.
+var keyscan = func(host, port string) ([]byte, error) {
+	return exec.Command("ssh-keyscan", "-T", "10", "-p", port, host).Output()
+}
+
+/*
+PinHostKeys adds the host keys of SSH hosts that are not in a known_hosts file yet,
+fetched like ssh-keyscan does, so the first clones from a new host don't stop at the
+host key prompt. Hosts are given as returned by SSHHost. Returns the hosts that were
+pinned; hosts whose keys could not be fetched are reported in the error, and their
+key is then accepted on first contact instead.
+*/
+func PinHostKeys(path string, hosts []string) ([]string, error) {
+	known, err := readKnownHosts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, host := range hosts {
+		if !known[host] {
+			known[host] = true
+			missing = append(missing, host)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	sort.Strings(missing)
+
+	if err := ensureWritable(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known hosts file: %w", err)
+	}
+	defer file.Close()
+
+	var pinned, failed []string
+	for _, host := range missing {
+		name, port := host, "22"
+		if strings.HasPrefix(host, "[") {
+			name, port, _ = strings.Cut(strings.TrimPrefix(host, "["), "]:")
+		}
+		keys, err := keyscan(name, port)
+		if err != nil || len(strings.TrimSpace(string(keys))) == 0 {
+			failed = append(failed, host)
+			continue
+		}
+		if _, err := file.Write(keys); err != nil {
+			return pinned, fmt.Errorf("failed to write known hosts file: %w", err)
+		}
+		pinned = append(pinned, host)
+	}
+	if len(failed) > 0 {
+		return pinned, fmt.Errorf("failed to fetch the host keys of %s", strings.Join(failed, ", "))
+	}
+	return pinned, nil
+}
+
+/*
+readKnownHosts returns the host names listed in a known_hosts file.
+A missing file lists no hosts.
+*/
+func readKnownHosts(path string) (map[string]bool, error) {
+	known := map[string]bool{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return known, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, name := range strings.Split(fields[0], ",") {
+			known[name] = true
+		}
+	}
+	return known, scanner.Err()
+}
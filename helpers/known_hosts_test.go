@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSSHHost(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{"git@gitlab.example.com:group/api.git", "gitlab.example.com", true},
+		{"ssh://git@gitlab.example.com:2222/group/api.git", "[gitlab.example.com]:2222", true},
+		{"ssh://git@github.com:22/acme/api.git", "github.com", true},
+		{"https://github.com/acme/api.git", "", false},
+		{"/srv/git/api.git", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, ok := SSHHost(tt.url)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("SSHHost(%q) = %q, %v, want %q, %v", tt.url, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPinHostKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".reposync", "known_hosts")
+	original := keyscan
+	t.Cleanup(func() { keyscan = original })
+	var scanned []string
+	keyscan = func(host, port string) ([]byte, error) {
+		scanned = append(scanned, host+":"+port)
+		if host == "offline.example.com" {
+			return nil, errors.New("connection refused")
+		}
+		name := host
+		if port != "22" {
+			name = "[" + host + "]:" + port
+		}
+		return []byte(name + " ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAITEST\n"), nil
+	}
+
+	pinned, err := PinHostKeys(path, []string{"gitlab.example.com", "[gitlab.example.com]:2222", "gitlab.example.com", "offline.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "offline.example.com") {
+		t.Errorf("PinHostKeys() error = %v, want the unreachable host reported", err)
+	}
+	if want := []string{"[gitlab.example.com]:2222", "gitlab.example.com"}; !reflect.DeepEqual(pinned, want) {
+		t.Errorf("PinHostKeys() = %v, want %v", pinned, want)
+	}
+
+	// Pinned hosts are not fetched again
+	scanned = nil
+	if pinned, err := PinHostKeys(path, []string{"gitlab.example.com", "[gitlab.example.com]:2222"}); err != nil || len(pinned) != 0 || len(scanned) != 0 {
+		t.Errorf("PinHostKeys() = %v, %v after pinning, scanned %v, want nothing to do", pinned, err, scanned)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Count(string(data), "ssh-ed25519") != 2 {
+		t.Errorf("known_hosts = %q, %v, want two keys", data, err)
+	}
+}
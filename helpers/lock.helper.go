@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// LockfileName is the file reposync lock writes in the workspace root.
+const LockfileName = "reposync.lock"
+
+/*
+GetLockfilePath returns the location of the lockfile for a workspace root.
+*/
+func GetLockfilePath(workspace string) string {
+	return filepath.Join(workspace, LockfileName)
+}
+
+/*
+LoadLockfile reads a lockfile. Unlike the state, a missing file is an error:
+a run that was asked to follow a lockfile cannot go on without one.
+*/
+func LoadLockfile(path string) (*models.Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	lock := &models.Lockfile{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	for _, repository := range lock.Repositories {
+		if repository.Commit == "" {
+			return nil, fmt.Errorf("invalid lockfile %s: %s has no commit", path, repository.Path)
+		}
+	}
+	return lock, nil
+}
+
+/*
+SaveLockfile writes a lockfile, through a temporary file like SaveState.
+*/
+func SaveLockfile(path string, lock *models.Lockfile) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace lockfile: %w", err)
+	}
+	return nil
+}
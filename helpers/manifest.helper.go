@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+GetManifestPath returns the location of the manifest for a workspace root.
+*/
+func GetManifestPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "manifest.json")
+}
+
+/*
+LoadManifest reads the workspace manifest.
+Workspaces without a manifest get an empty one, so it stays optional.
+*/
+func LoadManifest(workspace string) (*models.Manifest, error) {
+	manifest := &models.Manifest{}
+
+	data, err := os.ReadFile(GetManifestPath(workspace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", GetManifestPath(workspace), err)
+	}
+	for path, pin := range manifest.Pins {
+		if strings.TrimSpace(pin.Revision) == "" {
+			return nil, fmt.Errorf("invalid manifest %s: the pin of %s has no revision", GetManifestPath(workspace), path)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+/*
+OriginURL returns the URL of a clone's origin remote without credentials,
+so it can be shared, e.g. in the .gitmodules file of a meta repository.
+*/
+func OriginURL(runner GitRunner, repoPath string) (string, error) {
+	remote, err := gitOutput(runner, repoPath, "remote", "get-url", "origin")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the origin of %s: %w", repoPath, err)
+	}
+	return StripURLCredentials(remote), nil
+}
+
+/*
+StripURLCredentials removes a username and token from an HTTP(S) remote URL,
+such as the ones left in a clone's config by token-authenticated clones.
+Other URLs are returned unchanged. The URL is not parsed, as git output read
+through ExecGitRunner has its tokens redacted to a value url.Parse rejects.
+*/
+func StripURLCredentials(remote string) string {
+	for _, scheme := range []string{"https://", "http://"} {
+		rest, ok := strings.CutPrefix(remote, scheme)
+		if !ok {
+			continue
+		}
+		host, _, _ := strings.Cut(rest, "/")
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			return scheme + rest[at+1:]
+		}
+	}
+	return remote
+}
+
+/*
+InitMetaRepository makes a directory a git repository unless it is one already.
+reposync's own files in .reposync are excluded, so only the pinned submodules
+ever show up in its commits and status.
+*/
+func InitMetaRepository(runner GitRunner, dir string) error {
+	if err := ensureWritable(dir); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := gitRun(runner, dir, "init", "--quiet"); err != nil {
+			return err
+		}
+	}
+
+	excludePath := filepath.Join(dir, ".git", "info", "exclude")
+	exclude, err := os.ReadFile(excludePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", excludePath, err)
+	}
+	if strings.Contains(string(exclude), "/.reposync/") {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(excludePath), err)
+	}
+	file, err := os.OpenFile(excludePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", excludePath, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString("/.reposync/\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", excludePath, err)
+	}
+	return nil
+}
+
+/*
+PinSubmodule records an existing clone as a submodule of a meta repository at a commit,
+without cloning it again: the .gitmodules entry is written and the commit is staged
+as a gitlink. path is relative to the meta repository and uses forward slashes.
+*/
+func PinSubmodule(runner GitRunner, metaDir, path, remoteURL, commit string) error {
+	if err := ensureWritable(metaDir); err != nil {
+		return err
+	}
+	for key, value := range map[string]string{"path": path, "url": remoteURL} {
+		if err := gitRun(runner, metaDir, "config", "--file", ".gitmodules", "submodule."+path+"."+key, value); err != nil {
+			return err
+		}
+	}
+	return gitRun(runner, metaDir, "update-index", "--add", "--cacheinfo", "160000,"+commit+","+path)
+}
+
+/*
+CommitMetaRepository commits the staged submodule pins with a message.
+Returns the new commit, or an empty string when no pin changed since the last commit.
+*/
+func CommitMetaRepository(runner GitRunner, metaDir, message string) (string, error) {
+	if err := ensureWritable(metaDir); err != nil {
+		return "", err
+	}
+	if err := gitRun(runner, metaDir, "add", ".gitmodules"); err != nil {
+		return "", err
+	}
+	err := gitRun(runner, metaDir, "diff", "--cached", "--quiet")
+	var exitErr *exec.ExitError
+	if err == nil {
+		return "", nil
+	} else if !errors.As(err, &exitErr) {
+		return "", err
+	}
+	if err := gitRun(runner, metaDir, "commit", "--quiet", "-m", message); err != nil {
+		return "", err
+	}
+	return gitOutput(runner, metaDir, "rev-parse", "HEAD")
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	colors "github.com/itszeeshan/reposync/constants/colors"
 )
@@ -54,14 +56,40 @@ func ValidateOrganizationName(org string) error {
 
 /*
 ValidateGroupID validates group ID format.
-Ensures group ID is a valid integer.
+Ensures group ID is a valid integer or the full path of a subgroup, such as parent/child.
 */
 func ValidateGroupID(groupID string) error {
 	if groupID == "" {
 		return errors.New("group ID cannot be empty")
 	}
+	if IsGroupPath(groupID) {
+		return nil
+	}
 	if _, err := strconv.Atoi(groupID); err != nil {
-		return errors.New("group ID must be a valid integer")
+		return errors.New("group ID must be a valid integer or a subgroup path such as parent/child")
+	}
+	return nil
+}
+
+/*
+IsGroupPath reports whether a GitLab group is given by the full path of a subgroup
+(parent/child/grandchild) rather than by its numeric ID.
+*/
+func IsGroupPath(groupID string) bool {
+	return regexp.MustCompile(`^[a-zA-Z0-9_.-]+(/[a-zA-Z0-9_.-]+)+$`).MatchString(groupID)
+}
+
+/*
+ValidateProjectKey validates the format of a Bitbucket Server project key.
+Keys start with a letter and contain letters, digits and underscores;
+personal projects use the user's slug prefixed with "~".
+*/
+func ValidateProjectKey(key string) error {
+	if key == "" {
+		return errors.New("project key cannot be empty")
+	}
+	if !regexp.MustCompile(`^(~[a-zA-Z0-9._@-]+|[a-zA-Z][a-zA-Z0-9_]*)$`).MatchString(key) {
+		return errors.New("invalid project key format")
 	}
 	return nil
 }
@@ -81,7 +109,10 @@ func GetGitLabAPIURL(baseURL, endpoint string) string {
 
 /*
 GetGitHubAPIURL constructs the GitHub API URL for a given endpoint.
-Supports both cloud GitHub and GitHub Enterprise.
+Supports both cloud GitHub and GitHub Enterprise Server: a bare instance URL
+(https://github.example.com) gets the /api/v3 prefix GHES serves its API under,
+and https://github.com is mapped to https://api.github.com.
+Base URLs that already carry a path are used as they are.
 */
 func GetGitHubAPIURL(baseURL, endpoint string) string {
 	if baseURL == "" {
@@ -89,5 +120,84 @@ func GetGitHubAPIURL(baseURL, endpoint string) string {
 	}
 	// Ensure baseURL doesn't end with slash
 	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Path == "" {
+		switch parsed.Host {
+		case "api.github.com":
+		case "github.com", "www.github.com":
+			baseURL = "https://api.github.com"
+		default:
+			baseURL += "/api/v3"
+		}
+	}
 	return fmt.Sprintf("%s%s", baseURL, endpoint)
 }
+
+/*
+GetBitbucketServerAPIURL constructs the REST API URL of a Bitbucket Server or
+Data Center instance for a given endpoint. There is no cloud default: Bitbucket
+Cloud has an API of its own, so the instance URL is always required.
+*/
+func GetBitbucketServerAPIURL(baseURL, endpoint string) string {
+	return fmt.Sprintf("%s/rest/api/1.0%s", strings.TrimSuffix(baseURL, "/"), endpoint)
+}
+
+/*
+GetNextPageURL extracts the rel="next" target from an HTTP Link header.
+GitLab keyset pagination and GitHub pagination both advertise the next page this way;
+an empty string means the last page was reached.
+*/
+func GetNextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+/*
+ParseFailureBudget parses a failure budget: a count such as "10" or a percentage
+such as "5%". Exactly one of the results is set; "0" and "0%" are rejected.
+*/
+func ParseFailureBudget(s string) (count, percent int, err error) {
+	value, isPercent := strings.CutSuffix(strings.TrimSpace(s), "%")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || (isPercent && n > 100) {
+		return 0, 0, fmt.Errorf("invalid failure budget %q: use a count such as 10 or a percentage such as 5%%", s)
+	}
+	if isPercent {
+		return 0, n, nil
+	}
+	return n, 0, nil
+}
+
+/*
+ParseAge parses an age threshold such as "180d", "2w" or "36h".
+Days and weeks are accepted in addition to the units of time.ParseDuration,
+since staleness is usually measured in days.
+*/
+func ParseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: use a value like 180d, 2w or 36h", s)
+	}
+	return d, nil
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"testing"
+	"time"
 )
 
 func TestValidateToken(t *testing.T) {
@@ -49,6 +50,30 @@ func TestValidateOrganizationName(t *testing.T) {
 	}
 }
 
+func TestValidateProjectKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"empty key", "", true},
+		{"valid key", "PLAT", false},
+		{"valid key with digits and underscores", "TEAM_2", false},
+		{"personal project", "~jdoe", false},
+		{"key starting with a digit", "2TEAM", true},
+		{"key with a slash", "PLAT/api", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProjectKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProjectKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateGroupID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -59,6 +84,9 @@ func TestValidateGroupID(t *testing.T) {
 		{"valid group ID", "123456", false},
 		{"invalid group ID", "abc", true},
 		{"invalid group ID with letters", "123abc", true},
+		{"subgroup path", "parent/child/grandchild", false},
+		{"path with empty segment", "parent//child", true},
+		{"path with trailing slash", "parent/child/", true},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +131,8 @@ func TestGetGitHubAPIURL(t *testing.T) {
 		{"default URL", "", "/orgs/my-org", "https://api.github.com/orgs/my-org"},
 		{"custom URL", "https://github.company.com/api/v3", "/orgs/my-org", "https://github.company.com/api/v3/orgs/my-org"},
 		{"URL with trailing slash", "https://github.company.com/api/v3/", "/orgs/my-org", "https://github.company.com/api/v3/orgs/my-org"},
+		{"enterprise host without API path", "https://github.company.com", "/orgs/my-org", "https://github.company.com/api/v3/orgs/my-org"},
+		{"github.com web URL", "https://github.com/", "/orgs/my-org", "https://api.github.com/orgs/my-org"},
 	}
 
 	for _, tt := range tests {
@@ -114,3 +144,79 @@ func TestGetGitHubAPIURL(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNextPageURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty header", "", ""},
+		{"next only", `<https://gitlab.com/api/v4/projects?id_after=42>; rel="next"`, "https://gitlab.com/api/v4/projects?id_after=42"},
+		{"next and first", `<https://gitlab.com/api/v4/projects?page=1>; rel="first", <https://gitlab.com/api/v4/projects?id_after=42>; rel="next"`, "https://gitlab.com/api/v4/projects?id_after=42"},
+		{"last page", `<https://api.github.com/orgs/x/repos?page=1>; rel="first", <https://api.github.com/orgs/x/repos?page=3>; rel="prev"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetNextPageURL(tt.header)
+			if got != tt.want {
+				t.Errorf("GetNextPageURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"180d", 180 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"d", 0, true},
+		{"-5d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAge(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFailureBudget(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantCount   int
+		wantPercent int
+		wantErr     bool
+	}{
+		{"10", 10, 0, false},
+		{"5%", 0, 5, false},
+		{"0", 0, 0, true},
+		{"150%", 0, 0, true},
+		{"many", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			count, percent, err := ParseFailureBudget(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFailureBudget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != tt.wantCount || percent != tt.wantPercent {
+				t.Errorf("ParseFailureBudget() = %d, %d%%, want %d, %d%%", count, percent, tt.wantCount, tt.wantPercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// ErrReadOnly is returned by every mutating helper while read-only mode is active.
+var ErrReadOnly = errors.New("refusing to modify the filesystem in --no-write mode")
+
+var readOnly atomic.Bool
+
+/*
+SetReadOnly switches the process-wide read-only safety mode on or off.
+While enabled, helpers that clone, move, rewrite or save anything fail with
+ErrReadOnly, so a misbehaving code path cannot touch a production volume.
+*/
+func SetReadOnly(enabled bool) {
+	readOnly.Store(enabled)
+}
+
+/*
+IsReadOnly reports whether read-only safety mode is active.
+*/
+func IsReadOnly() bool {
+	return readOnly.Load()
+}
+
+/*
+ensureWritable guards a filesystem mutation of the given path.
+*/
+func ensureWritable(path string) error {
+	if readOnly.Load() {
+		return fmt.Errorf("%w: %s", ErrReadOnly, path)
+	}
+	return nil
+}
+
+/*
+EnsureDirectory creates a directory (and its parents) unless read-only mode is active.
+*/
+func EnsureDirectory(path string) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	return os.MkdirAll(path, os.ModePerm)
+}
+
+/*
+MoveDirectory renames a directory unless read-only mode is active,
+creating the parent directories of the destination first.
+*/
+func MoveDirectory(from, to string) error {
+	if err := ensureWritable(from); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", to, err)
+	}
+	return os.Rename(from, to)
+}
+
+/*
+RemoveDirectory deletes a directory and everything in it unless read-only mode is active.
+*/
+func RemoveDirectory(path string) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+/*
+RemoveEmptyDirectory deletes a directory if it exists and holds nothing, unless read-only
+mode is active. Reports whether the directory was removed.
+*/
+func RemoveEmptyDirectory(path string) (bool, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil || len(entries) > 0 {
+		return false, nil
+	}
+	if err := ensureWritable(path); err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+GetRegistryPath returns the location of the machine-wide registry.
+REPOSYNC_REGISTRY overrides the default ~/.reposync/registry.json, e.g. to give
+CI jobs on a shared machine a registry of their own.
+*/
+func GetRegistryPath() (string, error) {
+	if path := os.Getenv("REPOSYNC_REGISTRY"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".reposync", "registry.json"), nil
+}
+
+/*
+LoadRegistry reads the machine-wide registry.
+A missing file yields an empty registry, as on a machine reposync never synced on.
+*/
+func LoadRegistry(path string) (*models.Registry, error) {
+	registry := &models.Registry{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		registry.Clones = map[string][]string{}
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse registry %s: %w", path, err)
+	}
+	if registry.Clones == nil {
+		registry.Clones = map[string][]string{}
+	}
+	return registry, nil
+}
+
+/*
+SaveRegistry writes the machine-wide registry through a temporary file, like SaveState.
+*/
+func SaveRegistry(path string, registry *models.Registry) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create registry directory: %w", err)
+	}
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write registry: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace registry: %w", err)
+	}
+	return nil
+}
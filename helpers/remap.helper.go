@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+GetRemapPath returns the location of the destination remap file for a workspace root.
+*/
+func GetRemapPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "remap.json")
+}
+
+/*
+LoadRemap reads a destination remap file.
+A missing file yields an empty remap, so it stays optional. Every directory in
+the file must be relative and stay inside the workspace.
+*/
+func LoadRemap(path string) (*models.Remap, error) {
+	remap := &models.Remap{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return remap, nil
+		}
+		return nil, fmt.Errorf("failed to read remap file: %w", err)
+	}
+	if err := json.Unmarshal(data, remap); err != nil {
+		return nil, fmt.Errorf("failed to parse remap file %s: %w", path, err)
+	}
+
+	for from, to := range remap.Repositories {
+		if !filepath.IsLocal(filepath.FromSlash(to)) {
+			return nil, fmt.Errorf("invalid remap of %s: %q must be relative and stay inside the workspace", from, to)
+		}
+	}
+	for from, to := range remap.Paths {
+		if !filepath.IsLocal(filepath.FromSlash(from)) || !filepath.IsLocal(filepath.FromSlash(to)) {
+			return nil, fmt.Errorf("invalid remap %q → %q: both must be relative and stay inside the workspace", from, to)
+		}
+	}
+	return remap, nil
+}
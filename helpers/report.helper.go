@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+WriteJSONReport writes a report as indented JSON.
+Like the state file, it is written to a temporary file and renamed into place.
+*/
+func WriteJSONReport(path string, report any) error {
+	return writeJSONReport(path, report, 0644)
+}
+
+/*
+WritePrivateJSONReport writes a report containing secrets as indented JSON.
+Only the owner can read the file.
+*/
+func WritePrivateJSONReport(path string, report any) error {
+	return writeJSONReport(path, report, 0600)
+}
+
+/*
+writeJSONReport implements WriteJSONReport with the given file permissions.
+*/
+func writeJSONReport(path string, report any, perm os.FileMode) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath) // A leftover temporary file would keep its old permissions
+	if err := os.WriteFile(tmpPath, data, perm); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace report: %w", err)
+	}
+	return nil
+}
+
+/*
+WriteNDJSONReport writes records as newline-delimited JSON, one record per line.
+Written to a temporary file and renamed into place like WriteJSONReport.
+*/
+func WriteNDJSONReport[T any](path string, records []T) error {
+	if err := ensureWritable(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+DefaultScanCommand runs gitleaks over the full history of a clone.
+{path} is replaced with the clone directory and {report} with a file
+the scanner writes its JSON findings to.
+*/
+var DefaultScanCommand = []string{
+	"gitleaks", "detect", "--no-banner", "--source", "{path}",
+	"--report-format", "json", "--report-path", "{report}", "--exit-code", "0",
+}
+
+/*
+ScanRepository runs the scanner command over one clone and collects its findings.
+The scanner is expected to write a JSON array to {report}; its exit code is
+recorded as well, so scanners that signal findings through it still show up.
+*/
+func ScanRepository(command []string, repoPath string) models.RepositoryScan {
+	result := models.RepositoryScan{Path: repoPath, Findings: []json.RawMessage{}}
+
+	reportFile, err := os.CreateTemp("", "reposync-scan-*.json")
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create scan report: %v", err)
+		return result
+	}
+	reportFile.Close()
+	defer os.Remove(reportFile.Name())
+
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		absPath = repoPath
+	}
+	replacer := strings.NewReplacer("{path}", absPath, "{report}", reportFile.Name())
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		result.Error = fmt.Sprintf("failed to run scanner: %v", err)
+		return result
+	}
+
+	data, err := os.ReadFile(reportFile.Name())
+	if err == nil && len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &result.Findings); err != nil {
+			result.Error = fmt.Sprintf("failed to parse scanner report: %v", err)
+		}
+	}
+	if result.ExitCode != 0 && result.Error == "" {
+		result.Error = strings.TrimSpace(stderr.String())
+	}
+	return result
+}
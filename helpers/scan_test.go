@@ -0,0 +1,34 @@
+package helpers
+
+import "testing"
+
+func TestScanRepository(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		wantFindings int
+		wantExitCode int
+		wantErr      bool
+	}{
+		{"findings", `echo '[{"RuleID":"aws-key"},{"RuleID":"token"}]' > "$1"`, 2, 0, false},
+		{"clean", `echo '[]' > "$1"`, 0, 0, false},
+		{"no report", `exit 0`, 0, 0, false},
+		{"scanner failure", `echo boom >&2; exit 3`, 0, 3, true},
+		{"invalid report", `echo 'not json' > "$1"`, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ScanRepository([]string{"sh", "-c", tt.script, "scanner", "{report}"}, t.TempDir())
+			if len(result.Findings) != tt.wantFindings {
+				t.Errorf("findings = %d, want %d", len(result.Findings), tt.wantFindings)
+			}
+			if result.ExitCode != tt.wantExitCode {
+				t.Errorf("exit code = %d, want %d", result.ExitCode, tt.wantExitCode)
+			}
+			if (result.Error != "") != tt.wantErr {
+				t.Errorf("error = %q, wantErr %v", result.Error, tt.wantErr)
+			}
+		})
+	}
+}
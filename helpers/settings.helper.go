@@ -0,0 +1,62 @@
+package helpers
+
+import "path/filepath"
+
+// volatileSettingsFields change without anyone touching the configuration
+var volatileSettingsFields = []string{
+	"updated_at", "pushed_at", "last_activity_at", "last_response",
+	"size", "stargazers_count", "star_count", "watchers", "watchers_count",
+	"forks", "forks_count", "open_issues", "open_issues_count",
+	"subscribers_count", "network_count",
+}
+
+/*
+GetSettingsPath returns where the settings snapshot of a repository is stored.
+relPath is the clone location relative to the workspace root.
+*/
+func GetSettingsPath(workspace, relPath string) string {
+	return filepath.Join(workspace, ".reposync", "settings", filepath.FromSlash(relPath)+".json")
+}
+
+/*
+StripVolatileFields removes the fields of an API object that change on their own,
+such as counters and activity timestamps, so settings snapshots only differ
+when the configuration changed. extra names further fields to remove.
+*/
+func StripVolatileFields(object map[string]any, extra ...string) map[string]any {
+	for _, field := range volatileSettingsFields {
+		delete(object, field)
+	}
+	for _, field := range extra {
+		delete(object, field)
+	}
+	return object
+}
+
+/*
+GetCIConfigPath returns where the CI/CD configuration snapshot of a GitLab project
+or group is stored; kind is "projects" or "groups".
+*/
+func GetCIConfigPath(workspace, kind, relPath string) string {
+	return filepath.Join(workspace, ".reposync", "ci", kind, filepath.FromSlash(relPath)+".json")
+}
+
+/*
+MaskVariableValues replaces the values of CI/CD variables with a placeholder.
+Names and attributes such as protected or masked are kept, so the variables
+can be recreated and filled in from a secret store after a restore.
+*/
+func MaskVariableValues(variables []map[string]any) {
+	for _, variable := range variables {
+		if _, ok := variable["value"]; ok {
+			variable["value"] = redactedText
+		}
+	}
+}
+
+/*
+GetAccessSnapshotPath returns where the membership and team snapshot of a workspace is stored.
+*/
+func GetAccessSnapshotPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "access.json")
+}
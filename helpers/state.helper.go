@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+GetStatePath returns the location of the state file for a workspace root.
+*/
+func GetStatePath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "state.json")
+}
+
+/*
+StateKey builds the provider-scoped key used to index repositories in the state file.
+Provider IDs are only unique per provider, so the provider name is part of the key.
+*/
+func StateKey(provider string, id int64) string {
+	return fmt.Sprintf("%s:%d", provider, id)
+}
+
+/*
+LoadState reads the workspace state file.
+A missing file is not an error and yields an empty state, as happens on the first run.
+*/
+func LoadState(workspace string) (*models.State, error) {
+	state, err := LoadStateSnapshot(GetStatePath(workspace))
+	if errors.Is(err, os.ErrNotExist) {
+		return &models.State{Repositories: map[string]models.RepositoryState{}}, nil
+	}
+	return state, err
+}
+
+/*
+LoadStateSnapshot reads a state file from any location, e.g. a copy kept from an earlier run.
+Unlike LoadState, a missing file is an error.
+*/
+func LoadStateSnapshot(path string) (*models.State, error) {
+	state := &models.State{Repositories: map[string]models.RepositoryState{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Repositories == nil {
+		state.Repositories = map[string]models.RepositoryState{}
+	}
+	return state, nil
+}
+
+/*
+SaveState writes the workspace state file.
+Writes to a temporary file first and renames it into place,
+so an interrupted run never leaves a truncated state file behind.
+*/
+func SaveState(workspace string, state *models.State) error {
+	statePath := GetStatePath(workspace)
+	if err := ensureWritable(statePath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+/*
+ExpandPath resolves a leading ~ to the user's home directory and makes the path absolute.
+Shells do not expand ~ in --dest=~/src, so reposync does it itself.
+*/
+func ExpandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return absPath, nil
+}
+
+/*
+PrepareWorkspace makes sure a workspace root exists and can be written to.
+Creates missing directories and probes write access up front, so a wrong
+destination fails before any API call instead of on the first clone.
+In read-only mode the directory is only checked, never created.
+*/
+func PrepareWorkspace(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if IsReadOnly() {
+			return nil // Nothing exists yet, the plan will show every clone
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("failed to create workspace %s: %w", path, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to access workspace %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("workspace %s is not a directory", path)
+	}
+	if IsReadOnly() {
+		return nil
+	}
+
+	probe, err := os.CreateTemp(path, ".reposync-write-test-*")
+	if err != nil {
+		return fmt.Errorf("workspace %s is not writable: %w", path, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+/*
+GetWorkspaceConfigPath returns the location of the per-workspace config file.
+*/
+func GetWorkspaceConfigPath(workspace string) string {
+	return filepath.Join(workspace, ".reposync", "config")
+}
+
+/*
+LoadWorkspaceConfig reads the workspace's .reposync/config.
+Returns nil without an error when the workspace has no config file.
+*/
+func LoadWorkspaceConfig(workspace string) (*models.WorkspaceConfig, error) {
+	path, err := ExpandPath(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(GetWorkspaceConfigPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read workspace config: %w", err)
+	}
+
+	var config models.WorkspaceConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace config %s: %w", GetWorkspaceConfigPath(path), err)
+	}
+	return &config, nil
+}
+
+/*
+RootDirectory returns the name of the directory a group or organization is synced into.
+Provider names are case-insensitive, so a directory left by an earlier sync that
+spelled the name differently (myorg/ for MyOrg) is reused instead of creating a
+second copy next to it. Returns name when the workspace has no such directory.
+*/
+func RootDirectory(workspace, name string) string {
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		return name
+	}
+	match := ""
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.EqualFold(entry.Name(), name) {
+			continue
+		}
+		if entry.Name() == name {
+			return name
+		}
+		match = entry.Name()
+	}
+	if match != "" {
+		return match
+	}
+	return name
+}
+
+/*
+ValidateRootName checks a --root-name: a single directory name within the workspace.
+*/
+func ValidateRootName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q is not a directory name", name)
+	}
+	if name == ".reposync" {
+		return fmt.Errorf("%q is reserved for the workspace state", name)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	cwd, _ := os.Getwd()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"~", home},
+		{"~/src/acme", filepath.Join(home, "src", "acme")},
+		{"/srv/mirror", "/srv/mirror"},
+		{"mirror", filepath.Join(cwd, "mirror")},
+		{"~other/src", filepath.Join(cwd, "~other", "src")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ExpandPath(tt.input)
+			if err != nil {
+				t.Fatalf("ExpandPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareWorkspace(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PrepareWorkspace(filepath.Join(root, "a", "b")); err != nil {
+		t.Errorf("PrepareWorkspace() on a missing directory error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a", "b")); err != nil {
+		t.Errorf("workspace was not created: %v", err)
+	}
+	if err := PrepareWorkspace(file); err == nil {
+		t.Errorf("PrepareWorkspace() on a file should fail")
+	}
+}
+
+func TestLoadWorkspaceConfig(t *testing.T) {
+	workspace := t.TempDir()
+
+	config, err := LoadWorkspaceConfig(workspace)
+	if err != nil || config != nil {
+		t.Fatalf("LoadWorkspaceConfig() without a config = %v, %v; want nil, nil", config, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(workspace, ".reposync"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"provider": "github", "group": "acme", "visibility": ["internal"]}`)
+	if err := os.WriteFile(GetWorkspaceConfigPath(workspace), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err = LoadWorkspaceConfig(workspace)
+	if err != nil {
+		t.Fatalf("LoadWorkspaceConfig() error = %v", err)
+	}
+	if config.Provider != "github" || config.Group != "acme" || len(config.Visibility) != 1 {
+		t.Errorf("LoadWorkspaceConfig() = %+v", config)
+	}
+}
+
+func TestRootDirectory(t *testing.T) {
+	workspace := t.TempDir()
+	for _, dir := range []string{"myorg", "other"} {
+		if err := os.Mkdir(filepath.Join(workspace, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"MyOrg", "myorg"},
+		{"other", "other"},
+		{"NewOrg", "NewOrg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RootDirectory(workspace, tt.name); got != tt.want {
+				t.Errorf("RootDirectory(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
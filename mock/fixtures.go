@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+Group is a fixture group (GitLab group or GitHub organization).
+Parent is zero for top-level groups.
+*/
+type Group struct {
+	ID     int
+	Name   string
+	Path   string
+	Parent int
+}
+
+/*
+Project is a fixture repository living in a group.
+*/
+type Project struct {
+	ID            int64
+	Name          string
+	Path          string
+	GroupID       int
+	DefaultBranch string
+	Description   string
+}
+
+/*
+Fixture describes the group tree and repositories served by the mock provider.
+*/
+type Fixture struct {
+	Groups   []Group
+	Projects []Project
+}
+
+/*
+DefaultFixture returns a small GitLab-style tree used by `reposync -p mock`:
+a top-level group with two projects and a subgroup holding a third one.
+*/
+func DefaultFixture() Fixture {
+	return Fixture{
+		Groups: []Group{
+			{ID: 1, Name: "Mock Group", Path: "mock-group"},
+			{ID: 2, Name: "Tools", Path: "tools", Parent: 1},
+		},
+		Projects: []Project{
+			{ID: 101, Name: "API", Path: "api", GroupID: 1, DefaultBranch: "main", Description: "Backend service"},
+			{ID: 102, Name: "Web", Path: "web", GroupID: 1, DefaultBranch: "main", Description: "Frontend application"},
+			{ID: 201, Name: "CLI", Path: "cli", GroupID: 2, DefaultBranch: "main", Description: "Command-line tooling"},
+		},
+	}
+}
+
+/*
+group looks up a fixture group by ID.
+*/
+func (f Fixture) group(id int) (Group, bool) {
+	for _, group := range f.Groups {
+		if group.ID == id {
+			return group, true
+		}
+	}
+	return Group{}, false
+}
+
+/*
+FullPath returns the slash-separated namespace path of a group (e.g. mock-group/tools).
+*/
+func (f Fixture) FullPath(groupID int) string {
+	group, ok := f.group(groupID)
+	if !ok {
+		return ""
+	}
+	if group.Parent == 0 {
+		return group.Path
+	}
+	return f.FullPath(group.Parent) + "/" + group.Path
+}
+
+/*
+ProjectPath returns the path_with_namespace of a fixture project.
+*/
+func (f Fixture) ProjectPath(project Project) string {
+	return f.FullPath(project.GroupID) + "/" + project.Path
+}
+
+/*
+CreateBareRepositories generates a local bare repository for every fixture project.
+Each repository gets one commit on its default branch and is stored as
+<dir>/<project ID>.git, so the storage location stays stable when a
+project is renamed or moved in the fixture.
+*/
+func CreateBareRepositories(dir string, fixture Fixture) error {
+	runner := helpers.ExecGitRunner{}
+	for _, project := range fixture.Projects {
+		workDir, err := os.MkdirTemp("", "reposync-mock-")
+		if err != nil {
+			return fmt.Errorf("failed to create work directory: %w", err)
+		}
+
+		readme := fmt.Sprintf("# %s\n\nMock repository %s.\n", project.Name, fixture.ProjectPath(project))
+		if err := os.WriteFile(filepath.Join(workDir, "README.md"), []byte(readme), 0644); err != nil {
+			os.RemoveAll(workDir)
+			return fmt.Errorf("failed to write fixture file: %w", err)
+		}
+
+		steps := [][]string{
+			{"-C", workDir, "init", "--quiet", "--initial-branch", project.DefaultBranch},
+			{"-C", workDir, "add", "README.md"},
+			{"-C", workDir, "-c", "user.name=reposync", "-c", "user.email=mock@reposync.invalid", "commit", "--quiet", "-m", "Initial commit"},
+			{"clone", "--quiet", "--bare", workDir, BareRepositoryPath(dir, project.ID)},
+		}
+		for _, args := range steps {
+			if err := runner.Run(io.Discard, io.Discard, args...); err != nil {
+				os.RemoveAll(workDir)
+				return fmt.Errorf("failed to create fixture repository %s (git %s): %w", project.Path, strings.Join(args, " "), err)
+			}
+		}
+		os.RemoveAll(workDir)
+	}
+	return nil
+}
+
+/*
+BareRepositoryPath returns where CreateBareRepositories stores a project.
+*/
+func BareRepositoryPath(dir string, projectID int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%d.git", projectID))
+}
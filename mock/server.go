@@ -0,0 +1,256 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+var (
+	gitLabGroupPattern = regexp.MustCompile(`^/api/v4/groups/(\d+)(/subgroups|/projects)?$`)
+	gitLabProjectPath  = regexp.MustCompile(`^/api/v4/projects/(\d+)$`)
+	gitHubReposPattern = regexp.MustCompile(`^/api/v3/orgs/([^/]+)/repos$`)
+)
+
+/*
+Server is a fake GitLab/GitHub API backed by a Fixture.
+GitLab endpoints live under /api/v4 (use the server URL as gitlab_url),
+GitHub endpoints under /api/v3 like on GitHub Enterprise Server (use it as github_url). Clone URLs point
+at the bare repositories generated by CreateBareRepositories, so
+end-to-end syncs run without any network access.
+*/
+type Server struct {
+	mu       sync.Mutex
+	fixture  Fixture
+	repoRoot string
+	ownsRoot bool
+	server   *httptest.Server
+}
+
+/*
+NewServer starts a mock provider serving the fixture.
+repoRoot is the directory passed to CreateBareRepositories.
+*/
+func NewServer(fixture Fixture, repoRoot string) *Server {
+	s := &Server{fixture: fixture, repoRoot: repoRoot}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+/*
+StartDefault generates the default fixture's bare repositories in a temporary
+directory and serves them. Close removes the temporary directory again.
+Used by `reposync -p mock` as a self-contained playground.
+*/
+func StartDefault() (*Server, error) {
+	repoRoot, err := os.MkdirTemp("", "reposync-mock-repos-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	fixture := DefaultFixture()
+	if err := CreateBareRepositories(repoRoot, fixture); err != nil {
+		os.RemoveAll(repoRoot)
+		return nil, err
+	}
+
+	server := NewServer(fixture, repoRoot)
+	server.ownsRoot = true
+	return server, nil
+}
+
+/*
+URL returns the base URL of the mock provider.
+*/
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+/*
+Close shuts the mock provider down.
+*/
+func (s *Server) Close() {
+	s.server.Close()
+	if s.ownsRoot {
+		os.RemoveAll(s.repoRoot)
+	}
+}
+
+/*
+RenameProject changes a project's path, simulating an upstream rename.
+*/
+func (s *Server) RenameProject(id int64, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.fixture.Projects {
+		if s.fixture.Projects[i].ID == id {
+			s.fixture.Projects[i].Path = path
+			s.fixture.Projects[i].Name = path
+		}
+	}
+}
+
+/*
+MoveProject transfers a project to another group, simulating a GitLab transfer.
+*/
+func (s *Server) MoveProject(id int64, groupID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.fixture.Projects {
+		if s.fixture.Projects[i].ID == id {
+			s.fixture.Projects[i].GroupID = groupID
+		}
+	}
+}
+
+/*
+RemoveProject deletes a project from the fixture, simulating an upstream deletion.
+*/
+func (s *Server) RemoveProject(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projects := s.fixture.Projects[:0]
+	for _, project := range s.fixture.Projects {
+		if project.ID != id {
+			projects = append(projects, project)
+		}
+	}
+	s.fixture.Projects = projects
+}
+
+/*
+handle routes API requests to the fixture.
+Every list fits on one page: a request for page 2 or with id_after set
+past the last ID returns an empty list, which ends pagination for both providers.
+*/
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	idAfter, _ := strconv.ParseInt(query.Get("id_after"), 10, 64)
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page > 1 {
+		writeJSON(w, []any{})
+		return
+	}
+
+	if match := gitLabGroupPattern.FindStringSubmatch(r.URL.Path); match != nil {
+		groupID, _ := strconv.Atoi(match[1])
+		group, ok := s.fixture.group(groupID)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch match[2] {
+		case "":
+			writeJSON(w, models.GitLabGroup{ID: group.ID, Name: group.Name, Path: group.Path, FullPath: s.fixture.FullPath(group.ID)})
+		case "/subgroups":
+			subgroups := []models.GitLabSubgroup{}
+			for _, child := range s.fixture.Groups {
+				if child.Parent == group.ID && int64(child.ID) > idAfter {
+					subgroups = append(subgroups, models.GitLabSubgroup{ID: child.ID, Name: child.Name, FullPath: s.fixture.FullPath(child.ID)})
+				}
+			}
+			writeJSON(w, subgroups)
+		case "/projects":
+			projects := []models.GitLabRepository{}
+			for _, project := range s.fixture.Projects {
+				if project.GroupID == group.ID && project.ID > idAfter {
+					projects = append(projects, s.gitLabProject(project))
+				}
+			}
+			writeJSON(w, projects)
+		}
+		return
+	}
+
+	if match := gitLabProjectPath.FindStringSubmatch(r.URL.Path); match != nil {
+		id, _ := strconv.ParseInt(match[1], 10, 64)
+		for _, project := range s.fixture.Projects {
+			if project.ID == id {
+				writeJSON(w, s.gitLabProject(project))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Path == "/api/v4/projects" {
+		projects := []models.GitLabRepository{}
+		for _, project := range s.fixture.Projects {
+			if project.ID > idAfter {
+				projects = append(projects, s.gitLabProject(project))
+			}
+		}
+		writeJSON(w, projects)
+		return
+	}
+
+	if r.URL.Path == "/api/v3/meta" {
+		writeJSON(w, map[string]string{"installed_version": "mock"})
+		return
+	}
+
+	if match := gitHubReposPattern.FindStringSubmatch(r.URL.Path); match != nil {
+		repos := []models.GitHubRepository{}
+		for _, project := range s.fixture.Projects {
+			if s.fixture.FullPath(project.GroupID) == match[1] {
+				url := "file://" + BareRepositoryPath(s.repoRoot, project.ID)
+				repos = append(repos, models.GitHubRepository{
+					ID:            project.ID,
+					Name:          project.Path,
+					FullName:      match[1] + "/" + project.Path,
+					HTTPSURL:      url,
+					SSHURL:        url,
+					DefaultBranch: project.DefaultBranch,
+					Description:   project.Description,
+					WebURL:        s.server.URL + "/" + match[1] + "/" + project.Path,
+				})
+			}
+		}
+		writeJSON(w, repos)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+/*
+gitLabProject converts a fixture project into the GitLab API representation.
+*/
+func (s *Server) gitLabProject(project Project) models.GitLabRepository {
+	url := "file://" + BareRepositoryPath(s.repoRoot, project.ID)
+	return models.GitLabRepository{
+		ID:                project.ID,
+		HTTPSURL:          url,
+		SSHURL:            url,
+		Name:              project.Name,
+		Path:              project.Path,
+		PathWithNamespace: s.fixture.ProjectPath(project),
+		DefaultBranch:     project.DefaultBranch,
+		Description:       project.Description,
+		WebURL:            s.server.URL + "/" + s.fixture.ProjectPath(project),
+	}
+}
+
+/*
+writeJSON encodes a response body as JSON.
+*/
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
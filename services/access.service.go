@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// gitLabAccessLevels names GitLab's numeric access levels
+var gitLabAccessLevels = map[int]string{
+	5:  "minimal",
+	10: "guest",
+	15: "planner",
+	20: "reporter",
+	30: "developer",
+	40: "maintainer",
+	50: "owner",
+}
+
+/*
+gitLabMember is a direct member of a GitLab group or project.
+*/
+type gitLabMember struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	AccessLevel int    `json:"access_level"`
+}
+
+/*
+gitLabAccessLevel returns the name of a GitLab access level.
+*/
+func gitLabAccessLevel(level int) string {
+	if name, ok := gitLabAccessLevels[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("level %d", level)
+}
+
+/*
+getGitLabMembers fetches the direct members of a group or project endpoint
+(e.g. /groups/1/members), with their access levels.
+*/
+func getGitLabMembers(api providerAPI, endpoint string) ([]models.AccessMember, error) {
+	members, err := fetchGitLabKeysetPages(api, endpoint, "", func(member gitLabMember) int64 { return member.ID })
+	if err != nil {
+		return nil, err
+	}
+	access := make([]models.AccessMember, 0, len(members))
+	for _, member := range members {
+		access = append(access, models.AccessMember{Username: member.Username, Role: gitLabAccessLevel(member.AccessLevel)})
+	}
+	sortMembers(access)
+	return access, nil
+}
+
+/*
+getGitHubCollaborators fetches the direct collaborators of a GitHub repository with their roles.
+*/
+func getGitHubCollaborators(api providerAPI, fullName string) ([]models.AccessMember, error) {
+	collaborators, err := fetchGitHubPages[struct {
+		Login    string `json:"login"`
+		RoleName string `json:"role_name"`
+	}](api, "/repos/"+fullName+"/collaborators?affiliation=direct")
+	if err != nil {
+		return nil, err
+	}
+	access := make([]models.AccessMember, 0, len(collaborators))
+	for _, collaborator := range collaborators {
+		access = append(access, models.AccessMember{Username: collaborator.Login, Role: collaborator.RoleName})
+	}
+	sortMembers(access)
+	return access, nil
+}
+
+/*
+getGitHubUsers lists the logins of a GitHub user list endpoint, e.g. the members of a team.
+*/
+func getGitHubUsers(api providerAPI, endpoint, role string) ([]models.AccessMember, error) {
+	users, err := fetchGitHubPages[struct {
+		Login string `json:"login"`
+	}](api, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	access := make([]models.AccessMember, 0, len(users))
+	for _, user := range users {
+		access = append(access, models.AccessMember{Username: user.Login, Role: role})
+	}
+	return access, nil
+}
+
+/*
+collectGitHubAccess reads the members and teams of a GitHub organization,
+including the members of every team and the repositories it has access to.
+Sections the token cannot read are recorded in the snapshot's Errors.
+*/
+func collectGitHubAccess(api providerAPI, org string) models.AccessSnapshot {
+	snapshot := models.AccessSnapshot{Provider: "github", Namespace: org, Members: []models.AccessMember{}, Teams: []models.AccessTeam{}, Errors: map[string]string{}}
+
+	for _, role := range []string{"admin", "member"} {
+		members, err := getGitHubUsers(api, fmt.Sprintf("/orgs/%s/members?role=%s", org, role), role)
+		if err != nil {
+			snapshot.Errors["members"] = err.Error()
+			break
+		}
+		snapshot.Members = append(snapshot.Members, members...)
+	}
+	sortMembers(snapshot.Members)
+
+	teams, err := fetchGitHubPages[struct {
+		Name   string `json:"name"`
+		Slug   string `json:"slug"`
+		Parent *struct {
+			Slug string `json:"slug"`
+		} `json:"parent"`
+	}](api, fmt.Sprintf("/orgs/%s/teams", org))
+	if err != nil {
+		snapshot.Errors["teams"] = err.Error()
+		return snapshot
+	}
+
+	for _, team := range teams {
+		entry := models.AccessTeam{Name: team.Name, Path: team.Slug, Members: []models.AccessMember{}}
+		if team.Parent != nil {
+			entry.Parent = team.Parent.Slug
+		}
+		for _, role := range []string{"maintainer", "member"} {
+			members, err := getGitHubUsers(api, fmt.Sprintf("/orgs/%s/teams/%s/members?role=%s", org, team.Slug, role), role)
+			if err != nil {
+				snapshot.Errors["team "+team.Slug] = err.Error()
+				break
+			}
+			entry.Members = append(entry.Members, members...)
+		}
+		sortMembers(entry.Members)
+
+		repositories, err := fetchGitHubPages[struct {
+			FullName string `json:"full_name"`
+			RoleName string `json:"role_name"`
+		}](api, fmt.Sprintf("/orgs/%s/teams/%s/repos", org, team.Slug))
+		if err != nil {
+			snapshot.Errors["team "+team.Slug] = err.Error()
+		}
+		for _, repository := range repositories {
+			entry.Repositories = append(entry.Repositories, models.RepositoryPermission{Repository: repository.FullName, Permission: repository.RoleName})
+		}
+		sort.Slice(entry.Repositories, func(i, j int) bool { return entry.Repositories[i].Repository < entry.Repositories[j].Repository })
+		snapshot.Teams = append(snapshot.Teams, entry)
+	}
+	sort.Slice(snapshot.Teams, func(i, j int) bool { return snapshot.Teams[i].Path < snapshot.Teams[j].Path })
+	return snapshot
+}
+
+/*
+collectGitLabAccess reads the direct members of a GitLab group and, as teams,
+of all its subgroups. Projects shared with a group are part of the project
+settings (shared_with_groups) in the repository snapshots.
+*/
+func collectGitLabAccess(api providerAPI, group *models.GitLabGroup) models.AccessSnapshot {
+	snapshot := models.AccessSnapshot{Provider: "gitlab", Namespace: group.FullPath, Members: []models.AccessMember{}, Teams: []models.AccessTeam{}, Errors: map[string]string{}}
+
+	members, err := getGitLabMembers(api, fmt.Sprintf("/groups/%d/members", group.ID))
+	if err != nil {
+		snapshot.Errors["members"] = err.Error()
+	} else {
+		snapshot.Members = members
+	}
+
+	collectGitLabSubgroupAccess(api, group.ID, group.FullPath, &snapshot)
+	sort.Slice(snapshot.Teams, func(i, j int) bool { return snapshot.Teams[i].Path < snapshot.Teams[j].Path })
+	return snapshot
+}
+
+/*
+collectGitLabSubgroupAccess adds the subgroups below a group to the snapshot, recursively.
+*/
+func collectGitLabSubgroupAccess(api providerAPI, groupID int, groupPath string, snapshot *models.AccessSnapshot) {
+	subgroups, err := getGitLabSubgroups(api, groupID)
+	if err != nil {
+		snapshot.Errors["subgroups of "+groupPath] = err.Error()
+		return
+	}
+	for _, subgroup := range subgroups {
+		team := models.AccessTeam{Name: subgroup.Name, Path: subgroup.FullPath, Parent: groupPath, Members: []models.AccessMember{}}
+		members, err := getGitLabMembers(api, fmt.Sprintf("/groups/%d/members", subgroup.ID))
+		if err != nil {
+			snapshot.Errors["members of "+subgroup.FullPath] = err.Error()
+		} else {
+			team.Members = members
+		}
+		snapshot.Teams = append(snapshot.Teams, team)
+		collectGitLabSubgroupAccess(api, subgroup.ID, subgroup.FullPath, snapshot)
+	}
+}
+
+/*
+writeAccessSnapshot saves the membership and team snapshot of the run's namespace.
+*/
+func (r *syncRun) writeAccessSnapshot(snapshot models.AccessSnapshot) {
+	for section, reason := range snapshot.Errors {
+		r.notify(LevelWarning, "Skipping %s in the access snapshot: %s", section, reason)
+	}
+
+	path := helpers.GetAccessSnapshotPath(r.options.BaseDir)
+	err := helpers.WriteJSONReport(path, snapshot)
+	r.audit.Record("write-access-snapshot", path, "", err)
+	if err != nil {
+		r.notify(LevelError, "Failed to write access snapshot: %v", err)
+		return
+	}
+	r.notify(LevelSuccess, "Wrote access snapshot of %s (%d members, %d teams) to %s",
+		snapshot.Namespace, len(snapshot.Members), len(snapshot.Teams), path)
+}
+
+/*
+sortMembers orders members by username, keeping snapshots stable between runs.
+*/
+func sortMembers(members []models.AccessMember) {
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+}
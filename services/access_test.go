@@ -0,0 +1,59 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestCollectGitHubAccess(t *testing.T) {
+	responses := map[string]string{
+		"/orgs/acme/members?role=admin":                    `[{"login": "zoe"}]`,
+		"/orgs/acme/members?role=member":                   `[{"login": "bob"}]`,
+		"/orgs/acme/teams":                                 `[{"name": "Backend", "slug": "backend", "parent": {"slug": "engineering"}}]`,
+		"/orgs/acme/teams/backend/members?role=maintainer": `[{"login": "zoe"}]`,
+		"/orgs/acme/teams/backend/members?role=member":     `[]`,
+		"/orgs/acme/teams/backend/repos":                   `[{"full_name": "acme/web", "role_name": "write"}, {"full_name": "acme/api", "role_name": "admin"}]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("page") != "1" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/api/v3")
+		if role := query.Get("role"); role != "" {
+			key += "?role=" + role
+		}
+		body, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	snapshot := collectGitHubAccess(providerAPI{http: server.Client(), baseURL: server.URL}, "acme")
+	if len(snapshot.Errors) > 0 {
+		t.Fatalf("errors = %v", snapshot.Errors)
+	}
+
+	wantMembers := []models.AccessMember{{Username: "bob", Role: "member"}, {Username: "zoe", Role: "admin"}}
+	if len(snapshot.Members) != 2 || snapshot.Members[0] != wantMembers[0] || snapshot.Members[1] != wantMembers[1] {
+		t.Errorf("members = %v, want %v", snapshot.Members, wantMembers)
+	}
+	if len(snapshot.Teams) != 1 {
+		t.Fatalf("teams = %v, want backend", snapshot.Teams)
+	}
+	team := snapshot.Teams[0]
+	if team.Parent != "engineering" || len(team.Members) != 1 || team.Members[0].Role != "maintainer" {
+		t.Errorf("team = %+v", team)
+	}
+	if len(team.Repositories) != 2 || team.Repositories[0] != (models.RepositoryPermission{Repository: "acme/api", Permission: "admin"}) {
+		t.Errorf("team repositories = %v, want sorted with permissions", team.Repositories)
+	}
+}
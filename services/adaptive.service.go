@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	client "github.com/itszeeshan/reposync/client"
+)
+
+// Thresholds of the adaptive worker pool.
+const (
+	adaptiveWindow        = 10   // Recent results the failure rate is computed over
+	adaptiveMaxFailRate   = 0.2  // Failure rate above which the pool shrinks
+	adaptiveLowHeadroom   = 0.1  // Share of the rate limit left below which the pool shrinks
+	adaptiveGrowHeadroom  = 0.25 // Share of the rate limit left needed to grow the pool
+	adaptiveInitialActive = 2
+)
+
+/*
+concurrencyController sizes the worker pool of an adaptive sync while it runs.
+Works like TCP congestion control: after as many successes in a row as workers
+are active, one more worker may start; many failures or a nearly exhausted rate
+limit halve the number of active workers. Nil-safe, so callers need not check
+whether the run is adaptive.
+*/
+type concurrencyController struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	max       int
+	active    int
+	successes int
+	recent    []bool // Results of the last repositories, true for failures
+	headroom  func() float64
+	notify    func(level, format string, args ...any) // Reports resizes of the pool
+}
+
+/*
+newConcurrencyController starts a pool of two workers (or max, if lower) that can grow up to max.
+Resizes are reported to notify.
+*/
+func newConcurrencyController(max int, notify func(level, format string, args ...any)) *concurrencyController {
+	c := &concurrencyController{limit: min(adaptiveInitialActive, max), max: max, headroom: client.Headroom, notify: notify}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+/*
+acquire blocks until the pool admits another active worker.
+*/
+func (c *concurrencyController) acquire() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+}
+
+/*
+release returns a worker to the pool with the result of its repository
+and resizes the pool accordingly.
+*/
+func (c *concurrencyController) release(failed bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	defer c.cond.Broadcast()
+
+	c.recent = append(c.recent, failed)
+	if len(c.recent) > adaptiveWindow {
+		c.recent = c.recent[1:]
+	}
+	failures := 0
+	for _, result := range c.recent {
+		if result {
+			failures++
+		}
+	}
+	headroom := c.headroom()
+
+	switch {
+	case len(c.recent) == adaptiveWindow && float64(failures)/adaptiveWindow > adaptiveMaxFailRate:
+		c.shrink(fmt.Sprintf("%d of the last %d repositories failed", failures, adaptiveWindow))
+		c.recent = nil // Judge the smaller pool on its own results
+	case headroom < adaptiveLowHeadroom:
+		c.shrink(fmt.Sprintf("%.0f%% of the API rate limit left", headroom*100))
+	case failed:
+		c.successes = 0
+	default:
+		c.successes++
+		if c.successes >= c.limit && c.limit < c.max && headroom >= adaptiveGrowHeadroom {
+			c.successes = 0
+			c.limit++
+			c.notify(LevelNotice, "Raising concurrency to %d", c.limit)
+		}
+	}
+}
+
+/*
+shrink halves the number of active workers, keeping at least one.
+*/
+func (c *concurrencyController) shrink(reason string) {
+	c.successes = 0
+	if c.limit == 1 {
+		return
+	}
+	c.limit = max(c.limit/2, 1)
+	c.notify(LevelWarning, "Lowering concurrency to %d: %s", c.limit, reason)
+}
@@ -0,0 +1,41 @@
+package services
+
+import "testing"
+
+func TestConcurrencyController(t *testing.T) {
+	headroom := 1.0
+	c := newConcurrencyController(4, func(string, string, ...any) {})
+	c.headroom = func() float64 { return headroom }
+
+	// Successes grow the pool one worker at a time, up to the maximum
+	for i := 0; i < 20; i++ {
+		c.acquire()
+		c.release(false)
+	}
+	if c.limit != 4 {
+		t.Errorf("limit after successes = %d, want 4", c.limit)
+	}
+
+	// A mostly failing window halves it
+	for i := 0; i < adaptiveWindow; i++ {
+		c.acquire()
+		c.release(i%2 == 0)
+	}
+	if c.limit != 2 {
+		t.Errorf("limit after failures = %d, want 2", c.limit)
+	}
+
+	// An exhausted rate limit shrinks the pool and keeps it from growing
+	headroom = 0.05
+	for i := 0; i < 5; i++ {
+		c.acquire()
+		c.release(false)
+	}
+	if c.limit != 1 {
+		t.Errorf("limit with low headroom = %d, want 1", c.limit)
+	}
+
+	var none *concurrencyController
+	none.acquire()
+	none.release(true)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+Dependencies are the external systems the services talk to.
+Production code uses DefaultDependencies; tests inject an httptest-backed
+HTTP client and a fake git runner to exercise the sync paths offline.
+*/
+type Dependencies struct {
+	HTTP client.HTTPDoer
+	Git  helpers.GitRunner
+}
+
+/*
+DefaultDependencies returns the real HTTP client and git binary runner.
+*/
+func DefaultDependencies() Dependencies {
+	return Dependencies{
+		HTTP: http.DefaultClient,
+		Git:  helpers.ExecGitRunner{},
+	}
+}
+
+/*
+providerAPI bundles what a provider API call needs: the HTTP client,
+the access token and the instance base URL (empty for the public cloud).
+tokenType is the kind of GitLab token (see SyncOptions.TokenType).
+*/
+type providerAPI struct {
+	http      client.HTTPDoer
+	token     string
+	baseURL   string
+	tokenType string
+	render    Renderer // Presents the messages of requests, see notify
+}
+
+/*
+newProviderAPI builds the API accessor for a run from its options and dependencies.
+*/
+func newProviderAPI(options models.SyncOptions, deps Dependencies) providerAPI {
+	return providerAPI{http: deps.HTTP, token: options.Token, baseURL: options.BaseURL, tokenType: options.TokenType, render: runRenderer(options)}
+}
+
+/*
+notify reports a message about the listing of repositories, formatted like fmt.Sprintf.
+*/
+func (a providerAPI) notify(level, format string, args ...any) {
+	render := a.render
+	if render == nil {
+		// Accessors assembled without newProviderAPI, as in tests
+		render = NewTextRenderer(nil)
+	}
+	render.Render(Event{Type: EventMessage, Time: time.Now(), Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+/*
+get performs an authenticated GET request against the provider API.
+GitLab CI job tokens are sent in the JOB-TOKEN header, everything else as Bearer token.
+*/
+func (a providerAPI) get(url string) (*http.Response, error) {
+	if a.tokenType == models.TokenTypeJob {
+		return client.RequestWithHeader(a.http, "GET", url, "JOB-TOKEN", a.token)
+	}
+	return client.RequestWith(a.http, "GET", url, a.token)
+}
+
+/*
+getJSON performs an authenticated GET request and decodes the JSON response into v.
+*/
+func (a providerAPI) getJSON(url string, v any) error {
+	resp, err := a.get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
+
+/*
+decodeJSONArray decodes a JSON array one element at a time, passing each to yield.
+Listings of big instances are never held in memory as a whole page: only the
+current element is buffered, and fields the model does not declare are skipped.
+Returns the number of elements.
+*/
+func decodeJSONArray[T any](r io.Reader, yield func(T)) (int, error) {
+	return streamJSONArray(json.NewDecoder(r), yield)
+}
+
+/*
+streamJSONArray decodes the array the decoder is positioned at, element by element.
+*/
+func streamJSONArray[T any](decoder *json.Decoder, yield func(T)) (int, error) {
+	if token, err := decoder.Token(); err != nil {
+		return 0, err
+	} else if token != json.Delim('[') {
+		return 0, fmt.Errorf("expected a JSON array, got %v", token)
+	}
+
+	count := 0
+	for decoder.More() {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return count, err
+		}
+		yield(item)
+		count++
+	}
+	_, err := decoder.Token() // Closing bracket
+	return count, err
+}
+
+/*
+send performs an authenticated write request with a JSON body
+and decodes the response into v, unless v is nil.
+*/
+func (a providerAPI) send(method, url string, body, v any) error {
+	resp, err := client.RequestWithBody(a.http, method, url, a.token, body)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
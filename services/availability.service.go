@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// providerPollInterval is how often WaitForProvider checks an unavailable instance again.
+var providerPollInterval = 30 * time.Second
+
+/*
+providerStatusURL returns a lightweight API endpoint of a provider instance that
+answers without listing anything: GitLab's /version, GitHub's /rate_limit and
+Bitbucket Server's application properties. Empty for providers without an API.
+*/
+func providerStatusURL(provider, baseURL string) string {
+	switch provider {
+	case "gitlab":
+		return helpers.GetGitLabAPIURL(baseURL, "/version")
+	case "github":
+		return helpers.GetGitHubAPIURL(baseURL, "/rate_limit")
+	case "bitbucket-server":
+		return helpers.GetBitbucketServerAPIURL(baseURL, "/application-properties")
+	}
+	return ""
+}
+
+/*
+WaitForProvider blocks until the instance of a provider serves requests, e.g. after the
+maintenance window of a self-hosted GitLab, checking it every 30 seconds. Returns at once
+for an instance that is up, and an error once it was unavailable for longer than timeout.
+*/
+func WaitForProvider(provider, baseURL string, timeout time.Duration) error {
+	return waitForProvider(provider, baseURL, timeout, DefaultDependencies())
+}
+
+func waitForProvider(provider, baseURL string, timeout time.Duration, deps Dependencies) error {
+	url := providerStatusURL(provider, baseURL)
+	if url == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	waited := false
+	for {
+		err := client.CheckAvailable(deps.HTTP, url)
+		if err == nil {
+			if waited {
+				Notify(LevelSuccess, "%s is available again", provider)
+			}
+			return nil
+		}
+		if time.Now().Add(providerPollInterval).After(deadline) {
+			return fmt.Errorf("%s is still unavailable after waiting %s: %w", provider, timeout, err)
+		}
+		Notify(LevelWarning, "%s is unavailable (%v), checking again in %s", provider, err, providerPollInterval)
+		waited = true
+		time.Sleep(providerPollInterval)
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitForProvider(t *testing.T) {
+	providerPollInterval = time.Millisecond
+	t.Cleanup(func() { providerPollInterval = 30 * time.Second })
+
+	down := 2
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if down > 0 {
+			down--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized) // Anonymous, but the instance is up
+	}))
+	defer server.Close()
+	deps := Dependencies{HTTP: server.Client()}
+
+	if err := waitForProvider("gitlab", server.URL, time.Minute, deps); err != nil {
+		t.Fatalf("waitForProvider() error = %v", err)
+	}
+	if len(paths) != 3 || paths[0] != "/api/v4/version" {
+		t.Errorf("requests = %v, want three checks of /api/v4/version", paths)
+	}
+
+	down = 1000
+	if err := waitForProvider("gitlab", server.URL, 10*time.Millisecond, deps); err == nil {
+		t.Errorf("waitForProvider() succeeded for an instance that stayed unavailable")
+	}
+}
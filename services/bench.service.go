@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// benchRecommendShare is the share of the best throughput a lower concurrency level
+// must reach to be recommended instead: more workers only pay off above it.
+const benchRecommendShare = 0.9
+
+/*
+RunBenchmark measures the clone throughput at each of the given concurrency levels.
+Without a provider in source, count synthetic repositories of sizeBytes each are
+created locally and cloned over file://, which measures the disks and git itself;
+otherwise the first count repositories of the source are cloned, which includes
+the network and the provider. Clones go to a temporary directory that is removed
+afterwards.
+*/
+func RunBenchmark(source models.ManifestSource, options models.SyncOptions, levels []int, count int, sizeBytes int64) ([]models.BenchmarkResult, error) {
+	return runBenchmark(source, options, levels, count, sizeBytes, DefaultDependencies())
+}
+
+/*
+runBenchmark implements RunBenchmark on top of injectable dependencies.
+*/
+func runBenchmark(source models.ManifestSource, options models.SyncOptions, levels []int, count int, sizeBytes int64, deps Dependencies) ([]models.BenchmarkResult, error) {
+	scratch, err := os.MkdirTemp("", "reposync-bench-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	var targets []syncTarget
+	if source.Provider == "" {
+		Notify(LevelNotice, "Creating %d synthetic repositories of %s", count, helpers.FormatBytes(sizeBytes))
+		targets, err = createBenchmarkRepositories(deps.Git, filepath.Join(scratch, "source"), count, sizeBytes)
+	} else {
+		Notify(LevelNotice, "Listing source %s", describeSource(source))
+		options.NoWrite = true // Listing must not leave anything behind
+		run := &syncRun{provider: source.Provider, options: options, deps: deps, api: newProviderAPI(options, deps)}
+		targets, err = listSource(run, source)
+		targets = targets[:min(count, len(targets))]
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no repositories to clone")
+	}
+
+	results := make([]models.BenchmarkResult, 0, len(levels))
+	for _, level := range levels {
+		Notify(LevelNotice, "Cloning %d repositories with %d workers", len(targets), level)
+		result, err := benchmarkLevel(targets, filepath.Join(scratch, fmt.Sprintf("j%d", level)), level, options, deps)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+/*
+benchmarkLevel clones all targets into dir with the given number of workers
+and removes the clones again once they were measured.
+*/
+func benchmarkLevel(targets []syncTarget, dir string, workers int, options models.SyncOptions, deps Dependencies) (models.BenchmarkResult, error) {
+	result := models.BenchmarkResult{Concurrency: workers, Repositories: len(targets)}
+	defer os.RemoveAll(dir)
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				target := targets[i]
+				repoURL := helpers.GetPreferredRepositoryURL(target.HTTPSURL, target.SSHURL, options.CloneMethod)
+				// Names may repeat across a provider's namespaces, so every clone gets its own directory
+				err := helpers.CloneRepository(deps.Git, io.Discard, nil, repoURL, filepath.Join(dir, fmt.Sprint(i)), target.Name, options.Token, options.GitArgs...)
+				if err != nil {
+					Notify(LevelError, "Failed to clone %s: %s", target.Name, helpers.Redact(err.Error()))
+					mu.Lock()
+					result.Failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	size, err := helpers.DirectorySize(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to measure the clones: %w", err)
+	}
+	result.SizeBytes = size
+	result.DurationMS = elapsed.Milliseconds()
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		result.RepositoriesPerMinute = float64(len(targets)-result.Failed) / seconds * 60
+		result.BytesPerSecond = float64(size) / seconds
+	}
+	return result, nil
+}
+
+/*
+createBenchmarkRepositories creates count repositories in dir, each with one commit
+of sizeBytes of random data, which git cannot compress. They are cloned through
+file:// URLs, so git transfers packs as it would from a server instead of hard-linking.
+*/
+func createBenchmarkRepositories(runner helpers.GitRunner, dir string, count int, sizeBytes int64) ([]syncTarget, error) {
+	data := make([]byte, sizeBytes)
+	targets := make([]syncTarget, 0, count)
+	for i := 1; i <= count; i++ {
+		name := fmt.Sprintf("bench-%03d", i)
+		repoPath := filepath.Join(dir, name)
+		if err := os.MkdirAll(repoPath, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		for j := range data {
+			data[j] = byte(rand.IntN(256))
+		}
+		if err := os.WriteFile(filepath.Join(repoPath, "data.bin"), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write data of %s: %w", name, err)
+		}
+
+		for _, args := range [][]string{
+			{"init", "--quiet"},
+			{"add", "data.bin"},
+			{"-c", "user.name=reposync", "-c", "user.email=bench@reposync.invalid", "commit", "--quiet", "--message", "Benchmark data"},
+		} {
+			var stderr bytes.Buffer
+			if err := runner.Run(io.Discard, &stderr, append([]string{"-C", repoPath}, args...)...); err != nil {
+				return nil, fmt.Errorf("failed to create %s: git %s: %w: %s", name, args[0], err, strings.TrimSpace(stderr.String()))
+			}
+		}
+
+		repoURL := "file://" + filepath.ToSlash(repoPath)
+		targets = append(targets, syncTarget{Name: name, HTTPSURL: repoURL, SSHURL: repoURL})
+	}
+	return targets, nil
+}
+
+/*
+RecommendedConcurrency picks the lowest concurrency level whose throughput is
+within 10% of the best one, as more workers beyond it mostly add load.
+*/
+func RecommendedConcurrency(results []models.BenchmarkResult) int {
+	var best float64
+	for _, result := range results {
+		best = max(best, result.RepositoriesPerMinute)
+	}
+	recommended := 0
+	for _, result := range results {
+		if result.RepositoriesPerMinute >= best*benchRecommendShare && (recommended == 0 || result.Concurrency < recommended) {
+			recommended = result.Concurrency
+		}
+	}
+	return recommended
+}
+
+/*
+PrintBenchmark renders benchmark results as a table with a recommendation or, with asJSON, as JSON.
+*/
+func PrintBenchmark(w io.Writer, results []models.BenchmarkResult, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "Workers\tRepositories\tFailed\tDuration\tRepos/min\tThroughput")
+	for _, result := range results {
+		fmt.Fprintf(table, "%d\t%d\t%d\t%s\t%.1f\t%s/s\n", result.Concurrency, result.Repositories, result.Failed,
+			(time.Duration(result.DurationMS) * time.Millisecond).Round(10*time.Millisecond),
+			result.RepositoriesPerMinute, helpers.FormatBytes(int64(result.BytesPerSecond)))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if recommended := RecommendedConcurrency(results); recommended > 0 {
+		fmt.Fprintf(w, "\nRecommended: -j %d\n", recommended)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"os/exec"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestRecommendedConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []models.BenchmarkResult
+		want    int
+	}{
+		{"scales", []models.BenchmarkResult{{Concurrency: 1, RepositoriesPerMinute: 10}, {Concurrency: 4, RepositoriesPerMinute: 35}, {Concurrency: 8, RepositoriesPerMinute: 60}}, 8},
+		{"saturates", []models.BenchmarkResult{{Concurrency: 1, RepositoriesPerMinute: 10}, {Concurrency: 4, RepositoriesPerMinute: 38}, {Concurrency: 8, RepositoriesPerMinute: 40}}, 4},
+		{"none", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecommendedConcurrency(tt.results); got != tt.want {
+				t.Errorf("RecommendedConcurrency() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunBenchmarkSynthetic(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	options := models.SyncOptions{CloneMethod: "https"}
+	results, err := runBenchmark(models.ManifestSource{}, options, []int{1, 2}, 3, 64<<10, Dependencies{Git: helpers.ExecGitRunner{}})
+	if err != nil {
+		t.Fatalf("runBenchmark() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("runBenchmark() returned %d results, want 2", len(results))
+	}
+	for _, result := range results {
+		if result.Repositories != 3 || result.Failed != 0 || result.SizeBytes < 3*64<<10 {
+			t.Errorf("result = %+v, want 3 clones of at least 64 KiB each", result)
+		}
+	}
+}
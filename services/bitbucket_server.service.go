@@ -0,0 +1,205 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+fetchAllBitbucketServerRepositories fetches all repositories of a Bitbucket Server project.
+*/
+func fetchAllBitbucketServerRepositories(api providerAPI, projectKey string) ([]models.BitbucketServerRepository, error) {
+	return fetchBitbucketServerPages[models.BitbucketServerRepository](api, fmt.Sprintf("/projects/%s/repos", projectKey))
+}
+
+/*
+fetchBitbucketServerPages retrieves every item of a Bitbucket Server list endpoint.
+Pages are requested by start offset until the server reports the last page.
+*/
+func fetchBitbucketServerPages[T any](api providerAPI, endpoint string) ([]T, error) {
+	var allItems []T
+	for start := 0; ; {
+		url := helpers.GetBitbucketServerAPIURL(api.baseURL, fmt.Sprintf("%s?limit=100&start=%d", endpoint, start))
+		resp, err := api.get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch page at %d: %w", start, err)
+		}
+
+		page, err := decodeBitbucketServerPage(resp.Body, func(item T) { allItems = append(allItems, item) })
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode page at %d: %w", start, err)
+		}
+
+		if page.IsLastPage || page.NextPageStart <= start {
+			break
+		}
+		start = page.NextPageStart
+	}
+	return allItems, nil
+}
+
+/*
+decodeBitbucketServerPage decodes a page of a list endpoint, streaming its values to yield
+(see decodeJSONArray) and returning the paging fields. Other fields are skipped.
+*/
+func decodeBitbucketServerPage[T any](r io.Reader, yield func(T)) (models.BitbucketServerPage, error) {
+	var page models.BitbucketServerPage
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return page, err
+	} else if token != json.Delim('{') {
+		return page, fmt.Errorf("expected a JSON object, got %v", token)
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return page, err
+		}
+		switch key {
+		case "values":
+			_, err = streamJSONArray(decoder, yield)
+		case "isLastPage":
+			err = decoder.Decode(&page.IsLastPage)
+		case "nextPageStart":
+			err = decoder.Decode(&page.NextPageStart)
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return page, err
+		}
+	}
+	_, err := decoder.Token() // Closing brace
+	return page, err
+}
+
+/*
+checkBitbucketServerAPI verifies that the Bitbucket Server API is reachable before a sync starts.
+Calls /application-properties, which every version serves, and returns the server version.
+*/
+func checkBitbucketServerAPI(api providerAPI) (string, error) {
+	if api.baseURL == "" {
+		return "", fmt.Errorf("no Bitbucket Server URL configured: set bitbucket_server_url in the config file or REPOSYNC_BITBUCKET_SERVER_URL")
+	}
+	url := helpers.GetBitbucketServerAPIURL(api.baseURL, "/application-properties")
+	resp, err := api.get(url)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach the Bitbucket Server API at %s: %w", helpers.GetBitbucketServerAPIURL(api.baseURL, ""), err)
+	}
+	defer resp.Body.Close()
+
+	var properties struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&properties); err != nil {
+		return "", fmt.Errorf("unexpected response from %s: %w", url, err)
+	}
+	return properties.Version, nil
+}
+
+/*
+CloneBitbucketServerProjectWithOptions clones all repositories of a Bitbucket Server
+or Data Center project into a flat structure under the options' base directory.
+*/
+func CloneBitbucketServerProjectWithOptions(projectKey string, options models.SyncOptions) error {
+	return syncBitbucketServerProject(projectKey, options, DefaultDependencies())
+}
+
+/*
+syncBitbucketServerProject implements CloneBitbucketServerProjectWithOptions
+on top of injectable dependencies.
+*/
+func syncBitbucketServerProject(projectKey string, options models.SyncOptions, deps Dependencies) error {
+	if err := helpers.ValidateProjectKey(projectKey); err != nil {
+		return fmt.Errorf("invalid project key: %w", err)
+	}
+
+	api := newProviderAPI(options, deps)
+	version, err := checkBitbucketServerAPI(api)
+	if err != nil {
+		return err
+	}
+	api.notify(LevelInfo, "Connected to Bitbucket Server %s", version)
+
+	api.notify(LevelNotice, "Fetching Bitbucket Server repositories...")
+
+	repositories, err := fetchAllBitbucketServerRepositories(api, projectKey)
+	if err != nil {
+		return fmt.Errorf("failed to fetch repositories: %w", err)
+	}
+
+	api.notify(LevelInfo, "Found %d repositories", len(repositories))
+
+	run, err := newSyncRun("bitbucket-server", options, deps)
+	if err != nil {
+		return err
+	}
+
+	run.syncAll(run.groupTargets(bitbucketServerTargets(repositories, options.BaseDir)))
+	return run.finish()
+}
+
+/*
+bitbucketServerTargets converts Bitbucket Server repositories into sync targets.
+Repositories are placed in a flat structure under baseDir, named after their slug.
+The repository listing carries no activity dates, so LastActivity stays unset.
+*/
+func bitbucketServerTargets(repositories []models.BitbucketServerRepository, baseDir string) []syncTarget {
+	targets := make([]syncTarget, 0, len(repositories))
+	for _, repository := range repositories {
+		targets = append(targets, newSyncTarget(bitbucketServerRepository(repository), filepath.Join(baseDir, repository.Slug)))
+	}
+	return targets
+}
+
+/*
+bitbucketServerRepository converts a repository of the Bitbucket Server API into the provider-neutral model.
+*/
+func bitbucketServerRepository(repository models.BitbucketServerRepository) models.Repository {
+	converted := models.Repository{
+		ID:          repository.ID,
+		Name:        repository.Name,
+		Path:        repository.Slug,
+		Namespace:   repository.Project.Key,
+		Description: repository.Description,
+		Visibility:  "private",
+	}
+	if repository.Public {
+		converted.Visibility = "public"
+	}
+	for _, link := range repository.Links.Clone {
+		switch strings.ToLower(link.Name) {
+		case "http", "https":
+			converted.HTTPSURL = withoutUserInfo(link.Href)
+		case "ssh":
+			converted.SSHURL = link.Href
+		}
+	}
+	if len(repository.Links.Self) > 0 {
+		converted.WebURL = repository.Links.Self[0].Href
+	}
+	return converted
+}
+
+/*
+withoutUserInfo removes the username Bitbucket Server puts into HTTP clone URLs
+(https://jdoe@host/scm/...), as clones authenticate with their own credentials.
+*/
+func withoutUserInfo(cloneURL string) string {
+	parsed, err := url.Parse(cloneURL)
+	if err != nil || parsed.User == nil {
+		return cloneURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+newBitbucketServerServer serves the repositories of project PLAT, two per page.
+*/
+func newBitbucketServerServer(t *testing.T, slugs []string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer bbs_testtoken1234" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/1.0/application-properties":
+			w.Write([]byte(`{"version": "8.19.1", "displayName": "Bitbucket"}`))
+			return
+		case "/rest/api/1.0/projects/PLAT/repos":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var start int
+		fmt.Sscanf(r.URL.Query().Get("start"), "%d", &start)
+		end := min(start+2, len(slugs))
+		var values []string
+		for i, slug := range slugs[start:end] {
+			values = append(values, fmt.Sprintf(`{"id": %d, "slug": %q, "name": %q, "project": {"key": "PLAT"},
+				"links": {"clone": [{"href": "https://jdoe@bitbucket.example.com/scm/plat/%[2]s.git", "name": "http"},
+					{"href": "ssh://git@bitbucket.example.com:7999/plat/%[2]s.git", "name": "ssh"}]}}`, start+i+1, slug, strings.ToUpper(slug)))
+		}
+		fmt.Fprintf(w, `{"values": [%s], "isLastPage": %t, "nextPageStart": %d}`, strings.Join(values, ","), end == len(slugs), end)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchAllBitbucketServerRepositoriesPagination(t *testing.T) {
+	server := newBitbucketServerServer(t, []string{"api", "web", "docs"})
+
+	api := providerAPI{http: server.Client(), token: "bbs_testtoken1234", baseURL: server.URL}
+	repos, err := fetchAllBitbucketServerRepositories(api, "PLAT")
+	if err != nil {
+		t.Fatalf("fetchAllBitbucketServerRepositories() error = %v", err)
+	}
+	if len(repos) != 3 {
+		t.Fatalf("fetchAllBitbucketServerRepositories() returned %d repositories, want 3", len(repos))
+	}
+
+	targets := bitbucketServerTargets(repos, "/work/PLAT")
+	want := syncTarget{
+		ID:         3,
+		Name:       "DOCS",
+		RemotePath: "PLAT/docs",
+		HTTPSURL:   "https://bitbucket.example.com/scm/plat/docs.git",
+		SSHURL:     "ssh://git@bitbucket.example.com:7999/plat/docs.git",
+		Path:       filepath.Join("/work/PLAT", "docs"),
+		Visibility: "private",
+	}
+	if !reflect.DeepEqual(targets[2], want) {
+		t.Errorf("target = %+v, want %+v", targets[2], want)
+	}
+}
+
+func TestSyncBitbucketServerProject(t *testing.T) {
+	server := newBitbucketServerServer(t, []string{"api", "web"})
+	workspace := t.TempDir()
+	git := &fakeGitRunner{}
+
+	options := models.SyncOptions{
+		Token:       "bbs_testtoken1234",
+		CloneMethod: "ssh",
+		BaseDir:     workspace,
+		BaseURL:     server.URL,
+	}
+	if err := syncBitbucketServerProject("PLAT", options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+		t.Fatalf("syncBitbucketServerProject() error = %v", err)
+	}
+
+	for _, name := range []string{"api", "web"} {
+		if _, err := os.Stat(filepath.Join(workspace, name, ".git")); err != nil {
+			t.Errorf("repository %s was not cloned: %v", name, err)
+		}
+	}
+	wantClone := "clone ssh://git@bitbucket.example.com:7999/plat/web.git " + helpers.CloneTempPath(filepath.Join(workspace, "web"))
+	if !strings.Contains(strings.Join(git.commands(), "\n"), wantClone) {
+		t.Errorf("git commands = %v, want %s", git.commands(), wantClone)
+	}
+
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if got := state.Repositories["bitbucket-server:2"].RemotePath; got != "PLAT/web" {
+		t.Errorf("remote path of repository 2 = %q, want PLAT/web", got)
+	}
+
+	options.BaseURL = ""
+	if err := syncBitbucketServerProject("PLAT", options, Dependencies{HTTP: server.Client(), Git: git}); err == nil {
+		t.Error("syncBitbucketServerProject() without an instance URL succeeded")
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"fmt"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// Runner fields that change while the runner is working
+var volatileRunnerFields = []string{"online", "status", "contacted_at", "ip_address", "version", "revision", "platform", "architecture"}
+
+// Pipeline schedule fields that change with every scheduled run
+var volatileScheduleFields = []string{"next_run_at", "last_pipeline"}
+
+/*
+fetchGitLabOffsetPages retrieves every item of a GitLab list endpoint with page offsets.
+Used for lists without numeric IDs, such as CI/CD variables, which keyset pagination cannot follow.
+*/
+func fetchGitLabOffsetPages[T any](api providerAPI, endpoint string) ([]T, error) {
+	var allItems []T
+	for page := 1; ; page++ {
+		url := helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("%s?per_page=%d&page=%d", endpoint, gitLabPageSize, page))
+		resp, err := api.get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
+		}
+
+		count, err := decodeJSONArray(resp.Body, func(item T) { allItems = append(allItems, item) })
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode page %d: %w", page, err)
+		}
+
+		if count < gitLabPageSize {
+			return allItems, nil
+		}
+	}
+}
+
+/*
+getGitLabRunners fetches the runners registered to a project or group endpoint
+(e.g. /projects/1/runners?type=project_type) with their full configuration,
+including tags and access level, which the list endpoint does not return.
+*/
+func getGitLabRunners(api providerAPI, endpoint string) ([]map[string]any, error) {
+	runners, err := fetchGitLabOffsetPages[map[string]any](api, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	details := []map[string]any{}
+	for _, runner := range runners {
+		detail := map[string]any{}
+		if err := api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/runners/%d", jsonObjectID(runner))), &detail); err != nil {
+			detail = runner // Runner details need more rights than listing, keep what the list returned
+		}
+		details = append(details, helpers.StripVolatileFields(detail, volatileRunnerFields...))
+	}
+	return details, nil
+}
+
+/*
+collectGitLabProjectCIConfig reads the CI/CD variables, pipeline schedules
+(with their variables) and project runners of a GitLab project.
+These endpoints require the Maintainer role; missing sections are recorded in snapshot.Errors.
+*/
+func collectGitLabProjectCIConfig(api providerAPI, projectID int64, snapshot *models.CIConfigSnapshot) {
+	variables, err := fetchGitLabOffsetPages[map[string]any](api, fmt.Sprintf("/projects/%d/variables", projectID))
+	if err != nil {
+		snapshot.Errors["variables"] = err.Error()
+	} else {
+		snapshot.Variables = variables
+	}
+
+	schedules, err := fetchGitLabKeysetPages(api, fmt.Sprintf("/projects/%d/pipeline_schedules", projectID), "", jsonObjectID)
+	if err != nil {
+		snapshot.Errors["pipeline_schedules"] = err.Error()
+	}
+	for _, schedule := range schedules {
+		// Only the single schedule endpoint returns the schedule's variables
+		detail := map[string]any{}
+		endpoint := fmt.Sprintf("/projects/%d/pipeline_schedules/%d", projectID, jsonObjectID(schedule))
+		if err := api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, endpoint), &detail); err != nil {
+			snapshot.Errors["pipeline_schedules"] = err.Error()
+			detail = schedule
+		}
+		snapshot.PipelineSchedules = append(snapshot.PipelineSchedules, helpers.StripVolatileFields(detail, volatileScheduleFields...))
+	}
+
+	runners, err := getGitLabRunners(api, fmt.Sprintf("/projects/%d/runners?type=project_type", projectID))
+	if err != nil {
+		snapshot.Errors["runners"] = err.Error()
+	} else {
+		snapshot.Runners = runners
+	}
+}
+
+/*
+collectGitLabGroupCIConfig reads the CI/CD variables and group runners of a GitLab group.
+*/
+func collectGitLabGroupCIConfig(api providerAPI, groupID int, snapshot *models.CIConfigSnapshot) {
+	variables, err := fetchGitLabOffsetPages[map[string]any](api, fmt.Sprintf("/groups/%d/variables", groupID))
+	if err != nil {
+		snapshot.Errors["variables"] = err.Error()
+	} else {
+		snapshot.Variables = variables
+	}
+
+	runners, err := getGitLabRunners(api, fmt.Sprintf("/groups/%d/runners?type=group_type", groupID))
+	if err != nil {
+		snapshot.Errors["runners"] = err.Error()
+	} else {
+		snapshot.Runners = runners
+	}
+}
+
+/*
+exportCIConfig saves the CI/CD configuration snapshot of a GitLab project.
+*/
+func (r *syncRun) exportCIConfig(target syncTarget) {
+	snapshot := models.CIConfigSnapshot{Path: target.RemotePath, Errors: map[string]string{}}
+	collectGitLabProjectCIConfig(r.api, target.ID, &snapshot)
+	for _, schedule := range snapshot.PipelineSchedules {
+		if variables, ok := schedule["variables"].([]any); ok {
+			schedule["variables"] = r.maskVariables(anyObjects(variables))
+		}
+	}
+	r.writeCIConfig("projects", r.relativePath(target.Path), snapshot)
+}
+
+/*
+exportGroupCIConfig saves the CI/CD configuration snapshots of a GitLab group
+and, recursively, of all its subgroups.
+*/
+func (r *syncRun) exportGroupCIConfig(groupID int, fullPath string) {
+	snapshot := models.CIConfigSnapshot{Path: fullPath, Errors: map[string]string{}}
+	collectGitLabGroupCIConfig(r.api, groupID, &snapshot)
+	r.writeCIConfig("groups", fullPath, snapshot)
+
+	subgroups, err := getGitLabSubgroups(r.api, groupID)
+	if err != nil {
+		r.notify(LevelError, "Failed to export CI/CD configuration of the subgroups of %s: %v", fullPath, err)
+		return
+	}
+	for _, subgroup := range subgroups {
+		r.exportGroupCIConfig(subgroup.ID, subgroup.FullPath)
+	}
+}
+
+/*
+writeCIConfig masks the variable values unless they were requested and writes the snapshot.
+Snapshots with values can only be read by their owner.
+*/
+func (r *syncRun) writeCIConfig(kind, relPath string, snapshot models.CIConfigSnapshot) {
+	snapshot.Variables = r.maskVariables(snapshot.Variables)
+	snapshot.ValuesIncluded = r.options.IncludeVariableValues
+	for section, reason := range snapshot.Errors {
+		r.notify(LevelWarning, "Skipping CI/CD %s of %s: %s", section, snapshot.Path, reason)
+	}
+
+	path := helpers.GetCIConfigPath(r.options.BaseDir, kind, relPath)
+	var err error
+	if snapshot.ValuesIncluded {
+		err = helpers.WritePrivateJSONReport(path, snapshot)
+	} else {
+		err = helpers.WriteJSONReport(path, snapshot)
+	}
+	r.audit.Record("write-ci-config", path, "", err)
+	if err != nil {
+		r.notify(LevelError, "Failed to write CI/CD configuration of %s: %v", snapshot.Path, err)
+	}
+}
+
+/*
+maskVariables masks variable values unless IncludeVariableValues was set.
+An empty list stays an empty JSON array instead of null.
+*/
+func (r *syncRun) maskVariables(variables []map[string]any) []map[string]any {
+	if variables == nil {
+		variables = []map[string]any{}
+	}
+	if !r.options.IncludeVariableValues {
+		helpers.MaskVariableValues(variables)
+	}
+	return variables
+}
+
+/*
+anyObjects converts a decoded JSON array into its objects, skipping other values.
+*/
+func anyObjects(values []any) []map[string]any {
+	objects := []map[string]any{}
+	for _, value := range values {
+		if object, ok := value.(map[string]any); ok {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
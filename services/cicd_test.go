@@ -0,0 +1,85 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestExportCIConfig(t *testing.T) {
+	responses := map[string]string{
+		"/api/v4/projects/7/variables":            `[{"key": "DEPLOY_KEY", "value": "s3cret", "protected": true, "masked": true}]`,
+		"/api/v4/projects/7/pipeline_schedules":   `[{"id": 3, "description": "nightly"}]`,
+		"/api/v4/projects/7/pipeline_schedules/3": `{"id": 3, "description": "nightly", "cron": "0 2 * * *", "next_run_at": "2026-10-17T02:00:00Z", "variables": [{"key": "MODE", "value": "full"}]}`,
+		"/api/v4/projects/7/runners":              `[{"id": 9, "description": "builder"}]`,
+		"/api/v4/runners/9":                       `{"id": 9, "description": "builder", "tag_list": ["docker"], "contacted_at": "2026-10-16T10:00:00Z"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name          string
+		includeValues bool
+		wantValue     string
+		wantMode      os.FileMode
+	}{
+		{"masked", false, "[REDACTED]", 0644},
+		{"values included", true, "s3cret", 0600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			run := &syncRun{
+				provider: "gitlab",
+				options:  models.SyncOptions{BaseDir: workspace, ExportCIConfig: true, IncludeVariableValues: tt.includeValues},
+				api:      providerAPI{http: server.Client(), baseURL: server.URL},
+			}
+			run.exportCIConfig(syncTarget{ID: 7, RemotePath: "top/api", Path: filepath.Join(workspace, "top", "api")})
+
+			path := helpers.GetCIConfigPath(workspace, "projects", "top/api")
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("snapshot not written: %v", err)
+			}
+			if info.Mode().Perm() != tt.wantMode {
+				t.Errorf("mode = %v, want %v", info.Mode().Perm(), tt.wantMode)
+			}
+
+			data, _ := os.ReadFile(path)
+			var snapshot models.CIConfigSnapshot
+			if err := json.Unmarshal(data, &snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if len(snapshot.Variables) != 1 || snapshot.Variables[0]["value"] != tt.wantValue || snapshot.Variables[0]["protected"] != true {
+				t.Errorf("variables = %v, want value %q", snapshot.Variables, tt.wantValue)
+			}
+			if len(snapshot.PipelineSchedules) != 1 || snapshot.PipelineSchedules[0]["cron"] != "0 2 * * *" {
+				t.Fatalf("pipeline schedules = %v", snapshot.PipelineSchedules)
+			}
+			if _, ok := snapshot.PipelineSchedules[0]["next_run_at"]; ok {
+				t.Error("next_run_at should be stripped")
+			}
+			scheduleVariable := snapshot.PipelineSchedules[0]["variables"].([]any)[0].(map[string]any)
+			if tt.includeValues == (scheduleVariable["value"] == "[REDACTED]") {
+				t.Errorf("schedule variable = %v, includeValues %v", scheduleVariable, tt.includeValues)
+			}
+			if len(snapshot.Runners) != 1 || snapshot.Runners[0]["tag_list"] == nil || snapshot.Runners[0]["contacted_at"] != nil {
+				t.Errorf("runners = %v, want details without contacted_at", snapshot.Runners)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+commitCollector gathers the exported commits from the workers of a run.
+heads remembers the newest exported commit per state key, which becomes
+the repository's ExportedCommit once the state is saved.
+*/
+type commitCollector struct {
+	mu      sync.Mutex
+	records []models.CommitRecord
+	heads   map[string]string
+}
+
+/*
+exportedHead returns the newest commit exported for a repository during this run.
+Safe to call on a nil collector, when no export was requested.
+*/
+func (c *commitCollector) exportedHead(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head, ok := c.heads[key]
+	return head, ok
+}
+
+/*
+exportCommits collects the commits a repository received since the previous export.
+Existing clones are not updated by a sync, so origin is fetched first and the
+default branch's commits after the ExportedCommit recorded in the state are listed.
+The first export of a repository covers its whole history; when the recorded
+commit is gone (e.g. after a force push), the commits since the last sync are exported.
+*/
+func (r *syncRun) exportCommits(target syncTarget) {
+	if target.Empty || target.DefaultBranch == "" {
+		return // Empty repositories have no commits
+	}
+	relPath := r.relativePath(target.Path)
+	key := helpers.StateKey(r.provider, target.ID)
+	r.mu.Lock()
+	previous := r.state.Repositories[key]
+	r.mu.Unlock()
+
+	if err := helpers.FetchOrigin(r.deps.Git, target.Path); err != nil {
+		r.notify(LevelError, "Failed to export commits of %s: %v", relPath, err)
+		return
+	}
+	head, err := helpers.ResolveCommit(r.deps.Git, target.Path, "origin/HEAD")
+	if err != nil {
+		r.notify(LevelError, "Failed to export commits of %s: %v", relPath, err)
+		return
+	}
+	if head == previous.ExportedCommit {
+		return
+	}
+
+	args := []string{head}
+	if previous.ExportedCommit != "" {
+		if _, err := helpers.ResolveCommit(r.deps.Git, target.Path, previous.ExportedCommit); err == nil {
+			args = []string{previous.ExportedCommit + ".." + head}
+		} else {
+			r.notify(LevelWarning, "Commit %s of %s no longer exists, exporting commits since %s", previous.ExportedCommit, relPath, previous.LastSynced.Format(time.RFC3339))
+			args = []string{"--since=" + previous.LastSynced.Format(time.RFC3339), head}
+		}
+	}
+
+	commits, err := helpers.ReadCommitLog(r.deps.Git, target.Path, args...)
+	if err != nil {
+		r.notify(LevelError, "Failed to export commits of %s: %v", relPath, err)
+		return
+	}
+	for i := range commits {
+		commits[i].Repository = relPath
+		commits[i].RemotePath = target.RemotePath
+	}
+
+	r.commits.mu.Lock()
+	r.commits.records = append(r.commits.records, commits...)
+	r.commits.heads[key] = head
+	r.commits.mu.Unlock()
+}
+
+/*
+writeCommitExport saves the commits of the run as newline-delimited JSON.
+Records are grouped by repository and listed newest first within a repository.
+*/
+func (r *syncRun) writeCommitExport() error {
+	r.commits.mu.Lock()
+	records := append([]models.CommitRecord{}, r.commits.records...)
+	r.commits.mu.Unlock()
+
+	repositories := map[string]bool{}
+	for _, record := range records {
+		repositories[record.Repository] = true
+	}
+
+	// Workers finish in any order, git log already sorted each repository
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Repository < records[j].Repository })
+
+	path := r.options.CommitExportPath
+	err := helpers.WriteNDJSONReport(path, records)
+	r.audit.Record("write-commit-export", path, "", err)
+	if err != nil {
+		return err
+	}
+	r.notify(LevelSuccess, "Wrote %d commits from %d repositories to %s", len(records), len(repositories), path)
+	return nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestExportCommitsContinuesFromState(t *testing.T) {
+	repo := gitHubRepo(1, "api")
+	repo.DefaultBranch = "main"
+	server := newGitHubServer(t, [][]models.GitHubRepository{{repo}})
+	workspace := t.TempDir()
+	exportPath := filepath.Join(t.TempDir(), "commits.ndjson")
+	options := models.SyncOptions{Token: "ghp_testtoken1234", BaseDir: workspace, BaseURL: server.URL, CommitExportPath: exportPath}
+
+	git := &fakeGitRunner{outputs: map[string]string{
+		"rev-parse": "bbb\n",
+		"log":       "\x1ebbb\x1fJane Doe\x1fjane@example.com\x1f2026-10-02T14:03:11Z\x1fSecond\n\x1eaaa\x1fJane Doe\x1fjane@example.com\x1f2026-10-01T14:03:11Z\x1fFirst\n",
+	}}
+	if err := syncGitHubOrganization("acme", options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"repository":"api"`) || !strings.Contains(lines[0], `"hash":"bbb"`) {
+		t.Errorf("export =\n%s", data)
+	}
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := state.Repositories["github:1"].ExportedCommit; got != "bbb" {
+		t.Errorf("exported commit = %q, want bbb", got)
+	}
+
+	// The next export only covers what arrived after the recorded commit
+	git = &fakeGitRunner{outputs: map[string]string{"rev-parse": "ccc\n", "log": ""}}
+	if err := syncGitHubOrganization("acme", options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	if !slices.ContainsFunc(git.commands(), func(command string) bool { return strings.HasSuffix(command, " bbb..ccc") }) {
+		t.Errorf("expected a log of bbb..ccc, git ran:\n%s", strings.Join(git.commands(), "\n"))
+	}
+}
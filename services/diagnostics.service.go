@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// diagnosticsRunsBundled is the number of recent runs a diagnostics bundle contains.
+const diagnosticsRunsBundled = 20
+
+/*
+diagnosticsCollector counts the failures of a run by category for the opt-in
+diagnostics log. Only categories are kept, never the errors themselves, since
+their messages name repositories, paths and hosts.
+*/
+type diagnosticsCollector struct {
+	mu     sync.Mutex
+	start  time.Time
+	errors map[string]int
+}
+
+/*
+addFailure records the category of a repository's failure.
+Nil-safe, so callers need not check whether diagnostics were requested.
+*/
+func (c *diagnosticsCollector) addFailure(err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[classifyError(err)]++
+}
+
+/*
+classifyError maps an error to a coarse, anonymous category.
+*/
+func classifyError(err error) string {
+	var statusErr *client.StatusError
+	var ssoErr *client.SSOError
+	var netErr net.Error
+	var exitErr *exec.ExitError
+	var gitErr *helpers.GitError
+	switch {
+	case errors.Is(err, helpers.ErrGitTimeout):
+		return "timeout"
+	case errors.Is(err, helpers.ErrReadOnly):
+		return "read-only"
+	case errors.As(err, &ssoErr):
+		return "sso"
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusTooManyRequests:
+		return "rate-limit"
+	case errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden):
+		return "auth"
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound:
+		return "not-found"
+	case errors.As(err, &statusErr) && statusErr.Code >= 500:
+		return "server"
+	case errors.As(err, &statusErr):
+		return "http"
+	case errors.Is(err, syscall.ENOSPC):
+		return "disk-full"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.As(err, &gitErr) && gitErr.Class != helpers.GitErrorOther:
+		return gitErr.Class
+	case errors.As(err, &exitErr):
+		return "git"
+	}
+	return "other"
+}
+
+/*
+writeDiagnostics appends the anonymized record of the run to the diagnostics log.
+A failure to write it is reported but never fails the sync.
+*/
+func (r *syncRun) writeDiagnostics() {
+	record := models.DiagnosticsRecord{
+		Time:        time.Now().UTC(),
+		Provider:    r.provider,
+		CloneMethod: r.options.CloneMethod,
+		Concurrency: r.options.Concurrency,
+		Failed:      r.summary.failures(),
+		DurationMS:  time.Since(r.diagnostics.start).Milliseconds(),
+	}
+
+	r.diagnostics.mu.Lock()
+	if len(r.diagnostics.errors) > 0 {
+		record.Errors = r.diagnostics.errors
+	}
+	r.diagnostics.mu.Unlock()
+
+	r.summary.mu.Lock()
+	durations := make([]time.Duration, 0, len(r.summary.timings))
+	for _, timing := range r.summary.timings {
+		durations = append(durations, timing.duration)
+	}
+	r.summary.mu.Unlock()
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	record.Repositories = len(durations) + record.Failed
+	if len(durations) > 0 {
+		record.MedianRepoMS = durations[len(durations)/2].Milliseconds()
+		record.SlowestMS = durations[len(durations)-1].Milliseconds()
+	}
+
+	path := helpers.GetDiagnosticsPath(r.options.BaseDir)
+	err := helpers.AppendDiagnostics(path, record)
+	r.audit.Record("write-diagnostics", path, "", err)
+	if err != nil {
+		r.notify(LevelWarning, "%v", err)
+	}
+}
+
+/*
+Doctor checks the environment reposync runs in and prints the results.
+checks are the results the caller already determined (e.g. of the config file);
+git and the workspace are checked here. With a bundle path, the checks and the
+recent records of the workspace's diagnostics log are written to that file as
+JSON, for attaching to bug reports. Nothing in the bundle names a repository,
+path, host or token.
+*/
+func Doctor(workspace, bundlePath, version string, checks []models.DiagnosticsCheck) error {
+	bundle := models.DiagnosticsBundle{
+		Created:   time.Now().UTC(),
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	gitCheck := models.DiagnosticsCheck{Name: "git"}
+	if gitVersion, err := helpers.GitVersion(helpers.ExecGitRunner{}); err != nil {
+		gitCheck.Detail = err.Error()
+	} else {
+		bundle.GitVersion = gitVersion
+		gitCheck.OK, gitCheck.Detail = true, "version "+gitVersion
+	}
+	checks = append([]models.DiagnosticsCheck{gitCheck}, checks...)
+
+	stateCheck := models.DiagnosticsCheck{Name: "workspace state"}
+	if state, err := helpers.LoadState(workspace); err != nil {
+		stateCheck.Detail = "unreadable or invalid"
+	} else {
+		stateCheck.OK, stateCheck.Detail = true, fmt.Sprintf("%d repositories recorded", len(state.Repositories))
+	}
+	checks = append(checks, stateCheck)
+
+	runs, err := helpers.ReadDiagnostics(helpers.GetDiagnosticsPath(workspace), diagnosticsRunsBundled)
+	if err != nil {
+		return err
+	}
+	runsCheck := models.DiagnosticsCheck{Name: "diagnostics log", OK: true, Detail: fmt.Sprintf("%d runs recorded", len(runs))}
+	if len(runs) == 0 {
+		runsCheck.Detail = "no runs recorded: sync with --diagnostics to record some"
+	}
+	checks = append(checks, runsCheck)
+	bundle.Checks, bundle.Runs = checks, runs
+
+	for _, check := range checks {
+		level := LevelSuccess
+		if !check.OK {
+			level = LevelError
+		}
+		report(Event{Type: EventCheck, Level: level, Check: check.Name, Message: check.Detail})
+	}
+
+	if bundlePath == "" {
+		return nil
+	}
+	if err := helpers.WriteJSONReport(bundlePath, bundle); err != nil {
+		return err
+	}
+	Notify(LevelSuccess, "Wrote diagnostics bundle to %s", bundlePath)
+	return nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"git timeout", fmt.Errorf("git clone failed for api: %w after 10m0s", helpers.ErrGitTimeout), "timeout"},
+		{"sso", &client.SSOError{URL: "https://github.com/orgs/acme/sso"}, "sso"},
+		{"rate limit", &client.StatusError{Code: 429}, "rate-limit"},
+		{"unauthorized", fmt.Errorf("failed to fetch: %w", &client.StatusError{Code: 401}), "auth"},
+		{"not found", &client.StatusError{Code: 404}, "not-found"},
+		{"server", &client.StatusError{Code: 502}, "server"},
+		{"disk full", &os.PathError{Op: "write", Path: "/backup/api", Err: syscall.ENOSPC}, "disk-full"},
+		{"permission", &os.PathError{Op: "mkdir", Path: "/backup/api", Err: os.ErrPermission}, "permission"},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, "network"},
+		{"git", fmt.Errorf("git clone failed for api: %w", &exec.ExitError{}), "git"},
+		{"other", errors.New("something else"), "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	workspace := t.TempDir()
+	run := &syncRun{
+		provider:    "gitlab",
+		options:     models.SyncOptions{BaseDir: workspace, CloneMethod: "ssh", Concurrency: 4},
+		summary:     &syncSummary{},
+		diagnostics: &diagnosticsCollector{start: time.Now(), errors: map[string]int{}},
+	}
+	run.summary.addTiming("secret-group/api", 3*time.Second, 0, 0)
+	run.summary.addTiming("secret-group/web", time.Second, 0, 0)
+	run.summary.addTiming("secret-group/db", 2*time.Second, 0, 0)
+	run.summary.addFailed("secret-group/legacy")
+	run.diagnostics.addFailure(fmt.Errorf("git clone failed for secret-group/legacy: %w", helpers.ErrGitTimeout))
+
+	run.writeDiagnostics()
+
+	path := helpers.GetDiagnosticsPath(workspace)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-group") {
+		t.Errorf("diagnostics name a repository: %s", data)
+	}
+	records, err := helpers.ReadDiagnostics(path, diagnosticsRunsBundled)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("ReadDiagnostics() = %v, %v, want one record", records, err)
+	}
+	got := records[0]
+	if got.Repositories != 4 || got.Failed != 1 || got.Errors["timeout"] != 1 || got.MedianRepoMS != 2000 || got.SlowestMS != 3000 {
+		t.Errorf("record = %+v", got)
+	}
+	if got.Provider != "gitlab" || got.CloneMethod != "ssh" || got.Concurrency != 4 {
+		t.Errorf("record settings = %+v", got)
+	}
+}
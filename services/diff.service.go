@@ -0,0 +1,242 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+DiffGitHubOrganization compares the repositories of a GitHub organization with a state snapshot.
+Nothing is cloned or written; the snapshot is a state.json saved by an earlier run.
+*/
+func DiffGitHubOrganization(org string, options models.SyncOptions, snapshotPath string) (*models.DeltaReport, error) {
+	return diffGitHubOrganization(org, options, snapshotPath, DefaultDependencies())
+}
+
+/*
+diffGitHubOrganization implements DiffGitHubOrganization
+on top of injectable dependencies.
+*/
+func diffGitHubOrganization(org string, options models.SyncOptions, snapshotPath string, deps Dependencies) (*models.DeltaReport, error) {
+	if err := helpers.ValidateOrganizationName(org); err != nil {
+		return nil, fmt.Errorf("invalid organization name: %w", err)
+	}
+	snapshot, err := helpers.LoadStateSnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newDiffRun("github", options, deps)
+	if _, err := checkGitHubAPI(run.api); err != nil {
+		return nil, err
+	}
+	repositories, err := listGitHubRepositories(run.api, org, run.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repositories: %w", err)
+	}
+	targets := run.filterTargets(gitHubTargets(repositories, options.BaseDir))
+	return compareSnapshot(snapshot, "github", org, targets, run.seen), nil
+}
+
+/*
+DiffBitbucketServerProject compares the repositories of a Bitbucket Server project with a state snapshot.
+Bitbucket Server reports no activity dates, so only new and deleted repositories are found.
+*/
+func DiffBitbucketServerProject(projectKey string, options models.SyncOptions, snapshotPath string) (*models.DeltaReport, error) {
+	return diffBitbucketServerProject(projectKey, options, snapshotPath, DefaultDependencies())
+}
+
+/*
+diffBitbucketServerProject implements DiffBitbucketServerProject
+on top of injectable dependencies.
+*/
+func diffBitbucketServerProject(projectKey string, options models.SyncOptions, snapshotPath string, deps Dependencies) (*models.DeltaReport, error) {
+	if err := helpers.ValidateProjectKey(projectKey); err != nil {
+		return nil, fmt.Errorf("invalid project key: %w", err)
+	}
+	snapshot, err := helpers.LoadStateSnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newDiffRun("bitbucket-server", options, deps)
+	if _, err := checkBitbucketServerAPI(run.api); err != nil {
+		return nil, err
+	}
+	repositories, err := fetchAllBitbucketServerRepositories(run.api, projectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repositories: %w", err)
+	}
+	targets := run.filterTargets(bitbucketServerTargets(repositories, options.BaseDir))
+	return compareSnapshot(snapshot, "bitbucket-server", projectKey, targets, run.seen), nil
+}
+
+/*
+DiffGitLabGroup compares the repositories of a GitLab group tree with a state snapshot.
+*/
+func DiffGitLabGroup(groupID int, options models.SyncOptions, snapshotPath string) (*models.DeltaReport, error) {
+	return diffGitLabGroup(groupID, options, snapshotPath, DefaultDependencies())
+}
+
+/*
+diffGitLabGroup implements DiffGitLabGroup on top of injectable dependencies.
+The group tree is walked like a --no-write sync, so no directories are created.
+*/
+func diffGitLabGroup(groupID int, options models.SyncOptions, snapshotPath string, deps Dependencies) (*models.DeltaReport, error) {
+	if options.TokenType != models.TokenTypePersonal {
+		return nil, fmt.Errorf("diff needs to list the group's projects, which %s tokens cannot do", options.TokenType)
+	}
+	snapshot, err := helpers.LoadStateSnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newDiffRun("gitlab", options, deps)
+	rootGroup, err := getGitLabGroupInfo(run.api, groupID)
+	if client.IsNotFound(err) && options.TokenType == models.TokenTypePersonal {
+		return nil, explainGitLabGroupNotFound(run.api, groupID, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch group info: %w", err)
+	}
+	var targets []syncTarget
+	if err := collectGitLabGroup(run, groupID, options.BaseDir, &targets); err != nil {
+		return nil, err
+	}
+	report := compareSnapshot(snapshot, "gitlab", rootGroup.FullPath, run.filterTargets(targets), run.seen)
+	if options.MaxDepth > 0 {
+		// Repositories below --max-depth were not listed, which doesn't make them deleted
+		report.Deleted = slices.DeleteFunc(report.Deleted, func(repository models.DeltaRepository) bool {
+			return strings.Count(strings.TrimPrefix(repository.Path, rootGroup.FullPath+"/"), "/") >= options.MaxDepth
+		})
+	}
+	return report, nil
+}
+
+/*
+DiffAllGitLabProjects compares every project of a GitLab instance with a state snapshot.
+*/
+func DiffAllGitLabProjects(options models.SyncOptions, snapshotPath string) (*models.DeltaReport, error) {
+	return diffAllGitLabProjects(options, snapshotPath, DefaultDependencies())
+}
+
+/*
+diffAllGitLabProjects implements DiffAllGitLabProjects on top of injectable dependencies.
+*/
+func diffAllGitLabProjects(options models.SyncOptions, snapshotPath string, deps Dependencies) (*models.DeltaReport, error) {
+	snapshot, err := helpers.LoadStateSnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	run := newDiffRun("gitlab", options, deps)
+	projects, err := fetchAllGitLabProjects(run.api, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch projects: %w", err)
+	}
+	targets := run.filterTargets(gitLabProjectTargets(projects, options.BaseDir))
+	return compareSnapshot(snapshot, "gitlab", "", targets, run.seen), nil
+}
+
+/*
+newDiffRun returns a read-only run of a provider for a diff. Its filterTargets
+drops the repositories a sync would skip (inactive, opted out or filtered out)
+and records them in seen, so they are reported neither as new nor as deleted.
+*/
+func newDiffRun(provider string, options models.SyncOptions, deps Dependencies) *syncRun {
+	options.NoWrite = true
+	run := &syncRun{provider: provider, options: options, deps: deps, api: newProviderAPI(options, deps), seen: map[string]bool{}, summary: &syncSummary{}}
+	run.render = run.api.render
+	return run
+}
+
+/*
+compareSnapshot builds the delta between the current remote repositories and a snapshot.
+Repositories are matched by provider ID, so renamed repositories are not reported
+as deleted and new. A repository counts as updated when the provider reports activity
+after the activity recorded in the snapshot (or after its sync, for older state files);
+GitLab's activity date can also move for activity without new commits.
+Snapshot entries outside namespace belong to another group synced into the same
+workspace and are ignored; an empty namespace compares every entry of the provider.
+Entries whose state keys are in skipped were listed but filtered out, and are not deleted.
+*/
+func compareSnapshot(snapshot *models.State, provider, namespace string, targets []syncTarget, skipped map[string]bool) *models.DeltaReport {
+	report := &models.DeltaReport{New: []models.DeltaRepository{}, Deleted: []models.DeltaRepository{}, Updated: []models.DeltaRepository{}}
+	seen := map[string]bool{}
+	for key := range skipped {
+		seen[key] = true
+	}
+	for _, target := range targets {
+		key := helpers.StateKey(provider, target.ID)
+		seen[key] = true
+		previous, ok := snapshot.Repositories[key]
+		if !ok {
+			report.New = append(report.New, models.DeltaRepository{Path: target.RemotePath, LastActivity: target.LastActivity})
+			continue
+		}
+
+		since := previous.LastActivity
+		if since.IsZero() {
+			since = previous.LastSynced
+		}
+		if target.LastActivity.After(since) {
+			report.Updated = append(report.Updated, models.DeltaRepository{
+				Path:             target.RemotePath,
+				LastActivity:     target.LastActivity,
+				PreviousActivity: previous.LastActivity,
+			})
+		}
+	}
+
+	for key, entry := range snapshot.Repositories {
+		if entry.Provider != provider || seen[key] {
+			continue
+		}
+		if namespace != "" && !strings.HasPrefix(entry.RemotePath, namespace+"/") {
+			continue
+		}
+		report.Deleted = append(report.Deleted, models.DeltaRepository{Path: entry.RemotePath, PreviousActivity: entry.LastActivity})
+	}
+
+	for _, repositories := range [][]models.DeltaRepository{report.New, report.Deleted, report.Updated} {
+		sort.Slice(repositories, func(i, j int) bool { return repositories[i].Path < repositories[j].Path })
+	}
+	return report
+}
+
+/*
+PrintDeltaReport renders a delta report as a table or, with asJSON, as JSON.
+*/
+func PrintDeltaReport(w io.Writer, report *models.DeltaReport, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "New repositories: %d\n", len(report.New))
+	for _, repository := range report.New {
+		fmt.Fprintf(table, "  %s\n", repository.Path)
+	}
+	fmt.Fprintf(table, "\nDeleted repositories: %d\n", len(report.Deleted))
+	for _, repository := range report.Deleted {
+		fmt.Fprintf(table, "  %s\n", repository.Path)
+	}
+	fmt.Fprintf(table, "\nRepositories with new commits: %d\n", len(report.Updated))
+	for _, repository := range report.Updated {
+		previous := "unknown"
+		if !repository.PreviousActivity.IsZero() {
+			previous = repository.PreviousActivity.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(table, "  %s\t%s\t(was %s)\n", repository.Path, repository.LastActivity.Format("2006-01-02 15:04"), previous)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func deltaPaths(repositories []models.DeltaRepository) []string {
+	paths := []string{}
+	for _, repository := range repositories {
+		paths = append(paths, repository.Path)
+	}
+	return paths
+}
+
+func TestCompareSnapshot(t *testing.T) {
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := &models.State{Repositories: map[string]models.RepositoryState{
+		"gitlab:1": {Provider: "gitlab", ID: 1, RemotePath: "top/api", LastActivity: march},
+		"gitlab:2": {Provider: "gitlab", ID: 2, RemotePath: "top/web", LastActivity: march},
+		"gitlab:3": {Provider: "gitlab", ID: 3, RemotePath: "top/gone", LastActivity: march},
+		"gitlab:4": {Provider: "gitlab", ID: 4, RemotePath: "top/old", LastSynced: march},
+		"gitlab:5": {Provider: "gitlab", ID: 5, RemotePath: "other/lib", LastActivity: march},
+		"github:6": {Provider: "github", ID: 6, RemotePath: "top/cli", LastActivity: march},
+	}}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		targets     []syncTarget
+		wantNew     []string
+		wantDeleted []string
+		wantUpdated []string
+	}{
+		{
+			name:      "changes within the group",
+			namespace: "top",
+			targets: []syncTarget{
+				{ID: 1, RemotePath: "top/api", LastActivity: april},
+				{ID: 2, RemotePath: "top/frontend", LastActivity: march},
+				{ID: 4, RemotePath: "top/old", LastActivity: april},
+				{ID: 7, RemotePath: "top/new", LastActivity: april},
+			},
+			wantNew:     []string{"top/new"},
+			wantDeleted: []string{"top/gone"},
+			wantUpdated: []string{"top/api", "top/old"},
+		},
+		{
+			name:        "whole instance",
+			targets:     []syncTarget{{ID: 1, RemotePath: "top/api", LastActivity: march}},
+			wantNew:     []string{},
+			wantDeleted: []string{"other/lib", "top/gone", "top/old", "top/web"},
+			wantUpdated: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := compareSnapshot(snapshot, "gitlab", tt.namespace, tt.targets, nil)
+			if got := deltaPaths(report.New); !slices.Equal(got, tt.wantNew) {
+				t.Errorf("new = %v, want %v", got, tt.wantNew)
+			}
+			if got := deltaPaths(report.Deleted); !slices.Equal(got, tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := deltaPaths(report.Updated); !slices.Equal(got, tt.wantUpdated) {
+				t.Errorf("updated = %v, want %v", got, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestDiffGitLabGroup(t *testing.T) {
+	server := newGitLabServer(t, 1, false)
+	workspace := t.TempDir()
+	snapshotDir := t.TempDir()
+	snapshotPath := helpers.GetStatePath(snapshotDir)
+	if _, err := diffGitLabGroup(1, models.SyncOptions{BaseDir: workspace, BaseURL: server.URL}, snapshotPath, Dependencies{HTTP: server.Client()}); err == nil {
+		t.Fatal("diffGitLabGroup() should fail without a snapshot")
+	}
+
+	snapshot := &models.State{Repositories: map[string]models.RepositoryState{
+		"gitlab:1":  {Provider: "gitlab", ID: 1, RemotePath: "top/project-1"},
+		"gitlab:99": {Provider: "gitlab", ID: 99, RemotePath: "top/removed"},
+	}}
+	if err := helpers.SaveState(snapshotDir, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	options := models.SyncOptions{Token: "glpat-testtoken1234", BaseDir: workspace, BaseURL: server.URL}
+	report, err := diffGitLabGroup(1, options, snapshotPath, Dependencies{HTTP: server.Client()})
+	if err != nil {
+		t.Fatalf("diffGitLabGroup() error = %v", err)
+	}
+	if got := deltaPaths(report.New); !slices.Equal(got, []string{"top/sub/project-1000"}) {
+		t.Errorf("new = %v", got)
+	}
+	if got := deltaPaths(report.Deleted); !slices.Equal(got, []string{"top/removed"}) {
+		t.Errorf("deleted = %v", got)
+	}
+	if _, err := helpers.LoadStateSnapshot(helpers.GetStatePath(workspace)); err == nil {
+		t.Error("diffGitLabGroup() must not write to the workspace")
+	}
+}
+
+func TestDiffBitbucketServerProject(t *testing.T) {
+	server := newBitbucketServerServer(t, []string{"api", "web"})
+	snapshotDir := t.TempDir()
+	snapshot := &models.State{Repositories: map[string]models.RepositoryState{
+		"bitbucket-server:1":  {Provider: "bitbucket-server", ID: 1, RemotePath: "PLAT/api"},
+		"bitbucket-server:99": {Provider: "bitbucket-server", ID: 99, RemotePath: "PLAT/removed"},
+	}}
+	if err := helpers.SaveState(snapshotDir, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	options := models.SyncOptions{Token: "bbs_testtoken1234", BaseDir: t.TempDir(), BaseURL: server.URL}
+	report, err := diffBitbucketServerProject("PLAT", options, helpers.GetStatePath(snapshotDir), Dependencies{HTTP: server.Client()})
+	if err != nil {
+		t.Fatalf("diffBitbucketServerProject() error = %v", err)
+	}
+	if got := deltaPaths(report.New); !slices.Equal(got, []string{"PLAT/web"}) {
+		t.Errorf("new = %v", got)
+	}
+	if got := deltaPaths(report.Deleted); !slices.Equal(got, []string{"PLAT/removed"}) {
+		t.Errorf("deleted = %v", got)
+	}
+}
+
+func TestDiffGitHubOrganizationAppliesSyncFilters(t *testing.T) {
+	repositories := []models.GitHubRepository{gitHubRepo(1, "api"), gitHubRepo(2, "legacy"), gitHubRepo(3, "old-cli"), gitHubRepo(4, "internal-tools")}
+	for i := range repositories {
+		repositories[i].Visibility = "public"
+	}
+	repositories[1].Archived, repositories[2].Archived = true, true
+	repositories[3].Visibility = "private"
+	server := newGitHubServer(t, [][]models.GitHubRepository{repositories})
+
+	snapshotDir := t.TempDir()
+	snapshot := &models.State{Repositories: map[string]models.RepositoryState{
+		"github:1": {Provider: "github", ID: 1, RemotePath: "acme/api"},
+		"github:3": {Provider: "github", ID: 3, RemotePath: "acme/old-cli"},
+	}}
+	if err := helpers.SaveState(snapshotDir, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	options := models.SyncOptions{Token: "ghp_testtoken1234", BaseDir: t.TempDir(), BaseURL: server.URL, Visibility: []string{"public"}}
+	report, err := diffGitHubOrganization("acme", options, helpers.GetStatePath(snapshotDir), Dependencies{HTTP: server.Client()})
+	if err != nil {
+		t.Fatalf("diffGitHubOrganization() error = %v", err)
+	}
+	// Archived and filtered out repositories are skipped by a sync, so they are neither new nor deleted
+	if got := deltaPaths(report.New); len(got) != 0 {
+		t.Errorf("new = %v, want none", got)
+	}
+	if got := deltaPaths(report.Deleted); len(got) != 0 {
+		t.Errorf("deleted = %v, want none", got)
+	}
+
+	// Included like in a sync with --include-inactive and without --visibility
+	options.Visibility, options.IncludeInactive = nil, true
+	report, err = diffGitHubOrganization("acme", options, helpers.GetStatePath(snapshotDir), Dependencies{HTTP: server.Client()})
+	if err != nil {
+		t.Fatalf("diffGitHubOrganization() error = %v", err)
+	}
+	if got := deltaPaths(report.New); !slices.Equal(got, []string{"acme/internal-tools", "acme/legacy"}) {
+		t.Errorf("new with --include-inactive = %v", got)
+	}
+}
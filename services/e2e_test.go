@@ -0,0 +1,493 @@
+package services
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+	mock "github.com/itszeeshan/reposync/mock"
+)
+
+/*
+startMockProvider generates the default fixture and serves it for one test.
+End-to-end tests run real git against local bare repositories.
+*/
+func startMockProvider(t *testing.T) (*mock.Server, models.SyncOptions) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repoRoot := t.TempDir()
+	if err := mock.CreateBareRepositories(repoRoot, mock.DefaultFixture()); err != nil {
+		t.Fatalf("CreateBareRepositories() error = %v", err)
+	}
+	server := mock.NewServer(mock.DefaultFixture(), repoRoot)
+	t.Cleanup(server.Close)
+
+	return server, models.SyncOptions{
+		Token:       "mock-token-not-secret",
+		CloneMethod: "https",
+		BaseDir:     t.TempDir(),
+		BaseURL:     server.URL(),
+		Concurrency: 2,
+	}
+}
+
+func assertCloned(t *testing.T, workspace string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(workspace, path, "README.md")); err != nil {
+			t.Errorf("expected a checked-out clone at %s: %v", path, err)
+		}
+	}
+}
+
+func TestEndToEndGitLabLayout(t *testing.T) {
+	_, options := startMockProvider(t)
+
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+	assertCloned(t, options.BaseDir, "mock-group/api", "mock-group/web", "mock-group/tools/cli")
+
+	log, err := os.ReadFile(helpers.GetRepositoryLogPath(options.BaseDir, "mock-group/tools/cli"))
+	if err != nil {
+		t.Fatalf("expected a git log for the clone: %v", err)
+	}
+	if !strings.Contains(string(log), "$ git clone") || !strings.Contains(string(log), "Cloning into") {
+		t.Errorf("git log = %q, want the clone command and its output", log)
+	}
+}
+
+func TestEndToEndGitHubLayout(t *testing.T) {
+	_, options := startMockProvider(t)
+	options.BaseDir = filepath.Join(options.BaseDir, "mock-group")
+
+	if err := syncGitHubOrganization("mock-group", options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitHubOrganization() error = %v", err)
+	}
+	assertCloned(t, options.BaseDir, "api", "web")
+}
+
+func TestEndToEndGitLabTransfer(t *testing.T) {
+	server, options := startMockProvider(t)
+
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	server.MoveProject(101, 2)
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+
+	assertCloned(t, options.BaseDir, "mock-group/tools/api")
+	if _, err := os.Stat(filepath.Join(options.BaseDir, "mock-group", "api")); !os.IsNotExist(err) {
+		t.Errorf("transferred project left an orphaned clone behind")
+	}
+
+	state, err := helpers.LoadState(options.BaseDir)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if got := state.Repositories["gitlab:101"].RemotePath; got != "mock-group/tools/api" {
+		t.Errorf("state remote path = %q, want mock-group/tools/api", got)
+	}
+}
+
+func TestEndToEndNoWrite(t *testing.T) {
+	_, options := startMockProvider(t)
+	options.NoWrite = true
+	helpers.SetReadOnly(true)
+	t.Cleanup(func() { helpers.SetReadOnly(false) })
+
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(options.BaseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("--no-write run created %d entries in the workspace", len(entries))
+	}
+}
+
+func TestEndToEndMetaRepository(t *testing.T) {
+	_, options := startMockProvider(t)
+	for _, variable := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(variable, "reposync")
+	}
+	for _, variable := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(variable, "test@reposync.invalid")
+	}
+
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+	if err := InitMetaRepository(options.BaseDir, ""); err != nil {
+		t.Fatalf("InitMetaRepository() error = %v", err)
+	}
+
+	out, err := exec.Command("git", "-C", options.BaseDir, "ls-tree", "-r", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git ls-tree error = %v", err)
+	}
+	for _, path := range []string{"mock-group/api", "mock-group/web", "mock-group/tools/cli"} {
+		if !strings.Contains(string(out), "160000 commit ") || !strings.Contains(string(out), "\t"+path+"\n") {
+			t.Errorf("meta repository does not pin %s:\n%s", path, out)
+		}
+	}
+	if strings.Contains(string(out), ".reposync") {
+		t.Errorf("meta repository commits reposync's own files:\n%s", out)
+	}
+	gitmodules, err := os.ReadFile(filepath.Join(options.BaseDir, ".gitmodules"))
+	if err != nil || strings.Contains(string(gitmodules), "mock-token") {
+		t.Errorf(".gitmodules = %q, %v; want clone URLs without credentials", gitmodules, err)
+	}
+
+	// Nothing changed, so there is nothing to commit
+	head, _ := exec.Command("git", "-C", options.BaseDir, "rev-parse", "HEAD").Output()
+	if err := InitMetaRepository(options.BaseDir, ""); err != nil {
+		t.Fatalf("second InitMetaRepository() error = %v", err)
+	}
+	if again, _ := exec.Command("git", "-C", options.BaseDir, "rev-parse", "HEAD").Output(); string(again) != string(head) {
+		t.Errorf("second run committed again without changes")
+	}
+}
+
+func TestEndToEndExportWorkspace(t *testing.T) {
+	_, options := startMockProvider(t)
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+
+	var manifestOut strings.Builder
+	if err := ExportWorkspace(&manifestOut, options.BaseDir, ExportFormatRepoManifest); err != nil {
+		t.Fatalf("ExportWorkspace(repo-manifest) error = %v", err)
+	}
+	var manifest models.RepoManifest
+	if err := xml.Unmarshal([]byte(manifestOut.String()), &manifest); err != nil {
+		t.Fatalf("exported manifest is not valid XML: %v", err)
+	}
+	if len(manifest.Projects) != 3 {
+		t.Fatalf("exported %d projects, want 3:\n%s", len(manifest.Projects), manifestOut.String())
+	}
+	for _, project := range manifest.Projects {
+		if len(project.Revision) != 40 {
+			t.Errorf("project %s revision = %q, want a commit SHA", project.Path, project.Revision)
+		}
+	}
+	if strings.Contains(manifestOut.String(), "mock-token") {
+		t.Errorf("exported manifest contains credentials:\n%s", manifestOut.String())
+	}
+
+	var gitmanOut strings.Builder
+	if err := ExportWorkspace(&gitmanOut, options.BaseDir, ExportFormatGitman); err != nil {
+		t.Fatalf("ExportWorkspace(gitman) error = %v", err)
+	}
+	if !strings.Contains(gitmanOut.String(), `link: "mock-group/tools/cli"`) {
+		t.Errorf("gitman config lacks the nested project:\n%s", gitmanOut.String())
+	}
+
+	if err := ExportWorkspace(io.Discard, options.BaseDir, "west"); err == nil {
+		t.Errorf("ExportWorkspace() with an unknown format succeeded")
+	}
+}
+
+func TestEndToEndExportBundles(t *testing.T) {
+	_, options := startMockProvider(t)
+	if err := syncGitLabGroup(1, options, DefaultDependencies()); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+	api := filepath.Join(options.BaseDir, "mock-group", "api")
+	tag := exec.Command("git", "-C", api, "-c", "user.name=Tagger", "-c", "user.email=Tagger@Example.com", "tag", "-a", "v1.0", "-m", "Release")
+	if out, err := tag.CombinedOutput(); err != nil {
+		t.Fatalf("git tag error = %v: %s", err, out)
+	}
+
+	tests := []struct {
+		anonymize  string
+		want       string
+		wantTagger string
+	}{
+		{"", "mock@reposync.invalid", "<Tagger@Example.com>"},
+		{helpers.AnonymizeStrip, "", "<>"},
+		{helpers.AnonymizeHash, "68b395cb70771963@anonymized.invalid", "<9fe7552b23d38828@anonymized.invalid>"},
+	}
+	for _, tt := range tests {
+		t.Run("anonymize "+tt.anonymize, func(t *testing.T) {
+			outputDir := t.TempDir()
+			if err := ExportBundles(options.BaseDir, outputDir, tt.anonymize); err != nil {
+				t.Fatalf("ExportBundles() error = %v", err)
+			}
+			restored := filepath.Join(t.TempDir(), "api")
+			if out, err := exec.Command("git", "clone", "--quiet", filepath.Join(outputDir, "mock-group", "api.bundle"), restored).CombinedOutput(); err != nil {
+				t.Fatalf("git clone of the bundle error = %v: %s", err, out)
+			}
+			assertCloned(t, filepath.Dir(restored), "api")
+
+			out, err := exec.Command("git", "-C", restored, "log", "--format=%ae %ce", "v1.0").Output()
+			if got := strings.TrimSpace(string(out)); err != nil || got != strings.TrimSpace(tt.want+" "+tt.want) {
+				t.Errorf("author and committer = %q (%v), want %q", got, err, tt.want)
+			}
+			out, _ = exec.Command("git", "-C", restored, "cat-file", "tag", "v1.0").Output()
+			if !strings.Contains(string(out), "tagger Tagger "+tt.wantTagger) {
+				t.Errorf("tag v1.0 lacks the tagger Tagger %s:\n%s", tt.wantTagger, out)
+			}
+		})
+	}
+
+	if err := ExportBundles(options.BaseDir, t.TempDir(), "scramble"); err == nil {
+		t.Errorf("ExportBundles() with an unknown anonymization succeeded")
+	}
+}
+
+/*
+createPinnedRepository creates a local bare repository with the given number of
+commits on main and returns its path and the commits, oldest first.
+*/
+func createPinnedRepository(t *testing.T, commits int) (string, []string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	work, bare := t.TempDir(), filepath.Join(t.TempDir(), "lib.git")
+	run := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", work, "-c", "user.name=reposync", "-c", "user.email=test@reposync.invalid"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("git %v error = %v", args, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "--quiet", "--initial-branch", "main")
+	var hashes []string
+	for i := 0; i < commits; i++ {
+		os.WriteFile(filepath.Join(work, "README.md"), []byte(strings.Repeat("line\n", i+1)), 0644)
+		run("add", "README.md")
+		run("commit", "--quiet", "-m", "Commit")
+		hashes = append(hashes, run("rev-parse", "HEAD"))
+	}
+	run("clone", "--quiet", "--bare", work, bare)
+	return bare, hashes
+}
+
+func assertHead(t *testing.T, repoPath, want string) {
+	t.Helper()
+	out, err := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD").Output()
+	if got := strings.TrimSpace(string(out)); err != nil || got != want {
+		t.Errorf("HEAD of %s = %q (%v), want %s", repoPath, got, err, want)
+	}
+}
+
+func TestEndToEndRepoManifestSource(t *testing.T) {
+	bare, commits := createPinnedRepository(t, 2)
+	workspace := t.TempDir()
+	options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace, Concurrency: 1}
+	sources := []models.ManifestSource{{Provider: "repo-manifest", File: "default.xml"}}
+
+	writeManifest := func(revision string) {
+		manifest := `<manifest>
+  <remote name="local" fetch="` + filepath.Dir(bare) + `"/>
+  <default remote="local" revision="main"/>
+  <project name="lib.git" path="third_party/lib" revision="` + revision + `"/>
+  <project name="lib.git" path="tracking/lib"/>
+</manifest>`
+		if err := os.WriteFile(filepath.Join(workspace, "default.xml"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeManifest(commits[0])
+	if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+	assertHead(t, filepath.Join(workspace, "third_party", "lib"), commits[0])
+	assertHead(t, filepath.Join(workspace, "tracking", "lib"), commits[1])
+
+	// Moving the pin moves the existing clone
+	writeManifest(commits[1])
+	if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	assertHead(t, filepath.Join(workspace, "third_party", "lib"), commits[1])
+}
+
+func TestEndToEndWorkspacePins(t *testing.T) {
+	bare, commits := createPinnedRepository(t, 3)
+	workspace := t.TempDir()
+	manifest := `<manifest>
+  <remote name="local" fetch="` + filepath.Dir(bare) + `"/>
+  <default remote="local" revision="main"/>
+  <project name="lib.git" path="lib"/>
+</manifest>`
+	if err := os.WriteFile(filepath.Join(workspace, "default.xml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := []models.ManifestSource{{Provider: "repo-manifest", File: "default.xml"}}
+	clone := filepath.Join(workspace, "lib")
+
+	// Local repositories have their path as remote path; the pin wins over the manifest's main
+	sync := func(pin models.ManifestPin) {
+		t.Helper()
+		options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace, Concurrency: 1, Pins: map[string]models.ManifestPin{bare: pin}}
+		if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+			t.Fatalf("SyncWorkspaceSources() error = %v", err)
+		}
+	}
+
+	sync(models.ManifestPin{Revision: commits[0]})
+	assertHead(t, clone, commits[0])
+	if err := exec.Command("git", "-C", clone, "symbolic-ref", "--quiet", "HEAD").Run(); err == nil {
+		t.Errorf("a pin without branch should leave a detached HEAD")
+	}
+
+	sync(models.ManifestPin{Revision: commits[1], Branch: "release"})
+	assertHead(t, clone, commits[1])
+	out, err := exec.Command("git", "-C", clone, "symbolic-ref", "--short", "HEAD").Output()
+	if branch := strings.TrimSpace(string(out)); err != nil || branch != "release" {
+		t.Errorf("checked-out branch = %q (%v), want release", branch, err)
+	}
+}
+
+func TestEndToEndLockfile(t *testing.T) {
+	lib, libCommits := createPinnedRepository(t, 3)
+	tool, toolCommits := createPinnedRepository(t, 2)
+	workspace := t.TempDir()
+	manifest := `<manifest>
+  <remote name="libs" fetch="` + filepath.Dir(lib) + `"/>
+  <remote name="tools" fetch="` + filepath.Dir(tool) + `"/>
+  <default remote="libs" revision="main"/>
+  <project name="lib.git" path="lib"/>
+  <project name="lib.git" path="tool" remote="tools"/>
+</manifest>`
+	if err := os.WriteFile(filepath.Join(workspace, "default.xml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := []models.ManifestSource{{Provider: "repo-manifest", File: "default.xml"}}
+	options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace, Concurrency: 1}
+	if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+		t.Fatalf("SyncWorkspaceSources() error = %v", err)
+	}
+	checkout := func(commit string) {
+		t.Helper()
+		if out, err := exec.Command("git", "-C", filepath.Join(workspace, "lib"), "checkout", "--quiet", "--detach", commit).CombinedOutput(); err != nil {
+			t.Fatalf("git checkout error = %v: %s", err, out)
+		}
+	}
+	checkout(libCommits[0])
+
+	if err := LockWorkspace(workspace, ""); err != nil {
+		t.Fatalf("LockWorkspace() error = %v", err)
+	}
+	lock, err := helpers.LoadLockfile(helpers.GetLockfilePath(workspace))
+	if err != nil {
+		t.Fatalf("LoadLockfile() error = %v", err)
+	}
+	want := []models.LockedRepository{
+		{Path: "lib", Provider: "repo-manifest", RemotePath: lib, Commit: libCommits[0]},
+		{Path: "tool", Provider: "repo-manifest", RemotePath: tool, Commit: toolCommits[1], Branch: "main"},
+	}
+	if !reflect.DeepEqual(lock.Repositories, want) {
+		t.Fatalf("locked repositories = %+v, want %+v", lock.Repositories, want)
+	}
+
+	// The locked sync moves lib back and leaves out the repository the lock doesn't list
+	checkout(libCommits[2])
+	if err := os.RemoveAll(filepath.Join(workspace, "tool")); err != nil {
+		t.Fatal(err)
+	}
+	lock.Repositories = lock.Repositories[:1]
+	options.Pins, options.PinnedOnly = LockedPins(lock), true
+	if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+		t.Fatalf("locked sync error = %v", err)
+	}
+	assertHead(t, filepath.Join(workspace, "lib"), libCommits[0])
+	if _, err := os.Stat(filepath.Join(workspace, "tool")); !os.IsNotExist(err) {
+		t.Errorf("a repository missing from the lockfile was synced")
+	}
+}
+
+func TestEndToEndShareObjects(t *testing.T) {
+	bare, commits := createPinnedRepository(t, 2)
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+	sync := func(workspace string, share bool) {
+		t.Helper()
+		manifest := `<manifest>
+  <remote name="local" fetch="` + filepath.Dir(bare) + `"/>
+  <default remote="local" revision="main"/>
+  <project name="lib.git" path="lib"/>
+</manifest>`
+		if err := os.WriteFile(filepath.Join(workspace, "default.xml"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace, Concurrency: 1, RegistryPath: registryPath, ShareObjects: share}
+		if err := SyncWorkspaceSources([]models.ManifestSource{{Provider: "repo-manifest", File: "default.xml"}}, false, options, nil); err != nil {
+			t.Fatalf("SyncWorkspaceSources() error = %v", err)
+		}
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	sync(first, false)
+	registry, err := helpers.LoadRegistry(registryPath)
+	if err != nil {
+		t.Fatalf("LoadRegistry() error = %v", err)
+	}
+	if clones := registry.Clones[bare]; !reflect.DeepEqual(clones, []string{filepath.Join(first, "lib")}) {
+		t.Fatalf("registered clones = %v, want the clone of the first workspace", clones)
+	}
+
+	sync(second, true)
+	assertHead(t, filepath.Join(second, "lib"), commits[1])
+	log, err := os.ReadFile(helpers.GetRepositoryLogPath(second, "lib"))
+	if err != nil || !strings.Contains(string(log), "--reference "+filepath.Join(first, "lib", ".git")) {
+		t.Errorf("clone did not borrow the objects of the first workspace (%v):\n%s", err, log)
+	}
+	// --dissociate leaves no link to the other clone behind
+	if _, err := os.Stat(filepath.Join(second, "lib", ".git", "objects", "info", "alternates")); !os.IsNotExist(err) {
+		t.Errorf("clone still depends on the other clone's objects")
+	}
+	if registry, err = helpers.LoadRegistry(registryPath); err != nil || len(registry.Clones[bare]) != 2 {
+		t.Errorf("registered clones = %v (%v), want both workspaces", registry.Clones, err)
+	}
+}
+
+func TestEndToEndGitmodulesSource(t *testing.T) {
+	bare, commits := createPinnedRepository(t, 2)
+	for _, variable := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(variable, "reposync")
+	}
+	for _, variable := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(variable, "test@reposync.invalid")
+	}
+
+	// A superproject pinning the library at its first commit
+	superproject := t.TempDir()
+	git := helpers.ExecGitRunner{}
+	if err := helpers.InitMetaRepository(git, superproject); err != nil {
+		t.Fatal(err)
+	}
+	if err := helpers.PinSubmodule(git, superproject, "libs/lib", bare, commits[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helpers.CommitMetaRepository(git, superproject, "Pin lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	workspace := t.TempDir()
+	options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace, Concurrency: 1}
+	sources := []models.ManifestSource{{Provider: "gitmodules", File: filepath.Join(superproject, ".gitmodules")}}
+	if err := SyncWorkspaceSources(sources, false, options, nil); err != nil {
+		t.Fatalf("SyncWorkspaceSources() error = %v", err)
+	}
+	assertHead(t, filepath.Join(workspace, "libs", "lib"), commits[0])
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// Formats supported by ExportWorkspace.
+const (
+	ExportFormatRepoManifest = "repo-manifest"
+	ExportFormatGitman       = "gitman"
+	ExportFormatBundle       = "bundle" // See ExportBundles
+)
+
+/*
+workspaceClone is a clone of a workspace as other multi-repository tools see it:
+where it is, where it comes from and the commit it has checked out.
+*/
+type workspaceClone struct {
+	path   string // Relative to the workspace root, with forward slashes
+	url    string // Origin URL without credentials
+	commit string
+	branch string // Default branch of origin, empty when unknown
+}
+
+/*
+collectClones lists the clones recorded in a workspace's state, ordered by path.
+Repositories without a clone or without commits are reported and left out.
+*/
+func collectClones(workspace string, git helpers.GitRunner) ([]workspaceClone, error) {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Repositories) == 0 {
+		return nil, fmt.Errorf("no repositories recorded in %s: sync the workspace first", workspace)
+	}
+
+	paths := make([]string, 0, len(state.Repositories))
+	for _, entry := range state.Repositories {
+		paths = append(paths, entry.Path)
+	}
+	sort.Strings(paths)
+
+	var clones []workspaceClone
+	for _, relPath := range paths {
+		repoPath := filepath.Join(workspace, filepath.FromSlash(relPath))
+		if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+			Notify(LevelWarning, "Skipping %s: no clone", relPath)
+			continue
+		}
+		commit, err := helpers.ResolveCommit(git, repoPath, "HEAD")
+		if err != nil {
+			Notify(LevelWarning, "Skipping %s: no commits yet", relPath)
+			continue
+		}
+		remoteURL, err := helpers.OriginURL(git, repoPath)
+		if err != nil {
+			Notify(LevelWarning, "Skipping %s: %v", relPath, err)
+			continue
+		}
+		branch, _ := helpers.GetDefaultBranch(git, repoPath)
+		clones = append(clones, workspaceClone{path: relPath, url: remoteURL, commit: commit, branch: branch})
+	}
+	return clones, nil
+}
+
+/*
+ExportWorkspace writes the clones of a workspace, pinned at their checked-out
+commits, as a manifest of another multi-repository tool: a default.xml of Google's
+repo tool (repo-manifest) or a gitman.yml (gitman).
+*/
+func ExportWorkspace(w io.Writer, workspace, format string) error {
+	return exportWorkspace(w, workspace, format, DefaultDependencies())
+}
+
+/*
+exportWorkspace implements ExportWorkspace on top of injectable dependencies.
+*/
+func exportWorkspace(w io.Writer, workspace, format string, deps Dependencies) error {
+	if format != ExportFormatRepoManifest && format != ExportFormatGitman {
+		return fmt.Errorf("unknown export format %q: use %s or %s", format, ExportFormatRepoManifest, ExportFormatGitman)
+	}
+	clones, err := collectClones(workspace, deps.Git)
+	if err != nil {
+		return err
+	}
+	if format == ExportFormatGitman {
+		return writeGitmanConfig(w, clones)
+	}
+	return writeRepoManifest(w, clones)
+}
+
+/*
+ExportBundles writes every clone of a workspace as a git bundle to outputDir, at the
+clone's workspace path with a .bundle suffix (acme/api.bundle), for backups that are
+moved or stored as single files. With anonymize (helpers.AnonymizeStrip or
+helpers.AnonymizeHash), the email addresses of authors, committers and taggers are
+rewritten first, in a scratch copy of the clone, for backups leaving the organization.
+*/
+func ExportBundles(workspace, outputDir, anonymize string) error {
+	return exportBundles(workspace, outputDir, anonymize, DefaultDependencies())
+}
+
+func exportBundles(workspace, outputDir, anonymize string, deps Dependencies) error {
+	if anonymize != "" && anonymize != helpers.AnonymizeStrip && anonymize != helpers.AnonymizeHash {
+		return fmt.Errorf("unknown anonymization %q: use %s or %s", anonymize, helpers.AnonymizeStrip, helpers.AnonymizeHash)
+	}
+	// git writes the bundles from inside the repositories
+	outputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+	clones, err := collectClones(workspace, deps.Git)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, clone := range clones {
+		bundlePath := filepath.Join(outputDir, filepath.FromSlash(clone.path)+".bundle")
+		if err := exportBundle(deps.Git, filepath.Join(workspace, filepath.FromSlash(clone.path)), bundlePath, anonymize); err != nil {
+			failed++
+			Notify(LevelError, "Failed to bundle %s: %v", clone.path, err)
+			continue
+		}
+		Notify(LevelSuccess, "Bundled %s", clone.path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories could not be bundled", failed, len(clones))
+	}
+	return nil
+}
+
+/*
+exportBundle writes one clone as a bundle, anonymizing a scratch copy of it first if requested.
+*/
+func exportBundle(git helpers.GitRunner, repoPath, bundlePath, anonymize string) error {
+	if anonymize == "" {
+		return helpers.CreateBundle(git, repoPath, bundlePath)
+	}
+	scratch, err := os.MkdirTemp("", "reposync-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+
+	mirror := filepath.Join(scratch, "repository.git")
+	if err := git.Run(io.Discard, io.Discard, "clone", "--quiet", "--mirror", repoPath, mirror); err != nil {
+		return fmt.Errorf("failed to copy the clone: %w", err)
+	}
+	if err := helpers.AnonymizeEmails(git, mirror, anonymize); err != nil {
+		return fmt.Errorf("failed to anonymize email addresses: %w", err)
+	}
+	return helpers.CreateBundle(git, mirror, bundlePath)
+}
+
+/*
+writeRepoManifest writes clones as a repo manifest. Every host becomes a remote;
+projects are pinned at their commit, with the default branch as upstream.
+*/
+func writeRepoManifest(w io.Writer, clones []workspaceClone) error {
+	manifest := models.RepoManifest{}
+	remotes := map[string]string{} // Fetch URL → remote name
+	taken := map[string]bool{}
+	for _, clone := range clones {
+		fetch, name, host := splitCloneURL(clone.url)
+		remote, ok := remotes[fetch]
+		if !ok {
+			remote = host
+			for i := 2; taken[remote]; i++ {
+				remote = host + "-" + strconv.Itoa(i)
+			}
+			remotes[fetch], taken[remote] = remote, true
+			manifest.Remotes = append(manifest.Remotes, models.RepoRemote{Name: remote, Fetch: fetch})
+		}
+		manifest.Projects = append(manifest.Projects, models.RepoProject{
+			Name:     name,
+			Path:     clone.path,
+			Remote:   remote,
+			Revision: clone.commit,
+			Upstream: clone.branch,
+		})
+	}
+	if len(manifest.Remotes) == 1 {
+		// A single remote is the default, which keeps the projects short
+		manifest.Default = &models.RepoDefault{Remote: manifest.Remotes[0].Name}
+		for i := range manifest.Projects {
+			manifest.Projects[i].Remote = ""
+		}
+	}
+
+	data, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
+
+/*
+splitCloneURL splits a clone URL into the fetch URL of its host and the repository
+path on it, as repo manifests expect them, and returns the host name.
+scp-like SSH URLs (git@host:group/repo.git) become ssh:// fetch URLs.
+*/
+func splitCloneURL(cloneURL string) (fetch, name, host string) {
+	if parsed, err := url.Parse(cloneURL); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		name = strings.TrimPrefix(parsed.Path, "/")
+		parsed.Path, parsed.RawPath = "", ""
+		return parsed.String(), name, parsed.Hostname()
+	}
+	if user, rest, ok := strings.Cut(cloneURL, "@"); ok && !strings.Contains(user, "/") {
+		if hostname, repoPath, ok := strings.Cut(rest, ":"); ok {
+			return "ssh://" + user + "@" + hostname, repoPath, hostname
+		}
+	}
+	// Local paths and anything else: the parent directory is the remote
+	dir, base := path.Split(filepath.ToSlash(cloneURL))
+	return strings.TrimSuffix(dir, "/"), base, "local"
+}
+
+/*
+writeGitmanConfig writes clones as a gitman.yml. gitman checks sources out into its
+own location, so each source is linked to the clone's path in the workspace.
+Source names are the paths with slashes replaced, as gitman uses them as directory names.
+*/
+func writeGitmanConfig(w io.Writer, clones []workspaceClone) error {
+	var b strings.Builder
+	b.WriteString("location: .gitman\nsources:\n")
+	for _, clone := range clones {
+		fmt.Fprintf(&b, "  - name: %s\n", strconv.Quote(strings.ReplaceAll(clone.path, "/", "-")))
+		fmt.Fprintf(&b, "    type: git\n")
+		fmt.Fprintf(&b, "    repo: %s\n", strconv.Quote(clone.url))
+		fmt.Fprintf(&b, "    rev: %s\n", strconv.Quote(clone.commit))
+		fmt.Fprintf(&b, "    link: %s\n", strconv.Quote(clone.path))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
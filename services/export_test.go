@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestSplitCloneURL(t *testing.T) {
+	tests := []struct {
+		url                   string
+		fetch, name, hostName string
+	}{
+		{"https://gitlab.com/acme/backend/api.git", "https://gitlab.com", "acme/backend/api.git", "gitlab.com"},
+		{"ssh://git@git.example.com:2222/acme/api.git", "ssh://git@git.example.com:2222", "acme/api.git", "git.example.com"},
+		{"git@github.com:acme/web.git", "ssh://git@github.com", "acme/web.git", "github.com"},
+		{"/srv/git/tools.git", "/srv/git", "tools.git", "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			fetch, name, host := splitCloneURL(tt.url)
+			if fetch != tt.fetch || name != tt.name || host != tt.hostName {
+				t.Errorf("splitCloneURL() = %q, %q, %q, want %q, %q, %q", fetch, name, host, tt.fetch, tt.name, tt.hostName)
+			}
+		})
+	}
+}
+
+func TestWriteRepoManifest(t *testing.T) {
+	clones := []workspaceClone{
+		{path: "acme/api", url: "https://github.com/acme/api.git", commit: "1111111111111111111111111111111111111111", branch: "main"},
+		{path: "acme/web", url: "git@github.com:acme/web.git", commit: "2222222222222222222222222222222222222222"},
+	}
+	var out strings.Builder
+	if err := writeRepoManifest(&out, clones); err != nil {
+		t.Fatalf("writeRepoManifest() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), xml.Header) {
+		t.Errorf("manifest lacks the XML header:\n%s", out.String())
+	}
+
+	var manifest models.RepoManifest
+	if err := xml.Unmarshal([]byte(out.String()), &manifest); err != nil {
+		t.Fatalf("manifest is not valid XML: %v", err)
+	}
+	if len(manifest.Remotes) != 2 || manifest.Remotes[0].Name != "github.com" || manifest.Remotes[1].Name != "github.com-2" {
+		t.Errorf("remotes = %+v, want one per fetch URL with unique names", manifest.Remotes)
+	}
+	want := models.RepoProject{Name: "acme/api.git", Path: "acme/api", Remote: "github.com", Revision: clones[0].commit, Upstream: "main"}
+	if len(manifest.Projects) != 2 || manifest.Projects[0] != want || manifest.Projects[1].Remote != "github.com-2" {
+		t.Errorf("projects = %+v, want %+v first", manifest.Projects, want)
+	}
+}
+
+func TestWriteRepoManifestSingleRemote(t *testing.T) {
+	clones := []workspaceClone{
+		{path: "acme/api", url: "https://github.com/acme/api.git", commit: "1111111111111111111111111111111111111111"},
+		{path: "acme/web", url: "https://github.com/acme/web.git", commit: "2222222222222222222222222222222222222222"},
+	}
+	var out strings.Builder
+	if err := writeRepoManifest(&out, clones); err != nil {
+		t.Fatalf("writeRepoManifest() error = %v", err)
+	}
+	var manifest models.RepoManifest
+	if err := xml.Unmarshal([]byte(out.String()), &manifest); err != nil {
+		t.Fatalf("manifest is not valid XML: %v", err)
+	}
+	if manifest.Default == nil || manifest.Default.Remote != "github.com" || manifest.Projects[0].Remote != "" {
+		t.Errorf("manifest = %+v, want the only remote as default", manifest)
+	}
+}
+
+func TestWriteGitmanConfig(t *testing.T) {
+	clones := []workspaceClone{
+		{path: "acme/api", url: "https://github.com/acme/api.git", commit: "1111111111111111111111111111111111111111"},
+	}
+	var out strings.Builder
+	if err := writeGitmanConfig(&out, clones); err != nil {
+		t.Fatalf("writeGitmanConfig() error = %v", err)
+	}
+	want := `location: .gitman
+sources:
+  - name: "acme-api"
+    type: git
+    repo: "https://github.com/acme/api.git"
+    rev: "1111111111111111111111111111111111111111"
+    link: "acme/api"
+`
+	if out.String() != want {
+		t.Errorf("writeGitmanConfig() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
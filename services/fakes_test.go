@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+fakeGitRunner records git invocations instead of running git.
+Clones create an empty .git directory so the filesystem looks cloned;
+commands listed in failures fail the given number of times first,
+commands listed in outputs print the given text.
+*/
+type fakeGitRunner struct {
+	mu       sync.Mutex
+	calls    [][]string
+	failures map[string]int
+	outputs  map[string]string
+}
+
+func (f *fakeGitRunner) Run(stdout, stderr io.Writer, args ...string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, args)
+	command := args[0]
+	if command == "-C" {
+		command = args[2]
+	}
+	if f.failures[command] > 0 {
+		f.failures[command]--
+		f.mu.Unlock()
+		return errors.New("fake " + command + " failure")
+	}
+	output, hasOutput := f.outputs[command]
+	f.mu.Unlock()
+
+	if hasOutput {
+		_, err := io.WriteString(stdout, output)
+		return err
+	}
+
+	switch command {
+	case "clone":
+		return os.MkdirAll(filepath.Join(args[len(args)-1], ".git"), 0755)
+	case "symbolic-ref":
+		return errors.New("fake: no symbolic ref")
+	}
+	return nil
+}
+
+/*
+commands returns the recorded invocations as space-joined strings.
+*/
+func (f *fakeGitRunner) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result []string
+	for _, call := range f.calls {
+		result = append(result, strings.Join(call, " "))
+	}
+	return result
+}
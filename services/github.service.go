@@ -2,49 +2,349 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	client "github.com/itszeeshan/reposync/client"
-	colors "github.com/itszeeshan/reposync/constants/colors"
 	models "github.com/itszeeshan/reposync/constants/models"
 	helpers "github.com/itszeeshan/reposync/helpers"
 )
 
+// Prefixes of GitHub tokens that only see the repositories they were granted.
+const (
+	gitHubFineGrainedPrefix  = "github_pat_"
+	gitHubInstallationPrefix = "ghs_"
+)
+
 /*
 fetchAllGitHubRepositories fetches all repositories from a GitHub organization with pagination.
-Handles GitHub's pagination by making multiple API calls until all repositories are retrieved.
+Requests type=all so internal repositories of Enterprise organizations are included.
 Supports both cloud GitHub and GitHub Enterprise.
+
+Fine-grained personal access tokens and GitHub App installation tokens can be refused
+the organization listing. They then fall back to the repositories the token can access
+(/user/repos?affiliation=organization_member or /installation/repositories), narrowed
+down to the organization. Either way such tokens only see the repositories they were
+granted, so a note explains how to complete a partial listing.
+*/
+func fetchAllGitHubRepositories(api providerAPI, org string) ([]models.GitHubRepository, error) {
+	fineGrained := strings.HasPrefix(api.token, gitHubFineGrainedPrefix)
+	installation := strings.HasPrefix(api.token, gitHubInstallationPrefix)
+
+	repositories, err := fetchGitHubPages[models.GitHubRepository](api, fmt.Sprintf("/orgs/%s/repos?type=all", org))
+	var ssoErr *client.SSOError
+	var statusErr *client.StatusError
+	if err != nil && (fineGrained || installation) && !errors.As(err, &ssoErr) &&
+		errors.As(err, &statusErr) && (statusErr.Code == http.StatusForbidden || statusErr.Code == http.StatusNotFound) {
+		api.notify(LevelWarning, "The token cannot list the repositories of %s (%v), listing the repositories it can access instead", org, err)
+		if installation {
+			repositories, err = fetchGitHubInstallationRepositories(api)
+		} else {
+			repositories, err = fetchGitHubPages[models.GitHubRepository](api, "/user/repos?affiliation=organization_member")
+		}
+		repositories = ownedBy(repositories, org)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case fineGrained:
+		api.notify(LevelWarning, "Fine-grained tokens only list the repositories they were granted (%d in %s). If some are missing, select \"All repositories\" with %s as resource owner in the token settings.", len(repositories), org, org)
+	case installation:
+		api.notify(LevelWarning, "GitHub App tokens only list the repositories the installation was granted (%d in %s). If some are missing, grant the app access to all repositories of %s.", len(repositories), org, org)
+	}
+	return repositories, nil
+}
+
+// gitHubSearchLimit is the number of results GitHub's search API returns at most per query.
+const gitHubSearchLimit = 1000
+
+/*
+listGitHubRepositories lists the repositories of an organization to sync: the ones
+with files found by the code search (CodeSearch), the ones found by the repository
+search (Search), or all of them.
+*/
+func listGitHubRepositories(api providerAPI, org string, options models.SyncOptions) ([]models.GitHubRepository, error) {
+	var repositories []models.GitHubRepository
+	var err error
+	switch {
+	case options.CodeSearch != "":
+		repositories, err = searchGitHubCodeRepositories(api, org, options.CodeSearch)
+	case options.Search != "":
+		repositories, err = searchGitHubRepositories(api, org, options.Search)
+	default:
+		repositories, err = fetchAllGitHubRepositories(api, org)
+	}
+	if err != nil || len(options.Properties) == 0 {
+		return repositories, err
+	}
+	return filterGitHubProperties(api, org, repositories, options.Properties)
+}
+
+/*
+filterGitHubProperties keeps the repositories whose custom properties, the classification
+an organization defines for its repositories (e.g. team=payments or tier=1), have the
+wanted values. Every property must match; a property given several values matches any
+of them, and multi-select properties match if one of their values does. Values are
+compared case-insensitively.
+*/
+func filterGitHubProperties(api providerAPI, org string, repositories []models.GitHubRepository, wanted map[string][]string) ([]models.GitHubRepository, error) {
+	values, err := fetchGitHubPages[models.GitHubPropertyValues](api, fmt.Sprintf("/orgs/%s/properties/values", org))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the custom properties of %s: %w", org, err)
+	}
+
+	matching := map[int64]bool{}
+	for _, repository := range values {
+		matching[repository.RepositoryID] = matchesGitHubProperties(repository.Properties, wanted)
+	}
+	var included []models.GitHubRepository
+	for _, repository := range repositories {
+		if matching[repository.ID] {
+			included = append(included, repository)
+		}
+	}
+	api.notify(LevelInfo, "%d of %d repositories have the requested custom properties", len(included), len(repositories))
+	return included, nil
+}
+
+/*
+matchesGitHubProperties reports whether the property values of a repository match every wanted property.
+*/
+func matchesGitHubProperties(properties []models.GitHubPropertyValue, wanted map[string][]string) bool {
+	for name, accepted := range wanted {
+		var values []string
+		for _, property := range properties {
+			if !strings.EqualFold(property.PropertyName, name) {
+				continue
+			}
+			switch value := property.Value.(type) {
+			case string:
+				values = append(values, value)
+			case []any:
+				for _, item := range value {
+					if text, ok := item.(string); ok {
+						values = append(values, text)
+					}
+				}
+			}
+		}
+		if !slices.ContainsFunc(values, func(value string) bool {
+			return slices.ContainsFunc(accepted, func(want string) bool { return strings.EqualFold(value, want) })
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+searchGitHubRepositories finds the repositories of an organization matching a
+search query (GitHub's search syntax, e.g. "payments in:name" or "topic:go"),
+so only matching repositories are listed instead of the whole organization.
+The search covers forks and archived repositories like the organization listing,
+but returns at most 1000 results; a warning tells when results were cut off.
 */
-func fetchAllGitHubRepositories(token, org, baseURL string) ([]models.GitHubRepository, error) {
-	var allRepos []models.GitHubRepository
-	page := 1
+func searchGitHubRepositories(api providerAPI, org, query string) ([]models.GitHubRepository, error) {
+	q := url.QueryEscape(fmt.Sprintf("%s org:%s fork:true", query, org))
 
-	for {
-		url := helpers.GetGitHubAPIURL(baseURL, fmt.Sprintf("/orgs/%s/repos?per_page=100&page=%d", org, page))
-		resp, err := client.Request("GET", url, token)
+	var allItems []models.GitHubRepository
+	for page := 1; ; page++ {
+		var result models.GitHubSearchResult
+		pageURL := helpers.GetGitHubAPIURL(api.baseURL, fmt.Sprintf("/search/repositories?q=%s&per_page=100&page=%d", q, page))
+		if err := api.getJSON(pageURL, &result); err != nil {
+			return nil, fmt.Errorf("failed to search page %d: %w", page, err)
+		}
+		allItems = append(allItems, result.Items...)
+		if result.IncompleteResults {
+			api.notify(LevelWarning, "GitHub's search timed out, some matching repositories may be missing")
+		}
+		if len(result.Items) == 0 || len(allItems) >= min(result.TotalCount, gitHubSearchLimit) {
+			if result.TotalCount > gitHubSearchLimit {
+				api.notify(LevelWarning, "The search matches %d repositories, but GitHub only returns the first %d; narrow down the query", result.TotalCount, gitHubSearchLimit)
+			}
+			return allItems, nil
+		}
+	}
+}
+
+/*
+searchGitHubCodeRepositories finds the repositories of an organization containing
+files that match a code search query, e.g. "log4j filename:pom.xml" for every
+repository that still declares log4j. The search only returns file paths and
+repository names, so each matching repository is then fetched on its own.
+Code search only covers default branches and at most 1000 files per query.
+*/
+func searchGitHubCodeRepositories(api providerAPI, org, query string) ([]models.GitHubRepository, error) {
+	q := url.QueryEscape(fmt.Sprintf("%s org:%s", query, org))
+
+	found := map[string]bool{}
+	var names []string
+	for page, seen := 1, 0; ; page++ {
+		var result models.GitHubCodeSearchResult
+		pageURL := helpers.GetGitHubAPIURL(api.baseURL, fmt.Sprintf("/search/code?q=%s&per_page=100&page=%d", q, page))
+		if err := api.getJSON(pageURL, &result); err != nil {
+			return nil, fmt.Errorf("failed to search code page %d: %w", page, err)
+		}
+		for _, item := range result.Items {
+			if name := item.Repository.FullName; !found[name] {
+				found[name] = true
+				names = append(names, name)
+			}
+		}
+		if result.IncompleteResults {
+			api.notify(LevelWarning, "GitHub's code search timed out, some matching repositories may be missing")
+		}
+		seen += len(result.Items)
+		if len(result.Items) == 0 || seen >= min(result.TotalCount, gitHubSearchLimit) {
+			if result.TotalCount > gitHubSearchLimit {
+				api.notify(LevelWarning, "The code search matches %d files, but GitHub only returns the first %d; narrow down the query", result.TotalCount, gitHubSearchLimit)
+			}
+			break
+		}
+	}
+	sort.Strings(names)
+	api.notify(LevelInfo, "Code search found matches in %d repositories", len(names))
+
+	repositories := make([]models.GitHubRepository, 0, len(names))
+	for _, name := range names {
+		var repository models.GitHubRepository
+		if err := api.getJSON(helpers.GetGitHubAPIURL(api.baseURL, "/repos/"+name), &repository); err != nil {
+			return nil, fmt.Errorf("failed to fetch repository %s: %w", name, err)
+		}
+		repositories = append(repositories, repository)
+	}
+	return repositories, nil
+}
+
+/*
+fetchGitHubInstallationRepositories lists the repositories a GitHub App installation token was granted.
+Unlike the other list endpoints, pages are objects with the total count next to the repositories.
+*/
+func fetchGitHubInstallationRepositories(api providerAPI) ([]models.GitHubRepository, error) {
+	var allItems []models.GitHubRepository
+	for page := 1; ; page++ {
+		var result models.GitHubInstallationRepositories
+		url := helpers.GetGitHubAPIURL(api.baseURL, fmt.Sprintf("/installation/repositories?per_page=100&page=%d", page))
+		if err := api.getJSON(url, &result); err != nil {
+			return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
+		}
+		allItems = append(allItems, result.Repositories...)
+		if len(result.Repositories) == 0 || len(allItems) >= result.TotalCount {
+			return allItems, nil
+		}
+	}
+}
+
+/*
+ownedBy keeps the repositories of one organization, compared case-insensitively like GitHub does.
+*/
+func ownedBy(repositories []models.GitHubRepository, org string) []models.GitHubRepository {
+	var owned []models.GitHubRepository
+	for _, repository := range repositories {
+		if owner, _, _ := strings.Cut(repository.FullName, "/"); strings.EqualFold(owner, org) {
+			owned = append(owned, repository)
+		}
+	}
+	return owned
+}
+
+/*
+fetchGitHubPages retrieves every item of a GitHub list endpoint.
+Handles GitHub's pagination by making multiple API calls until an empty page is returned.
+Pages are decoded item by item (see decodeJSONArray).
+*/
+func fetchGitHubPages[T any](api providerAPI, endpoint string) ([]T, error) {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	var allItems []T
+	for page := 1; ; page++ {
+		url := helpers.GetGitHubAPIURL(api.baseURL, fmt.Sprintf("%s%sper_page=100&page=%d", endpoint, separator, page))
+		resp, err := api.get(url)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
 		}
-		defer resp.Body.Close()
 
-		var repos []models.GitHubRepository
-		if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+		count, err := decodeJSONArray(resp.Body, func(item T) { allItems = append(allItems, item) })
+		resp.Body.Close()
+		if err != nil {
 			return nil, fmt.Errorf("failed to decode page %d: %w", page, err)
 		}
 
-		if len(repos) == 0 {
-			break // No more repositories
+		if count == 0 {
+			break // No more items
 		}
+	}
+	return allItems, nil
+}
 
-		allRepos = append(allRepos, repos...)
-		page++
+/*
+checkGitHubAPI verifies that the GitHub API is reachable before a sync starts.
+Calls /meta, which every GitHub and GHES version serves, so a wrong instance URL
+or API prefix fails early with a clear message instead of on the first listing.
+Returns the GHES version, or an empty string on github.com.
+*/
+func checkGitHubAPI(api providerAPI) (string, error) {
+	resp, err := api.get(helpers.GetGitHubAPIURL(api.baseURL, "/meta"))
+	if err != nil {
+		return "", fmt.Errorf("cannot reach the GitHub API at %s (for GitHub Enterprise Server, set github_url to the instance URL, e.g. https://github.example.com): %w",
+			helpers.GetGitHubAPIURL(api.baseURL, ""), err)
+	}
+	defer resp.Body.Close()
 
-		// Add rate limiting to avoid hitting GitHub's rate limits
-		time.Sleep(100 * time.Millisecond)
+	var meta struct {
+		InstalledVersion string `json:"installed_version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return "", fmt.Errorf("unexpected response from %s: %w", helpers.GetGitHubAPIURL(api.baseURL, "/meta"), err)
+	}
+	return meta.InstalledVersion, nil
+}
+
+/*
+explainGitHubOrganizationNotFound tells why listing an organization answered 404.
+GitHub answers 404 for organizations that do not exist, for user accounts
+and for private resources the token may not see, so the token and the public
+view of the account are probed to tell a wrong name from a token that lacks access.
+*/
+func explainGitHubOrganizationNotFound(api providerAPI, org string, err error) error {
+	var user struct {
+		Login string `json:"login"`
+	}
+	// GitHub App installation tokens have no user
+	if !strings.HasPrefix(api.token, gitHubInstallationPrefix) {
+		if userErr := api.getJSON(helpers.GetGitHubAPIURL(api.baseURL, "/user"), &user); userErr != nil {
+			return fmt.Errorf("organization %s not found: the token was rejected (%v), check that it is valid and not expired: %w", org, userErr, err)
+		}
 	}
 
-	return allRepos, nil
+	resp, publicErr := client.RequestAnonymous(api.http, "GET", helpers.GetGitHubAPIURL(api.baseURL, "/users/"+org))
+	if publicErr != nil {
+		if client.IsNotFound(publicErr) {
+			return fmt.Errorf("organization %s not found: no account has this name, check its spelling: %w", org, err)
+		}
+		return fmt.Errorf("organization %s not found: either the name is wrong or the token of %s lacks access to it: %w", org, user.Login, err)
+	}
+	defer resp.Body.Close()
+	var account struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	}
+	json.NewDecoder(resp.Body).Decode(&account)
+	if account.Type == "User" {
+		return fmt.Errorf("%s is a user account, not an organization; only organizations can be synced: %w", account.Login, err)
+	}
+	return fmt.Errorf("organization %s exists but the token lacks access to its repositories, check the token's scopes (repo, read:org) and its organization access: %w", org, err)
 }
 
 /*
@@ -62,29 +362,125 @@ CloneGitHubRepositoriesWithURL clones all repositories in a GitHub organization
 Allows specifying custom GitHub instance URL for self-hosted installations.
 */
 func CloneGitHubRepositoriesWithURL(token string, org string, cloneMethod string, baseDir string, baseURL string) error {
+	return CloneGitHubRepositoriesWithOptions(org, models.SyncOptions{
+		Token:       token,
+		CloneMethod: cloneMethod,
+		BaseDir:     baseDir,
+		BaseURL:     baseURL,
+	})
+}
+
+/*
+GitHubOrganizationLogin returns the name of an organization as GitHub spells it.
+Organization names are case-insensitive in the API, so -g myorg and -g MyOrg
+reach the same organization; the canonical login names its directory.
+*/
+func GitHubOrganizationLogin(org string, options models.SyncOptions) (string, error) {
+	return gitHubOrganizationLogin(newProviderAPI(options, DefaultDependencies()), org)
+}
+
+/*
+gitHubOrganizationLogin implements GitHubOrganizationLogin on top of a provider API.
+*/
+func gitHubOrganizationLogin(api providerAPI, org string) (string, error) {
+	var organization struct {
+		Login string `json:"login"`
+	}
+	if err := api.getJSON(helpers.GetGitHubAPIURL(api.baseURL, "/orgs/"+org), &organization); err != nil {
+		return "", fmt.Errorf("failed to fetch organization %s: %w", org, err)
+	}
+	if organization.Login == "" {
+		return org, nil
+	}
+	return organization.Login, nil
+}
+
+/*
+CloneGitHubRepositoriesWithOptions clones all repositories in a GitHub organization.
+Takes the complete run settings, including how many repositories to sync in parallel.
+*/
+func CloneGitHubRepositoriesWithOptions(org string, options models.SyncOptions) error {
+	return syncGitHubOrganization(org, options, DefaultDependencies())
+}
+
+/*
+syncGitHubOrganization implements CloneGitHubRepositoriesWithOptions
+on top of injectable dependencies.
+*/
+func syncGitHubOrganization(org string, options models.SyncOptions, deps Dependencies) error {
 	// Validate inputs
 	if err := helpers.ValidateOrganizationName(org); err != nil {
 		return fmt.Errorf("invalid organization name: %w", err)
 	}
 
-	fmt.Println(colors.Cyan + "Fetching GitHub repositories..." + colors.Reset)
-
-	repositories, err := fetchAllGitHubRepositories(token, org, baseURL)
+	api := newProviderAPI(options, deps)
+	version, err := checkGitHubAPI(api)
 	if err != nil {
+		return err
+	}
+	if version != "" {
+		api.notify(LevelInfo, "Connected to GitHub Enterprise Server %s", version)
+	}
+
+	api.notify(LevelNotice, "Fetching GitHub repositories...")
+
+	repositories, err := listGitHubRepositories(api, org, options)
+	if client.IsNotFound(err) {
+		return explainGitHubOrganizationNotFound(api, org, err)
+	} else if err != nil {
 		return fmt.Errorf("failed to fetch repositories: %w", err)
 	}
 
-	fmt.Printf("Found %d repositories\n", len(repositories))
+	api.notify(LevelInfo, "Found %d repositories", len(repositories))
+
+	run, err := newSyncRun("github", options, deps)
+	if err != nil {
+		return err
+	}
 
-	for i, repository := range repositories {
-		fmt.Printf("Progress: %d/%d (%.1f%%)\n", i+1, len(repositories), float64(i+1)/float64(len(repositories))*100)
+	run.syncAll(run.groupTargets(gitHubTargets(repositories, options.BaseDir)))
+	if options.ExportSettings && !options.NoWrite {
+		run.writeAccessSnapshot(collectGitHubAccess(run.api, org))
+	}
 
-		repoURL := helpers.GetPreferredRepositoryURL(repository.HTTPSURL, repository.SSHURL, cloneMethod)
-		if err := helpers.CloneRepository(repoURL, baseDir, repository.Name, token); err != nil {
-			fmt.Printf(colors.Red+"Failed to clone %s: %v\n"+colors.Reset, repository.Name, err)
-			continue // Continue with other repos
-		}
+	return run.finish()
+}
+
+/*
+gitHubRepository converts a repository of the GitHub API into the provider-neutral model.
+*/
+func gitHubRepository(repository models.GitHubRepository) models.Repository {
+	namespace, _, _ := strings.Cut(repository.FullName, "/")
+	return models.Repository{
+		ID:            repository.ID,
+		Name:          repository.Name,
+		Path:          repository.Name,
+		Namespace:     namespace,
+		HTTPSURL:      repository.HTTPSURL,
+		SSHURL:        repository.SSHURL,
+		WebURL:        repository.WebURL,
+		DefaultBranch: repository.DefaultBranch,
+		Description:   repository.Description,
+		Language:      repository.Language,
+		LastActivity:  repository.PushedAt,
+		Size:          repository.Size << 10,
+		Empty:         repository.Size == 0,
+		Archived:      repository.Archived,
+		Disabled:      repository.Disabled,
+		Fork:          repository.Fork,
+		Topics:        repository.Topics,
+		Visibility:    repository.Visibility,
 	}
+}
 
-	return nil
+/*
+gitHubTargets converts GitHub repositories into sync targets.
+Repositories are placed in a flat structure under baseDir.
+*/
+func gitHubTargets(repositories []models.GitHubRepository, baseDir string) []syncTarget {
+	targets := make([]syncTarget, 0, len(repositories))
+	for _, repository := range repositories {
+		targets = append(targets, newSyncTarget(gitHubRepository(repository), filepath.Join(baseDir, repository.Name)))
+	}
+	return targets
 }
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func newGitHubServer(t *testing.T, pages [][]models.GitHubRepository) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp_testtoken1234" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Loopback URLs are treated like an Enterprise Server instance
+		path := strings.TrimPrefix(r.URL.Path, "/api/v3")
+		if path == "/meta" {
+			w.Write([]byte(`{"installed_version": "3.14.0"}`))
+			return
+		}
+		if path != "/orgs/acme/repos" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var page int
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		repos := []models.GitHubRepository{}
+		if page >= 1 && page <= len(pages) {
+			repos = pages[page-1]
+		}
+		json.NewEncoder(w).Encode(repos)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func gitHubRepo(id int64, name string) models.GitHubRepository {
+	return models.GitHubRepository{
+		ID:       id,
+		Name:     name,
+		FullName: "acme/" + name,
+		HTTPSURL: "https://github.com/acme/" + name + ".git",
+		SSHURL:   "git@github.com:acme/" + name + ".git",
+	}
+}
+
+func TestFetchAllGitHubRepositoriesPagination(t *testing.T) {
+	server := newGitHubServer(t, [][]models.GitHubRepository{
+		{gitHubRepo(1, "api"), gitHubRepo(2, "web")},
+		{gitHubRepo(3, "docs")},
+	})
+
+	api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+	repos, err := fetchAllGitHubRepositories(api, "acme")
+	if err != nil {
+		t.Fatalf("fetchAllGitHubRepositories() error = %v", err)
+	}
+	if len(repos) != 3 {
+		t.Fatalf("fetchAllGitHubRepositories() returned %d repositories, want 3", len(repos))
+	}
+	if repos[2].Name != "docs" {
+		t.Errorf("last repository = %s, want docs", repos[2].Name)
+	}
+}
+
+func TestSearchGitHubRepositories(t *testing.T) {
+	tests := []struct {
+		name       string
+		totalCount int
+		want       int
+	}{
+		{"one page", 2, 2},
+		{"several pages", 250, 250},
+		{"capped by github", 1500, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.TrimPrefix(r.URL.Path, "/api/v3") != "/search/repositories" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				queries = append(queries, r.URL.Query().Get("q"))
+				var page int
+				fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+				result := models.GitHubSearchResult{TotalCount: tt.totalCount, Items: []models.GitHubRepository{}}
+				// Like GitHub, nothing beyond the first 1000 results is served
+				for i := (page-1)*100 + 1; i <= min(page*100, tt.totalCount, 1000); i++ {
+					result.Items = append(result.Items, gitHubRepo(int64(i), fmt.Sprintf("payments-%d", i)))
+				}
+				json.NewEncoder(w).Encode(result)
+			}))
+			defer server.Close()
+
+			api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+			repos, err := listGitHubRepositories(api, "acme", models.SyncOptions{Search: "payments in:name"})
+			if err != nil {
+				t.Fatalf("listGitHubRepositories() error = %v", err)
+			}
+			if len(repos) != tt.want {
+				t.Errorf("listGitHubRepositories() returned %d repositories, want %d", len(repos), tt.want)
+			}
+			if queries[0] != "payments in:name org:acme fork:true" {
+				t.Errorf("query = %q, want the search scoped to the organization", queries[0])
+			}
+		})
+	}
+}
+
+func TestSearchGitHubCodeRepositories(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := strings.TrimPrefix(r.URL.Path, "/api/v3"); path {
+		case "/search/code":
+			query = r.URL.Query().Get("q")
+			result := models.GitHubCodeSearchResult{TotalCount: 3}
+			if r.URL.Query().Get("page") == "1" {
+				for _, name := range []string{"acme/web", "acme/billing", "acme/billing"} {
+					item := models.GitHubCodeSearchItem{Path: "pom.xml"}
+					item.Repository.FullName = name
+					result.Items = append(result.Items, item)
+				}
+			}
+			json.NewEncoder(w).Encode(result)
+		case "/repos/acme/billing", "/repos/acme/web":
+			json.NewEncoder(w).Encode(gitHubRepo(int64(len(path)), strings.TrimPrefix(path, "/repos/acme/")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+	repos, err := listGitHubRepositories(api, "acme", models.SyncOptions{CodeSearch: "log4j filename:pom.xml"})
+	if err != nil {
+		t.Fatalf("listGitHubRepositories() error = %v", err)
+	}
+	if query != "log4j filename:pom.xml org:acme" {
+		t.Errorf("query = %q, want the code search scoped to the organization", query)
+	}
+	if len(repos) != 2 || repos[0].Name != "billing" || repos[1].Name != "web" {
+		t.Errorf("listGitHubRepositories() = %v, want billing and web once each", repos)
+	}
+}
+
+func TestFilterGitHubProperties(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/orgs/acme/properties/values" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("page") != "1" {
+			w.Write([]byte("[]"))
+			return
+		}
+		w.Write([]byte(`[
+			{"repository_id": 1, "properties": [{"property_name": "team", "value": "Payments"}, {"property_name": "tier", "value": "1"}]},
+			{"repository_id": 2, "properties": [{"property_name": "team", "value": "payments"}, {"property_name": "tier", "value": "3"}]},
+			{"repository_id": 3, "properties": [{"property_name": "team", "value": ["search", "payments"]}, {"property_name": "tier", "value": "2"}]},
+			{"repository_id": 4, "properties": [{"property_name": "team", "value": null}]}
+		]`))
+	}))
+	defer server.Close()
+	api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+	repositories := []models.GitHubRepository{gitHubRepo(1, "api"), gitHubRepo(2, "web"), gitHubRepo(3, "search"), gitHubRepo(4, "docs"), gitHubRepo(5, "new")}
+
+	tests := []struct {
+		name   string
+		wanted map[string][]string
+		want   []string
+	}{
+		{"single property", map[string][]string{"team": {"payments"}}, []string{"api", "web", "search"}},
+		{"all properties must match", map[string][]string{"team": {"payments"}, "tier": {"1"}}, []string{"api"}},
+		{"any value of a property", map[string][]string{"tier": {"1", "2"}}, []string{"api", "search"}},
+		{"unknown property", map[string][]string{"cost-center": {"42"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterGitHubProperties(api, "acme", repositories, tt.wanted)
+			if err != nil {
+				t.Fatalf("filterGitHubProperties() error = %v", err)
+			}
+			var names []string
+			for _, repository := range got {
+				names = append(names, repository.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("filterGitHubProperties() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONArray(t *testing.T) {
+	type item struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		want    []item
+		wantErr bool
+	}{
+		{"skips unknown fields", `[{"id": 1, "name": "api", "owner": {"login": "acme"}, "topics": ["go"]}, {"id": 2, "name": "web"}]`, []item{{1, "api"}, {2, "web"}}, false},
+		{"empty page", `[]`, nil, false},
+		{"not an array", `{"message": "Not Found"}`, nil, true},
+		{"truncated", `[{"id": 1, "name": "api"}, {"id": 2`, []item{{1, "api"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []item
+			count, err := decodeJSONArray(strings.NewReader(tt.body), func(value item) { got = append(got, value) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeJSONArray() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != len(got) || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("decodeJSONArray() = %d, %v, want %v", count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAllGitHubRepositoriesRestrictedTokens(t *testing.T) {
+	other := gitHubRepo(9, "elsewhere")
+	other.FullName = "other/elsewhere"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/api/v3") {
+		case "/orgs/acme/repos":
+			w.WriteHeader(http.StatusForbidden)
+		case "/user/repos":
+			json.NewEncoder(w).Encode([]models.GitHubRepository{gitHubRepo(1, "api"), other})
+		case "/installation/repositories":
+			json.NewEncoder(w).Encode(models.GitHubInstallationRepositories{TotalCount: 2, Repositories: []models.GitHubRepository{other, gitHubRepo(2, "web")}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"github_pat_testtoken1234", "acme/api"},
+		{"ghs_testtoken1234", "acme/web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			api := providerAPI{http: server.Client(), token: tt.token, baseURL: server.URL}
+			repos, err := fetchAllGitHubRepositories(api, "Acme")
+			if err != nil {
+				t.Fatalf("fetchAllGitHubRepositories() error = %v", err)
+			}
+			if len(repos) != 1 || repos[0].FullName != tt.want {
+				t.Errorf("fetchAllGitHubRepositories() = %v, want only %s", repos, tt.want)
+			}
+		})
+	}
+
+	// Classic tokens see the whole organization, a refusal is an error
+	api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+	if _, err := fetchAllGitHubRepositories(api, "acme"); err == nil {
+		t.Errorf("fetchAllGitHubRepositories() with a classic token succeeded, want the 403")
+	}
+}
+
+func TestFetchAllGitHubRepositoriesUnauthorized(t *testing.T) {
+	server := newGitHubServer(t, nil)
+
+	api := providerAPI{http: server.Client(), token: "ghp_wrongtoken123", baseURL: server.URL}
+	_, err := fetchAllGitHubRepositories(api, "acme")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("fetchAllGitHubRepositories() error = %v, want permission denied", err)
+	}
+}
+
+func TestExplainGitHubOrganizationNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/api/v3") {
+		case "/user":
+			if r.Header.Get("Authorization") != "Bearer ghp_testtoken1234" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"login":"jane"}`))
+		case "/users/jane":
+			w.Write([]byte(`{"login":"jane","type":"User"}`))
+		case "/users/acme":
+			w.Write([]byte(`{"login":"acme","type":"Organization"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		token string
+		org   string
+		want  string
+	}{
+		{"invalid token", "ghp_expired1234567", "acme", "the token was rejected"},
+		{"user account", "ghp_testtoken1234", "jane", "jane is a user account, not an organization"},
+		{"no access", "ghp_testtoken1234", "acme", "organization acme exists but the token lacks access"},
+		{"wrong name", "ghp_testtoken1234", "acne", "no account has this name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := providerAPI{http: server.Client(), token: tt.token, baseURL: server.URL}
+			err := explainGitHubOrganizationNotFound(api, tt.org, &client.StatusError{Code: http.StatusNotFound})
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("explainGitHubOrganizationNotFound() = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubOrganizationLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/orgs/myorg" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"login":"MyOrg","id":1}`))
+	}))
+	defer server.Close()
+
+	api := providerAPI{http: server.Client(), token: "ghp_testtoken1234", baseURL: server.URL}
+	if login, err := gitHubOrganizationLogin(api, "myorg"); err != nil || login != "MyOrg" {
+		t.Errorf("gitHubOrganizationLogin() = %q, %v, want MyOrg", login, err)
+	}
+	if _, err := gitHubOrganizationLogin(api, "unknown"); err == nil {
+		t.Error("gitHubOrganizationLogin() expected an error for an unknown organization")
+	}
+}
+
+func TestSyncGitHubOrganization(t *testing.T) {
+	server := newGitHubServer(t, [][]models.GitHubRepository{
+		{gitHubRepo(1, "api"), gitHubRepo(2, "web")},
+	})
+	workspace := t.TempDir()
+	git := &fakeGitRunner{}
+
+	options := models.SyncOptions{
+		Token:       "ghp_testtoken1234",
+		CloneMethod: "https",
+		BaseDir:     workspace,
+		BaseURL:     server.URL,
+		Concurrency: 2,
+	}
+	if err := syncGitHubOrganization("acme", options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+		t.Fatalf("syncGitHubOrganization() error = %v", err)
+	}
+
+	for _, name := range []string{"api", "web"} {
+		if _, err := os.Stat(filepath.Join(workspace, name, ".git")); err != nil {
+			t.Errorf("repository %s was not cloned: %v", name, err)
+		}
+	}
+
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if got := state.Repositories["github:2"].Path; got != "web" {
+		t.Errorf("state path of repository 2 = %q, want web", got)
+	}
+}
+
+func TestSyncGitHubOrganizationFollowsRename(t *testing.T) {
+	workspace := t.TempDir()
+	git := &fakeGitRunner{}
+	deps := Dependencies{Git: git}
+
+	first := newGitHubServer(t, [][]models.GitHubRepository{{gitHubRepo(7, "old-name")}})
+	deps.HTTP = first.Client()
+	options := models.SyncOptions{Token: "ghp_testtoken1234", BaseDir: workspace, BaseURL: first.URL}
+	if err := syncGitHubOrganization("acme", options, deps); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	second := newGitHubServer(t, [][]models.GitHubRepository{{gitHubRepo(7, "new-name")}})
+	deps.HTTP = second.Client()
+	options.BaseURL = second.URL
+	if err := syncGitHubOrganization("acme", options, deps); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workspace, "old-name")); !os.IsNotExist(err) {
+		t.Errorf("old clone still exists after rename")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "new-name", ".git")); err != nil {
+		t.Errorf("clone was not moved to new-name: %v", err)
+	}
+
+	clones := 0
+	for _, command := range git.commands() {
+		if strings.HasPrefix(command, "clone ") {
+			clones++
+		}
+	}
+	if clones != 1 {
+		t.Errorf("git clone ran %d times, want 1", clones)
+	}
+}
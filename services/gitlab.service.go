@@ -2,35 +2,93 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	client "github.com/itszeeshan/reposync/client"
-	colors "github.com/itszeeshan/reposync/constants/colors"
 	models "github.com/itszeeshan/reposync/constants/models"
 	helpers "github.com/itszeeshan/reposync/helpers"
 )
 
+// gitLabPageSize is the largest page size GitLab accepts for list endpoints.
+const gitLabPageSize = 100
+
+/*
+fetchGitLabKeysetPages retrieves every item of a GitLab list endpoint.
+Uses keyset pagination ordered by ID (order_by=id, id_after) instead of page offsets,
+which time out on groups and instances with tens of thousands of projects.
+Follows the Link header when native keyset pagination was requested and otherwise
+continues after the last seen ID; endpoints that ignore id_after fall back to offset pagination.
+*/
+func fetchGitLabKeysetPages[T any](api providerAPI, endpoint, query string, idOf func(T) int64) ([]T, error) {
+	pageURL := func(extra string) string {
+		return helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("%s?%sper_page=%d&order_by=id&sort=asc%s", endpoint, query, gitLabPageSize, extra))
+	}
+
+	native := strings.Contains(query, "pagination=keyset")
+
+	var allItems []T
+	var lastID int64
+	offsetPage := 0 // Set once the endpoint turned out to ignore id_after
+
+	url := pageURL("")
+	for page := 1; url != ""; page++ {
+		resp, err := api.get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
+		}
+
+		// Items are appended as they are decoded and dropped again if the page turns out repeated
+		pageStart := len(allItems)
+		count, err := decodeJSONArray(resp.Body, func(item T) { allItems = append(allItems, item) })
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode page %d: %w", page, err)
+		}
+
+		repeated := offsetPage == 0 && lastID > 0 && count > 0 && idOf(allItems[pageStart]) <= lastID
+		if repeated {
+			offsetPage = 1 // The first page came back again, it is already collected
+			allItems = allItems[:pageStart]
+		}
+
+		switch {
+		case offsetPage > 0 && (repeated || count == gitLabPageSize):
+			offsetPage++
+			url = pageURL(fmt.Sprintf("&page=%d", offsetPage))
+		case native:
+			url = helpers.GetNextPageURL(resp.Header.Get("Link"))
+		case offsetPage == 0 && count == gitLabPageSize:
+			lastID = idOf(allItems[len(allItems)-1])
+			url = pageURL(fmt.Sprintf("&id_after=%d", lastID))
+		default:
+			url = ""
+		}
+
+	}
+
+	return allItems, nil
+}
+
 /*
 getGitLabSubgroups fetches subgroup hierarchy from GitLab API.
 Uses paginated API to retrieve all subgroups within specified parent group,
 enabling complete group structure analysis for directory creation.
 Supports both cloud GitLab and self-hosted instances.
 */
-func getGitLabSubgroups(token string, groupID int, baseURL string) ([]models.GitLabSubgroup, error) {
-	url := helpers.GetGitLabAPIURL(baseURL, fmt.Sprintf("/groups/%d/subgroups", groupID))
-	resp, err := client.Request("GET", url, token)
+func getGitLabSubgroups(api providerAPI, groupID int) ([]models.GitLabSubgroup, error) {
+	subgroups, err := fetchGitLabKeysetPages(api, fmt.Sprintf("/groups/%d/subgroups", groupID), "",
+		func(subgroup models.GitLabSubgroup) int64 { return int64(subgroup.ID) })
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch subgroups: %w", err)
 	}
-	defer resp.Body.Close()
-
-	var subgroups []models.GitLabSubgroup
-	if err := json.NewDecoder(resp.Body).Decode(&subgroups); err != nil {
-		return nil, fmt.Errorf("failed to decode subgroups: %w", err)
-	}
 	return subgroups, nil
 }
 
@@ -38,43 +96,203 @@ func getGitLabSubgroups(token string, groupID int, baseURL string) ([]models.Git
 getGitLabRepositories retrieves project list from GitLab group.
 Fetches all repositories in specified group, including those shared
 from parent groups, using GitLab's projects API endpoint.
+The query (see gitLabProjectQuery) narrows the listing on the server.
 Supports both cloud GitLab and self-hosted instances.
 */
-func getGitLabRepositories(token string, groupID int, baseURL string) ([]models.GitLabRepository, error) {
-	url := helpers.GetGitLabAPIURL(baseURL, fmt.Sprintf("/groups/%d/projects", groupID))
-	resp, err := client.Request("GET", url, token)
+func getGitLabRepositories(api providerAPI, groupID int, query string) ([]models.GitLabRepository, error) {
+	repositories, err := fetchGitLabKeysetPages(api, fmt.Sprintf("/groups/%d/projects", groupID), query,
+		func(project models.GitLabRepository) int64 { return project.ID })
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch repositories: %w", err)
 	}
-	defer resp.Body.Close()
+	return repositories, nil
+}
 
-	var repositories []models.GitLabRepository
-	if err := json.NewDecoder(resp.Body).Decode(&repositories); err != nil {
-		return nil, fmt.Errorf("failed to decode repositories: %w", err)
+/*
+gitLabProjectQuery turns the search, topic, language and activity filters of a run into
+query parameters of GitLab's project listings, so huge groups are filtered on the
+server instead of being listed page by page. Each parameter ends with "&".
+*/
+func gitLabProjectQuery(options models.SyncOptions) string {
+	query := url.Values{}
+	if len(options.Topics) > 0 {
+		query.Set("topic", strings.Join(options.Topics, ","))
 	}
-	return repositories, nil
+	if options.Language != "" {
+		query.Set("with_programming_language", options.Language)
+	}
+	if !options.ActiveAfter.IsZero() {
+		query.Set("last_activity_after", options.ActiveAfter.UTC().Format(time.RFC3339))
+	}
+	if options.Search != "" {
+		query.Set("search", options.Search)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return query.Encode() + "&"
 }
 
 /*
 getGitLabGroupInfo fetches basic information about a GitLab group.
-Returns the group name and path for directory structure creation.
+Returns the group name, path and full path for directory structure creation.
+*/
+func getGitLabGroupInfo(api providerAPI, groupID int) (*models.GitLabGroup, error) {
+	url := helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/groups/%d", groupID))
+	resp, err := api.get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var group models.GitLabGroup
+	if err := json.NewDecoder(resp.Body).Decode(&group); err != nil {
+		return nil, fmt.Errorf("failed to decode group info: %w", err)
+	}
+	return &group, nil
+}
+
+/*
+ResolveGitLabGroup returns the ID of a GitLab group given by its numeric ID
+or by the full path of a subgroup, such as parent/child/grandchild.
+*/
+func ResolveGitLabGroup(group string, options models.SyncOptions) (int, error) {
+	return resolveGitLabGroup(newProviderAPI(options, DefaultDependencies()), group)
+}
+
+func resolveGitLabGroup(api providerAPI, group string) (int, error) {
+	if id, err := strconv.Atoi(group); err == nil {
+		return id, nil
+	}
+	var info models.GitLabGroup
+	err := api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, "/groups/"+url.PathEscape(group)), &info)
+	if client.IsNotFound(err) {
+		return 0, fmt.Errorf("group %s not found: check the path, which is case-sensitive, and that the token can see the group: %w", group, err)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to look up group %s: %w", group, err)
+	}
+	return info.ID, nil
+}
+
+/*
+explainGitLabGroupNotFound tells why a group lookup answered 404.
+GitLab answers 404 both for groups that do not exist and for groups the
+token may not see, so the token, the ID and the public view of the group
+are probed to tell a wrong ID from a token that lacks access.
+*/
+func explainGitLabGroupNotFound(api providerAPI, groupID int, err error) error {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if userErr := api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, "/user"), &user); userErr != nil {
+		var statusErr *client.StatusError
+		if errors.As(userErr, &statusErr) && statusErr.Code == http.StatusForbidden {
+			return fmt.Errorf("group %d not found: the token lacks the read_api scope (%v), so it cannot see groups: %w", groupID, userErr, err)
+		}
+		return fmt.Errorf("group %d not found: the token was rejected (%v), check that it is valid and not expired: %w", groupID, userErr, err)
+	}
+
+	var project models.GitLabRepository
+	if api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/projects/%d", groupID)), &project) == nil {
+		return fmt.Errorf("group %d not found: %d is the ID of the project %s, not of a group; use the ID shown on the group's page: %w",
+			groupID, groupID, project.PathWithNamespace, err)
+	}
+
+	resp, publicErr := client.RequestAnonymous(api.http, "GET", helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/groups/%d", groupID)))
+	if publicErr == nil {
+		defer resp.Body.Close()
+		var group models.GitLabGroup
+		json.NewDecoder(resp.Body).Decode(&group)
+		return fmt.Errorf("group %d (%s) exists but the token of %s lacks access to it, check the token's scopes and the group's membership: %w",
+			groupID, group.FullPath, user.Username, err)
+	}
+	return fmt.Errorf("group ID %d not found: either the ID is wrong or the group is private and %s is not a member of it: %w", groupID, user.Username, err)
+}
+
+/*
+getGitLabProject fetches a single GitLab project by its numeric ID.
+Project IDs survive renames and transfers, so this resolves the
+current namespace of a project that moved out of the synced group.
 */
-func getGitLabGroupInfo(token string, groupID int, baseURL string) (string, string, error) {
-	url := helpers.GetGitLabAPIURL(baseURL, fmt.Sprintf("/groups/%d", groupID))
-	resp, err := client.Request("GET", url, token)
+func getGitLabProject(api providerAPI, projectID int64) (*models.GitLabRepository, error) {
+	url := helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/projects/%d", projectID))
+	resp, err := api.get(url)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to fetch group info: %w", err)
+		return nil, fmt.Errorf("failed to fetch project: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var groupInfo struct {
-		Name string `json:"name"`
-		Path string `json:"path"`
+	var project models.GitLabRepository
+	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+		return nil, fmt.Errorf("failed to decode project: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&groupInfo); err != nil {
-		return "", "", fmt.Errorf("failed to decode group info: %w", err)
+	return &project, nil
+}
+
+/*
+getGitLabApprovalRules fetches the merge request approval rules of a project.
+Approval rules require a paid tier; callers treat errors as "no rules".
+*/
+func getGitLabApprovalRules(api providerAPI, projectID int64) ([]models.GitLabApprovalRule, error) {
+	url := helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/projects/%d/approval_rules", projectID))
+	resp, err := api.get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch approval rules: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var rules []models.GitLabApprovalRule
+	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to decode approval rules: %w", err)
+	}
+	return rules, nil
+}
+
+/*
+followGitLabTransfers handles projects transferred out of the synced group.
+Projects transferred within the group tree are relocated while walking it;
+previously synced projects that were not seen during this run are looked up
+by ID and, if they now live in another namespace, their clone's remote URL
+is rewritten so it keeps working. The clone stays in place because its new
+namespace has no counterpart in the workspace layout.
+*/
+func followGitLabTransfers(run *syncRun, rootNamespace string) {
+	if run.listingFiltered() {
+		return // Projects filtered out on the server were not seen, but did not move
+	}
+	for key, entry := range run.state.Repositories {
+		if entry.Provider != "gitlab" || run.seen[key] {
+			continue
+		}
+		if !strings.HasPrefix(entry.RemotePath, rootNamespace+"/") {
+			continue // Belongs to another group synced into the same workspace
+		}
+
+		project, err := getGitLabProject(run.api, entry.ID)
+		if err != nil || project.PathWithNamespace == entry.RemotePath {
+			continue // Deleted, inaccessible or unchanged
+		}
+
+		if run.options.NoWrite {
+			run.notify(LevelNotice, "Would follow transfer: %s -> %s", entry.RemotePath, project.PathWithNamespace)
+			run.summary.addPlanned("update remote of " + entry.Path + " for transfer to " + project.PathWithNamespace)
+			continue
+		}
+
+		repoPath := filepath.Join(run.options.BaseDir, entry.Path)
+		repoURL := helpers.GetPreferredRepositoryURL(project.HTTPSURL, project.SSHURL, run.options.CloneMethod)
+		err = helpers.SetRemoteURL(run.deps.Git, repoPath, repoURL)
+		run.audit.Record("set-remote", entry.Path, client.RedactURL(repoURL), err)
+		if err != nil {
+			run.notify(LevelError, "Failed to update remote of %s: %v", entry.Path, err)
+			continue
+		}
+
+		run.notify(LevelWarning, "Transferred: %s -> %s (outside synced group, clone kept at %s)", entry.RemotePath, project.PathWithNamespace, entry.Path)
+		run.summary.addTransferred(entry.RemotePath, project.PathWithNamespace+" (outside synced group)")
+		entry.RemotePath = project.PathWithNamespace
+		run.state.Repositories[key] = entry
 	}
-	return groupInfo.Name, groupInfo.Path, nil
 }
 
 /*
@@ -94,58 +312,323 @@ CloneGitLabRepositoriesWithURL recursively clones all repositories in a GitLab g
 Allows specifying custom GitLab instance URL for self-hosted installations.
 */
 func CloneGitLabRepositoriesWithURL(token string, groupID int, cloneMethod string, baseDir string, baseURL string) error {
-	fmt.Println(colors.Cyan + "Fetching GitLab repositories..." + colors.Reset)
+	return CloneGitLabRepositoriesWithOptions(groupID, models.SyncOptions{
+		Token:       token,
+		CloneMethod: cloneMethod,
+		BaseDir:     baseDir,
+		BaseURL:     baseURL,
+	})
+}
+
+/*
+CloneGitLabRepositoriesWithOptions recursively clones all repositories in a GitLab group.
+Discovers the whole group tree first, then syncs the collected repositories
+using the configured number of parallel workers.
+*/
+func CloneGitLabRepositoriesWithOptions(groupID int, options models.SyncOptions) error {
+	return syncGitLabGroup(groupID, options, DefaultDependencies())
+}
+
+/*
+syncGitLabGroup implements CloneGitLabRepositoriesWithOptions
+on top of injectable dependencies.
+*/
+func syncGitLabGroup(groupID int, options models.SyncOptions, deps Dependencies) error {
+	run, err := newSyncRun("gitlab", options, deps)
+	if err != nil {
+		return err
+	}
+
+	// Deploy tokens have no API access at all
+	if options.TokenType == models.TokenTypeDeploy {
+		return syncGitLabFromState(run, fmt.Errorf("deploy tokens cannot use the API"))
+	}
+	var targets []syncTarget
+	rootGroup, err := getGitLabGroupInfo(run.api, groupID)
+	if client.IsNotFound(err) && options.TokenType == models.TokenTypePersonal {
+		err = explainGitLabGroupNotFound(run.api, groupID, err)
+	} else if err != nil {
+		err = fmt.Errorf("failed to fetch group info: %w", err)
+	} else {
+		err = collectGitLabGroup(run, groupID, options.BaseDir, &targets)
+	}
+	if err != nil && options.TokenType == models.TokenTypeJob {
+		// Job tokens may only reach a few endpoints, depending on the instance's allowlist
+		return syncGitLabFromState(run, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	run.notify(LevelInfo, "Found %d repositories", len(targets))
+	run.syncAll(run.groupTargets(targets))
+	if options.ExportSettings && !options.NoWrite {
+		run.writeAccessSnapshot(collectGitLabAccess(run.api, rootGroup))
+	}
+	if options.ExportCIConfig && !options.NoWrite {
+		run.exportGroupCIConfig(groupID, rootGroup.FullPath)
+	}
+
+	followGitLabTransfers(run, rootGroup.FullPath)
+	return run.finish()
+}
+
+/*
+syncGitLabFromState updates the GitLab repositories recorded in the workspace state.
+CI job tokens and deploy tokens can clone repositories but cannot list the
+projects of a group, so a workspace first synced with a personal access token
+is kept up to date from its state file instead. Clone URLs are derived from the
+instance URL and the recorded project paths; listErr explains why listing failed.
+*/
+func syncGitLabFromState(run *syncRun, listErr error) error {
+	instanceURL := strings.TrimSuffix(run.options.BaseURL, "/")
+	if instanceURL == "" {
+		instanceURL = "https://gitlab.com"
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(instanceURL, "https://"), "http://")
+
+	var targets []syncTarget
+	for _, entry := range run.state.Repositories {
+		if entry.Provider != "gitlab" || entry.RemotePath == "" {
+			continue
+		}
+		targets = append(targets, syncTarget{
+			ID:           entry.ID,
+			Name:         entry.Name,
+			RemotePath:   entry.RemotePath,
+			HTTPSURL:     instanceURL + "/" + entry.RemotePath + ".git",
+			SSHURL:       "git@" + host + ":" + entry.RemotePath + ".git",
+			Path:         filepath.Join(run.options.BaseDir, filepath.FromSlash(entry.Path)),
+			Description:  entry.Description,
+			Language:     entry.Language,
+			WebURL:       entry.WebURL,
+			LastActivity: entry.LastActivity,
+			Visibility:   entry.Visibility,
+			Topics:       entry.Topics,
+		})
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%s tokens cannot list group projects (%w) and the workspace state records no repositories; run the first sync with a personal access token", run.options.TokenType, listErr)
+	}
+
+	// Map iteration order is random, keep the output stable
+	sort.Slice(targets, func(i, j int) bool { return targets[i].RemotePath < targets[j].RemotePath })
+
+	run.notify(LevelWarning, "Cannot list projects with a %s token (%v), syncing the %d repositories recorded in the workspace state", run.options.TokenType, listErr, len(targets))
+	run.syncAll(targets)
+	return run.finish()
+}
+
+/*
+collectGitLabGroup discovers the repositories of a GitLab group and its subgroups.
+Stops descending at MaxDepth group levels and never visits a group twice, so
+the walk ends even if a listing reports a group among its own descendants.
+The shape of the discovered tree is reported before anything is cloned.
+*/
+func collectGitLabGroup(run *syncRun, groupID int, baseDir string, targets *[]syncTarget) error {
+	walk := &gitLabGroupWalk{run: run, visited: map[int]bool{}, targets: targets}
+	if err := walk.collect(groupID, baseDir, 1); err != nil {
+		return err
+	}
+	walk.report()
+	return nil
+}
+
+/*
+gitLabGroupWalk is the state of one walk of a GitLab group tree.
+groups counts the groups found per level, projects the repositories found,
+and truncated the subgroups left out by MaxDepth.
+*/
+type gitLabGroupWalk struct {
+	run       *syncRun
+	visited   map[int]bool
+	targets   *[]syncTarget
+	groups    []int
+	projects  int
+	truncated int
+}
+
+/*
+collect discovers the repositories of a single GitLab group level, level 1 being the synced group.
+Creates the group directory and recurses into subgroups before adding the
+group's own repositories to the list of sync targets.
+Directories are named by group and project paths, as in GitLab's URLs, rooted
+at the synced group; with FullPaths they are the group's full path instead.
+*/
+func (w *gitLabGroupWalk) collect(groupID int, baseDir string, level int) error {
+	run := w.run
+	w.visited[groupID] = true
+	run.notify(LevelNotice, "Fetching GitLab repositories...")
 
 	// Get group info to create proper root directory
-	groupName, groupPath, err := getGitLabGroupInfo(token, groupID, baseURL)
+	group, err := getGitLabGroupInfo(run.api, groupID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch group info: %w", err)
 	}
 
 	// Create root directory with group path
-	rootDir := filepath.Join(baseDir, groupPath)
-	if err := os.MkdirAll(rootDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create root directory %s: %w", rootDir, err)
+	rootDir, subgroupDir := filepath.Join(baseDir, group.Path), filepath.Join(baseDir, group.Path)
+	if run.options.FullPaths {
+		// Full paths already hold the parent groups
+		rootDir, subgroupDir = filepath.Join(baseDir, filepath.FromSlash(group.FullPath)), baseDir
+	}
+	if run.options.SkipEmptyGroups {
+		// git creates the directories of the groups that have repositories when cloning
+		run.groupDirectories = append(run.groupDirectories, rootDir)
+	} else if !run.options.NoWrite {
+		if err := helpers.EnsureDirectory(rootDir); err != nil {
+			return fmt.Errorf("failed to create root directory %s: %w", rootDir, err)
+		}
 	}
 
-	fmt.Printf("Creating directory structure for group: %s (%s)\n", groupName, groupPath)
+	run.notify(LevelInfo, "Creating directory structure for group: %s (%s)", group.Name, group.Path)
+	if len(w.groups) < level {
+		w.groups = append(w.groups, 0)
+	}
+	w.groups[level-1]++
 
 	// Process all subgroups first to create directory structure
-	subgroups, err := getGitLabSubgroups(token, groupID, baseURL)
+	subgroups, err := getGitLabSubgroups(run.api, groupID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch subgroups: %w", err)
 	}
 
 	for _, subgroup := range subgroups {
-		fmt.Println(colors.Yellow + "Processing subgroup: " + subgroup.FullPath + colors.Reset)
+		if w.visited[subgroup.ID] {
+			run.notify(LevelWarning, "Skipping subgroup %s: group %d was already visited", subgroup.FullPath, subgroup.ID)
+			continue
+		}
+		if run.options.MaxDepth > 0 && level >= run.options.MaxDepth {
+			// Repositories below the limit are unknown, not deleted
+			w.truncated++
+			run.incomplete = true
+			continue
+		}
+		run.notify(LevelNotice, "Processing subgroup: %s", subgroup.FullPath)
 
 		// Recursively process the subgroup - pass the root directory
-		if err := CloneGitLabRepositoriesWithURL(token, subgroup.ID, cloneMethod, rootDir, baseURL); err != nil {
-			fmt.Printf(colors.Red+"Failed to process subgroup %s: %v\n"+colors.Reset, subgroup.FullPath, err)
+		if err := w.collect(subgroup.ID, subgroupDir, level+1); err != nil {
+			run.notify(LevelError, "Failed to process subgroup %s: %v", subgroup.FullPath, err)
+			run.incomplete = true
 			continue // Continue with other subgroups
 		}
 	}
 
 	// Process repositories in current group
-	repositories, err := getGitLabRepositories(token, groupID, baseURL)
+	repositories, err := getGitLabRepositories(run.api, groupID, gitLabProjectQuery(run.options))
 	if err != nil {
 		return fmt.Errorf("failed to fetch repositories: %w", err)
 	}
 
-	fmt.Printf("Found %d repositories in current group\n", len(repositories))
+	run.notify(LevelInfo, "Found %d repositories in current group", len(repositories))
+	run.incomplete = run.incomplete || run.listingFiltered()
+	w.projects += len(repositories)
 
-	for i, repository := range repositories {
-		fmt.Printf("Progress: %d/%d (%.1f%%)\n", i+1, len(repositories), float64(i+1)/float64(len(repositories))*100)
+	for _, repository := range repositories {
+		*w.targets = append(*w.targets, newSyncTarget(gitLabRepository(repository), filepath.Join(rootDir, repository.Path)))
+	}
 
-		repoURL := helpers.GetPreferredRepositoryURL(repository.HTTPSURL, repository.SSHURL, cloneMethod)
-		if err := helpers.CloneRepository(repoURL, rootDir, repository.Path, token); err != nil {
-			fmt.Printf(colors.Red+"Failed to clone %s: %v\n"+colors.Reset, repository.Name, err)
-			continue // Continue with other repos
-		}
+	return nil
+}
+
+/*
+report prints the shape of the discovered group tree: the groups per level,
+the repositories in all of them and the subgroups left out by MaxDepth.
+*/
+func (w *gitLabGroupWalk) report() {
+	levels := make([]string, len(w.groups))
+	total := 0
+	for i, count := range w.groups {
+		levels[i] = fmt.Sprintf("%d", count)
+		total += count
 	}
+	shape := fmt.Sprintf("Group tree: %d groups in %d levels (%s per level), %d repositories", total, len(w.groups), strings.Join(levels, "/"), w.projects)
+	if w.truncated > 0 {
+		shape += fmt.Sprintf(", %d subgroups below --max-depth %d skipped", w.truncated, w.run.options.MaxDepth)
+	}
+	w.run.notify(LevelNotice, "%s", shape)
+}
 
-	// Add rate limiting to avoid hitting GitLab's rate limits
-	time.Sleep(100 * time.Millisecond)
+/*
+fetchAllGitLabProjects lists every project visible to the token on a GitLab instance.
+Requests GitLab's native keyset pagination, which the /projects endpoint supports.
+With an administrator token this covers all projects of a self-hosted instance.
+*/
+func fetchAllGitLabProjects(api providerAPI, query string) ([]models.GitLabRepository, error) {
+	return fetchGitLabKeysetPages(api, "/projects", "pagination=keyset&"+query,
+		func(project models.GitLabRepository) int64 { return project.ID })
+}
 
-	return nil
+/*
+CloneAllGitLabProjects clones every project of a GitLab instance.
+Intended for instance-level backups with an administrator token; projects
+are placed by their full namespace path so the directory tree mirrors the instance.
+*/
+func CloneAllGitLabProjects(options models.SyncOptions) error {
+	return syncAllGitLabProjects(options, DefaultDependencies())
+}
+
+/*
+syncAllGitLabProjects implements CloneAllGitLabProjects
+on top of injectable dependencies.
+*/
+func syncAllGitLabProjects(options models.SyncOptions, deps Dependencies) error {
+	api := newProviderAPI(options, deps)
+	api.notify(LevelNotice, "Fetching all GitLab projects on the instance...")
+
+	projects, err := fetchAllGitLabProjects(api, gitLabProjectQuery(options))
+	if err != nil {
+		return fmt.Errorf("failed to fetch projects: %w", err)
+	}
+
+	api.notify(LevelInfo, "Found %d projects", len(projects))
+
+	run, err := newSyncRun("gitlab", options, deps)
+	if err != nil {
+		return err
+	}
+
+	run.syncAll(run.groupTargets(gitLabProjectTargets(projects, options.BaseDir)))
+
+	return run.finish()
+}
+
+/*
+gitLabRepository converts a project of the GitLab API into the provider-neutral model.
+*/
+func gitLabRepository(project models.GitLabRepository) models.Repository {
+	namespace := ""
+	if i := strings.LastIndex(project.PathWithNamespace, "/"); i >= 0 {
+		namespace = project.PathWithNamespace[:i]
+	}
+	return models.Repository{
+		ID:              project.ID,
+		Name:            project.Name,
+		Path:            project.Path,
+		Namespace:       namespace,
+		HTTPSURL:        project.HTTPSURL,
+		SSHURL:          project.SSHURL,
+		WebURL:          project.WebURL,
+		DefaultBranch:   project.DefaultBranch,
+		Description:     project.Description,
+		LastActivity:    project.LastActivityAt,
+		Empty:           project.EmptyRepo || project.DefaultBranch == "",
+		Archived:        project.Archived,
+		PendingDeletion: project.MarkedForDeletionOn != "" || project.MarkedForDeletionAt != "",
+		Fork:            project.ForkedFromProject != nil,
+		Topics:          project.Topics,
+		Visibility:      project.Visibility,
+	}
+}
+
+/*
+gitLabProjectTargets converts instance-wide GitLab projects into sync targets.
+Projects are placed by their full namespace path under baseDir.
+*/
+func gitLabProjectTargets(projects []models.GitLabRepository, baseDir string) []syncTarget {
+	targets := make([]syncTarget, 0, len(projects))
+	for _, project := range projects {
+		targets = append(targets, newSyncTarget(gitLabRepository(project), filepath.Join(baseDir, filepath.FromSlash(project.PathWithNamespace))))
+	}
+	return targets
 }
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+newGitLabServer serves a two-level group tree: group 1 ("top") with subgroup 2 ("sub").
+The top group holds projectCount projects, paginated with id_after unless ignoreKeyset is set,
+in which case offset pagination must be used.
+*/
+func newGitLabServer(t *testing.T, projectCount int, ignoreKeyset bool) *httptest.Server {
+	t.Helper()
+	project := func(id int, namespace string) models.GitLabRepository {
+		path := fmt.Sprintf("project-%d", id)
+		return models.GitLabRepository{
+			ID:                int64(id),
+			Name:              path,
+			Path:              path,
+			PathWithNamespace: namespace + "/" + path,
+			HTTPSURL:          "https://gitlab.com/" + namespace + "/" + path + ".git",
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		idAfter, _ := strconv.Atoi(query.Get("id_after"))
+		page, _ := strconv.Atoi(query.Get("page"))
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+		if ignoreKeyset {
+			idAfter = 0
+			if page > 1 {
+				idAfter = (page - 1) * perPage
+			}
+		}
+
+		var body any
+		switch r.URL.Path {
+		case "/api/v4/groups/1":
+			body = models.GitLabGroup{ID: 1, Name: "Top", Path: "top", FullPath: "top"}
+		case "/api/v4/groups/2", "/api/v4/groups/top/sub":
+			body = models.GitLabGroup{ID: 2, Name: "Sub", Path: "sub", FullPath: "top/sub"}
+		case "/api/v4/groups/1/subgroups":
+			body = []models.GitLabSubgroup{{ID: 2, Name: "Sub", FullPath: "top/sub"}}
+		case "/api/v4/groups/2/subgroups":
+			body = []models.GitLabSubgroup{}
+		case "/api/v4/groups/1/projects":
+			projects := []models.GitLabRepository{}
+			for id := idAfter + 1; id <= projectCount && len(projects) < perPage; id++ {
+				projects = append(projects, project(id, "top"))
+			}
+			body = projects
+		case "/api/v4/groups/2/projects":
+			body = []models.GitLabRepository{project(1000, "top/sub")}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetGitLabRepositoriesPagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		projectCount int
+		ignoreKeyset bool
+	}{
+		{"single page", 5, false},
+		{"keyset pages", 250, false},
+		{"exact page size", 200, false},
+		{"offset fallback", 250, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGitLabServer(t, tt.projectCount, tt.ignoreKeyset)
+			api := providerAPI{http: server.Client(), token: "glpat-testtoken1234", baseURL: server.URL}
+
+			projects, err := getGitLabRepositories(api, 1, "")
+			if err != nil {
+				t.Fatalf("getGitLabRepositories() error = %v", err)
+			}
+			if len(projects) != tt.projectCount {
+				t.Fatalf("getGitLabRepositories() returned %d projects, want %d", len(projects), tt.projectCount)
+			}
+			for i, p := range projects {
+				if p.ID != int64(i+1) {
+					t.Fatalf("project %d has ID %d, want %d (duplicates or gaps)", i, p.ID, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestGitLabProjectQuery(t *testing.T) {
+	activeAfter := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options models.SyncOptions
+		want    string
+	}{
+		{"no filters", models.SyncOptions{}, ""},
+		{"topics", models.SyncOptions{Topics: []string{"payments", "backend"}}, "topic=payments%2Cbackend&"},
+		{"search", models.SyncOptions{Search: "payments api"}, "search=payments+api&"},
+		{"all filters", models.SyncOptions{Topics: []string{"go"}, Language: "C++", ActiveAfter: activeAfter},
+			"last_activity_after=2024-03-01T12%3A00%3A00Z&topic=go&with_programming_language=C%2B%2B&"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitLabProjectQuery(tt.options); got != tt.want {
+				t.Errorf("gitLabProjectQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetGitLabRepositoriesQuery(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		json.NewEncoder(w).Encode([]models.GitLabRepository{{ID: 1, Name: "api", Topics: []string{"payments"}}})
+	}))
+	defer server.Close()
+	api := providerAPI{http: server.Client(), token: "glpat-testtoken1234", baseURL: server.URL}
+
+	if _, err := getGitLabRepositories(api, 1, gitLabProjectQuery(models.SyncOptions{Topics: []string{"payments"}})); err != nil {
+		t.Fatalf("getGitLabRepositories() error = %v", err)
+	}
+	if len(queries) != 1 || !strings.HasPrefix(queries[0], "topic=payments&per_page=") {
+		t.Errorf("queries = %v, want the topic filter sent to the server", queries)
+	}
+}
+
+func TestGetGitLabGroupInfoNotFound(t *testing.T) {
+	server := newGitLabServer(t, 0, false)
+	api := providerAPI{http: server.Client(), token: "glpat-testtoken1234", baseURL: server.URL}
+
+	if _, err := getGitLabGroupInfo(api, 99); err == nil {
+		t.Error("getGitLabGroupInfo() expected an error for an unknown group")
+	}
+}
+
+func TestResolveGitLabGroup(t *testing.T) {
+	server := newGitLabServer(t, 0, false)
+	api := providerAPI{http: server.Client(), token: "glpat-testtoken1234", baseURL: server.URL}
+
+	tests := []struct {
+		group   string
+		want    int
+		wantErr bool
+	}{
+		{"42", 42, false},
+		{"top/sub", 2, false},
+		{"top/missing", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.group, func(t *testing.T) {
+			got, err := resolveGitLabGroup(api, tt.group)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveGitLabGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveGitLabGroup() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainGitLabGroupNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated := r.Header.Get("Authorization") != ""
+		switch {
+		case r.URL.Path == "/api/v4/user" && r.Header.Get("Authorization") == "Bearer glpat-noscope12345":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"insufficient_scope"}`))
+		case r.URL.Path == "/api/v4/user" && authenticated:
+			w.Write([]byte(`{"username":"jane"}`))
+		case r.URL.Path == "/api/v4/projects/7":
+			w.Write([]byte(`{"id":7,"path_with_namespace":"acme/api"}`))
+		case r.URL.Path == "/api/v4/groups/8" && !authenticated:
+			w.Write([]byte(`{"id":8,"full_path":"acme"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		groupID int
+		want    string
+	}{
+		{"missing scope", "glpat-noscope12345", 9, "lacks the read_api scope"},
+		{"project ID", "glpat-testtoken1234", 7, "project acme/api, not of a group"},
+		{"no access", "glpat-testtoken1234", 8, "group 8 (acme) exists but the token of jane lacks access"},
+		{"wrong ID", "glpat-testtoken1234", 9, "group ID 9 not found: either the ID is wrong or the group is private and jane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := providerAPI{http: server.Client(), token: tt.token, baseURL: server.URL}
+			_, lookupErr := getGitLabGroupInfo(api, tt.groupID)
+			err := explainGitLabGroupNotFound(api, tt.groupID, lookupErr)
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("explainGitLabGroupNotFound() = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncGitLabGroupLayout(t *testing.T) {
+	server := newGitLabServer(t, 2, false)
+	workspace := t.TempDir()
+	git := &fakeGitRunner{}
+
+	options := models.SyncOptions{
+		Token:       "glpat-testtoken1234",
+		CloneMethod: "https",
+		BaseDir:     workspace,
+		BaseURL:     server.URL,
+		Concurrency: 3,
+	}
+	if err := syncGitLabGroup(1, options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+		t.Fatalf("syncGitLabGroup() error = %v", err)
+	}
+
+	for _, path := range []string{"top/project-1", "top/project-2", "top/sub/project-1000"} {
+		if _, err := os.Stat(filepath.Join(workspace, path, ".git")); err != nil {
+			t.Errorf("expected clone at %s: %v", path, err)
+		}
+	}
+}
+
+func TestSyncGitLabSubgroupFullPaths(t *testing.T) {
+	server := newGitLabServer(t, 0, false)
+
+	tests := []struct {
+		fullPaths bool
+		want      string
+	}{
+		{false, "sub/project-1000"},
+		{true, "top/sub/project-1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			workspace := t.TempDir()
+			options := models.SyncOptions{
+				Token:       "glpat-testtoken1234",
+				CloneMethod: "https",
+				BaseDir:     workspace,
+				BaseURL:     server.URL,
+				FullPaths:   tt.fullPaths,
+			}
+			if err := syncGitLabGroup(2, options, Dependencies{HTTP: server.Client(), Git: &fakeGitRunner{}}); err != nil {
+				t.Fatalf("syncGitLabGroup() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(workspace, tt.want, ".git")); err != nil {
+				t.Errorf("expected clone at %s: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCollectGitLabGroupMaxDepth(t *testing.T) {
+	server := newGitLabServer(t, 2, false)
+
+	tests := []struct {
+		maxDepth       int
+		wantTargets    int
+		wantIncomplete bool
+	}{
+		{0, 3, false},
+		{2, 3, false},
+		{1, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("max depth %d", tt.maxDepth), func(t *testing.T) {
+			options := models.SyncOptions{
+				Token:    "glpat-testtoken1234",
+				BaseDir:  t.TempDir(),
+				BaseURL:  server.URL,
+				NoWrite:  true,
+				MaxDepth: tt.maxDepth,
+			}
+			deps := Dependencies{HTTP: server.Client(), Git: &fakeGitRunner{}}
+			run := &syncRun{provider: "gitlab", options: options, deps: deps, api: newProviderAPI(options, deps)}
+			var targets []syncTarget
+			if err := collectGitLabGroup(run, 1, options.BaseDir, &targets); err != nil {
+				t.Fatalf("collectGitLabGroup() error = %v", err)
+			}
+			if len(targets) != tt.wantTargets {
+				t.Errorf("collectGitLabGroup() found %d repositories, want %d", len(targets), tt.wantTargets)
+			}
+			if run.incomplete != tt.wantIncomplete {
+				t.Errorf("run.incomplete = %v, want %v", run.incomplete, tt.wantIncomplete)
+			}
+		})
+	}
+}
+
+func TestSyncGitLabSkipEmptyGroups(t *testing.T) {
+	server := newGitLabServer(t, 0, false)
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		wantTop  bool
+	}{
+		{"group with a subgroup repository", 0, true},
+		{"group left empty", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			// Left behind by an earlier run
+			if err := os.Mkdir(filepath.Join(workspace, "top"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			options := models.SyncOptions{
+				Token:           "glpat-testtoken1234",
+				CloneMethod:     "https",
+				BaseDir:         workspace,
+				BaseURL:         server.URL,
+				SkipEmptyGroups: true,
+				MaxDepth:        tt.maxDepth,
+			}
+			if err := syncGitLabGroup(1, options, Dependencies{HTTP: server.Client(), Git: &fakeGitRunner{}}); err != nil {
+				t.Fatalf("syncGitLabGroup() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(workspace, "top")); (err == nil) != tt.wantTop {
+				t.Errorf("top directory exists = %v, want %v", err == nil, tt.wantTop)
+			}
+		})
+	}
+}
+
+func TestSyncGitLabRestrictedTokensUseState(t *testing.T) {
+	var jobTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jobTokens = append(jobTokens, r.Header.Get("JOB-TOKEN"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name      string
+		tokenType string
+		wantAPI   bool
+	}{
+		{"job token", models.TokenTypeJob, true},
+		{"deploy token", models.TokenTypeDeploy, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobTokens = nil
+			workspace := t.TempDir()
+			state := &models.State{Repositories: map[string]models.RepositoryState{
+				"gitlab:1": {Provider: "gitlab", ID: 1, Name: "api", Path: "top/api", RemotePath: "top/api"},
+				"gitlab:2": {Provider: "gitlab", ID: 2, Name: "cli", Path: "top/sub/cli", RemotePath: "top/sub/cli"},
+			}}
+			if err := helpers.SaveState(workspace, state); err != nil {
+				t.Fatal(err)
+			}
+
+			git := &fakeGitRunner{}
+			options := models.SyncOptions{
+				Token:       "job-token-1234567890",
+				TokenType:   tt.tokenType,
+				CloneMethod: "https",
+				BaseDir:     workspace,
+				BaseURL:     server.URL,
+			}
+			if err := syncGitLabGroup(1, options, Dependencies{HTTP: server.Client(), Git: git}); err != nil {
+				t.Fatalf("syncGitLabGroup() error = %v", err)
+			}
+
+			if (len(jobTokens) > 0) != tt.wantAPI {
+				t.Errorf("API requests = %d, want API used: %v", len(jobTokens), tt.wantAPI)
+			}
+			if tt.wantAPI && jobTokens[0] != "job-token-1234567890" {
+				t.Errorf("JOB-TOKEN header = %q, want the job token", jobTokens[0])
+			}
+			commands := strings.Join(git.commands(), "\n")
+			for _, path := range []string{"top/api", "top/sub/cli"} {
+				if !strings.Contains(commands, server.URL+"/"+path+".git") {
+					t.Errorf("expected a clone of %s, git ran:\n%s", path, commands)
+				}
+			}
+		})
+	}
+
+	options := models.SyncOptions{Token: "deploy-token-123456", TokenType: models.TokenTypeDeploy, BaseDir: t.TempDir(), BaseURL: server.URL}
+	if err := syncGitLabGroup(1, options, Dependencies{HTTP: server.Client(), Git: &fakeGitRunner{}}); err == nil {
+		t.Error("syncGitLabGroup() should fail when the state records no repositories")
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+dependencyCollector gathers the dependency manifests found by the workers of a run.
+*/
+type dependencyCollector struct {
+	mu           sync.Mutex
+	repositories []models.RepositoryDependency
+}
+
+/*
+harvestDependencies collects the dependency manifests of a clone.
+*/
+func (r *syncRun) harvestDependencies(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+	manifests, err := helpers.HarvestDependencies(target.Path)
+	if err != nil {
+		r.notify(LevelError, "Failed to harvest dependencies of %s: %v", relPath, err)
+		return
+	}
+
+	r.dependencies.mu.Lock()
+	r.dependencies.repositories = append(r.dependencies.repositories, models.RepositoryDependency{Path: relPath, Manifests: manifests})
+	r.dependencies.mu.Unlock()
+}
+
+/*
+writeDependencyInventory saves the consolidated dependency inventory of the run.
+*/
+func (r *syncRun) writeDependencyInventory() error {
+	r.dependencies.mu.Lock()
+	inventory := models.DependencyInventory{
+		Generated:    time.Now().UTC(),
+		Repositories: append([]models.RepositoryDependency{}, r.dependencies.repositories...),
+	}
+	r.dependencies.mu.Unlock()
+
+	sort.Slice(inventory.Repositories, func(i, j int) bool {
+		return inventory.Repositories[i].Path < inventory.Repositories[j].Path
+	})
+
+	manifests := 0
+	for _, repository := range inventory.Repositories {
+		manifests += len(repository.Manifests)
+	}
+
+	path := r.options.DependencyInventoryPath
+	err := helpers.WriteJSONReport(path, inventory)
+	r.audit.Record("write-dependency-inventory", path, "", err)
+	if err != nil {
+		return err
+	}
+	r.notify(LevelSuccess, "Wrote %d dependency manifests from %d repositories to %s", manifests, len(inventory.Repositories), path)
+	return nil
+}
+
+/*
+DependentRepositories reads a dependency inventory written by --harvest deps and
+returns the clone paths of the repositories declaring a dependency whose name
+contains the query, case-insensitively: "log4j" finds
+org.apache.logging.log4j:log4j-core as well as log4j:log4j.
+*/
+func DependentRepositories(inventoryPath, query string) (map[string]bool, error) {
+	var inventory models.DependencyInventory
+	found, err := readSnapshot(inventoryPath, &inventory)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no dependency inventory at %s: sync with --harvest deps first", inventoryPath)
+	}
+
+	query = strings.ToLower(query)
+	paths := map[string]bool{}
+	for _, repository := range inventory.Repositories {
+		for _, manifest := range repository.Manifests {
+			if slices.ContainsFunc(manifest.Dependencies, func(dependency models.Dependency) bool {
+				return strings.Contains(strings.ToLower(dependency.Name), query)
+			}) {
+				paths[repository.Path] = true
+			}
+		}
+	}
+	return paths, nil
+}
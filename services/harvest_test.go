@@ -0,0 +1,42 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestDependentRepositories(t *testing.T) {
+	inventoryPath := filepath.Join(t.TempDir(), "dependencies.json")
+	inventory := models.DependencyInventory{Repositories: []models.RepositoryDependency{
+		{Path: "acme/billing", Manifests: []models.DependencyManifest{
+			{Path: "pom.xml", Ecosystem: "maven", Dependencies: []models.Dependency{{Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1"}}},
+		}},
+		{Path: "acme/legacy", Manifests: []models.DependencyManifest{
+			{Path: "go.mod", Ecosystem: "go", Dependencies: []models.Dependency{{Name: "github.com/sirupsen/logrus"}}},
+			{Path: "tools/pom.xml", Ecosystem: "maven", Dependencies: []models.Dependency{{Name: "Log4j:Log4j", Version: "1.2.17"}}},
+		}},
+		{Path: "acme/web", Manifests: []models.DependencyManifest{
+			{Path: "package.json", Ecosystem: "npm", Dependencies: []models.Dependency{{Name: "react"}}},
+		}},
+	}}
+	if err := helpers.WriteJSONReport(inventoryPath, inventory); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DependentRepositories(inventoryPath, "LOG4J")
+	if err != nil {
+		t.Fatalf("DependentRepositories() error = %v", err)
+	}
+	want := map[string]bool{"acme/billing": true, "acme/legacy": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DependentRepositories() = %v, want %v", got, want)
+	}
+
+	if _, err := DependentRepositories(filepath.Join(t.TempDir(), "missing.json"), "log4j"); err == nil {
+		t.Errorf("DependentRepositories() without an inventory succeeded, want an error")
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+pinnedTargets lists the repositories of a repo manifest or a superproject's .gitmodules
+as sync targets, each checked out at the revision the file pins it at. The file is
+relative to the workspace; repositories are placed at the paths it declares.
+*/
+func pinnedTargets(run *syncRun, source models.ManifestSource, baseDir string) ([]syncTarget, error) {
+	file := source.File
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(run.options.BaseDir, file)
+	}
+
+	var targets []syncTarget
+	listed := map[string]bool{}
+	add := func(cloneURL, relPath, revision string) error {
+		if !filepath.IsLocal(filepath.FromSlash(relPath)) {
+			return fmt.Errorf("path %q of %s must be relative and stay inside the workspace", relPath, cloneURL)
+		}
+		remotePath, _, ok := parseCloneURL(cloneURL)
+		if !ok {
+			remotePath = cloneURL // Local paths have no host
+		}
+		// A repository checked out at several paths is a repository per path
+		id := urlID(cloneURL)
+		if listed[cloneURL] {
+			id = urlID(cloneURL + "#" + relPath)
+		}
+		listed[cloneURL] = true
+		targets = append(targets, syncTarget{
+			ID:         id,
+			Name:       path.Base(relPath),
+			RemotePath: remotePath,
+			HTTPSURL:   cloneURL,
+			SSHURL:     cloneURL,
+			Path:       filepath.Join(baseDir, filepath.FromSlash(relPath)),
+			Revision:   revision,
+		})
+		return nil
+	}
+
+	if source.Provider == "gitmodules" {
+		submodules, err := helpers.ReadSubmodules(run.deps.Git, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, submodule := range submodules {
+			if err := add(submodule.URL, submodule.Path, submodule.Commit); err != nil {
+				return nil, err
+			}
+		}
+		return targets, nil
+	}
+
+	manifest, err := helpers.ReadRepoManifest(file)
+	if err != nil {
+		return nil, err
+	}
+	remotes := map[string]models.RepoRemote{}
+	for _, remote := range manifest.Remotes {
+		remotes[remote.Name] = remote
+	}
+	defaults := models.RepoDefault{}
+	if manifest.Default != nil {
+		defaults = *manifest.Default
+	}
+
+	for _, project := range manifest.Projects {
+		remoteName := firstNonEmpty(project.Remote, defaults.Remote)
+		remote, ok := remotes[remoteName]
+		if !ok {
+			return nil, fmt.Errorf("project %s of %s uses the undeclared remote %q", project.Name, file, remoteName)
+		}
+		fetch, err := resolveManifestFetch(run, file, remote.Fetch)
+		if err != nil {
+			return nil, err
+		}
+		cloneURL := strings.TrimSuffix(fetch, "/") + "/" + project.Name
+		revision := firstNonEmpty(project.Revision, remote.Revision, defaults.Revision)
+		if err := add(cloneURL, firstNonEmpty(project.Path, project.Name), revision); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+/*
+resolveManifestFetch resolves a relative fetch URL of a repo manifest (e.g. "..") against
+the origin of the manifest repository the file is checked out from, as repo does.
+*/
+func resolveManifestFetch(run *syncRun, file, fetch string) (string, error) {
+	if !strings.HasPrefix(fetch, ".") {
+		return fetch, nil
+	}
+	origin, err := helpers.OriginURL(run.deps.Git, filepath.Dir(file))
+	if err != nil {
+		return "", fmt.Errorf("relative fetch URL %q needs %s to be in a clone of the manifest repository: %w", fetch, file, err)
+	}
+	// repo joins fetch URLs with the manifest URL itself, not with its directory
+	return helpers.ResolveRelativeURL(origin, path.Join("..", fetch))
+}
+
+/*
+firstNonEmpty returns the first of the values that is not empty.
+*/
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
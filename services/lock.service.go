@@ -0,0 +1,241 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+LockWorkspace writes the commit every clone recorded in a workspace's state has
+checked out to a lockfile (lockPath, reposync.lock in the workspace by default).
+Repositories without a clone or without commits are reported and left out.
+*/
+func LockWorkspace(workspace, lockPath string) error {
+	return lockWorkspace(workspace, lockPath, DefaultDependencies())
+}
+
+func lockWorkspace(workspace, lockPath string, deps Dependencies) error {
+	if lockPath == "" {
+		lockPath = helpers.GetLockfilePath(workspace)
+	}
+	lock, err := lockRepositories(workspace, deps.Git)
+	if err != nil {
+		return err
+	}
+	if err := helpers.SaveLockfile(lockPath, lock); err != nil {
+		return err
+	}
+	Notify(LevelSuccess, "Locked %d repositories in %s", len(lock.Repositories), lockPath)
+	return nil
+}
+
+/*
+lockRepositories reads the checked-out commit of every clone of a workspace.
+*/
+func lockRepositories(workspace string, git helpers.GitRunner) (*models.Lockfile, error) {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Repositories) == 0 {
+		return nil, fmt.Errorf("no repositories recorded in %s: sync the workspace first", workspace)
+	}
+
+	entries := make([]models.RepositoryState, 0, len(state.Repositories))
+	for _, entry := range state.Repositories {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	lock := &models.Lockfile{Repositories: []models.LockedRepository{}}
+	for _, entry := range entries {
+		repoPath := filepath.Join(workspace, filepath.FromSlash(entry.Path))
+		if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+			Notify(LevelWarning, "Skipping %s: no clone", entry.Path)
+			continue
+		}
+		if entry.RemotePath == "" {
+			Notify(LevelWarning, "Skipping %s: no remote path recorded, sync the workspace again", entry.Path)
+			continue
+		}
+		commit, err := helpers.ResolveCommit(git, repoPath, "HEAD")
+		if err != nil {
+			Notify(LevelWarning, "Skipping %s: no commits yet", entry.Path)
+			continue
+		}
+		lock.Repositories = append(lock.Repositories, models.LockedRepository{
+			Path:       entry.Path,
+			Provider:   entry.Provider,
+			RemotePath: entry.RemotePath,
+			WebURL:     entry.WebURL,
+			Commit:     commit,
+			Branch:     helpers.CurrentBranch(git, repoPath),
+		})
+	}
+	return lock, nil
+}
+
+/*
+LockedPins turns a lockfile into the pins of a sync, keyed by provider and remote
+path like the pins of the workspace manifest: every repository is checked out at
+its locked commit, on the branch that was checked out when it was locked.
+*/
+func LockedPins(lock *models.Lockfile) map[string]models.ManifestPin {
+	pins := make(map[string]models.ManifestPin, len(lock.Repositories))
+	for _, repository := range lock.Repositories {
+		pins[repository.Provider+":"+repository.RemotePath] = models.ManifestPin{Revision: repository.Commit, Branch: repository.Branch}
+	}
+	return pins
+}
+
+/*
+DiffLockfiles compares two lockfiles and reports the repositories locked at another
+commit, with a link to the provider's compare view, and those added or removed.
+Commits are counted in the clones of workspace, which need to have both commits;
+nothing is fetched.
+*/
+func DiffLockfiles(oldPath, newPath, workspace string) (*models.LockDiff, error) {
+	return diffLockfiles(oldPath, newPath, workspace, DefaultDependencies())
+}
+
+func diffLockfiles(oldPath, newPath, workspace string, deps Dependencies) (*models.LockDiff, error) {
+	oldLock, err := helpers.LoadLockfile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newLock, err := helpers.LoadLockfile(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := map[string]models.LockedRepository{}
+	for _, repository := range oldLock.Repositories {
+		previous[lockKey(repository)] = repository
+	}
+	diff := &models.LockDiff{Changed: []models.LockChange{}, Added: []models.LockedRepository{}, Removed: []models.LockedRepository{}}
+	for _, repository := range newLock.Repositories {
+		old, ok := previous[lockKey(repository)]
+		delete(previous, lockKey(repository))
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, repository)
+		case old.Commit != repository.Commit:
+			diff.Changed = append(diff.Changed, lockChange(deps.Git, workspace, old, repository))
+		}
+	}
+	for _, repository := range oldLock.Repositories {
+		if _, ok := previous[lockKey(repository)]; ok {
+			diff.Removed = append(diff.Removed, repository)
+		}
+	}
+	return diff, nil
+}
+
+/*
+lockKey identifies a repository across lockfiles like the pins of a locked sync do,
+by provider and remote path, so moving a clone is not reported as a change.
+*/
+func lockKey(repository models.LockedRepository) string {
+	return repository.Provider + ":" + repository.RemotePath
+}
+
+/*
+lockChange describes a repository locked at another commit, counting the commits in its clone.
+*/
+func lockChange(git helpers.GitRunner, workspace string, old, current models.LockedRepository) models.LockChange {
+	change := models.LockChange{
+		Path:       current.Path,
+		Provider:   current.Provider,
+		RemotePath: current.RemotePath,
+		OldCommit:  old.Commit,
+		NewCommit:  current.Commit,
+		Commits:    -1,
+		Dropped:    -1,
+		CompareURL: compareURL(current, old.Commit, current.Commit),
+	}
+	repoPath := filepath.Join(workspace, filepath.FromSlash(current.Path))
+	commits, err := helpers.CountCommits(git, repoPath, old.Commit, current.Commit)
+	if err != nil {
+		return change
+	}
+	dropped, err := helpers.CountCommits(git, repoPath, current.Commit, old.Commit)
+	if err != nil {
+		return change
+	}
+	change.Commits, change.Dropped = commits, dropped
+	return change
+}
+
+/*
+compareURL links the provider's view of the changes between two commits of a repository,
+built from its web URL. Returns "" for providers without one or without a web URL.
+*/
+func compareURL(repository models.LockedRepository, from, to string) string {
+	if repository.WebURL == "" {
+		return ""
+	}
+	webURL := strings.TrimSuffix(repository.WebURL, "/")
+	switch repository.Provider {
+	case "github":
+		return webURL + "/compare/" + from + "..." + to
+	case "gitlab":
+		return webURL + "/-/compare/" + from + "..." + to
+	case "bitbucket-server":
+		// Web URLs point at the file browser (.../repos/api/browse)
+		return strings.TrimSuffix(webURL, "/browse") + "/compare/commits?sourceBranch=" + to + "&targetBranch=" + from
+	}
+	return ""
+}
+
+/*
+PrintLockDiff renders a lockfile comparison as a list or, with asJSON, as JSON.
+*/
+func PrintLockDiff(w io.Writer, diff *models.LockDiff, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "Changed repositories: %d\n", len(diff.Changed))
+	for _, change := range diff.Changed {
+		commits := "commits unknown"
+		switch {
+		case change.Commits < 0:
+		case change.Dropped > 0:
+			commits = fmt.Sprintf("%d new, %d dropped commits", change.Commits, change.Dropped)
+		default:
+			commits = fmt.Sprintf("%d new commits", change.Commits)
+		}
+		fmt.Fprintf(table, "  %s\t%s..%s\t%s\t%s\n", change.Path, shortCommit(change.OldCommit), shortCommit(change.NewCommit), commits, change.CompareURL)
+	}
+	fmt.Fprintf(table, "\nAdded repositories: %d\n", len(diff.Added))
+	for _, repository := range diff.Added {
+		fmt.Fprintf(table, "  %s\t%s\n", repository.Path, shortCommit(repository.Commit))
+	}
+	fmt.Fprintf(table, "\nRemoved repositories: %d\n", len(diff.Removed))
+	for _, repository := range diff.Removed {
+		fmt.Fprintf(table, "  %s\t%s\n", repository.Path, shortCommit(repository.Commit))
+	}
+	return table.Flush()
+}
+
+/*
+shortCommit abbreviates a commit hash for display.
+*/
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
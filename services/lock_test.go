@@ -0,0 +1,78 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestDiffLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.lock"), filepath.Join(dir, "new.lock")
+	oldLock := &models.Lockfile{Repositories: []models.LockedRepository{
+		{Path: "acme/api", Provider: "github", RemotePath: "acme/api", WebURL: "https://github.com/acme/api", Commit: "aaa"},
+		{Path: "acme/legacy", Provider: "github", RemotePath: "acme/legacy", Commit: "bbb"},
+		{Path: "acme/web", Provider: "github", RemotePath: "acme/web", Commit: "ccc"},
+	}}
+	newLock := &models.Lockfile{Repositories: []models.LockedRepository{
+		{Path: "services/api", Provider: "github", RemotePath: "acme/api", WebURL: "https://github.com/acme/api", Commit: "ddd"},
+		{Path: "acme/tools", Provider: "github", RemotePath: "acme/tools", Commit: "eee"},
+		{Path: "acme/web", Provider: "github", RemotePath: "acme/web", Commit: "ccc"},
+	}}
+	for path, lock := range map[string]*models.Lockfile{oldPath: oldLock, newPath: newLock} {
+		if err := helpers.SaveLockfile(path, lock); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git := &fakeGitRunner{outputs: map[string]string{"rev-list": "4\n"}}
+	diff, err := diffLockfiles(oldPath, newPath, dir, Dependencies{Git: git})
+	if err != nil {
+		t.Fatalf("diffLockfiles() error = %v", err)
+	}
+	want := &models.LockDiff{
+		Changed: []models.LockChange{{
+			Path: "services/api", Provider: "github", RemotePath: "acme/api", OldCommit: "aaa", NewCommit: "ddd",
+			Commits: 4, Dropped: 4, CompareURL: "https://github.com/acme/api/compare/aaa...ddd",
+		}},
+		Added:   []models.LockedRepository{newLock.Repositories[1]},
+		Removed: []models.LockedRepository{oldLock.Repositories[1]},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffLockfiles() = %+v, want %+v", diff, want)
+	}
+
+	// Without both commits in the clone, the count is unknown
+	git = &fakeGitRunner{failures: map[string]int{"rev-list": 1}}
+	if diff, err = diffLockfiles(oldPath, newPath, dir, Dependencies{Git: git}); err != nil {
+		t.Fatalf("diffLockfiles() error = %v", err)
+	}
+	if change := diff.Changed[0]; change.Commits != -1 || change.Dropped != -1 {
+		t.Errorf("commits = %d, dropped = %d, want -1 for a clone without the commits", change.Commits, change.Dropped)
+	}
+}
+
+func TestCompareURL(t *testing.T) {
+	tests := []struct {
+		provider string
+		webURL   string
+		want     string
+	}{
+		{"github", "https://github.com/acme/api", "https://github.com/acme/api/compare/aaa...bbb"},
+		{"gitlab", "https://gitlab.example.com/acme/api/", "https://gitlab.example.com/acme/api/-/compare/aaa...bbb"},
+		{"bitbucket-server", "https://bitbucket.example.com/projects/ACME/repos/api/browse", "https://bitbucket.example.com/projects/ACME/repos/api/compare/commits?sourceBranch=bbb&targetBranch=aaa"},
+		{"repo-manifest", "", ""},
+		{"github", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			repository := models.LockedRepository{Provider: tt.provider, WebURL: tt.webURL}
+			if got := compareURL(repository, "aaa", "bbb"); got != tt.want {
+				t.Errorf("compareURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+InitMetaRepository turns a workspace into a meta repository: a git repository
+with every synced clone as a submodule pinned at the commit it has checked out,
+so "the whole organization at this point in time" is a single commit. The clones
+are registered where they are, nothing is cloned again. Running it again after
+a sync pins the new commits and adds new repositories in a further commit.
+*/
+func InitMetaRepository(workspace, message string) error {
+	return initMetaRepository(workspace, message, DefaultDependencies())
+}
+
+/*
+initMetaRepository implements InitMetaRepository on top of injectable dependencies.
+*/
+func initMetaRepository(workspace, message string, deps Dependencies) error {
+	clones, err := collectClones(workspace, deps.Git)
+	if err != nil {
+		return err
+	}
+
+	audit, err := helpers.OpenAuditLog(helpers.GetAuditLogPath(workspace))
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+
+	err = helpers.InitMetaRepository(deps.Git, workspace)
+	audit.Record("meta-init", workspace, "", err)
+	if err != nil {
+		return err
+	}
+
+	pinned := 0
+	for _, clone := range clones {
+		err := helpers.PinSubmodule(deps.Git, workspace, clone.path, clone.url, clone.commit)
+		audit.Record("meta-pin", clone.path, clone.commit, err)
+		if err != nil {
+			Notify(LevelError, "Failed to pin %s: %v", clone.path, err)
+			continue
+		}
+		pinned++
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Snapshot of %d repositories at %s", pinned, time.Now().UTC().Format(time.RFC3339))
+	}
+	commit, err := helpers.CommitMetaRepository(deps.Git, workspace, message)
+	audit.Record("meta-commit", workspace, commit, err)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		Notify(LevelSuccess, "Meta repository %s is up to date (%d repositories pinned)", workspace, pinned)
+		return nil
+	}
+	Notify(LevelSuccess, "Pinned %d repositories in meta repository %s (commit %.12s)", pinned, workspace, commit)
+	return nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+ownershipCollector gathers ownership rules from the repositories of a run.
+Safe for use by concurrent workers.
+*/
+type ownershipCollector struct {
+	mu           sync.Mutex
+	repositories []models.RepositoryOwnership
+}
+
+/*
+add records the ownership rules of one repository.
+*/
+func (c *ownershipCollector) add(ownership models.RepositoryOwnership) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.repositories = append(c.repositories, ownership)
+}
+
+/*
+report builds the org-wide report, indexing repositories by owner.
+*/
+func (c *ownershipCollector) report() models.OwnershipReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := models.OwnershipReport{
+		Generated:    time.Now().UTC(),
+		Repositories: append([]models.RepositoryOwnership{}, c.repositories...),
+		Owners:       map[string][]string{},
+	}
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Path < report.Repositories[j].Path
+	})
+
+	for _, repository := range report.Repositories {
+		for _, rule := range repository.Rules {
+			for _, owner := range rule.Owners {
+				repos := report.Owners[owner]
+				if len(repos) == 0 || repos[len(repos)-1] != repository.Path {
+					report.Owners[owner] = append(repos, repository.Path)
+				}
+			}
+		}
+	}
+	return report
+}
+
+/*
+collectOwnership reads the CODEOWNERS file of a clone and, on GitLab,
+the project's approval rules.
+*/
+func (r *syncRun) collectOwnership(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+
+	source, rules, err := helpers.ReadCodeowners(target.Path)
+	if err != nil {
+		r.notify(LevelWarning, "Skipping ownership of %s: %v", relPath, err)
+	} else if source != "" {
+		r.ownership.add(models.RepositoryOwnership{Path: relPath, Source: source, Rules: rules})
+	}
+
+	if r.provider != "gitlab" {
+		return
+	}
+	approvalRules, err := getGitLabApprovalRules(r.api, target.ID)
+	if err != nil || len(approvalRules) == 0 {
+		return // Not available on this tier or not configured
+	}
+	ownership := models.RepositoryOwnership{Path: relPath, Source: "gitlab approval rules"}
+	for _, approvalRule := range approvalRules {
+		rule := models.OwnershipRule{Pattern: approvalRule.Name}
+		for _, approver := range approvalRule.EligibleApprovers {
+			rule.Owners = append(rule.Owners, "@"+approver.Username)
+		}
+		for _, group := range approvalRule.Groups {
+			rule.Owners = append(rule.Owners, "@"+group.FullPath)
+		}
+		ownership.Rules = append(ownership.Rules, rule)
+	}
+	r.ownership.add(ownership)
+}
+
+/*
+writeOwnershipReport saves the aggregated ownership report of the run.
+*/
+func (r *syncRun) writeOwnershipReport() error {
+	report := r.ownership.report()
+	err := helpers.WriteJSONReport(r.options.OwnershipReportPath, report)
+	r.audit.Record("write-ownership-report", r.options.OwnershipReportPath, "", err)
+	if err != nil {
+		return err
+	}
+	r.notify(LevelSuccess, "Wrote ownership report for %d repositories (%d owners) to %s",
+		len(report.Repositories), len(report.Owners), r.options.OwnershipReportPath)
+	return nil
+}
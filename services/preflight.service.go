@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// Config file keys of the instance URLs, named in pre-flight errors.
+var instanceURLKeys = map[string]string{
+	"gitlab":           "gitlab_url",
+	"github":           "github_url",
+	"bitbucket-server": "bitbucket_server_url",
+}
+
+/*
+CheckProvider is the pre-flight check of a run. It calls a lightweight endpoint of the
+provider with the run's token (see providerStatusURL), so an unreachable instance or a
+rejected token stops the run before anything is listed or cloned, with a message that
+says what to fix. Returns a description of the instance, e.g. "GitLab 17.2.1-ee at
+gitlab.example.com", or "" when the provider or token kind cannot be checked.
+*/
+func CheckProvider(provider string, options models.SyncOptions) (string, error) {
+	return checkProvider(provider, options, DefaultDependencies())
+}
+
+func checkProvider(provider string, options models.SyncOptions, deps Dependencies) (string, error) {
+	statusURL := providerStatusURL(provider, options.BaseURL)
+	// Job and deploy tokens cannot call the GitLab API beyond their project
+	if statusURL == "" || (provider == "gitlab" && options.TokenType != models.TokenTypePersonal) {
+		return "", nil
+	}
+	host := statusURL
+	if parsed, err := url.Parse(statusURL); err == nil {
+		host = parsed.Host
+	}
+
+	resp, err := newProviderAPI(options, deps).get(statusURL)
+	if err != nil {
+		return "", explainPreflightFailure(provider, host, err)
+	}
+	defer resp.Body.Close()
+
+	switch provider {
+	case "gitlab":
+		var version struct {
+			Version string `json:"version"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&version) != nil || version.Version == "" {
+			return "", fmt.Errorf("%s did not answer like the GitLab API: check gitlab_url in the config file, it must be the instance root such as https://gitlab.example.com", host)
+		}
+		return fmt.Sprintf("GitLab %s at %s", version.Version, host), nil
+
+	case "github":
+		var limits struct {
+			Rate struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"rate"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&limits) != nil || limits.Rate.Limit == 0 {
+			return "", fmt.Errorf("%s did not answer like the GitHub API: check github_url in the config file", host)
+		}
+		if limits.Rate.Remaining == 0 {
+			reset := time.Unix(limits.Rate.Reset, 0).Local().Format("15:04")
+			return "", fmt.Errorf("the token has no API requests left until %s: wait until then or use another token", reset)
+		}
+		product := "GitHub.com"
+		if version := resp.Header.Get("X-GitHub-Enterprise-Version"); version != "" {
+			product = fmt.Sprintf("GitHub Enterprise Server %s at %s", version, host)
+		}
+		return fmt.Sprintf("%s (%d of %d API requests left)", product, limits.Rate.Remaining, limits.Rate.Limit), nil
+
+	case "bitbucket-server":
+		var properties struct {
+			Version     string `json:"version"`
+			DisplayName string `json:"displayName"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&properties) != nil || properties.Version == "" {
+			return "", fmt.Errorf("%s did not answer like the Bitbucket Server API: check bitbucket_server_url in the config file", host)
+		}
+		return fmt.Sprintf("%s %s at %s", properties.DisplayName, properties.Version, host), nil
+	}
+	return "", nil
+}
+
+/*
+explainPreflightFailure turns the error of a pre-flight request into a message that says what to fix.
+*/
+func explainPreflightFailure(provider, host string, err error) error {
+	var statusErr *client.StatusError
+	var ssoErr *client.SSOError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		return fmt.Errorf("%s rejected the token: it is invalid, expired or revoked, or was created on another instance; create a new token and store it with reposync config", host)
+	case errors.As(err, &ssoErr):
+		return err
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden && provider == "gitlab":
+		return fmt.Errorf("%s accepted the token but refused the API request: the token needs the read_api scope (%w)", host, err)
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden:
+		return fmt.Errorf("%s accepted the token but refused the API request, check its permissions: %w", host, err)
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound:
+		return fmt.Errorf("no %s API found at %s: check %s in the config file: %w", provider, host, instanceURLKeys[provider], err)
+	case errors.As(err, &statusErr) && statusErr.Code >= 500:
+		return fmt.Errorf("%s is unavailable: %w; try again later, or wait for it with --wait-for-provider", host, err)
+	case errors.As(err, &urlErr):
+		return fmt.Errorf("cannot reach %s: %w; check %s in the config file, the proxy settings (HTTPS_PROXY) and the network", host, err, instanceURLKeys[provider])
+	}
+	return fmt.Errorf("pre-flight check of %s failed: %w", host, err)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func TestCheckProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer glpat-valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v4/version":
+			fmt.Fprint(w, `{"version":"17.2.1-ee","revision":"4a8e5f1"}`)
+		case "/api/v3/rate_limit":
+			w.Header().Set("X-GitHub-Enterprise-Version", "3.13.0")
+			fmt.Fprint(w, `{"rate":{"limit":5000,"remaining":4990,"reset":1760000000}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	deps := Dependencies{HTTP: server.Client()}
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name     string
+		provider string
+		options  models.SyncOptions
+		want     string
+		wantErr  string
+	}{
+		{"gitlab", "gitlab", models.SyncOptions{Token: "glpat-valid", BaseURL: server.URL}, "GitLab 17.2.1-ee at " + host, ""},
+		{"github enterprise", "github", models.SyncOptions{Token: "glpat-valid", BaseURL: server.URL}, "GitHub Enterprise Server 3.13.0 at " + host + " (4990 of 5000 API requests left)", ""},
+		{"rejected token", "gitlab", models.SyncOptions{Token: "glpat-expired", BaseURL: server.URL}, "", "rejected the token"},
+		{"job token not checked", "gitlab", models.SyncOptions{Token: "job", TokenType: models.TokenTypeJob, BaseURL: server.URL}, "", ""},
+		{"wrong instance URL", "bitbucket-server", models.SyncOptions{Token: "glpat-valid", BaseURL: server.URL}, "", "check bitbucket_server_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkProvider(tt.provider, tt.options, deps)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkProvider() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("checkProvider() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainPreflightFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		err      error
+		want     string
+	}{
+		{"scope", "gitlab", &client.StatusError{Code: http.StatusForbidden}, "needs the read_api scope"},
+		{"forbidden", "github", &client.StatusError{Code: http.StatusForbidden}, "check its permissions"},
+		{"unavailable", "gitlab", &client.StatusError{Code: http.StatusServiceUnavailable}, "--wait-for-provider"},
+		{"unreachable", "github", fmt.Errorf("failed to fetch data: %w", &url.Error{Op: "Get", URL: "https://github.example.com", Err: errors.New("no such host")}), "check github_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := explainPreflightFailure(tt.provider, "example.com", tt.err); !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("explainPreflightFailure() = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+cloneRegistryKey identifies a repository in the machine-wide registry by the host and
+path of its clone URL, lower-cased, so its HTTPS and SSH clones are the same repository.
+Repositories cloned from local paths are identified by the path.
+*/
+func cloneRegistryKey(target syncTarget) string {
+	cloneURL := target.HTTPSURL
+	if cloneURL == "" {
+		cloneURL = target.SSHURL
+	}
+	if remotePath, _, ok := parseCloneURL(helpers.StripURLCredentials(cloneURL)); ok {
+		return strings.ToLower(remotePath)
+	}
+	return filepath.Clean(cloneURL)
+}
+
+/*
+cloneRegistry is the machine-wide registry of clones as a run uses it: the clones
+known when the run started and those synced during it, added at the end.
+*/
+type cloneRegistry struct {
+	mu         sync.Mutex
+	known      *models.Registry
+	synced     map[string]string // Clone paths by registry key
+	duplicates int               // Repositories cloned although a clone elsewhere could have lent its objects
+}
+
+/*
+add records a repository synced in this run. Nil-safe, like the collectors.
+*/
+func (c *cloneRegistry) add(target syncTarget) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced[cloneRegistryKey(target)] = target.Path
+}
+
+/*
+loadRegistry reads the machine-wide registry of clones (RegistryPath).
+An unreadable registry is reported and the run goes on without it.
+*/
+func (r *syncRun) loadRegistry() {
+	if r.options.RegistryPath == "" {
+		return
+	}
+	registry, err := helpers.LoadRegistry(r.options.RegistryPath)
+	if err != nil {
+		r.notify(LevelWarning, "Ignoring the clone registry: %v", err)
+		return
+	}
+	r.clones = &cloneRegistry{known: registry, synced: map[string]string{}}
+}
+
+/*
+existingClone returns a clone of a repository that another workspace, or another path
+of this one, already has, or "" if the registry knows none that still exists.
+*/
+func (r *syncRun) existingClone(target syncTarget) string {
+	if r.clones == nil {
+		return ""
+	}
+	for _, path := range r.clones.known.Clones[cloneRegistryKey(target)] {
+		if path == target.Path {
+			continue
+		}
+		// A directory for clones, a file for worktrees
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+/*
+sharedObjectArgs returns the clone arguments that borrow the objects of an existing
+clone of the repository, so only what it lacks is downloaded. --dissociate copies the
+borrowed objects afterwards, so the new clone does not depend on the other one.
+Without ShareObjects, the repository is counted for the hint at the end of the run.
+Returns nil when there is nothing to borrow from.
+*/
+func (r *syncRun) sharedObjectArgs(target syncTarget) []string {
+	existing := r.existingClone(target)
+	if existing == "" {
+		return nil
+	}
+	if !r.options.ShareObjects {
+		r.clones.mu.Lock()
+		r.clones.duplicates++
+		r.clones.mu.Unlock()
+		return nil
+	}
+	source, err := helpers.ObjectSource(r.deps.Git, existing)
+	if err != nil {
+		r.notify(LevelWarning, "Cannot share the objects of %s: %v", existing, err)
+		return nil
+	}
+	r.notify(LevelInfo, "Cloning %s with the objects of %s", target.Name, existing)
+	return []string{"--reference", source, "--dissociate"}
+}
+
+/*
+saveRegistry adds the clones synced in this run to the machine-wide registry and drops
+the clones that no longer exist. The registry is read again first, as other runs may
+have updated it in the meantime.
+*/
+func (r *syncRun) saveRegistry() {
+	if r.clones == nil {
+		return
+	}
+	if r.clones.duplicates > 0 {
+		r.notify(LevelInfo, "%d repositories were already cloned elsewhere on this machine; --share-objects reuses their objects instead of downloading them again", r.clones.duplicates)
+	}
+
+	registry, err := helpers.LoadRegistry(r.options.RegistryPath)
+	if err != nil {
+		r.notify(LevelWarning, "Failed to update the clone registry: %v", err)
+		return
+	}
+	for key, path := range r.clones.synced {
+		if !slices.Contains(registry.Clones[key], path) {
+			registry.Clones[key] = append(registry.Clones[key], path)
+		}
+	}
+	for key, paths := range registry.Clones {
+		paths = slices.DeleteFunc(paths, func(path string) bool {
+			_, err := os.Stat(path)
+			return os.IsNotExist(err)
+		})
+		if len(paths) == 0 {
+			delete(registry.Clones, key)
+			continue
+		}
+		registry.Clones[key] = paths
+	}
+	if err := helpers.SaveRegistry(r.options.RegistryPath, registry); err != nil {
+		r.notify(LevelWarning, "Failed to update the clone registry: %v", err)
+	}
+}
+
+/*
+RegisterWorkspace records a sync of a workspace in the machine-wide registry,
+replacing the previous sync of the same workspace and sources.
+*/
+func RegisterWorkspace(registryPath string, workspace models.RegisteredWorkspace) error {
+	registry, err := helpers.LoadRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(registry.Workspaces, func(registered models.RegisteredWorkspace) bool {
+		return registered.Path == workspace.Path && slices.Equal(registered.Sources, workspace.Sources)
+	})
+	if i >= 0 {
+		registry.Workspaces[i] = workspace
+	} else {
+		registry.Workspaces = append(registry.Workspaces, workspace)
+	}
+	sort.SliceStable(registry.Workspaces, func(i, j int) bool { return registry.Workspaces[i].Path < registry.Workspaces[j].Path })
+	return helpers.SaveRegistry(registryPath, registry)
+}
+
+/*
+ListWorkspaces prints the workspaces of the machine-wide registry with their sources
+and last sync, as a table or, with asJSON, as JSON. Workspaces that no longer exist are marked.
+*/
+func ListWorkspaces(w io.Writer, registryPath string, asJSON bool) error {
+	registry, err := helpers.LoadRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(append([]models.RegisteredWorkspace{}, registry.Workspaces...))
+	}
+	if len(registry.Workspaces) == 0 {
+		fmt.Fprintln(w, "No workspaces synced on this machine yet")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "WORKSPACE\tSOURCES\tLAST SYNC\tRESULT")
+	for _, workspace := range registry.Workspaces {
+		result := "ok"
+		switch {
+		case !directoryExists(workspace.Path):
+			result = "missing"
+		case workspace.LastError != "":
+			result = "failed: " + workspace.LastError
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", workspace.Path, strings.Join(workspace.Sources, ","), workspace.LastSync.Local().Format("2006-01-02 15:04"), result)
+	}
+	return table.Flush()
+}
+
+// ErrWorkspacesFailed is returned when some workspaces failed to sync in reposync workspaces sync-all.
+var ErrWorkspacesFailed = errors.New("some workspaces failed to sync")
+
+/*
+SyncAllWorkspaces syncs every workspace of the machine-wide registry again, one after
+another, by running executable (reposync itself) with the arguments of its last sync
+in the directory it ran in. Workspaces that no longer exist are skipped, and a failed
+workspace does not stop the others.
+*/
+func SyncAllWorkspaces(registryPath, executable string) error {
+	registry, err := helpers.LoadRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+	if len(registry.Workspaces) == 0 {
+		return fmt.Errorf("no workspaces synced on this machine yet: sync a workspace first")
+	}
+
+	failed := 0
+	for _, workspace := range registry.Workspaces {
+		if !directoryExists(workspace.Path) {
+			Notify(LevelWarning, "Skipping %s: the workspace no longer exists", workspace.Path)
+			continue
+		}
+		Notify(LevelNotice, "Syncing %s (%s)", workspace.Path, strings.Join(workspace.Sources, ", "))
+		cmd := exec.Command(executable, append([]string{"sync"}, workspace.Args...)...)
+		cmd.Dir = workspace.Dir
+		if !directoryExists(cmd.Dir) {
+			cmd.Dir = workspace.Path
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed++
+			Notify(LevelError, "Sync of %s failed: %v", workspace.Path, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrWorkspacesFailed, failed, len(registry.Workspaces))
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	colors "github.com/itszeeshan/reposync/constants/colors"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// Kinds of the events a sync run reports.
+const (
+	EventMessage            = "message"
+	EventPhaseStarted       = "phase-started"
+	EventPhaseFinished      = "phase-finished"
+	EventRepositoryStarted  = "repository-started"
+	EventRepositoryFinished = "repository-finished"
+	EventSummary            = "summary"
+	EventCheck              = "check"
+)
+
+// Levels of messages, from routine progress to failures.
+const (
+	LevelInfo    = "info"
+	LevelNotice  = "notice"
+	LevelSuccess = "success"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+/*
+levelColor returns the color messages of a level are printed in. It is looked up
+on every message, as colors.Configure may turn the colors off after startup.
+*/
+func levelColor(level string) string {
+	switch level {
+	case LevelNotice:
+		return colors.Cyan
+	case LevelSuccess:
+		return colors.Green
+	case LevelWarning:
+		return colors.Yellow
+	case LevelError:
+		return colors.Red
+	}
+	return ""
+}
+
+/*
+Event is something that happened during a sync run or another command.
+Only the fields of its kind are set: messages have a level and a text,
+repository events the repository and its position in the run, the
+summary event the sections of the run summary, and check events the name
+of a reposync doctor check, its detail and a level (error when it failed).
+*/
+type Event struct {
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	Level      string           `json:"level,omitempty"`
+	Message    string           `json:"message,omitempty"`
+	Phase      string           `json:"phase,omitempty"`
+	Repository string           `json:"repository,omitempty"`
+	Current    int64            `json:"current,omitempty"`
+	Total      int              `json:"total,omitempty"`
+	DurationMS int64            `json:"duration_ms,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Class      string           `json:"class,omitempty"` // Class of a failed clone (helpers.GitError*)
+	Summary    []SummarySection `json:"summary,omitempty"`
+	Check      string           `json:"check,omitempty"`
+}
+
+/*
+SummarySection is a titled list of the run summary, such as the moved or failed repositories.
+Problem marks failures and repositories that were left unsynced.
+*/
+type SummarySection struct {
+	Kind    string   `json:"kind"`
+	Title   string   `json:"title"`
+	Entries []string `json:"entries"`
+	Problem bool     `json:"problem,omitempty"`
+}
+
+/*
+Renderer presents the events of sync runs and the messages of the other commands to the user.
+The services only report what happens; how it looks is up to the renderer,
+so a new frontend implements this interface without touching the services.
+Render is called from the workers of a run concurrently.
+*/
+type Renderer interface {
+	Render(event Event)
+}
+
+// renderer presents the sync runs of this package, see SetRenderer.
+var renderer Renderer
+
+/*
+SetRenderer makes all following sync runs and commands report to r.
+By default runs print colored text, or timestamped lines in CI mode.
+*/
+func SetRenderer(r Renderer) {
+	renderer = r
+}
+
+/*
+runRenderer returns the renderer of a run with the given options: the one set with
+SetRenderer, or the default for CI mode or the terminal.
+*/
+func runRenderer(options models.SyncOptions) Renderer {
+	switch {
+	case renderer != nil:
+		return renderer
+	case options.CI:
+		return NewCIRenderer(os.Stdout)
+	}
+	return NewTextRenderer(nil)
+}
+
+/*
+Notify reports a message outside of a sync run, such as the checks before it,
+to the renderer of sync runs, so it follows the output format of the runs.
+*/
+func Notify(level, format string, args ...any) {
+	report(Event{Type: EventMessage, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+/*
+report renders an event outside of a sync run, such as the results of the other commands.
+*/
+func report(event Event) {
+	event.Time = time.Now()
+	runRenderer(models.SyncOptions{}).Render(event)
+}
+
+/*
+NewTextRenderer returns the default renderer: colored lines with progress percentages.
+A nil out writes to the current os.Stdout.
+*/
+func NewTextRenderer(out io.Writer) Renderer {
+	return &textRenderer{out: out}
+}
+
+/*
+NewCIRenderer returns a renderer for CI logs: timestamped lines instead of
+percentages, with the phases of a run in collapsible sections.
+*/
+func NewCIRenderer(out io.Writer) Renderer {
+	return &ciRenderer{log: helpers.NewCILog(out), text: &textRenderer{out: out}}
+}
+
+/*
+NewQuietRenderer returns a renderer that only prints failures: error messages,
+failed repositories and the problem sections of the summary.
+*/
+func NewQuietRenderer(out io.Writer) Renderer {
+	return &quietRenderer{text: &textRenderer{out: out}}
+}
+
+/*
+NewJSONRenderer returns a renderer writing every event as a line of JSON,
+for tools that follow a sync run.
+*/
+func NewJSONRenderer(out io.Writer) Renderer {
+	return &jsonRenderer{encoder: json.NewEncoder(out)}
+}
+
+/*
+textRenderer prints events as colored text.
+*/
+type textRenderer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *textRenderer) Render(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := t.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	switch event.Type {
+	case EventMessage:
+		if color := levelColor(event.Level); color != "" {
+			fmt.Fprintln(out, color+event.Message+colors.Reset)
+		} else {
+			fmt.Fprintln(out, event.Message)
+		}
+	case EventRepositoryStarted:
+		fmt.Fprintf(out, "Progress: %d/%d (%.1f%%)\n", event.Current, event.Total, float64(event.Current)/float64(event.Total)*100)
+	case EventRepositoryFinished:
+		if event.Error != "" {
+			fmt.Fprintf(out, colors.Red+"Failed to clone %s: %s\n"+colors.Reset, event.Repository, event.Error)
+		}
+	case EventSummary:
+		for _, section := range event.Summary {
+			fmt.Fprintln(out, colors.Cyan+section.Title+colors.Reset)
+			for _, entry := range section.Entries {
+				fmt.Fprintln(out, "  "+entry)
+			}
+		}
+	case EventCheck:
+		if event.Level == LevelError {
+			fmt.Fprintf(out, colors.Red+"fail  "+colors.Reset+"%s: %s\n", event.Check, event.Message)
+		} else {
+			fmt.Fprintf(out, colors.Green+"ok    "+colors.Reset+"%s: %s\n", event.Check, event.Message)
+		}
+	}
+}
+
+/*
+ciRenderer prints events as timestamped CI log lines.
+*/
+type ciRenderer struct {
+	log  *helpers.CILog
+	text *textRenderer
+}
+
+func (c *ciRenderer) Render(event Event) {
+	switch event.Type {
+	case EventPhaseStarted:
+		c.log.StartSection(event.Phase, event.Message)
+	case EventPhaseFinished:
+		c.log.EndSection(event.Phase)
+	case EventRepositoryStarted:
+		c.log.Printf("[%d/%d] Syncing %s", event.Current, event.Total, event.Repository)
+	case EventRepositoryFinished:
+		if event.Error != "" {
+			c.log.Printf("[%d/%d] Failed %s: %s", event.Current, event.Total, event.Repository, event.Error)
+		} else {
+			c.log.Printf("[%d/%d] Done %s in %s", event.Current, event.Total, event.Repository, time.Duration(event.DurationMS)*time.Millisecond)
+		}
+	default:
+		c.text.Render(event)
+	}
+}
+
+/*
+quietRenderer prints failures only.
+*/
+type quietRenderer struct {
+	text *textRenderer
+}
+
+func (q *quietRenderer) Render(event Event) {
+	switch event.Type {
+	case EventMessage, EventCheck:
+		if event.Level == LevelError {
+			q.text.Render(event)
+		}
+	case EventRepositoryFinished:
+		q.text.Render(event)
+	case EventSummary:
+		var problems []SummarySection
+		for _, section := range event.Summary {
+			if section.Problem {
+				problems = append(problems, section)
+			}
+		}
+		q.text.Render(Event{Type: EventSummary, Summary: problems})
+	}
+}
+
+/*
+jsonRenderer writes events as JSON lines.
+*/
+type jsonRenderer struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func (j *jsonRenderer) Render(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.encoder.Encode(event)
+}
+
+/*
+emit reports an event of the run to its renderer.
+*/
+func (r *syncRun) emit(event Event) {
+	event.Time = time.Now()
+	if r.render == nil {
+		// Runs assembled without newSyncRun, as in tests
+		NewTextRenderer(nil).Render(event)
+		return
+	}
+	r.render.Render(event)
+}
+
+/*
+notify reports a message of the run, formatted like fmt.Sprintf.
+*/
+func (r *syncRun) notify(level, format string, args ...any) {
+	r.emit(Event{Type: EventMessage, Level: level, Message: fmt.Sprintf(format, args...)})
+}
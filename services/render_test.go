@@ -0,0 +1,162 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	colors "github.com/itszeeshan/reposync/constants/colors"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+recordingRenderer keeps the events it was given.
+*/
+type recordingRenderer struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingRenderer) Render(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func TestSyncAllEvents(t *testing.T) {
+	helpers.SetCloneAttempts(1)
+	t.Cleanup(func() { helpers.SetCloneAttempts(3) })
+
+	workspace := t.TempDir()
+	recorder := &recordingRenderer{}
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{BaseDir: workspace, Concurrency: 1},
+		deps:     Dependencies{Git: &fakeGitRunner{failures: map[string]int{"clone": 1}}},
+		state:    &models.State{Repositories: map[string]models.RepositoryState{}},
+		seen:     map[string]bool{},
+		summary:  &syncSummary{},
+		claims:   map[string]pathClaim{},
+		render:   recorder,
+	}
+	run.syncAll([]syncTarget{
+		{ID: 1, Name: "api", RemotePath: "acme/api", Path: filepath.Join(workspace, "api")},
+		{ID: 2, Name: "web", RemotePath: "acme/web", Path: filepath.Join(workspace, "web")},
+	})
+	run.emit(Event{Type: EventSummary, Summary: run.summary.sections()})
+
+	var kinds []string
+	failed := map[string]string{}
+	for _, event := range recorder.events {
+		if event.Type == EventMessage {
+			continue
+		}
+		kinds = append(kinds, event.Type)
+		if event.Type == EventRepositoryFinished {
+			failed[event.Repository] = event.Error
+		}
+	}
+	want := []string{EventPhaseStarted, EventRepositoryStarted, EventRepositoryFinished, EventRepositoryStarted, EventRepositoryFinished, EventPhaseFinished, EventSummary}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", kinds, want)
+	}
+	if failed["acme/api"] == "" || failed["acme/web"] != "" {
+		t.Errorf("finished events = %v, want only acme/api to fail", failed)
+	}
+
+	summary := recorder.events[len(recorder.events)-1].Summary
+	if len(summary) == 0 || summary[0].Kind != "failed" || !summary[0].Problem {
+		t.Errorf("summary = %+v, want the failed repositories", summary)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	events := []Event{
+		{Type: EventMessage, Level: LevelInfo, Message: "Found 2 repositories"},
+		{Type: EventMessage, Level: LevelError, Message: "Failed to move api"},
+		{Type: EventRepositoryStarted, Repository: "acme/api", Current: 1, Total: 2},
+		{Type: EventRepositoryFinished, Repository: "acme/api", Current: 1, Total: 2, Error: "exit status 128"},
+		{Type: EventSummary, Summary: []SummarySection{
+			{Kind: "moved", Title: "Moved repositories:", Entries: []string{"old -> new"}},
+			{Kind: "failed", Title: "Failed repositories:", Entries: []string{"api"}, Problem: true},
+		}},
+		{Type: EventCheck, Level: LevelSuccess, Check: "git", Message: "git 2.39.2"},
+		{Type: EventCheck, Level: LevelError, Check: "config file", Message: "not found"},
+	}
+
+	tests := []struct {
+		name    string
+		new     func(out *bytes.Buffer) Renderer
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "text",
+			new:  func(out *bytes.Buffer) Renderer { return NewTextRenderer(out) },
+			want: []string{"Found 2 repositories", "Progress: 1/2 (50.0%)", "Failed to clone acme/api: exit status 128", "Moved repositories:", "  api", "git: git 2.39.2", "config file: not found"},
+		},
+		{
+			name:    "quiet",
+			new:     func(out *bytes.Buffer) Renderer { return NewQuietRenderer(out) },
+			want:    []string{"Failed to move api", "Failed to clone acme/api", "Failed repositories:", "config file: not found"},
+			notWant: []string{"Found 2 repositories", "Progress", "Moved repositories:", "git: git 2.39.2"},
+		},
+		{
+			name:    "ci",
+			new:     func(out *bytes.Buffer) Renderer { return NewCIRenderer(out) },
+			want:    []string{"[1/2] Syncing acme/api", "[1/2] Failed acme/api: exit status 128", "Found 2 repositories"},
+			notWant: []string{"Progress"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			renderer := tt.new(&out)
+			for _, event := range events {
+				renderer.Render(event)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q does not contain %q", out.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("output %q contains %q", out.String(), notWant)
+				}
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	renderer := NewJSONRenderer(&out)
+	for _, event := range events {
+		renderer.Render(event)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(events) {
+		t.Fatalf("JSON renderer wrote %d lines, want %d", len(lines), len(events))
+	}
+	var finished Event
+	if err := json.Unmarshal([]byte(lines[3]), &finished); err != nil || finished.Type != EventRepositoryFinished || finished.Error == "" {
+		t.Errorf("JSON line %q decoded to %+v, %v", lines[3], finished, err)
+	}
+}
+
+func TestTextRendererFollowsColorConfiguration(t *testing.T) {
+	reset, red, green, yellow, blue, cyan := colors.Reset, colors.Red, colors.Green, colors.Yellow, colors.Blue, colors.Cyan
+	t.Cleanup(func() {
+		colors.Reset, colors.Red, colors.Green, colors.Yellow, colors.Blue, colors.Cyan = reset, red, green, yellow, blue, cyan
+	})
+	colors.Configure("never", nil)
+
+	var out bytes.Buffer
+	NewTextRenderer(&out).Render(Event{Type: EventMessage, Level: LevelSuccess, Message: "done"})
+	if out.String() != "done\n" {
+		t.Errorf("output = %q, want no colors", out.String())
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+repairRun carries the state of one repair of a workspace.
+*/
+type repairRun struct {
+	git         helpers.GitRunner
+	audit       *helpers.AuditLog
+	credentials map[string]models.SourceCredentials
+	noWrite     bool
+	repaired    map[string]bool // Repositories with a fix applied, or needed with noWrite
+	failed      map[string]bool // Repositories with a fix that failed or that cannot be repaired
+}
+
+/*
+RepairWorkspace checks the clones recorded in the state of a workspace and fixes what
+interrupted runs of older versions left behind. Clones without HEAD or failing git fsck
+are cloned again from their origin with the credentials of their provider, and only once
+that clone succeeded, the broken clone is moved aside to <name>.broken-<time>, so files
+that were never committed are kept. Clones without an origin remote are left alone.
+Origin remotes that hold credentials or point at another path than the one recorded
+for the repository are rewritten, and partial clones are removed.
+With noWrite, the problems are only reported.
+*/
+func RepairWorkspace(workspace string, noWrite bool, credentials map[string]models.SourceCredentials) error {
+	return repairWorkspace(workspace, noWrite, credentials, DefaultDependencies())
+}
+
+func repairWorkspace(workspace string, noWrite bool, credentials map[string]models.SourceCredentials, deps Dependencies) error {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return err
+	}
+
+	r := &repairRun{git: deps.Git, credentials: credentials, noWrite: noWrite, repaired: map[string]bool{}, failed: map[string]bool{}}
+	if !noWrite {
+		if r.audit, err = helpers.OpenAuditLog(helpers.GetAuditLogPath(workspace)); err != nil {
+			return err
+		}
+		defer r.audit.Close()
+	}
+
+	entries := make([]models.RepositoryState, 0, len(state.Repositories))
+	for _, entry := range state.Repositories {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	for _, entry := range entries {
+		r.repairRepository(workspace, entry)
+	}
+
+	// A repository counts once, however many fixes it needed
+	repaired := 0
+	for path := range r.repaired {
+		if !r.failed[path] {
+			repaired++
+		}
+	}
+	switch {
+	case len(r.failed) > 0:
+		return fmt.Errorf("%d of %d repositories could not be repaired", len(r.failed), len(entries))
+	case repaired == 0:
+		Notify(LevelSuccess, "No problems found in %d repositories", len(entries))
+	case noWrite:
+		Notify(LevelNotice, "%d of %d repositories need repairs", repaired, len(entries))
+	default:
+		Notify(LevelSuccess, "Repaired %d of %d repositories", repaired, len(entries))
+	}
+	return nil
+}
+
+/*
+repairRepository checks and fixes the clone of one state entry.
+Missing clones are left to the next sync, and directories that are no git
+repository at all are reported but never removed, as they may hold local work.
+*/
+func (r *repairRun) repairRepository(workspace string, entry models.RepositoryState) {
+	dir := filepath.Join(workspace, filepath.FromSlash(entry.Path))
+
+	if tmpPath := helpers.CloneTempPath(dir); directoryExists(tmpPath) {
+		r.fix(entry.Path, "Remove partial clone", entry.Path, func() error {
+			err := helpers.RemoveDirectory(tmpPath)
+			r.audit.Record("remove-partial-clone", tmpPath, "", err)
+			return err
+		})
+	}
+	if !directoryExists(dir) {
+		return
+	}
+	if !directoryExists(filepath.Join(dir, ".git")) {
+		Notify(LevelWarning, "Not a git repository, left alone: %s", entry.Path)
+		r.failed[entry.Path] = true
+		return
+	}
+
+	remote, err := helpers.ConfiguredOriginURL(r.git, dir)
+	if err != nil {
+		remote = ""
+	}
+	repairedRemote := repairedRemoteURL(entry, remote)
+
+	if problem := helpers.CloneProblem(r.git, dir); problem != "" {
+		if repairedRemote == "" {
+			Notify(LevelWarning, "No origin remote to clone from, left alone: %s (%s)", entry.Path, problem)
+			r.failed[entry.Path] = true
+			return
+		}
+		r.fix(entry.Path, "Re-clone", entry.Path+" ("+problem+")", func() error {
+			return r.reclone(entry, dir, repairedRemote, problem)
+		})
+		return
+	}
+
+	if repairedRemote != remote {
+		change := "remove credentials"
+		if previous := helpers.StripURLCredentials(remote); previous != repairedRemote {
+			change = previous + " -> " + repairedRemote
+		}
+		r.fix(entry.Path, "Fix remote of "+entry.Path, change, func() error {
+			err := helpers.SetRemoteURL(r.git, dir, repairedRemote)
+			r.audit.Record("set-remote", entry.Path, repairedRemote, err)
+			return err
+		})
+	}
+}
+
+/*
+reclone clones remote next to the broken clone in dir and swaps the two once the clone
+succeeded. The broken clone is renamed to <name>.broken-<time> instead of being removed,
+as its working tree may hold changes that were never committed.
+*/
+func (r *repairRun) reclone(entry models.RepositoryState, dir, remote, problem string) error {
+	stamp := time.Now().Format("20060102-150405")
+	fresh := dir + ".repair-" + stamp
+	creds := r.credentials[entry.Provider]
+	helpers.SetCloneUsername(creds.CloneUsername)
+
+	err := helpers.CloneRepository(r.git, io.Discard, nil, remote, filepath.Dir(fresh), filepath.Base(fresh), creds.Token)
+	r.audit.Record("clone", entry.Path, remote, err)
+	if err != nil {
+		return fmt.Errorf("%w; the broken clone was left as it is", err)
+	}
+
+	broken := dir + ".broken-" + stamp
+	err = os.Rename(dir, broken)
+	r.audit.Record("set-aside-broken-clone", entry.Path, broken, err)
+	if err != nil {
+		helpers.RemoveDirectory(fresh)
+		return fmt.Errorf("failed to move the broken clone (%s) aside: %w", problem, err)
+	}
+	if err := os.Rename(fresh, dir); err != nil {
+		os.Rename(broken, dir)
+		helpers.RemoveDirectory(fresh)
+		return fmt.Errorf("failed to move the new clone into place: %w", err)
+	}
+	Notify(LevelNotice, "The broken clone of %s was kept in %s", entry.Path, broken)
+	return nil
+}
+
+/*
+fix reports one repair of the repository at path and applies it unless noWrite is set.
+*/
+func (r *repairRun) fix(path, action, subject string, apply func() error) {
+	if r.noWrite {
+		r.repaired[path] = true
+		Notify(LevelNotice, "Would %s: %s", strings.ToLower(action[:1])+action[1:], subject)
+		return
+	}
+	if err := apply(); err != nil {
+		r.failed[path] = true
+		Notify(LevelError, "Failed to %s: %s: %s", strings.ToLower(action[:1])+action[1:], subject, helpers.Redact(err.Error()))
+		return
+	}
+	r.repaired[path] = true
+	Notify(LevelSuccess, "%s: %s", action, subject)
+}
+
+/*
+repairedRemoteURL returns the origin URL a clone should have: without credentials and,
+for GitLab and GitHub, whose URL paths are the repository's remote path, pointing at the
+remote path recorded in the state. The host is kept, so SSH aliases and URL rewrites survive.
+Returns "" for an empty remote.
+*/
+func repairedRemoteURL(entry models.RepositoryState, remote string) string {
+	if remote == "" {
+		return ""
+	}
+	repaired := helpers.StripURLCredentials(remote)
+	if entry.RemotePath == "" || (entry.Provider != "gitlab" && entry.Provider != "github") {
+		return repaired
+	}
+
+	prefix, remotePath := splitRemoteURL(repaired)
+	if prefix == "" {
+		return repaired // Local path
+	}
+	current := strings.ToLower(strings.TrimSuffix(strings.Trim(remotePath, "/"), ".git"))
+	want := strings.ToLower(entry.RemotePath)
+	if current == want || strings.HasSuffix(current, "/"+want) {
+		return repaired
+	}
+	if strings.HasPrefix(remotePath, "/") {
+		return prefix + "/" + entry.RemotePath + ".git"
+	}
+	return prefix + entry.RemotePath + ".git"
+}
+
+/*
+splitRemoteURL splits a remote URL into everything up to its path and the path,
+for URLs (https://host/a/b.git) as well as scp-like SSH remotes (git@host:a/b.git).
+The prefix is empty for local paths and file:// URLs.
+*/
+func splitRemoteURL(remote string) (string, string) {
+	if scheme, rest, ok := strings.Cut(remote, "://"); ok {
+		host, _, _ := strings.Cut(rest, "/")
+		if scheme == "file" || host == "" {
+			return "", remote
+		}
+		prefix := scheme + "://" + host
+		return prefix, strings.TrimPrefix(remote, prefix)
+	}
+	if i := strings.Index(remote, ":"); i > 1 && !strings.Contains(remote[:i], "/") {
+		return remote[:i+1], remote[i+1:]
+	}
+	return "", remote
+}
+
+/*
+directoryExists reports whether path exists and is a directory.
+*/
+func directoryExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
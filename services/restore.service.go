@@ -0,0 +1,684 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// ErrPartialRestore is returned when a restore completed but some repositories could not be restored.
+var ErrPartialRestore = errors.New("some repositories could not be restored")
+
+// Project settings copied from a GitLab snapshot; everything else is instance-specific or read-only
+var gitLabRestoredSettings = []string{
+	"description", "merge_method", "squash_option", "merge_requests_enabled", "issues_enabled", "wiki_enabled",
+	"only_allow_merge_if_pipeline_succeeds", "only_allow_merge_if_all_discussions_are_resolved",
+	"allow_merge_on_skipped_pipeline", "remove_source_branch_after_merge", "merge_commit_template",
+	"squash_commit_template", "ci_config_path", "printing_merge_request_link_enabled",
+}
+
+// Repository settings copied from a GitHub snapshot
+var gitHubRestoredSettings = []string{
+	"description", "homepage", "has_issues", "has_projects", "has_wiki", "has_discussions",
+	"allow_merge_commit", "allow_squash_merge", "allow_rebase_merge", "allow_auto_merge",
+	"allow_update_branch", "delete_branch_on_merge", "squash_merge_commit_title",
+	"squash_merge_commit_message", "merge_commit_title", "merge_commit_message", "web_commit_signoff_required",
+}
+
+// Branch protection toggles GitHub reports as {"enabled": bool} but expects as plain booleans
+var gitHubProtectionToggles = []string{
+	"required_linear_history", "allow_force_pushes", "allow_deletions",
+	"required_conversation_resolution", "block_creations", "lock_branch", "allow_fork_syncing",
+}
+
+/*
+restoreItem is a repository of a backup set together with its snapshots.
+Location is where it goes below the target group or organization.
+*/
+type restoreItem struct {
+	entry     models.RepositoryState
+	clonePath string
+	location  []string
+	settings  *models.RepositorySettings
+	ci        *models.CIConfigSnapshot
+}
+
+/*
+restoreRun carries the settings and results of a single restore.
+Manual collects what cannot be restored through the APIs, such as
+webhook secrets and runner registrations, for the final summary.
+*/
+type restoreRun struct {
+	provider string
+	options  models.SyncOptions
+	deps     Dependencies
+	api      providerAPI
+	restored []string
+	failed   []string
+	manual   []string
+}
+
+/*
+RestoreGitLabGroup restores a backup set into a GitLab group.
+The backup set is a workspace synced by reposync (options.BaseDir): its clones are pushed
+to projects created below the group, recreating the subgroup structure, and the
+settings and CI/CD snapshots taken with --with-settings and --with-ci-config are applied.
+*/
+func RestoreGitLabGroup(groupID int, options models.SyncOptions) error {
+	return restoreGitLabGroup(groupID, options, DefaultDependencies())
+}
+
+/*
+RestoreGitHubOrganization restores a backup set into a GitHub organization.
+Repositories are created flat in the organization, as GitHub has no nested namespaces.
+*/
+func RestoreGitHubOrganization(org string, options models.SyncOptions) error {
+	return restoreGitHubOrganization(org, options, DefaultDependencies())
+}
+
+/*
+loadRestoreItems reads the repositories of a backup set from its state file.
+A GitLab group backup places every clone below a directory named after the synced
+group, which is dropped so the repositories land directly in the target group.
+*/
+func loadRestoreItems(backupDir string) ([]restoreItem, error) {
+	state, err := helpers.LoadStateSnapshot(helpers.GetStatePath(backupDir))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a reposync backup: %w", backupDir, err)
+	}
+
+	items := make([]restoreItem, 0, len(state.Repositories))
+	for _, entry := range state.Repositories {
+		location := strings.Split(entry.Path, "/")
+		if entry.Provider == "gitlab" && len(location) > 1 {
+			location = location[1:]
+		}
+		item := restoreItem{
+			entry:     entry,
+			clonePath: filepath.Join(backupDir, filepath.FromSlash(entry.Path)),
+			location:  location,
+		}
+
+		var settings models.RepositorySettings
+		if found, err := readSnapshot(helpers.GetSettingsPath(backupDir, entry.Path), &settings); err != nil {
+			return nil, err
+		} else if found {
+			item.settings = &settings
+		}
+		var ci models.CIConfigSnapshot
+		if found, err := readSnapshot(helpers.GetCIConfigPath(backupDir, "projects", entry.Path), &ci); err != nil {
+			return nil, err
+		} else if found {
+			item.ci = &ci
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].entry.Path < items[j].entry.Path })
+	return items, nil
+}
+
+/*
+readSnapshot decodes a JSON snapshot of the backup set.
+Returns false when the snapshot was not taken.
+*/
+func readSnapshot(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+/*
+restoreRepository runs the provider-specific restore of one repository and records the result.
+With NoWrite only the plan is printed.
+*/
+func (r *restoreRun) restoreRepository(item restoreItem, target string, restore func() error) {
+	if _, err := os.Stat(filepath.Join(item.clonePath, ".git")); err != nil {
+		Notify(LevelError, "Cannot restore %s: no clone at %s", item.entry.Path, item.clonePath)
+		r.failed = append(r.failed, item.entry.Path)
+		return
+	}
+	if r.options.NoWrite {
+		Notify(LevelNotice, "Would restore: %s -> %s", item.entry.Path, target)
+		return
+	}
+
+	Notify(LevelInfo, "Restoring %s -> %s", item.entry.Path, target)
+	if err := restore(); err != nil {
+		Notify(LevelError, "Failed to restore %s: %s", item.entry.Path, helpers.Redact(err.Error()))
+		r.failed = append(r.failed, item.entry.Path)
+		return
+	}
+	r.restored = append(r.restored, target)
+}
+
+/*
+pushRepository pushes a clone to its restored repository and returns the default branch.
+The default branch comes from the settings snapshot or, without one, from the clone.
+*/
+func (r *restoreRun) pushRepository(item restoreItem, httpsURL, sshURL string) (string, error) {
+	repoURL := helpers.GetPreferredRepositoryURL(httpsURL, sshURL, r.options.CloneMethod)
+	if err := helpers.PushRepository(r.deps.Git, item.clonePath, repoURL, r.options.Token); err != nil {
+		return "", err
+	}
+	if item.settings != nil && item.settings.DefaultBranch != "" {
+		return item.settings.DefaultBranch, nil
+	}
+	return helpers.GetDefaultBranch(r.deps.Git, item.clonePath)
+}
+
+/*
+addManual records a step that has to be done by hand after the restore.
+*/
+func (r *restoreRun) addManual(format string, args ...any) {
+	r.manual = append(r.manual, fmt.Sprintf(format, args...))
+}
+
+/*
+finish prints the restore summary.
+Returns ErrPartialRestore when repositories failed.
+*/
+func (r *restoreRun) finish(total int) error {
+	if r.options.NoWrite {
+		return nil
+	}
+	Notify(LevelSuccess, "Restored %d of %d repositories", len(r.restored), total)
+	var sections []SummarySection
+	if len(r.failed) > 0 {
+		sections = append(sections, SummarySection{Kind: "failed", Title: "Failed repositories:", Entries: r.failed, Problem: true})
+	}
+	if len(r.manual) > 0 {
+		sections = append(sections, SummarySection{Kind: "manual", Title: "To do by hand:", Entries: r.manual, Problem: true})
+	}
+	if len(sections) > 0 {
+		report(Event{Type: EventSummary, Summary: sections})
+	}
+	if len(r.failed) > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrPartialRestore, len(r.failed), total)
+	}
+	return nil
+}
+
+/*
+copySettings copies the given keys that are present in a snapshot section.
+*/
+func copySettings(source map[string]any, keys []string) map[string]any {
+	copied := map[string]any{}
+	for _, key := range keys {
+		if value, ok := source[key]; ok {
+			copied[key] = value
+		}
+	}
+	return copied
+}
+
+/*
+restoreGitLabGroup implements RestoreGitLabGroup on top of injectable dependencies.
+*/
+func restoreGitLabGroup(groupID int, options models.SyncOptions, deps Dependencies) error {
+	items, err := loadRestoreItems(options.BaseDir)
+	if err != nil {
+		return err
+	}
+	run := &restoreRun{provider: "gitlab", options: options, deps: deps, api: newProviderAPI(options, deps)}
+	root, err := getGitLabGroupInfo(run.api, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch target group: %w", err)
+	}
+	Notify(LevelInfo, "Restoring %d repositories into GitLab group %s", len(items), root.FullPath)
+
+	groups := map[string]int{"": groupID} // Subgroup path below the target group → ID
+	for _, item := range items {
+		target := root.FullPath + "/" + strings.Join(item.location, "/")
+		run.restoreRepository(item, target, func() error {
+			namespaceID, err := run.ensureGitLabSubgroups(groups, root.FullPath, item.location[:len(item.location)-1])
+			if err != nil {
+				return err
+			}
+			project, err := run.ensureGitLabProject(namespaceID, target, item)
+			if err != nil {
+				return err
+			}
+			defaultBranch, err := run.pushRepository(item, project.HTTPSURL, project.SSHURL)
+			if err != nil {
+				return err
+			}
+			if err := run.restoreGitLabSettings(project.ID, target, defaultBranch, item.settings); err != nil {
+				return err
+			}
+			return run.restoreGitLabCIConfig(fmt.Sprintf("/projects/%d", project.ID), target, item.ci)
+		})
+	}
+
+	if !options.NoWrite {
+		run.restoreGitLabGroupCIConfig(groups, root.FullPath)
+		if _, err := os.Stat(helpers.GetAccessSnapshotPath(options.BaseDir)); err == nil {
+			run.addManual("grant access to members and teams as recorded in %s", helpers.GetAccessSnapshotPath(options.BaseDir))
+		}
+	}
+	return run.finish(len(items))
+}
+
+/*
+ensureGitLabSubgroups returns the ID of the subgroup at path below the target group,
+creating missing subgroups along the way. In NoWrite mode nothing is created.
+*/
+func (r *restoreRun) ensureGitLabSubgroups(groups map[string]int, rootPath string, path []string) (int, error) {
+	parentID := groups[""]
+	for i, segment := range path {
+		relPath := strings.Join(path[:i+1], "/")
+		if id, ok := groups[relPath]; ok {
+			parentID = id
+			continue
+		}
+
+		var group models.GitLabGroup
+		fullPath := rootPath + "/" + relPath
+		err := r.api.getJSON(helpers.GetGitLabAPIURL(r.api.baseURL, "/groups/"+url.PathEscape(fullPath)), &group)
+		if client.IsNotFound(err) {
+			body := map[string]any{"name": segment, "path": segment, "parent_id": parentID}
+			err = r.api.send("POST", helpers.GetGitLabAPIURL(r.api.baseURL, "/groups"), body, &group)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to create subgroup %s: %w", fullPath, err)
+		}
+		groups[relPath] = group.ID
+		parentID = group.ID
+	}
+	return parentID, nil
+}
+
+/*
+ensureGitLabProject returns the project at fullPath, creating it when it does not exist yet,
+so an interrupted restore can be run again.
+*/
+func (r *restoreRun) ensureGitLabProject(namespaceID int, fullPath string, item restoreItem) (*models.GitLabRepository, error) {
+	var project models.GitLabRepository
+	err := r.api.getJSON(helpers.GetGitLabAPIURL(r.api.baseURL, "/projects/"+url.PathEscape(fullPath)), &project)
+	if err == nil {
+		return &project, nil
+	}
+	if !client.IsNotFound(err) {
+		return nil, err
+	}
+
+	body := map[string]any{
+		"name":         item.entry.Name,
+		"path":         item.location[len(item.location)-1],
+		"namespace_id": namespaceID,
+		"description":  item.entry.Description,
+	}
+	if item.settings != nil {
+		if visibility, ok := item.settings.Repository["visibility"].(string); ok {
+			body["visibility"] = visibility
+		}
+	}
+	if err := r.api.send("POST", helpers.GetGitLabAPIURL(r.api.baseURL, "/projects"), body, &project); err != nil {
+		return nil, fmt.Errorf("failed to create project %s: %w", fullPath, err)
+	}
+	return &project, nil
+}
+
+/*
+restoreGitLabSettings applies the default branch and, from a GitLab snapshot,
+the project settings, protected branches and webhooks.
+*/
+func (r *restoreRun) restoreGitLabSettings(projectID int64, target, defaultBranch string, settings *models.RepositorySettings) error {
+	projectURL := helpers.GetGitLabAPIURL(r.api.baseURL, fmt.Sprintf("/projects/%d", projectID))
+	update := map[string]any{}
+	if settings != nil && settings.Provider == "gitlab" {
+		update = copySettings(settings.Repository, gitLabRestoredSettings)
+	} else if settings != nil {
+		r.addManual("%s: settings were taken on %s and cannot be applied on GitLab", target, settings.Provider)
+	}
+	if defaultBranch != "" {
+		update["default_branch"] = defaultBranch
+	}
+	if err := r.api.send("PUT", projectURL, update, nil); err != nil {
+		return fmt.Errorf("failed to update project settings: %w", err)
+	}
+	if settings == nil || settings.Provider != "gitlab" {
+		return nil
+	}
+
+	for name, value := range settings.BranchProtection {
+		rule, _ := value.(map[string]any)
+		body := map[string]any{
+			"name":                         name,
+			"push_access_level":            firstAccessLevel(rule["push_access_levels"]),
+			"merge_access_level":           firstAccessLevel(rule["merge_access_levels"]),
+			"unprotect_access_level":       firstAccessLevel(rule["unprotect_access_levels"]),
+			"allow_force_push":             rule["allow_force_push"],
+			"code_owner_approval_required": rule["code_owner_approval_required"],
+		}
+		// New projects protect their default branch, replace that rule with the backed up one
+		protectedURL := projectURL + "/protected_branches"
+		r.api.send("DELETE", protectedURL+"/"+url.PathEscape(name), nil, nil)
+		if err := r.api.send("POST", protectedURL, body, nil); err != nil {
+			return fmt.Errorf("failed to protect branch %s: %w", name, err)
+		}
+	}
+
+	existing, err := fetchGitLabKeysetPages(r.api, fmt.Sprintf("/projects/%d/hooks", projectID), "", jsonObjectID)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, hook := range settings.Webhooks {
+		if hasWebhook(existing, hook["url"]) {
+			continue
+		}
+		body := map[string]any{}
+		for key, value := range hook {
+			if key != "id" && key != "project_id" && key != "created_at" {
+				body[key] = value
+			}
+		}
+		if err := r.api.send("POST", projectURL+"/hooks", body, nil); err != nil {
+			return fmt.Errorf("failed to create webhook %v: %w", hook["url"], err)
+		}
+		r.addManual("%s: set the secret token of webhook %v", target, hook["url"])
+	}
+	return nil
+}
+
+/*
+restoreGitLabCIConfig recreates the CI/CD variables and pipeline schedules of a project
+or group endpoint (e.g. /projects/1). Variables are only created when the snapshot includes
+their values; runners have to be registered again.
+*/
+func (r *restoreRun) restoreGitLabCIConfig(endpoint, target string, snapshot *models.CIConfigSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+	baseURL := helpers.GetGitLabAPIURL(r.api.baseURL, endpoint)
+
+	if len(snapshot.Variables) > 0 && !snapshot.ValuesIncluded {
+		r.addManual("%s: recreate %d CI/CD variables, their values were not backed up", target, len(snapshot.Variables))
+	}
+	for _, variable := range snapshot.Variables {
+		if !snapshot.ValuesIncluded {
+			break
+		}
+		if err := r.api.send("POST", baseURL+"/variables", variable, nil); err != nil {
+			return fmt.Errorf("failed to create variable %v: %w", variable["key"], err)
+		}
+	}
+
+	for _, schedule := range snapshot.PipelineSchedules {
+		body := copySettings(schedule, []string{"description", "ref", "cron", "cron_timezone", "active"})
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if err := r.api.send("POST", baseURL+"/pipeline_schedules", body, &created); err != nil {
+			return fmt.Errorf("failed to create pipeline schedule %v: %w", schedule["description"], err)
+		}
+		variables, _ := schedule["variables"].([]any)
+		if len(variables) > 0 && !snapshot.ValuesIncluded {
+			r.addManual("%s: recreate %d variables of pipeline schedule %v", target, len(variables), schedule["description"])
+			continue
+		}
+		for _, variable := range anyObjects(variables) {
+			scheduleURL := fmt.Sprintf("%s/pipeline_schedules/%d/variables", baseURL, created.ID)
+			if err := r.api.send("POST", scheduleURL, copySettings(variable, []string{"key", "value", "variable_type"}), nil); err != nil {
+				return fmt.Errorf("failed to create variable %v of pipeline schedule %v: %w", variable["key"], schedule["description"], err)
+			}
+		}
+	}
+
+	if len(snapshot.Runners) > 0 {
+		r.addManual("%s: register %d runners again (see the backed up runner settings)", target, len(snapshot.Runners))
+	}
+	return nil
+}
+
+/*
+restoreGitLabGroupCIConfig recreates the CI/CD variables of the backed up groups
+whose counterparts exist in the target group. The backed up root group is the
+snapshot with the shortest path; its subgroups map to the restored subgroups.
+*/
+func (r *restoreRun) restoreGitLabGroupCIConfig(groups map[string]int, rootPath string) {
+	groupsDir := filepath.Join(r.options.BaseDir, ".reposync", "ci", "groups")
+	var snapshots []models.CIConfigSnapshot
+	filepath.WalkDir(groupsDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		var snapshot models.CIConfigSnapshot
+		if found, err := readSnapshot(path, &snapshot); err == nil && found {
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if len(snapshots) == 0 {
+		return
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return len(snapshots[i].Path) < len(snapshots[j].Path) })
+
+	sourceRoot := snapshots[0].Path
+	for _, snapshot := range snapshots {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(snapshot.Path, sourceRoot), "/")
+		target := strings.TrimSuffix(rootPath+"/"+relPath, "/")
+		id, ok := groups[relPath]
+		if !ok {
+			r.addManual("%s: group not restored, its CI/CD configuration was skipped", target)
+			continue
+		}
+		if err := r.restoreGitLabCIConfig(fmt.Sprintf("/groups/%d", id), target, &snapshot); err != nil {
+			Notify(LevelError, "Failed to restore CI/CD configuration of %s: %s", target, helpers.Redact(err.Error()))
+			r.addManual("%s: restore the CI/CD configuration by hand (%v)", target, err)
+		}
+	}
+}
+
+/*
+firstAccessLevel returns the role-based access level of a GitLab protected branch rule.
+Rules for individual users or groups cannot be mapped onto another instance and are skipped.
+*/
+func firstAccessLevel(levels any) any {
+	for _, level := range anyObjects(asSlice(levels)) {
+		if level["user_id"] == nil && level["group_id"] == nil {
+			return level["access_level"]
+		}
+	}
+	return nil
+}
+
+/*
+asSlice returns a decoded JSON array, or nil for other values.
+*/
+func asSlice(value any) []any {
+	values, _ := value.([]any)
+	return values
+}
+
+/*
+hasWebhook reports whether a webhook with the given URL already exists.
+*/
+func hasWebhook(hooks []map[string]any, hookURL any) bool {
+	for _, hook := range hooks {
+		if hook["url"] == hookURL {
+			return true
+		}
+		if config, ok := hook["config"].(map[string]any); ok && config["url"] == hookURL {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+restoreGitHubOrganization implements RestoreGitHubOrganization on top of injectable dependencies.
+*/
+func restoreGitHubOrganization(org string, options models.SyncOptions, deps Dependencies) error {
+	if err := helpers.ValidateOrganizationName(org); err != nil {
+		return fmt.Errorf("invalid organization name: %w", err)
+	}
+	items, err := loadRestoreItems(options.BaseDir)
+	if err != nil {
+		return err
+	}
+	run := &restoreRun{provider: "github", options: options, deps: deps, api: newProviderAPI(options, deps)}
+	Notify(LevelInfo, "Restoring %d repositories into GitHub organization %s", len(items), org)
+
+	for _, item := range items {
+		name := item.location[len(item.location)-1]
+		target := org + "/" + name
+		run.restoreRepository(item, target, func() error {
+			repository, err := run.ensureGitHubRepository(org, name, item)
+			if err != nil {
+				return err
+			}
+			defaultBranch, err := run.pushRepository(item, repository.HTTPSURL, repository.SSHURL)
+			if err != nil {
+				return err
+			}
+			return run.restoreGitHubSettings(target, defaultBranch, item.settings)
+		})
+		if item.ci != nil && !options.NoWrite {
+			run.addManual("%s: GitLab CI/CD configuration cannot be restored on GitHub", target)
+		}
+	}
+
+	if !options.NoWrite {
+		if _, err := os.Stat(helpers.GetAccessSnapshotPath(options.BaseDir)); err == nil {
+			run.addManual("grant access to members and teams as recorded in %s", helpers.GetAccessSnapshotPath(options.BaseDir))
+		}
+	}
+	return run.finish(len(items))
+}
+
+/*
+ensureGitHubRepository returns the repository org/name, creating it when it does not exist yet.
+*/
+func (r *restoreRun) ensureGitHubRepository(org, name string, item restoreItem) (*models.GitHubRepository, error) {
+	var repository models.GitHubRepository
+	err := r.api.getJSON(helpers.GetGitHubAPIURL(r.api.baseURL, "/repos/"+org+"/"+name), &repository)
+	if err == nil {
+		return &repository, nil
+	}
+	if !client.IsNotFound(err) {
+		return nil, err
+	}
+
+	body := map[string]any{"name": name, "description": item.entry.Description, "private": true}
+	if item.settings != nil {
+		if visibility, ok := item.settings.Repository["visibility"].(string); ok {
+			body["visibility"] = visibility
+			body["private"] = visibility != "public"
+		}
+	}
+	if err := r.api.send("POST", helpers.GetGitHubAPIURL(r.api.baseURL, "/orgs/"+org+"/repos"), body, &repository); err != nil {
+		return nil, fmt.Errorf("failed to create repository %s/%s: %w", org, name, err)
+	}
+	return &repository, nil
+}
+
+/*
+restoreGitHubSettings applies the default branch and, from a GitHub snapshot,
+the repository settings, branch protection rules and webhooks.
+*/
+func (r *restoreRun) restoreGitHubSettings(target, defaultBranch string, settings *models.RepositorySettings) error {
+	repositoryURL := helpers.GetGitHubAPIURL(r.api.baseURL, "/repos/"+target)
+	update := map[string]any{}
+	if settings != nil && settings.Provider == "github" {
+		update = copySettings(settings.Repository, gitHubRestoredSettings)
+	} else if settings != nil {
+		r.addManual("%s: settings were taken on %s and cannot be applied on GitHub", target, settings.Provider)
+	}
+	if defaultBranch != "" {
+		update["default_branch"] = defaultBranch
+	}
+	if err := r.api.send("PATCH", repositoryURL, update, nil); err != nil {
+		return fmt.Errorf("failed to update repository settings: %w", err)
+	}
+	if settings == nil || settings.Provider != "github" {
+		return nil
+	}
+	if archived, _ := settings.Repository["archived"].(bool); archived {
+		r.addManual("%s: archive the repository", target)
+	}
+
+	for branch, value := range settings.BranchProtection {
+		protection, _ := value.(map[string]any)
+		if protection["restrictions"] != nil {
+			r.addManual("%s: restrict who can push to %s", target, branch)
+		}
+		protectionURL := fmt.Sprintf("%s/branches/%s/protection", repositoryURL, url.PathEscape(branch))
+		if err := r.api.send("PUT", protectionURL, gitHubProtectionRequest(protection), nil); err != nil {
+			return fmt.Errorf("failed to protect branch %s: %w", branch, err)
+		}
+	}
+
+	existing, err := fetchGitHubPages[map[string]any](r.api, "/repos/"+target+"/hooks")
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, hook := range settings.Webhooks {
+		config, _ := hook["config"].(map[string]any)
+		if hasWebhook(existing, config["url"]) {
+			continue
+		}
+		body := map[string]any{
+			"name":   "web",
+			"active": hook["active"],
+			"events": hook["events"],
+			"config": copySettings(config, []string{"url", "content_type", "insecure_ssl"}),
+		}
+		if err := r.api.send("POST", repositoryURL+"/hooks", body, nil); err != nil {
+			return fmt.Errorf("failed to create webhook %v: %w", config["url"], err)
+		}
+		r.addManual("%s: set the secret of webhook %v", target, config["url"])
+	}
+	return nil
+}
+
+/*
+gitHubProtectionRequest converts a branch protection rule as returned by the API
+into the request that creates it. Push restrictions name users and teams that may
+not exist in the target organization and are left for manual review.
+*/
+func gitHubProtectionRequest(protection map[string]any) map[string]any {
+	request := map[string]any{
+		"required_status_checks":        nil,
+		"enforce_admins":                false,
+		"required_pull_request_reviews": nil,
+		"restrictions":                  nil,
+	}
+	if checks, ok := protection["required_status_checks"].(map[string]any); ok {
+		contexts := checks["contexts"]
+		if contexts == nil {
+			contexts = []any{}
+		}
+		request["required_status_checks"] = map[string]any{"strict": checks["strict"], "contexts": contexts}
+	}
+	if enforceAdmins, ok := protection["enforce_admins"].(map[string]any); ok {
+		request["enforce_admins"] = enforceAdmins["enabled"]
+	}
+	if reviews, ok := protection["required_pull_request_reviews"].(map[string]any); ok {
+		request["required_pull_request_reviews"] = copySettings(reviews, []string{
+			"dismiss_stale_reviews", "require_code_owner_reviews", "required_approving_review_count", "require_last_push_approval",
+		})
+	}
+	for _, toggle := range gitHubProtectionToggles {
+		if value, ok := protection[toggle].(map[string]any); ok {
+			request[toggle] = value["enabled"]
+		}
+	}
+	return request
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+scanCollector gathers secret scanner results from the workers of a run.
+*/
+type scanCollector struct {
+	mu      sync.Mutex
+	results []models.RepositoryScan
+}
+
+/*
+scanRepository runs the configured secret scanner over a clone.
+Runs inside the sync workers, so repositories are scanned in parallel.
+*/
+func (r *syncRun) scanRepository(target syncTarget) {
+	command := r.options.ScanCommand
+	if len(command) == 0 {
+		command = helpers.DefaultScanCommand
+	}
+
+	result := helpers.ScanRepository(command, target.Path)
+	result.Path = r.relativePath(target.Path)
+	if result.Error != "" {
+		r.notify(LevelError, "Secret scan of %s failed: %s", result.Path, result.Error)
+	} else if len(result.Findings) > 0 {
+		r.notify(LevelWarning, "Secret scan of %s: %d findings", result.Path, len(result.Findings))
+		r.summary.addScanFindings(result.Path, len(result.Findings))
+	}
+
+	r.scans.mu.Lock()
+	r.scans.results = append(r.scans.results, result)
+	r.scans.mu.Unlock()
+}
+
+/*
+writeScanReport saves the aggregated scanner findings of the run.
+*/
+func (r *syncRun) writeScanReport() error {
+	r.scans.mu.Lock()
+	report := models.ScanReport{
+		Generated:    time.Now().UTC(),
+		Command:      r.options.ScanCommand,
+		Repositories: append([]models.RepositoryScan{}, r.scans.results...),
+	}
+	r.scans.mu.Unlock()
+
+	if len(report.Command) == 0 {
+		report.Command = helpers.DefaultScanCommand
+	}
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Path < report.Repositories[j].Path
+	})
+
+	err := helpers.WriteJSONReport(r.options.ScanReportPath, report)
+	r.audit.Record("write-scan-report", r.options.ScanReportPath, "", err)
+	if err != nil {
+		return err
+	}
+	r.notify(LevelSuccess, "Wrote secret scan report for %d repositories to %s", len(report.Repositories), r.options.ScanReportPath)
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+exportSettings saves the configuration snapshot of a repository.
+Written to .reposync/settings/<path>.json next to the state file, one file per
+repository, so the snapshots can be committed and diffed between runs.
+*/
+func (r *syncRun) exportSettings(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+	settings := models.RepositorySettings{
+		Provider:      r.provider,
+		RemotePath:    target.RemotePath,
+		DefaultBranch: target.DefaultBranch,
+		Errors:        map[string]string{},
+	}
+	if r.provider == "gitlab" {
+		collectGitLabSettings(r.api, target.ID, &settings)
+	} else {
+		collectGitHubSettings(r.api, target.RemotePath, &settings)
+	}
+	for section, reason := range settings.Errors {
+		r.notify(LevelWarning, "Skipping %s settings of %s: %s", section, relPath, reason)
+	}
+
+	path := helpers.GetSettingsPath(r.options.BaseDir, relPath)
+	err := helpers.WriteJSONReport(path, settings)
+	r.audit.Record("write-settings", relPath, "", err)
+	if err != nil {
+		r.notify(LevelError, "Failed to write settings of %s: %v", relPath, err)
+	}
+}
+
+/*
+collectGitHubSettings reads the repository settings, branch protection rules,
+webhooks and direct collaborators of a GitHub repository. Protection rules and
+webhooks require admin access to the repository; missing sections are recorded
+in settings.Errors.
+*/
+func collectGitHubSettings(api providerAPI, fullName string, settings *models.RepositorySettings) {
+	repository := map[string]any{}
+	if err := api.getJSON(helpers.GetGitHubAPIURL(api.baseURL, "/repos/"+fullName), &repository); err != nil {
+		settings.Errors["repository"] = err.Error()
+	} else {
+		settings.Repository = helpers.StripVolatileFields(repository)
+	}
+
+	branches, err := fetchGitHubPages[struct {
+		Name string `json:"name"`
+	}](api, "/repos/"+fullName+"/branches?protected=true")
+	if err != nil {
+		settings.Errors["branch_protection"] = err.Error()
+	} else {
+		settings.BranchProtection = map[string]any{}
+		for _, branch := range branches {
+			protection := map[string]any{}
+			endpoint := fmt.Sprintf("/repos/%s/branches/%s/protection", fullName, url.PathEscape(branch.Name))
+			if err := api.getJSON(helpers.GetGitHubAPIURL(api.baseURL, endpoint), &protection); err != nil {
+				settings.Errors["branch_protection"] = err.Error()
+				continue
+			}
+			settings.BranchProtection[branch.Name] = protection
+		}
+	}
+
+	hooks, err := fetchGitHubPages[map[string]any](api, "/repos/"+fullName+"/hooks")
+	if err != nil {
+		settings.Errors["webhooks"] = err.Error()
+	} else {
+		settings.Webhooks = stripVolatileObjects(hooks)
+	}
+
+	if settings.Members, err = getGitHubCollaborators(api, fullName); err != nil {
+		settings.Errors["members"] = err.Error()
+	}
+}
+
+/*
+collectGitLabSettings reads the project settings, protected branches,
+webhooks and direct members of a GitLab project. Webhooks require the
+Maintainer role; missing sections are recorded in settings.Errors.
+*/
+func collectGitLabSettings(api providerAPI, projectID int64, settings *models.RepositorySettings) {
+	project := map[string]any{}
+	if err := api.getJSON(helpers.GetGitLabAPIURL(api.baseURL, fmt.Sprintf("/projects/%d", projectID)), &project); err != nil {
+		settings.Errors["repository"] = err.Error()
+	} else {
+		settings.Repository = helpers.StripVolatileFields(project)
+	}
+
+	branches, err := fetchGitLabKeysetPages(api, fmt.Sprintf("/projects/%d/protected_branches", projectID), "", jsonObjectID)
+	if err != nil {
+		settings.Errors["branch_protection"] = err.Error()
+	} else {
+		settings.BranchProtection = map[string]any{}
+		for _, branch := range branches {
+			name, _ := branch["name"].(string)
+			settings.BranchProtection[name] = branch
+		}
+	}
+
+	hooks, err := fetchGitLabKeysetPages(api, fmt.Sprintf("/projects/%d/hooks", projectID), "", jsonObjectID)
+	if err != nil {
+		settings.Errors["webhooks"] = err.Error()
+	} else {
+		settings.Webhooks = stripVolatileObjects(hooks)
+	}
+
+	if settings.Members, err = getGitLabMembers(api, fmt.Sprintf("/projects/%d/members", projectID)); err != nil {
+		settings.Errors["members"] = err.Error()
+	}
+}
+
+/*
+jsonObjectID returns the numeric "id" field of a decoded JSON object.
+*/
+func jsonObjectID(object map[string]any) int64 {
+	id, _ := object["id"].(float64)
+	return int64(id)
+}
+
+/*
+stripVolatileObjects applies helpers.StripVolatileFields to every object of a list.
+An empty list stays an empty JSON array instead of null.
+*/
+func stripVolatileObjects(objects []map[string]any) []map[string]any {
+	stripped := []map[string]any{}
+	for _, object := range objects {
+		stripped = append(stripped, helpers.StripVolatileFields(object))
+	}
+	return stripped
+}
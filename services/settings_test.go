@@ -0,0 +1,57 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestExportSettingsGitLab(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/7":
+			w.Write([]byte(`{"id": 7, "merge_method": "ff", "star_count": 12, "last_activity_at": "2026-10-01T00:00:00Z"}`))
+		case "/api/v4/projects/7/protected_branches":
+			w.Write([]byte(`[{"id": 1, "name": "main", "allow_force_push": false}]`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	workspace := t.TempDir()
+	run := &syncRun{
+		provider: "gitlab",
+		options:  models.SyncOptions{BaseDir: workspace, BaseURL: server.URL, ExportSettings: true},
+		api:      providerAPI{http: server.Client(), baseURL: server.URL},
+	}
+	run.exportSettings(syncTarget{ID: 7, RemotePath: "top/api", DefaultBranch: "main", Path: filepath.Join(workspace, "top", "api")})
+
+	data, err := os.ReadFile(helpers.GetSettingsPath(workspace, "top/api"))
+	if err != nil {
+		t.Fatalf("settings snapshot not written: %v", err)
+	}
+	var settings models.RepositorySettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.Repository["merge_method"] != "ff" {
+		t.Errorf("repository settings = %v, want merge_method ff", settings.Repository)
+	}
+	if _, ok := settings.Repository["star_count"]; ok {
+		t.Error("volatile field star_count should be stripped")
+	}
+	if _, ok := settings.BranchProtection["main"]; !ok {
+		t.Errorf("branch protection = %v, want main", settings.BranchProtection)
+	}
+	if settings.Errors["webhooks"] == "" {
+		t.Errorf("errors = %v, want the forbidden webhooks recorded", settings.Errors)
+	}
+}
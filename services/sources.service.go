@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+sourcePlan is a source of a multi-source sync with the repositories it lists.
+*/
+type sourcePlan struct {
+	source      models.ManifestSource
+	credentials models.SourceCredentials
+	run         *syncRun
+	targets     []syncTarget
+}
+
+/*
+ValidateSources checks the sources of a workspace manifest before anything is fetched.
+*/
+func ValidateSources(sources []models.ManifestSource) error {
+	for i, source := range sources {
+		var err error
+		switch source.Provider {
+		case "gitlab":
+			if !source.AllProjects {
+				err = helpers.ValidateGroupID(source.Group)
+			}
+		case "github":
+			err = helpers.ValidateOrganizationName(source.Group)
+		case "bitbucket-server":
+			err = helpers.ValidateProjectKey(source.Group)
+		case "urls":
+			if len(source.URLs) == 0 {
+				err = errors.New("no urls given")
+			}
+			for _, cloneURL := range source.URLs {
+				if _, _, ok := parseCloneURL(cloneURL); !ok {
+					err = fmt.Errorf("invalid clone URL %q", cloneURL)
+				}
+			}
+		case "repo-manifest", "gitmodules":
+			if source.File == "" {
+				err = errors.New("no file given")
+			}
+		default:
+			err = fmt.Errorf("unsupported provider %q: use gitlab, github, bitbucket-server, urls, repo-manifest or gitmodules", source.Provider)
+		}
+		if err == nil && source.Path != "" && !filepath.IsLocal(source.Path) {
+			err = fmt.Errorf("path %q must be relative and stay inside the workspace", source.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid source %d (%s): %w", i+1, source.Provider, err)
+		}
+	}
+	return nil
+}
+
+/*
+SyncWorkspaceSources synchronizes every source of a workspace manifest in a single run.
+All sources are listed first and merged into one plan, then synced into the workspace
+with one state file and one summary. With prune, clones of repositories that no source
+lists anymore are deleted; otherwise they are only reported.
+*/
+func SyncWorkspaceSources(sources []models.ManifestSource, prune bool, options models.SyncOptions, credentials map[string]models.SourceCredentials) error {
+	return syncWorkspaceSources(sources, prune, options, credentials, DefaultDependencies())
+}
+
+/*
+syncWorkspaceSources implements SyncWorkspaceSources on top of injectable dependencies.
+*/
+func syncWorkspaceSources(sources []models.ManifestSource, prune bool, options models.SyncOptions, credentials map[string]models.SourceCredentials, deps Dependencies) error {
+	if err := ValidateSources(sources); err != nil {
+		return err
+	}
+	run, err := newSyncRun("workspace", options, deps)
+	if err != nil {
+		return err
+	}
+
+	complete := true
+	var plans []*sourcePlan
+	for _, source := range sources {
+		plan := &sourcePlan{source: source, credentials: credentials[source.Provider]}
+		plan.run = run.forSource(source.Provider, plan.credentials)
+		run.notify(LevelNotice, "Listing source %s", describeSource(source))
+		if plan.targets, err = listSource(plan.run, source); err != nil {
+			run.notify(LevelError, "Failed to list source %s: %s", describeSource(source), helpers.Redact(err.Error()))
+			run.summary.addFailed("source " + describeSource(source))
+			complete = false
+			continue
+		}
+		complete = complete && !plan.run.incomplete
+		plan.targets = plan.run.groupTargets(plan.targets)
+		plans = append(plans, plan)
+	}
+	run.resolveCollisions(plans)
+
+	for _, plan := range plans {
+		run.notify(LevelInfo, "Syncing %d repositories of %s", len(plan.targets), describeSource(plan.source))
+		helpers.SetCloneUsername(plan.credentials.CloneUsername)
+		plan.run.syncAll(plan.targets)
+	}
+
+	if complete {
+		run.pruneRepositories(prune)
+	} else {
+		run.notify(LevelWarning, "Not every source could be listed completely, skipping the check for removed repositories")
+	}
+	return run.finish()
+}
+
+/*
+forSource derives the run of one source from the run of a multi-source sync.
+The state, summary, audit log, collectors and claimed directories are shared, so
+the sources end up in one state file and one set of reports. Sources are synced one after another,
+so the shared state is never used by two runs at the same time.
+*/
+func (r *syncRun) forSource(provider string, credentials models.SourceCredentials) *syncRun {
+	options := r.options
+	options.Token, options.BaseURL, options.TokenType = credentials.Token, credentials.BaseURL, models.TokenTypePersonal
+	return &syncRun{
+		provider:     provider,
+		options:      options,
+		deps:         r.deps,
+		api:          newProviderAPI(options, r.deps),
+		state:        r.state,
+		seen:         r.seen,
+		summary:      r.summary,
+		audit:        r.audit,
+		render:       r.render,
+		ownership:    r.ownership,
+		scans:        r.scans,
+		dependencies: r.dependencies,
+		commits:      r.commits,
+		claims:       r.claims,
+		clones:       r.clones,
+	}
+}
+
+/*
+listSource lists the repositories of a source as sync targets.
+Without a path, GitLab groups are placed in a directory named after the group,
+GitHub organizations and Bitbucket Server projects in one named after them,
+and instance-wide GitLab projects, URL lists and the projects of repo manifests
+and superprojects directly in the workspace, in the layout their file declares.
+*/
+func listSource(run *syncRun, source models.ManifestSource) ([]syncTarget, error) {
+	baseDir := filepath.Join(run.options.BaseDir, filepath.FromSlash(source.Path))
+	switch source.Provider {
+	case "gitlab":
+		if source.AllProjects {
+			projects, err := fetchAllGitLabProjects(run.api, gitLabProjectQuery(run.options))
+			run.incomplete = run.incomplete || run.listingFiltered()
+			return gitLabProjectTargets(projects, baseDir), err
+		}
+		groupID, err := resolveGitLabGroup(run.api, source.Group)
+		if err != nil {
+			return nil, err
+		}
+		var targets []syncTarget
+		err = collectGitLabGroup(run, groupID, baseDir, &targets)
+		return targets, err
+	case "github":
+		if source.Path == "" {
+			baseDir = filepath.Join(baseDir, source.Group)
+		}
+		if _, err := checkGitHubAPI(run.api); err != nil {
+			return nil, err
+		}
+		repositories, err := listGitHubRepositories(run.api, source.Group, run.options)
+		run.incomplete = run.incomplete || run.options.Search != "" || run.options.CodeSearch != "" || len(run.options.Properties) > 0
+		return gitHubTargets(repositories, baseDir), err
+	case "bitbucket-server":
+		if source.Path == "" {
+			baseDir = filepath.Join(baseDir, source.Group)
+		}
+		if _, err := checkBitbucketServerAPI(run.api); err != nil {
+			return nil, err
+		}
+		repositories, err := fetchAllBitbucketServerRepositories(run.api, source.Group)
+		return bitbucketServerTargets(repositories, baseDir), err
+	}
+	if source.Provider == "repo-manifest" || source.Provider == "gitmodules" {
+		return pinnedTargets(run, source, baseDir)
+	}
+	return urlTargets(source.URLs, baseDir), nil
+}
+
+/*
+resolveCollisions removes conflicts between the sources from the plan.
+A repository listed by several sources (e.g. overlapping GitLab groups) is synced
+once, by the first source. Different repositories that would be cloned into the
+same directory are not merged: the first source in the manifest keeps the directory,
+later ones are reported as failed. Every listed repository counts as seen, so its
+clone is never pruned because of a collision or a failed sync.
+*/
+func (r *syncRun) resolveCollisions(plans []*sourcePlan) {
+	owners := map[string]string{} // Workspace-relative path → provider and remote path
+	listed := map[string]bool{}
+	for _, plan := range plans {
+		var targets []syncTarget
+		for _, target := range plan.targets {
+			key := helpers.StateKey(plan.run.provider, target.ID)
+			if listed[key] {
+				continue
+			}
+			listed[key] = true
+			r.seen[key] = true // Listed, so never pruned even if its sync fails
+
+			relPath := r.relativePath(target.Path)
+			remote := plan.run.provider + ":" + target.RemotePath
+			if owner, ok := owners[relPath]; ok {
+				r.notify(LevelError, "Skipping %s: %s is already used by %s", remote, relPath, owner)
+				r.summary.addFailed(fmt.Sprintf("%s (%s collides with %s)", relPath, remote, owner))
+				continue
+			}
+			owners[relPath] = remote
+			targets = append(targets, target)
+		}
+		plan.targets = targets
+	}
+}
+
+/*
+pruneRepositories handles repositories of the state that no source listed in this run.
+They are reported, and with prune their clones are deleted and their entries dropped.
+Clones with local work, or whose directory is now used by another repository, are kept.
+*/
+func (r *syncRun) pruneRepositories(prune bool) {
+	inUse := map[string]bool{}
+	var gone []string
+	for key, entry := range r.state.Repositories {
+		if r.seen[key] {
+			inUse[entry.Path] = true
+		} else {
+			gone = append(gone, key)
+		}
+	}
+	sort.Strings(gone)
+
+	for _, key := range gone {
+		entry := r.state.Repositories[key]
+		switch {
+		case !prune:
+			r.summary.addGone(entry.Path)
+		case inUse[entry.Path]:
+			delete(r.state.Repositories, key) // Another repository took over its directory
+		case r.options.NoWrite:
+			r.notify(LevelNotice, "Would prune: %s", entry.Path)
+			r.summary.addPlanned("delete " + entry.Path + " (no longer listed by any source)")
+		default:
+			r.pruneRepository(key, entry)
+		}
+	}
+}
+
+/*
+pruneRepository deletes the clone of a repository no source lists anymore.
+*/
+func (r *syncRun) pruneRepository(key string, entry models.RepositoryState) {
+	repoPath := filepath.Join(r.options.BaseDir, filepath.FromSlash(entry.Path))
+	if changed, err := helpers.HasLocalChanges(r.deps.Git, repoPath); err != nil || changed {
+		r.notify(LevelWarning, "Not pruning %s: the clone has local changes or cannot be inspected", entry.Path)
+		r.summary.addGone(entry.Path + " (local changes)")
+		return
+	}
+
+	err := helpers.RemoveDirectory(repoPath)
+	r.audit.Record("prune", entry.Path, entry.Provider+":"+entry.RemotePath, err)
+	if err != nil {
+		r.notify(LevelError, "Failed to prune %s: %v", entry.Path, err)
+		return
+	}
+	delete(r.state.Repositories, key)
+	r.notify(LevelWarning, "Pruned: %s", entry.Path)
+	r.summary.addPruned(entry.Path)
+}
+
+/*
+urlTargets converts a list of clone URLs into sync targets named after the repository.
+URLs have no provider ID, so the ID is derived from the URL; the same URL always
+maps to the same state entry. The URL is used for HTTPS and SSH clones alike.
+*/
+func urlTargets(urls []string, baseDir string) []syncTarget {
+	targets := make([]syncTarget, 0, len(urls))
+	for _, cloneURL := range urls {
+		remotePath, name, _ := parseCloneURL(cloneURL)
+		targets = append(targets, syncTarget{
+			ID:         urlID(cloneURL),
+			Name:       name,
+			RemotePath: remotePath,
+			HTTPSURL:   cloneURL,
+			SSHURL:     cloneURL,
+			Path:       filepath.Join(baseDir, name),
+		})
+	}
+	return targets
+}
+
+/*
+urlID derives the ID of a repository without a provider ID from its clone URL.
+*/
+func urlID(cloneURL string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(cloneURL))
+	return int64(hash.Sum64() & math.MaxInt64)
+}
+
+/*
+parseCloneURL splits a clone URL (https://, ssh:// or scp-like git@host:path)
+into host and path without the .git suffix, and the repository name.
+*/
+func parseCloneURL(cloneURL string) (remotePath, name string, ok bool) {
+	var host, repoPath string
+	if parsed, err := url.Parse(cloneURL); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		host, repoPath = parsed.Hostname(), parsed.Path
+	} else if at, rest, found := strings.Cut(cloneURL, "@"); found && !strings.Contains(at, "/") {
+		host, repoPath, _ = strings.Cut(rest, ":")
+	}
+	repoPath = strings.Trim(strings.TrimSuffix(repoPath, ".git"), "/")
+	if host == "" || repoPath == "" {
+		return "", "", false
+	}
+	return host + "/" + repoPath, path.Base(repoPath), true
+}
+
+/*
+describeSource names a source for progress messages and the summary.
+*/
+func describeSource(source models.ManifestSource) string {
+	switch {
+	case source.Provider == "urls":
+		return fmt.Sprintf("urls (%d repositories)", len(source.URLs))
+	case source.Provider == "repo-manifest" || source.Provider == "gitmodules":
+		return source.Provider + ":" + source.File
+	case source.AllProjects:
+		return "gitlab (all projects)"
+	}
+	return source.Provider + ":" + source.Group
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestParseCloneURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantRemote string
+		wantName   string
+		wantOK     bool
+	}{
+		{"https://git.example.com/tools/lint.git", "git.example.com/tools/lint", "lint", true},
+		{"ssh://git@git.example.com:2222/tools/lint", "git.example.com/tools/lint", "lint", true},
+		{"git@github.com:acme/api.git", "github.com/acme/api", "api", true},
+		{"https://git.example.com/", "", "", false},
+		{"not a url", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			remote, name, ok := parseCloneURL(tt.url)
+			if remote != tt.wantRemote || name != tt.wantName || ok != tt.wantOK {
+				t.Errorf("parseCloneURL() = %q, %q, %v, want %q, %q, %v", remote, name, ok, tt.wantRemote, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  models.ManifestSource
+		wantErr bool
+	}{
+		{"gitlab group", models.ManifestSource{Provider: "gitlab", Group: "42"}, false},
+		{"gitlab instance", models.ManifestSource{Provider: "gitlab", AllProjects: true}, false},
+		{"github without organization", models.ManifestSource{Provider: "github"}, true},
+		{"urls", models.ManifestSource{Provider: "urls", URLs: []string{"git@example.com:a/b.git"}}, false},
+		{"empty urls", models.ManifestSource{Provider: "urls"}, true},
+		{"repo manifest", models.ManifestSource{Provider: "repo-manifest", File: "default.xml"}, false},
+		{"gitmodules without file", models.ManifestSource{Provider: "gitmodules"}, true},
+		{"path outside the workspace", models.ManifestSource{Provider: "github", Group: "acme", Path: "../elsewhere"}, true},
+		{"unknown provider", models.ManifestSource{Provider: "gitea", Group: "acme"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSources([]models.ManifestSource{tt.source})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSyncWorkspaceSources(t *testing.T) {
+	workspace := t.TempDir()
+	git := &fakeGitRunner{}
+	options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace}
+	sources := []models.ManifestSource{
+		{Provider: "github", Group: "acme"},
+		{Provider: "urls", Path: "acme", URLs: []string{"https://git.example.com/tools/api.git", "https://git.example.com/tools/lint.git"}},
+	}
+
+	first := newGitHubServer(t, [][]models.GitHubRepository{{gitHubRepo(1, "api"), gitHubRepo(2, "web")}})
+	credentials := map[string]models.SourceCredentials{
+		"github": {Token: "ghp_testtoken1234", BaseURL: first.URL, CloneUsername: "oauth2"},
+	}
+	err := syncWorkspaceSources(sources, true, options, credentials, Dependencies{HTTP: first.Client(), Git: git})
+	if !errors.Is(err, ErrPartialSync) {
+		t.Fatalf("first sync error = %v, want ErrPartialSync for the collision", err)
+	}
+	for _, name := range []string{"api", "web", "lint"} {
+		if _, err := os.Stat(filepath.Join(workspace, "acme", name, ".git")); err != nil {
+			t.Errorf("repository %s was not cloned: %v", name, err)
+		}
+	}
+	state, _ := helpers.LoadState(workspace)
+	if len(state.Repositories) != 3 {
+		t.Fatalf("state has %d repositories, want 3", len(state.Repositories))
+	}
+	if got := state.Repositories["github:1"].Path; got != "acme/api" {
+		t.Errorf("acme/api belongs to %q, want the GitHub repository", got)
+	}
+
+	// The organization no longer lists web: its clone is pruned
+	second := newGitHubServer(t, [][]models.GitHubRepository{{gitHubRepo(1, "api")}})
+	credentials["github"] = models.SourceCredentials{Token: "ghp_testtoken1234", BaseURL: second.URL, CloneUsername: "oauth2"}
+	syncWorkspaceSources(sources, true, options, credentials, Dependencies{HTTP: second.Client(), Git: git})
+
+	if _, err := os.Stat(filepath.Join(workspace, "acme", "web")); !os.IsNotExist(err) {
+		t.Errorf("clone of the removed repository still exists")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "acme", "api", ".git")); err != nil {
+		t.Errorf("clone of acme/api was pruned: %v", err)
+	}
+	state, _ = helpers.LoadState(workspace)
+	if _, ok := state.Repositories["github:2"]; ok {
+		t.Errorf("state still lists the pruned repository")
+	}
+}
+
+func TestSyncWorkspaceSourcesKeepsChangedClones(t *testing.T) {
+	workspace := t.TempDir()
+	git := &fakeGitRunner{outputs: map[string]string{"status": " M README.md\n"}}
+	options := models.SyncOptions{CloneMethod: "https", BaseDir: workspace}
+	deps := Dependencies{Git: git}
+
+	lint := models.ManifestSource{Provider: "urls", URLs: []string{"https://git.example.com/tools/lint.git"}}
+	if err := syncWorkspaceSources([]models.ManifestSource{lint}, true, options, nil, deps); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	other := models.ManifestSource{Provider: "urls", URLs: []string{"https://git.example.com/tools/fmt.git"}}
+	if err := syncWorkspaceSources([]models.ManifestSource{other}, true, options, nil, deps); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "lint", ".git")); err != nil {
+		t.Errorf("clone with local changes was pruned: %v", err)
+	}
+}
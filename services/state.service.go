@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+ListState prints the repositories recorded in the state of a workspace, ordered by path,
+as a table or, with asJSON, as the state entries keyed like the state file.
+*/
+func ListState(w io.Writer, workspace string, asJSON bool) error {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(state.Repositories)
+	}
+
+	keys := make([]string, 0, len(state.Repositories))
+	for key := range state.Repositories {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return state.Repositories[keys[i]].Path < state.Repositories[keys[j]].Path
+	})
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "Key\tPath\tRemote\tLast synced")
+	for _, key := range keys {
+		entry := state.Repositories[key]
+		lastSynced := "-"
+		if !entry.LastSynced.IsZero() {
+			lastSynced = entry.LastSynced.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", key, entry.Path, entry.RemotePath, lastSynced)
+	}
+	return table.Flush()
+}
+
+/*
+ShowState prints the state entry of one repository as JSON.
+The repository is named by its key, workspace path or remote path.
+*/
+func ShowState(w io.Writer, workspace, repository string) error {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return err
+	}
+	key, err := findStateEntry(state, repository)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]models.RepositoryState{key: state.Repositories[key]})
+}
+
+/*
+RemoveState drops the entry of one repository from the state of a workspace.
+Only the record is removed, the clone stays where it is: reposync forgets the
+repository, e.g. to stop a clone from being moved or pruned, and adopts the clone
+again if a later sync lists it at the same path.
+*/
+func RemoveState(workspace, repository string) error {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return err
+	}
+	key, err := findStateEntry(state, repository)
+	if err != nil {
+		return err
+	}
+
+	audit, err := helpers.OpenAuditLog(helpers.GetAuditLogPath(workspace))
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+
+	entry := state.Repositories[key]
+	delete(state.Repositories, key)
+	err = helpers.SaveState(workspace, state)
+	audit.Record("state-rm", entry.Path, key, err)
+	if err != nil {
+		return err
+	}
+	Notify(LevelSuccess, "Removed %s (%s) from the state, its clone was kept", key, entry.Path)
+	return nil
+}
+
+/*
+findStateEntry resolves a repository named by its state key (gitlab:123), its path in
+the workspace or its remote path to its key. A path or remote path shared by several
+entries, e.g. the same path on two providers, has to be named by its key.
+*/
+func findStateEntry(state *models.State, repository string) (string, error) {
+	if _, ok := state.Repositories[repository]; ok {
+		return repository, nil
+	}
+	name := path.Clean(filepath.ToSlash(repository))
+	var matches []string
+	for key, entry := range state.Repositories {
+		if entry.Path == name || entry.RemotePath == name {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no repository %q in the state: use a key, path or remote path from reposync state list", repository)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%q matches several repositories (%s): use the key", repository, strings.Join(matches, ", "))
+}
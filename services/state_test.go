@@ -0,0 +1,76 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func writeTestState(t *testing.T) string {
+	t.Helper()
+	workspace := t.TempDir()
+	state := &models.State{Repositories: map[string]models.RepositoryState{
+		"gitlab:1": {Provider: "gitlab", ID: 1, Name: "api", Path: "acme/api", RemotePath: "acme/backend/api"},
+		"gitlab:2": {Provider: "gitlab", ID: 2, Name: "web", Path: "acme/web", RemotePath: "acme/web"},
+		"github:2": {Provider: "github", ID: 2, Name: "web", Path: "mirror/web", RemotePath: "acme/web"},
+	}}
+	if err := helpers.SaveState(workspace, state); err != nil {
+		t.Fatal(err)
+	}
+	return workspace
+}
+
+func TestFindStateEntry(t *testing.T) {
+	state, err := helpers.LoadState(writeTestState(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		repository string
+		want       string
+		wantErr    bool
+	}{
+		{"gitlab:1", "gitlab:1", false},
+		{"acme/api", "gitlab:1", false},
+		{"./acme/api/", "gitlab:1", false},
+		{"acme/backend/api", "gitlab:1", false},
+		{"mirror/web", "github:2", false},
+		{"acme/web", "", true}, // Path of one entry, remote path of both
+		{"acme/unknown", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			got, err := findStateEntry(state, tt.repository)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("findStateEntry() = %q, %v, want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListState(t *testing.T) {
+	var out strings.Builder
+	if err := ListState(&out, writeTestState(t), false); err != nil {
+		t.Fatalf("ListState() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "gitlab:1") || !strings.HasPrefix(lines[3], "github:2") {
+		t.Errorf("ListState() =\n%s\nwant a header and the entries ordered by path", out.String())
+	}
+}
+
+func TestRemoveState(t *testing.T) {
+	workspace := writeTestState(t)
+	if err := RemoveState(workspace, "acme/api"); err != nil {
+		t.Fatalf("RemoveState() error = %v", err)
+	}
+	state, _ := helpers.LoadState(workspace)
+	if _, ok := state.Repositories["gitlab:1"]; ok || len(state.Repositories) != 2 {
+		t.Errorf("state after rm = %v, want only gitlab:1 removed", state.Repositories)
+	}
+	if err := RemoveState(workspace, "acme/api"); err == nil {
+		t.Errorf("removing a repository twice succeeded")
+	}
+}
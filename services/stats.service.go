@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+ComputeWorkspaceStats aggregates statistics over the repositories of a workspace.
+See computeWorkspaceStats.
+*/
+func ComputeWorkspaceStats(workspace string, staleAfter time.Duration) (*models.WorkspaceStats, error) {
+	return computeWorkspaceStats(workspace, staleAfter, DefaultDependencies())
+}
+
+/*
+computeWorkspaceStats walks the repositories recorded in the workspace state.
+Measures each clone on disk, counts repositories per provider-reported language
+and flags clones whose last commit is older than staleAfter.
+Repositories whose clone has disappeared are counted as missing.
+*/
+func computeWorkspaceStats(workspace string, staleAfter time.Duration, deps Dependencies) (*models.WorkspaceStats, error) {
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.WorkspaceStats{
+		Languages:      map[string]int{},
+		StaleAfterDays: int(staleAfter.Hours() / 24),
+		Stale:          []models.StaleRepository{},
+	}
+	cutoff := time.Now().Add(-staleAfter)
+
+	for _, repository := range state.Repositories {
+		stats.TotalRepositories++
+
+		repoPath := filepath.Join(workspace, filepath.FromSlash(repository.Path))
+		if _, err := os.Stat(repoPath); err != nil {
+			stats.MissingClones++
+			continue
+		}
+
+		size, err := helpers.DirectorySize(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		stats.TotalSizeBytes += size
+
+		language := repository.Language
+		if language == "" {
+			language = "unknown"
+		}
+		stats.Languages[language]++
+
+		// Empty repositories have no commit to judge staleness by
+		lastCommit, err := helpers.LastCommitTime(deps.Git, repoPath)
+		if err == nil && lastCommit.Before(cutoff) {
+			stats.Stale = append(stats.Stale, models.StaleRepository{Path: repository.Path, LastCommit: lastCommit})
+		}
+	}
+
+	sort.Slice(stats.Stale, func(i, j int) bool {
+		return stats.Stale[i].LastCommit.Before(stats.Stale[j].LastCommit)
+	})
+	return stats, nil
+}
+
+/*
+PrintWorkspaceStats renders workspace statistics as a table or, with asJSON, as JSON.
+*/
+func PrintWorkspaceStats(w io.Writer, stats *models.WorkspaceStats, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "Repositories:\t%d\n", stats.TotalRepositories)
+	if stats.MissingClones > 0 {
+		fmt.Fprintf(table, "Missing clones:\t%d\n", stats.MissingClones)
+	}
+	fmt.Fprintf(table, "Size on disk:\t%s\n", helpers.FormatBytes(stats.TotalSizeBytes))
+
+	languages := make([]string, 0, len(stats.Languages))
+	for language := range stats.Languages {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if stats.Languages[languages[i]] != stats.Languages[languages[j]] {
+			return stats.Languages[languages[i]] > stats.Languages[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	fmt.Fprintln(table, "\nLanguage\tRepositories")
+	for _, language := range languages {
+		fmt.Fprintf(table, "%s\t%d\n", language, stats.Languages[language])
+	}
+
+	fmt.Fprintf(table, "\nStale (no commit in %d days)\tLast commit\n", stats.StaleAfterDays)
+	for _, repository := range stats.Stale {
+		fmt.Fprintf(table, "%s\t%s\n", repository.Path, repository.LastCommit.Format("2006-01-02"))
+	}
+	return table.Flush()
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestComputeWorkspaceStats(t *testing.T) {
+	workspace := t.TempDir()
+	state := &models.State{Repositories: map[string]models.RepositoryState{
+		"github:1": {Path: "org/api", Language: "Go"},
+		"github:2": {Path: "org/web", Language: "TypeScript"},
+		"github:3": {Path: "org/gone", Language: "Go"},
+	}}
+	if err := helpers.SaveState(workspace, state); err != nil {
+		t.Fatal(err)
+	}
+	for _, repo := range []string{"org/api", "org/web"} {
+		if err := os.MkdirAll(filepath.Join(workspace, repo), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workspace, repo, "README.md"), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every clone reports a last commit in 2001
+	git := &fakeGitRunner{outputs: map[string]string{"log": "1000000000\n"}}
+	stats, err := computeWorkspaceStats(workspace, 180*24*time.Hour, Dependencies{Git: git})
+	if err != nil {
+		t.Fatalf("computeWorkspaceStats() error = %v", err)
+	}
+
+	if stats.TotalRepositories != 3 || stats.MissingClones != 1 {
+		t.Errorf("repositories = %d, missing = %d, want 3 and 1", stats.TotalRepositories, stats.MissingClones)
+	}
+	if stats.TotalSizeBytes != 10 {
+		t.Errorf("size = %d, want 10", stats.TotalSizeBytes)
+	}
+	if stats.Languages["Go"] != 1 || stats.Languages["TypeScript"] != 1 {
+		t.Errorf("languages = %v", stats.Languages)
+	}
+	if len(stats.Stale) != 2 {
+		t.Errorf("stale = %v, want both clones", stats.Stale)
+	}
+}
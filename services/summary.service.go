@@ -0,0 +1,376 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// slowestShown is the number of repositories listed under the slowest ones.
+const slowestShown = 10
+
+/*
+syncSummary collects notable events during a synchronization run.
+Filled in while repositories are processed and printed once at the end,
+so important changes are not lost in the per-repository progress output.
+Safe for use by concurrent workers.
+*/
+type syncSummary struct {
+	mu               sync.Mutex
+	migratedBranches []string
+	moved            []string
+	renamed          []string
+	transferred      []string
+	planned          []string
+	stale            []string
+	emptyRepos       []string
+	skipped          []string
+	scanFindings     []string
+	timedOut         []string
+	failed           []string
+	failureClasses   map[string]string // Class of a failed clone (helpers.GitError*) by repository
+	aborted          []string          // Not synced because the run was aborted
+	abortReason      string
+	gone             []string
+	pruned           []string
+	prunedRefs       []string
+	timings          []repositoryTiming
+	apiUsage         []string
+}
+
+/*
+repositoryTiming is the cost of syncing one repository.
+previous is the duration recorded by the last run (0 when unknown).
+*/
+type repositoryTiming struct {
+	name     string
+	duration time.Duration
+	size     int64
+	previous time.Duration
+}
+
+/*
+addMigratedBranch records a repository whose default branch was switched locally.
+*/
+func (s *syncSummary) addMigratedBranch(name, previous, current string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.migratedBranches = append(s.migratedBranches, fmt.Sprintf("%s (%s -> %s)", name, previous, current))
+}
+
+/*
+addMoved records a clone that was relocated after an upstream rename or move.
+*/
+func (s *syncSummary) addMoved(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moved = append(s.moved, fmt.Sprintf("%s -> %s", from, to))
+}
+
+/*
+addRenamed records a clone placed in another directory because its own differs from another only in case.
+*/
+func (s *syncSummary) addRenamed(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renamed = append(s.renamed, fmt.Sprintf("%s -> %s", from, to))
+}
+
+/*
+addTransferred records a project that was transferred to another namespace upstream.
+*/
+func (s *syncSummary) addTransferred(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transferred = append(s.transferred, fmt.Sprintf("%s -> %s", from, to))
+}
+
+/*
+addPlanned records a change that a read-only run would have made.
+*/
+func (s *syncSummary) addPlanned(change string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.planned = append(s.planned, change)
+}
+
+/*
+addStale records a repository without upstream activity since the given time.
+*/
+func (s *syncSummary) addStale(name string, lastActivity time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stale = append(s.stale, fmt.Sprintf("%s (last activity %s)", name, lastActivity.Format("2006-01-02")))
+}
+
+/*
+addEmpty records a repository that was cloned without commits.
+*/
+func (s *syncSummary) addEmpty(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emptyRepos = append(s.emptyRepos, name)
+}
+
+/*
+addSkipped records a repository that was not synced because its provider holds it back.
+*/
+func (s *syncSummary) addSkipped(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped = append(s.skipped, name)
+}
+
+/*
+addScanFindings records a repository in which the secret scanner found something.
+*/
+func (s *syncSummary) addScanFindings(name string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanFindings = append(s.scanFindings, fmt.Sprintf("%s (%d findings)", name, count))
+}
+
+/*
+addTimedOut records a repository whose git command was killed by the timeout.
+*/
+func (s *syncSummary) addTimedOut(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timedOut = append(s.timedOut, name)
+}
+
+/*
+addGone records a repository that no source lists anymore and whose clone was kept.
+*/
+func (s *syncSummary) addGone(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gone = append(s.gone, name)
+}
+
+/*
+addPruned records a clone that was deleted because no source lists its repository anymore.
+*/
+func (s *syncSummary) addPruned(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruned = append(s.pruned, name)
+}
+
+/*
+addPrunedRefs records the branches and tags of a clone that were deleted because they were deleted upstream.
+*/
+func (s *syncSummary) addPrunedRefs(name string, refs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prunedRefs = append(s.prunedRefs, fmt.Sprintf("%s (%s)", name, strings.Join(refs, ", ")))
+}
+
+/*
+addAPIUsage records the API calls made to a host and what is left of its rate limit.
+The projection tells how many runs like this one fit into a rate-limit window,
+which helps to choose the concurrency and the schedule of unattended syncs.
+*/
+func (s *syncSummary) addAPIUsage(usage client.HostUsage) {
+	entry := fmt.Sprintf("%s: %d API calls", usage.Host, usage.Requests)
+	if usage.Limit > 0 {
+		entry += fmt.Sprintf(", %d of %d left", usage.Remaining, usage.Limit)
+		if !usage.Reset.IsZero() {
+			entry += fmt.Sprintf(" until %s", usage.Reset.Local().Format("15:04"))
+		}
+		switch {
+		case usage.Remaining < usage.Requests:
+			entry += ", the next run will hit the rate limit"
+		case usage.Requests > 0:
+			entry += fmt.Sprintf(", ~%d runs like this fit into one window", usage.Limit/usage.Requests)
+		}
+	} else {
+		entry += ", no rate-limit headers"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiUsage = append(s.apiUsage, entry)
+}
+
+/*
+addFailed records a repository that could not be synchronized.
+*/
+func (s *syncSummary) addFailed(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = append(s.failed, name)
+}
+
+/*
+abort stops the run: repositories not started yet are not synced anymore.
+Only the first reason is kept. Returns whether this call aborted the run.
+*/
+func (s *syncSummary) abort(reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.abortReason != "" {
+		return false
+	}
+	s.abortReason = reason
+	return true
+}
+
+/*
+abortedBy returns why the run was aborted, or an empty string while it goes on.
+*/
+func (s *syncSummary) abortedBy() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.abortReason
+}
+
+/*
+addAborted records a repository that was not synced because the run was aborted.
+*/
+func (s *syncSummary) addAborted(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aborted = append(s.aborted, name)
+}
+
+/*
+addFailedClass records a repository that could not be synchronized because git failed,
+so the summary groups it with the repositories that failed for the same reason.
+*/
+func (s *syncSummary) addFailedClass(name, class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = append(s.failed, name)
+	if class != "" {
+		if s.failureClasses == nil {
+			s.failureClasses = map[string]string{}
+		}
+		s.failureClasses[name] = class
+	}
+}
+
+/*
+failures returns the number of repositories that could not be synchronized.
+*/
+func (s *syncSummary) failures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.failed)
+}
+
+/*
+addTiming records how long a repository took to clone or update and its size on disk.
+*/
+func (s *syncSummary) addTiming(name string, duration time.Duration, size int64, previous time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timings = append(s.timings, repositoryTiming{name: name, duration: duration, size: size, previous: previous})
+}
+
+/*
+slowest returns the limit slowest repositories of the run, slowest first.
+*/
+func (s *syncSummary) slowest(limit int) []string {
+	s.mu.Lock()
+	timings := append([]repositoryTiming(nil), s.timings...)
+	s.mu.Unlock()
+
+	sort.Slice(timings, func(i, j int) bool { return timings[i].duration > timings[j].duration })
+	if len(timings) > limit {
+		timings = timings[:limit]
+	}
+
+	var entries []string
+	for _, timing := range timings {
+		entry := fmt.Sprintf("%s (%s, %s", timing.name, timing.duration.Round(time.Millisecond), helpers.FormatBytes(timing.size))
+		if timing.previous > 0 {
+			entry += fmt.Sprintf(", previously %s", timing.previous)
+		}
+		entries = append(entries, entry+")")
+	}
+	return entries
+}
+
+/*
+empty reports whether no events were collected.
+*/
+func (s *syncSummary) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.migratedBranches)+len(s.moved)+len(s.renamed)+len(s.transferred)+len(s.planned)+len(s.stale)+len(s.emptyRepos)+len(s.skipped)+len(s.scanFindings)+len(s.timedOut)+len(s.failed)+len(s.aborted)+len(s.gone)+len(s.pruned)+len(s.prunedRefs)+len(s.timings)+len(s.apiUsage) == 0
+}
+
+/*
+sections returns the collected events as the sections of the summary.
+Sections without entries are omitted to keep the output short.
+*/
+func (s *syncSummary) sections() []SummarySection {
+	var sections []SummarySection
+	add := func(kind, title string, entries []string, problem bool) {
+		if len(entries) > 0 {
+			sections = append(sections, SummarySection{Kind: kind, Title: title, Entries: entries, Problem: problem})
+		}
+	}
+	add("migrated-branches", "Default branch migrated:", s.migratedBranches, false)
+	add("moved", "Moved repositories:", s.moved, false)
+	add("renamed", "Renamed to avoid case-only collisions:", s.renamed, false)
+	add("transferred", "Transferred projects:", s.transferred, false)
+	add("planned", "Planned changes (nothing was modified):", s.planned, false)
+	add("stale", "Stale repositories (archiving candidates):", s.stale, false)
+	add("empty", "Empty repositories (no commits yet):", s.emptyRepos, false)
+	add("skipped", "Skipped (archived, disabled or pending deletion):", s.skipped, false)
+	add("scan-findings", "Secret scan findings:", s.scanFindings, true)
+	add("timed-out", "Timed out (git killed):", s.timedOut, true)
+	sections = append(sections, s.failedSections()...)
+	add("aborted", "Not synced, the run was aborted ("+s.abortReason+"):", s.aborted, true)
+	add("gone", "No longer listed by any source (clone kept):", s.gone, false)
+	add("pruned", "Pruned repositories:", s.pruned, false)
+	add("pruned-refs", "Branches and tags deleted upstream:", s.prunedRefs, false)
+	add("slowest", fmt.Sprintf("Slowest repositories (top %d):", slowestShown), s.slowest(slowestShown), false)
+	add("api-usage", "API usage:", s.apiUsage, false)
+	return sections
+}
+
+// failureHints explain the classes of failed clones in the summary.
+var failureHints = map[string]string{
+	helpers.GitErrorAuth:     "check the token, its scopes or the SSH key",
+	helpers.GitErrorNotFound: "deleted, renamed or not visible to the token",
+	helpers.GitErrorDiskFull: "free up space in the workspace",
+	helpers.GitErrorNetwork:  "connection, TLS or server errors",
+	helpers.GitErrorTimeout:  "killed after --clone-timeout",
+}
+
+/*
+failedSections lists the failed repositories grouped by why git failed,
+followed by the failures without a class (e.g. listing errors).
+*/
+func (s *syncSummary) failedSections() []SummarySection {
+	byClass := map[string][]string{}
+	var classes []string
+	for _, name := range s.failed {
+		class := s.failureClasses[name]
+		if _, ok := byClass[class]; !ok {
+			classes = append(classes, class)
+		}
+		byClass[class] = append(byClass[class], name)
+	}
+	sort.Strings(classes)
+
+	var sections []SummarySection
+	for _, class := range classes {
+		title := "Failed repositories:"
+		if hint, ok := failureHints[class]; ok {
+			title = fmt.Sprintf("Failed repositories (%s: %s):", class, hint)
+		} else if class != "" {
+			title = fmt.Sprintf("Failed repositories (%s):", class)
+		}
+		sections = append(sections, SummarySection{Kind: "failed", Title: title, Entries: byClass[class], Problem: true})
+	}
+	return sections
+}
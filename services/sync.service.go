@@ -0,0 +1,1209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+/*
+syncRun carries the settings and shared bookkeeping of a single synchronization run.
+Shared by all workers of the run (and GitLab's subgroup recursion), so state
+tracking and the summary cover the whole run; mu guards state and seen.
+*/
+type syncRun struct {
+	provider string
+	options  models.SyncOptions
+	deps     Dependencies
+	api      providerAPI
+	mu       sync.Mutex
+	state    *models.State
+	seen     map[string]bool
+	summary  *syncSummary
+	audit    *helpers.AuditLog
+	render   Renderer // Presents the events of the run
+
+	ownership *ownershipCollector // Set when an ownership report was requested
+	scans     *scanCollector      // Set when secret scanning was requested
+
+	dependencies *dependencyCollector  // Set when dependency harvesting was requested
+	commits      *commitCollector      // Set when a commit activity export was requested
+	diagnostics  *diagnosticsCollector // Set when diagnostics were requested
+
+	incomplete   bool                 // Set when part of the remote listing failed, so missing repositories may still exist
+	failureLimit int                  // Failed repositories after which the run is aborted (0: no limit)
+	claims       map[string]pathClaim // Directories handed out in this run, by lower-cased workspace-relative path
+
+	groupDirectories []string       // Directories of the GitLab groups walked, parents first, removed if left empty (SkipEmptyGroups)
+	clones           *cloneRegistry // Set when the machine-wide registry is kept (RegistryPath)
+}
+
+/*
+pathClaim is the repository a directory was handed out to during planning.
+*/
+type pathClaim struct {
+	key  string // State key of the repository
+	path string // Workspace-relative path as claimed
+}
+
+/*
+syncTarget describes a repository to synchronize in provider-neutral terms.
+Path is the local clone location, RemotePath the provider-side location.
+*/
+type syncTarget struct {
+	ID            int64
+	Name          string
+	RemotePath    string
+	HTTPSURL      string
+	SSHURL        string
+	DefaultBranch string
+	Path          string
+	Description   string
+	Language      string
+	WebURL        string
+	LastActivity  time.Time
+	Visibility    string
+	Topics        []string
+	Empty         bool   // The provider reports no commits (size 0 or no default branch)
+	Inactive      string // Why the provider holds the repository back: archived, disabled or pending deletion
+	Revision      string // Branch, tag or commit pinned by a repo manifest, superproject or the workspace manifest
+	PinBranch     string // Local branch the Revision is checked out on (empty: detached HEAD)
+	Size          int64  // In bytes as reported by the provider, 0 when unknown
+}
+
+/*
+newSyncTarget plans the sync of a repository into the local clone location path.
+*/
+func newSyncTarget(repository models.Repository, path string) syncTarget {
+	remotePath := repository.Path
+	if repository.Namespace != "" {
+		remotePath = repository.Namespace + "/" + repository.Path
+	}
+	return syncTarget{
+		ID:            repository.ID,
+		Name:          repository.Name,
+		RemotePath:    remotePath,
+		HTTPSURL:      repository.HTTPSURL,
+		SSHURL:        repository.SSHURL,
+		DefaultBranch: repository.DefaultBranch,
+		Path:          path,
+		Description:   repository.Description,
+		Language:      repository.Language,
+		WebURL:        repository.WebURL,
+		LastActivity:  repository.LastActivity,
+		Visibility:    repository.Visibility,
+		Topics:        repository.Topics,
+		Empty:         repository.Empty,
+		Inactive:      inactiveReason(repository),
+		Size:          repository.Size,
+	}
+}
+
+/*
+inactiveReason tells why a repository is held back from syncing, if it is.
+*/
+func inactiveReason(repository models.Repository) string {
+	switch {
+	case repository.Disabled:
+		return "disabled"
+	case repository.PendingDeletion:
+		return "pending deletion"
+	case repository.Archived:
+		return "archived"
+	}
+	return ""
+}
+
+/*
+newSyncRun prepares a run rooted at the options' base directory.
+Loads the workspace state file so repositories can be matched with previous runs
+and opens the audit log that records every filesystem mutation of the run.
+*/
+func newSyncRun(provider string, options models.SyncOptions, deps Dependencies) (*syncRun, error) {
+	state, err := helpers.LoadState(options.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read-only runs leave no trace, not even in the audit log
+	var audit *helpers.AuditLog
+	if !options.NoWrite {
+		auditPath := options.AuditLogPath
+		if auditPath == "" {
+			auditPath = helpers.GetAuditLogPath(options.BaseDir)
+		}
+		if audit, err = helpers.OpenAuditLog(auditPath); err != nil {
+			return nil, err
+		}
+		audit.Record("sync-start", options.BaseDir, provider, nil)
+	}
+
+	run := &syncRun{
+		provider: provider,
+		options:  options,
+		deps:     deps,
+		api:      newProviderAPI(options, deps),
+		state:    state,
+		seen:     map[string]bool{},
+		summary:  &syncSummary{},
+		audit:    audit,
+		claims:   map[string]pathClaim{},
+	}
+	run.render = run.api.render
+	if options.OwnershipReportPath != "" {
+		run.ownership = &ownershipCollector{}
+	}
+	if options.ScanReportPath != "" {
+		run.scans = &scanCollector{}
+	}
+	if options.DependencyInventoryPath != "" {
+		run.dependencies = &dependencyCollector{}
+	}
+	if options.CommitExportPath != "" {
+		run.commits = &commitCollector{heads: map[string]string{}}
+	}
+	if options.Diagnostics && !options.NoWrite {
+		run.diagnostics = &diagnosticsCollector{start: time.Now(), errors: map[string]int{}}
+	}
+	run.loadRegistry()
+	return run, nil
+}
+
+// ErrPartialSync is returned when a run completed but some repositories failed to sync.
+var ErrPartialSync = errors.New("some repositories failed to sync")
+
+// ErrSyncAborted is returned when a run stopped syncing before all repositories were synced.
+var ErrSyncAborted = errors.New("sync aborted")
+
+/*
+removeEmptyGroupDirectories removes the directories of walked GitLab groups that hold nothing,
+children before their parents, so a group whose subgroups are all empty goes too.
+*/
+func (r *syncRun) removeEmptyGroupDirectories() {
+	for i := len(r.groupDirectories) - 1; i >= 0; i-- {
+		dir := r.groupDirectories[i]
+		removed, err := helpers.RemoveEmptyDirectory(dir)
+		if removed || err != nil {
+			r.audit.Record("remove-empty-group", dir, "", err)
+		}
+		if err != nil {
+			r.notify(LevelWarning, "Failed to remove empty group directory %s: %v", dir, err)
+		} else if removed {
+			r.notify(LevelInfo, "Removed empty group directory %s", dir)
+		}
+	}
+}
+
+/*
+finish persists the workspace state, prints the run summary and closes the audit log.
+Also renders the workspace index and the reports that were requested.
+Returns ErrSyncAborted when the run was aborted and ErrPartialSync when repositories
+failed, after everything else was written.
+*/
+func (r *syncRun) finish() error {
+	defer r.audit.Close()
+
+	if !r.options.NoWrite {
+		r.removeEmptyGroupDirectories()
+		r.saveRegistry()
+	}
+	for _, usage := range client.TakeUsage() {
+		r.summary.addAPIUsage(usage)
+	}
+	if !r.summary.empty() {
+		r.emit(Event{Type: EventPhaseStarted, Phase: "summary", Message: "Summary"})
+		r.emit(Event{Type: EventSummary, Summary: r.summary.sections()})
+		r.emit(Event{Type: EventPhaseFinished, Phase: "summary"})
+	}
+	if r.options.NoWrite {
+		return nil
+	}
+
+	// Written before the state, so the exported commits only advance once the records are on disk
+	if r.commits != nil {
+		if err := r.writeCommitExport(); err != nil {
+			return err
+		}
+	}
+
+	err := helpers.SaveState(r.options.BaseDir, r.state)
+	r.audit.Record("write-state", helpers.GetStatePath(r.options.BaseDir), "", err)
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	if r.options.IndexPath != "" {
+		err := helpers.WriteIndex(r.options.BaseDir, r.options.IndexPath, r.options.IndexTemplate, r.state)
+		r.audit.Record("write-index", r.options.IndexPath, "", err)
+		if err != nil {
+			return err
+		}
+		r.notify(LevelSuccess, "Wrote repository index to %s", r.options.IndexPath)
+	}
+
+	if r.ownership != nil {
+		if err := r.writeOwnershipReport(); err != nil {
+			return err
+		}
+	}
+	if r.scans != nil {
+		if err := r.writeScanReport(); err != nil {
+			return err
+		}
+	}
+	if r.dependencies != nil {
+		if err := r.writeDependencyInventory(); err != nil {
+			return err
+		}
+	}
+	if r.diagnostics != nil {
+		r.writeDiagnostics()
+	}
+	r.audit.Record("sync-end", r.options.BaseDir, r.provider, nil)
+
+	if reason := r.summary.abortedBy(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrSyncAborted, reason)
+	}
+	if failed := r.summary.failures(); failed > 0 {
+		return fmt.Errorf("%w: %d repositories", ErrPartialSync, failed)
+	}
+	return nil
+}
+
+/*
+relocateRepository moves an existing clone when the repository was renamed or moved upstream.
+Looks up the repository ID in the state file and, if it was previously synced to a
+different path, renames the old directory to the new location instead of re-cloning
+it and leaving an orphaned copy behind. The origin remote is rewritten to the new URL.
+Namespace changes are reported as transfers, plain renames as moves.
+*/
+func (r *syncRun) relocateRepository(target syncTarget) {
+	r.mu.Lock()
+	previous, ok := r.state.Repositories[helpers.StateKey(r.provider, target.ID)]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	relPath := r.relativePath(target.Path)
+	if previous.Path == relPath {
+		return
+	}
+
+	oldPath := filepath.Join(r.options.BaseDir, previous.Path)
+	if _, err := os.Stat(oldPath); err != nil {
+		return // Old clone no longer exists, it will be cloned fresh
+	}
+	if _, err := os.Stat(target.Path); err == nil {
+		r.notify(LevelWarning, "Not moving %s to %s: destination already exists", previous.Path, relPath)
+		return
+	}
+
+	err := helpers.MoveDirectory(oldPath, target.Path)
+	r.audit.Record("move", relPath, "from "+previous.Path, err)
+	if err != nil {
+		r.notify(LevelError, "Failed to move %s to %s: %v", previous.Path, relPath, err)
+		return
+	}
+
+	repoURL := helpers.GetPreferredRepositoryURL(target.HTTPSURL, target.SSHURL, r.options.CloneMethod)
+	err = helpers.SetRemoteURL(r.deps.Git, target.Path, repoURL)
+	r.audit.Record("set-remote", relPath, client.RedactURL(repoURL), err)
+	if err != nil {
+		r.notify(LevelError, "Failed to update remote of %s: %v", relPath, err)
+	}
+
+	if previous.RemotePath != "" && path.Dir(previous.RemotePath) != path.Dir(target.RemotePath) {
+		r.notify(LevelWarning, "Transferred: %s -> %s (moved %s -> %s)", previous.RemotePath, target.RemotePath, previous.Path, relPath)
+		r.summary.addTransferred(previous.RemotePath, target.RemotePath)
+		return
+	}
+	r.notify(LevelWarning, "Moved: %s -> %s", previous.Path, relPath)
+	r.summary.addMoved(previous.Path, relPath)
+}
+
+/*
+recordRepository stores the current location of a synchronized repository in the state.
+The time the clone or update took and the size of the clone are stored alongside
+and added to the summary, together with the duration of the previous run.
+The newest exported commit is carried over unless this run exported newer ones.
+*/
+func (r *syncRun) recordRepository(target syncTarget, duration time.Duration, size int64) {
+	key := helpers.StateKey(r.provider, target.ID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.state.Repositories[key]
+	r.summary.addTiming(r.relativePath(target.Path), duration, size, time.Duration(previous.SyncDurationMS)*time.Millisecond)
+
+	exportedCommit := previous.ExportedCommit
+	if head, ok := r.commits.exportedHead(key); ok {
+		exportedCommit = head
+	}
+
+	r.seen[key] = true
+	r.clones.add(target)
+	r.state.Repositories[key] = models.RepositoryState{
+		Provider:   r.provider,
+		ID:         target.ID,
+		Name:       target.Name,
+		Path:       r.relativePath(target.Path),
+		RemotePath: target.RemotePath,
+		LastSynced: time.Now().UTC(),
+
+		Description:  target.Description,
+		Language:     target.Language,
+		WebURL:       target.WebURL,
+		LastActivity: target.LastActivity,
+		Visibility:   target.Visibility,
+		Topics:       target.Topics,
+
+		SyncDurationMS: duration.Milliseconds(),
+		SizeBytes:      size,
+		ExportedCommit: exportedCommit,
+	}
+}
+
+/*
+relativePath converts a repository path into a path relative to the workspace root.
+*/
+func (r *syncRun) relativePath(repoPath string) string {
+	relPath, err := filepath.Rel(r.options.BaseDir, repoPath)
+	if err != nil {
+		return repoPath
+	}
+	return filepath.ToSlash(relPath)
+}
+
+/*
+rewriteURLs applies the SSH host and port override of the provider (SSHHosts),
+then the URL rewrite rules (URLRewrites) to the clone URLs.
+Existing clones keep their origin remote unless they are moved.
+*/
+func (r *syncRun) rewriteURLs(targets []syncTarget) []syncTarget {
+	override, ok := r.options.SSHHosts[r.provider]
+	if !ok && len(r.options.URLRewrites) == 0 {
+		return targets
+	}
+	// Validated when the config file was read
+	rewrites, _ := helpers.CompileURLRewrites(r.options.URLRewrites)
+
+	rewritten := make([]syncTarget, 0, len(targets))
+	for _, target := range targets {
+		target.SSHURL = helpers.RewriteURL(helpers.RewriteSSHURL(target.SSHURL, override), rewrites)
+		target.HTTPSURL = helpers.RewriteURL(target.HTTPSURL, rewrites)
+		rewritten = append(rewritten, target)
+	}
+	return rewritten
+}
+
+/*
+filterTargets drops repositories excluded by the run's filters.
+Archived, disabled and pending-deletion repositories are skipped with their reason
+unless IncludeInactive is set; disabled ones cannot be cloned and are always skipped.
+Repositories their owners opted out of mirroring with the SkipTopic topic are skipped too,
+and with PinnedOnly, those without a pin. Excluded repositories still count as seen, so they are not mistaken
+for repositories that disappeared upstream.
+*/
+func (r *syncRun) filterTargets(targets []syncTarget) []syncTarget {
+	var included []syncTarget
+	filtered, unmatched, unpinned := 0, 0, 0
+	for _, target := range targets {
+		switch {
+		case r.options.PinnedOnly && !r.isPinned(target):
+			unpinned++
+		case len(r.options.Visibility) > 0 && !slices.Contains(r.options.Visibility, target.Visibility):
+			filtered++
+		case !r.matchesListingFilters(target):
+			unmatched++
+		case r.options.SkipTopic != "" && slices.ContainsFunc(target.Topics, func(topic string) bool { return strings.EqualFold(topic, r.options.SkipTopic) }):
+			r.skipOptedOut(target)
+		case target.Inactive == "disabled" || (target.Inactive != "" && !r.options.IncludeInactive):
+			r.skipInactive(target)
+		default:
+			included = append(included, target)
+			continue
+		}
+		r.mu.Lock()
+		r.seen[helpers.StateKey(r.provider, target.ID)] = true
+		r.mu.Unlock()
+	}
+	if filtered > 0 {
+		r.notify(LevelInfo, "Skipping %d repositories not matching visibility %s", filtered, strings.Join(r.options.Visibility, ","))
+	}
+	if unmatched > 0 {
+		r.notify(LevelInfo, "Skipping %d repositories not matching the topic, language or activity filters", unmatched)
+	}
+	if unpinned > 0 {
+		r.notify(LevelWarning, "Skipping %d repositories not in the lockfile", unpinned)
+	}
+	return included
+}
+
+/*
+filterDependents keeps the repositories whose clones a dependency inventory lists
+as declaring a dependency (DependencyPaths). Runs after remapping, as the inventory
+records where clones are. The others count as seen, like other filtered repositories.
+*/
+func (r *syncRun) filterDependents(targets []syncTarget) []syncTarget {
+	if r.options.DependencyPaths == nil {
+		return targets
+	}
+	var included []syncTarget
+	for _, target := range targets {
+		if r.options.DependencyPaths[r.relativePath(target.Path)] {
+			included = append(included, target)
+			continue
+		}
+		r.mu.Lock()
+		r.seen[helpers.StateKey(r.provider, target.ID)] = true
+		r.mu.Unlock()
+	}
+	r.notify(LevelInfo, "Syncing the %d of %d repositories that declare the dependency", len(included), len(targets))
+	return included
+}
+
+/*
+listingFiltered reports whether the run only syncs repositories matching a search
+or code search, certain topics or custom properties, a language or recent activity. Searches and GitLab's
+filters are applied by the server, so listings then leave out repositories that still exist.
+*/
+func (r *syncRun) listingFiltered() bool {
+	return r.options.Search != "" || r.options.CodeSearch != "" || len(r.options.Topics) > 0 || len(r.options.Properties) > 0 || r.options.Language != "" || !r.options.ActiveAfter.IsZero()
+}
+
+/*
+matchesListingFilters reports whether a repository has all topics, the language
+and the recent activity the run asks for; values are compared case-insensitively.
+Providers without server-side filters rely on this alone. GitLab's listings carry
+no language, so its language filter only works on the server.
+*/
+func (r *syncRun) matchesListingFilters(target syncTarget) bool {
+	for _, topic := range r.options.Topics {
+		if !slices.ContainsFunc(target.Topics, func(value string) bool { return strings.EqualFold(value, topic) }) {
+			return false
+		}
+	}
+	if r.options.Language != "" && r.provider != "gitlab" && !strings.EqualFold(target.Language, r.options.Language) {
+		return false
+	}
+	return r.options.ActiveAfter.IsZero() || target.LastActivity.After(r.options.ActiveAfter)
+}
+
+/*
+skipInactive reports a repository held back by its provider.
+Existing clones are left as they are.
+*/
+func (r *syncRun) skipInactive(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+	if r.options.NoWrite {
+		r.notify(LevelNotice, "Would skip: %s (%s)", relPath, target.Inactive)
+		r.summary.addPlanned(fmt.Sprintf("skip %s (%s)", relPath, target.Inactive))
+		return
+	}
+	r.notify(LevelWarning, "Skipping %s: the repository is %s", relPath, target.Inactive)
+	r.summary.addSkipped(fmt.Sprintf("%s (%s)", relPath, target.Inactive))
+}
+
+/*
+skipOptedOut reports a repository whose owners opted out of mirroring with the SkipTopic topic.
+Like inactive repositories, its existing clone is kept as it is.
+*/
+func (r *syncRun) skipOptedOut(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+	if r.options.NoWrite {
+		r.notify(LevelNotice, "Would skip: %s (topic %s)", relPath, r.options.SkipTopic)
+		r.summary.addPlanned(fmt.Sprintf("skip %s (topic %s)", relPath, r.options.SkipTopic))
+		return
+	}
+	r.notify(LevelWarning, "Skipping %s: its owners opted out with the topic %s", relPath, r.options.SkipTopic)
+	r.summary.addSkipped(fmt.Sprintf("%s (topic %s)", relPath, r.options.SkipTopic))
+}
+
+/*
+groupTargets places repositories in subdirectories by a metadata attribute (GroupBy):
+their first topic in alphabetical order, their language or their visibility.
+The provider layout is kept below that directory; repositories without a value for
+the attribute go to "_ungrouped". Clones move along when the attribute changes,
+like after a rename.
+*/
+func (r *syncRun) groupTargets(targets []syncTarget) []syncTarget {
+	if r.options.GroupBy == "" {
+		return targets
+	}
+
+	grouped := make([]syncTarget, 0, len(targets))
+	for _, target := range targets {
+		var value string
+		switch r.options.GroupBy {
+		case "topic":
+			if len(target.Topics) > 0 {
+				value = slices.Min(target.Topics)
+			}
+		case "language":
+			value = target.Language
+		case "visibility":
+			value = target.Visibility
+		}
+
+		relPath, err := filepath.Rel(r.options.BaseDir, target.Path)
+		if err == nil {
+			target.Path = filepath.Join(r.options.BaseDir, groupDirectoryName(value), relPath)
+		}
+		grouped = append(grouped, target)
+	}
+	return grouped
+}
+
+/*
+pinTargets applies the revisions the workspace manifest pins repositories at (Pins),
+looked up like remap entries: by provider and remote path first, then by remote path.
+A pin wins over the revision a repo manifest or superproject gives.
+*/
+func (r *syncRun) pinTargets(targets []syncTarget) []syncTarget {
+	if len(r.options.Pins) == 0 {
+		return targets
+	}
+	pinned := make([]syncTarget, 0, len(targets))
+	for _, target := range targets {
+		if pin, ok := r.pin(target); ok {
+			target.Revision, target.PinBranch = pin.Revision, pin.Branch
+		}
+		pinned = append(pinned, target)
+	}
+	return pinned
+}
+
+/*
+isPinned reports whether a repository has a pin.
+*/
+func (r *syncRun) isPinned(target syncTarget) bool {
+	_, ok := r.pin(target)
+	return ok
+}
+
+/*
+pin returns the pin of a repository, if it has one.
+*/
+func (r *syncRun) pin(target syncTarget) (models.ManifestPin, bool) {
+	pin, ok := r.options.Pins[r.provider+":"+target.RemotePath]
+	if !ok {
+		pin, ok = r.options.Pins[target.RemotePath]
+	}
+	return pin, ok
+}
+
+/*
+remapTargets applies the destination remap file (Remap) to the planned paths.
+A repository entry wins over directory prefixes, and of several matching prefixes
+the longest applies. Clones still at their previous location are moved like after
+a rename, so adding an entry for an existing clone relocates it once.
+*/
+func (r *syncRun) remapTargets(targets []syncTarget) []syncTarget {
+	remap := r.options.Remap
+	if remap == nil || len(remap.Repositories)+len(remap.Paths) == 0 {
+		return targets
+	}
+
+	remapped := make([]syncTarget, 0, len(targets))
+	for _, target := range targets {
+		relPath, ok := remap.Repositories[r.provider+":"+target.RemotePath]
+		if !ok {
+			relPath, ok = remap.Repositories[target.RemotePath]
+		}
+		if !ok {
+			relPath = remapPrefix(r.relativePath(target.Path), remap.Paths)
+		}
+		target.Path = filepath.Join(r.options.BaseDir, filepath.FromSlash(relPath))
+		remapped = append(remapped, target)
+	}
+	return remapped
+}
+
+/*
+remapPrefix replaces the longest directory prefix of relPath found in paths.
+Prefixes match whole path segments: "api" covers "api/v2", but not "api-gateway".
+*/
+func remapPrefix(relPath string, paths map[string]string) string {
+	var longest, to string
+	for from, dir := range paths {
+		prefix := path.Clean(from)
+		if (relPath == prefix || strings.HasPrefix(relPath, prefix+"/")) && len(prefix) > len(longest) {
+			longest, to = prefix, dir
+		}
+	}
+	if longest == "" {
+		return relPath
+	}
+	return path.Join(to, strings.TrimPrefix(relPath, longest))
+}
+
+/*
+claimPaths gives every repository a directory that differs from the others by more than case.
+On case-insensitive file systems (macOS, Windows), "Repo" and "repo" are the same directory
+and two such repositories would silently end up in one clone. The repository that already
+owns the directory keeps it, otherwise the one with the lowest ID; the others get their ID
+appended ("repo-42"), so the renaming is the same on every run and on every platform.
+*/
+func (r *syncRun) claimPaths(targets []syncTarget) []syncTarget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owns := make([]bool, len(targets))
+	order := make([]int, len(targets))
+	for i, target := range targets {
+		entry, ok := r.state.Repositories[helpers.StateKey(r.provider, target.ID)]
+		owns[i] = ok && entry.Path == r.relativePath(target.Path)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if owns[order[a]] != owns[order[b]] {
+			return owns[order[a]]
+		}
+		return targets[order[a]].ID < targets[order[b]].ID
+	})
+
+	claimed := slices.Clone(targets)
+	for _, i := range order {
+		target := targets[i]
+		key := helpers.StateKey(r.provider, target.ID)
+		relPath := r.relativePath(target.Path)
+		if owner, ok := r.claims[strings.ToLower(relPath)]; ok && owner.key != key {
+			target.Path = fmt.Sprintf("%s-%d", target.Path, target.ID)
+			renamed := r.relativePath(target.Path)
+			r.notify(LevelWarning, "%s collides with %s on case-insensitive file systems, cloning it into %s", relPath, owner.path, renamed)
+			r.summary.addRenamed(relPath, renamed)
+			relPath = renamed
+		}
+		r.claims[strings.ToLower(relPath)] = pathClaim{key: key, path: relPath}
+		claimed[i] = target
+	}
+	return claimed
+}
+
+/*
+groupDirectoryName turns an attribute value into a directory name.
+Values are lower-cased and characters that are not allowed in file names are
+replaced, so "C++" and "c++" share a directory on every platform.
+*/
+func groupDirectoryName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '-'
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(value)))
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "_ungrouped"
+	}
+	return name
+}
+
+/*
+syncAll synchronizes the given repositories using a pool of parallel workers.
+The pool size comes from the Concurrency option (at least one worker); with
+Adaptive, it is the upper bound of a pool resized by a concurrencyController.
+API calls made by the workers still share the client's rate budget.
+Failures are reported per repository and never stop the remaining work.
+In CI mode progress is printed as timestamped lines inside a log section.
+*/
+func (r *syncRun) syncAll(targets []syncTarget) {
+	targets = r.orderTargets(r.claimPaths(r.filterDependents(r.remapTargets(r.filterTargets(r.pinTargets(r.rewriteURLs(targets)))))))
+	if r.options.AcceptNewHostKeys && r.options.CloneMethod == "ssh" && !r.options.NoWrite {
+		r.pinHostKeys(targets)
+	}
+	workers := r.options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var controller *concurrencyController
+	if r.options.Adaptive {
+		controller = newConcurrencyController(workers, r.notify)
+	}
+
+	r.emit(Event{Type: EventPhaseStarted, Phase: "sync", Message: fmt.Sprintf("Syncing %d repositories", len(targets))})
+	defer r.emit(Event{Type: EventPhaseFinished, Phase: "sync"})
+
+	r.failureLimit = r.options.MaxFailures
+	if r.options.MaxFailuresPercent > 0 {
+		r.failureLimit = max(1, (len(targets)*r.options.MaxFailuresPercent+99)/100)
+	}
+
+	// Priority repositories are done before the long tail starts
+	var started atomic.Int64
+	priority, rest := r.splitPriority(targets)
+	if len(priority) > 0 {
+		r.notify(LevelNotice, "Syncing %d priority repositories first", len(priority))
+		r.syncTargets(priority, workers, controller, &started, len(targets))
+	}
+	r.syncTargets(rest, workers, controller, &started, len(targets))
+}
+
+/*
+syncTargets syncs targets with a pool of workers. started counts the repositories
+started in the whole run, out of total, for the progress lines.
+Once the run is aborted, the remaining targets are only recorded as not synced.
+*/
+func (r *syncRun) syncTargets(targets []syncTarget, workers int, controller *concurrencyController, started *atomic.Int64, total int) {
+	jobs := make(chan syncTarget)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				if r.summary.abortedBy() != "" {
+					r.skipAborted(target)
+					continue
+				}
+				controller.acquire()
+				err := r.syncReported(target, started.Add(1), total)
+				controller.release(err != nil)
+				if err != nil {
+					r.checkFailure(target)
+				}
+			}
+		}()
+	}
+
+	for _, target := range targets {
+		jobs <- target
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+/*
+checkFailure aborts the run after a failed repository when it was a priority
+repository or the failure budget (--max-failures) is used up, so systemic problems
+such as an expired token don't produce hundreds of identical errors.
+*/
+func (r *syncRun) checkFailure(target syncTarget) {
+	if r.isPriority(target) && r.summary.abort("priority repository "+target.RemotePath+" failed") {
+		r.notify(LevelError, "Priority repository %s failed, aborting the run", target.RemotePath)
+		return
+	}
+	failed := r.summary.failures()
+	if r.failureLimit > 0 && failed >= r.failureLimit && r.summary.abort(fmt.Sprintf("%d repositories failed", failed)) {
+		r.notify(LevelError, "%d repositories failed, the --max-failures budget is used up, aborting the run", failed)
+	}
+}
+
+/*
+skipAborted records a repository that is not synced because the run was aborted.
+It counts as seen, so its clone is not pruned.
+*/
+func (r *syncRun) skipAborted(target syncTarget) {
+	r.mu.Lock()
+	r.seen[helpers.StateKey(r.provider, target.ID)] = true
+	r.mu.Unlock()
+	r.summary.addAborted(r.relativePath(target.Path))
+}
+
+/*
+isPriority reports whether a repository is in the priority list of the config,
+by its remote path or a path.Match pattern such as acme/platform/*.
+*/
+func (r *syncRun) isPriority(target syncTarget) bool {
+	for _, pattern := range r.options.Priority {
+		if matched, _ := path.Match(pattern, target.RemotePath); matched || pattern == target.RemotePath {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+splitPriority separates the priority repositories from the others, keeping their order.
+*/
+func (r *syncRun) splitPriority(targets []syncTarget) (priority, rest []syncTarget) {
+	for _, target := range targets {
+		if r.isPriority(target) {
+			priority = append(priority, target)
+		} else {
+			rest = append(rest, target)
+		}
+	}
+	return priority, rest
+}
+
+/*
+orderTargets sorts the targets into the order the workers pick them up in.
+size-asc and size-desc use the size reported by the provider (GitHub) or else the size
+on disk recorded by the previous sync, with repositories of unknown size last;
+name sorts by remote path and activity puts the most recently active first.
+The sort is stable, so ties keep the order of the listing.
+*/
+func (r *syncRun) orderTargets(targets []syncTarget) []syncTarget {
+	size := func(target syncTarget) int64 {
+		if target.Size > 0 {
+			return target.Size
+		}
+		return r.state.Repositories[helpers.StateKey(r.provider, target.ID)].SizeBytes
+	}
+	switch r.options.Order {
+	case "size-asc", "size-desc":
+		sort.SliceStable(targets, func(i, j int) bool {
+			a, b := size(targets[i]), size(targets[j])
+			if a == 0 || b == 0 {
+				return b == 0 && a != 0
+			}
+			if r.options.Order == "size-desc" {
+				return a > b
+			}
+			return a < b
+		})
+	case "name":
+		sort.SliceStable(targets, func(i, j int) bool { return targets[i].RemotePath < targets[j].RemotePath })
+	case "activity":
+		sort.SliceStable(targets, func(i, j int) bool { return targets[i].LastActivity.After(targets[j].LastActivity) })
+	}
+	return targets
+}
+
+/*
+pinHostKeys pins the host keys of the SSH hosts the targets are cloned from
+before any clone starts, so parallel clones never race for a new host's key.
+*/
+func (r *syncRun) pinHostKeys(targets []syncTarget) {
+	var hosts []string
+	for _, target := range targets {
+		if host, ok := helpers.SSHHost(target.SSHURL); ok {
+			hosts = append(hosts, host)
+		}
+	}
+	path := helpers.GetKnownHostsPath(r.options.BaseDir)
+	pinned, err := helpers.PinHostKeys(path, hosts)
+	for _, host := range pinned {
+		r.audit.Record("pin-host-key", path, host, nil)
+		r.notify(LevelNotice, "Pinned the SSH host keys of %s", host)
+	}
+	if err != nil {
+		r.notify(LevelWarning, "%v, their keys are accepted on first contact", err)
+	}
+}
+
+/*
+syncReported syncs a repository between a started and a finished event,
+the current-th one of total in the run.
+Returns the error of the repository after it was reported.
+*/
+func (r *syncRun) syncReported(target syncTarget, current int64, total int) error {
+	name := target.RemotePath
+	if name == "" {
+		name = target.Name
+	}
+	r.emit(Event{Type: EventRepositoryStarted, Repository: name, Current: current, Total: total})
+	start := time.Now()
+	err := r.syncRepository(target)
+	finished := Event{Type: EventRepositoryFinished, Repository: name, Current: current, Total: total, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		finished.Error = helpers.Redact(err.Error())
+		finished.Class = helpers.GitErrorClass(err)
+		r.summary.addFailedClass(r.relativePath(target.Path), finished.Class)
+		r.diagnostics.addFailure(err)
+	}
+	r.emit(finished)
+	return err
+}
+
+/*
+syncRepository brings a single repository in line with its upstream.
+Relocates clones of renamed or moved repositories, clones missing ones,
+keeps the default branch aligned and records the result in the state,
+including how long the clone or update took. Empty repositories are cloned
+and recorded, but skip the steps that need commits and are listed separately
+in the summary.
+*/
+func (r *syncRun) syncRepository(target syncTarget) error {
+	r.checkStale(target)
+	if r.options.NoWrite {
+		r.planRepository(target)
+		return nil
+	}
+
+	start := time.Now()
+	r.relocateRepository(target)
+
+	_, statErr := os.Stat(target.Path)
+	exists := statErr == nil
+
+	repoURL := helpers.GetPreferredRepositoryURL(target.HTTPSURL, target.SSHURL, r.options.CloneMethod)
+	var err error
+	if exists {
+		err = helpers.CloneRepository(r.deps.Git, io.Discard, r.cloneStatus, repoURL, filepath.Dir(target.Path), filepath.Base(target.Path), r.options.Token, r.options.GitArgs...)
+	} else {
+		err = r.cloneRepository(target, repoURL)
+		r.audit.Record("clone", r.relativePath(target.Path), client.RedactURL(repoURL), err)
+	}
+	if errors.Is(err, helpers.ErrGitTimeout) {
+		r.summary.addTimedOut(r.relativePath(target.Path))
+	}
+	if err != nil {
+		return err
+	}
+	if exists && r.options.PruneRefs {
+		if err := r.pruneRefs(target); err != nil {
+			return err
+		}
+	}
+
+	// Providers can be late to notice the first push, so the clone has the last word
+	target.Empty = target.Empty && helpers.IsEmptyRepository(r.deps.Git, target.Path)
+	if target.Empty {
+		r.summary.addEmpty(r.relativePath(target.Path))
+		r.applyGitConfig(target)
+		r.recordRepository(target, time.Since(start), 0)
+		return nil
+	}
+
+	// Apart from checking out a pinned revision, everything from here on reports
+	// its failures but never fails the repository
+	if target.Revision != "" {
+		if err := r.checkoutRevision(target); err != nil {
+			return err
+		}
+	} else {
+		r.syncDefaultBranch(target)
+	}
+	r.syncTrackingBranches(target)
+	r.applyGitConfig(target)
+	duration := time.Since(start)
+
+	// Size is informational only, an unreadable file must not fail the sync
+	size, _ := helpers.DirectorySize(target.Path)
+	if r.ownership != nil {
+		r.collectOwnership(target)
+	}
+	if r.scans != nil {
+		r.scanRepository(target)
+	}
+	if r.dependencies != nil {
+		r.harvestDependencies(target)
+	}
+	if r.commits != nil {
+		r.exportCommits(target)
+	}
+	if r.options.ExportSettings && (r.provider == "gitlab" || r.provider == "github") {
+		r.exportSettings(target)
+	}
+	if r.options.ExportCIConfig && r.provider == "gitlab" {
+		r.exportCIConfig(target)
+	}
+	r.recordRepository(target, duration, size)
+	return nil
+}
+
+/*
+cloneRepository clones a missing repository, capturing git's output in its log file.
+The console only shows the clone's status lines unless ShowGitOutput is set,
+so the output of parallel clones doesn't interleave; the log is always written.
+*/
+func (r *syncRun) cloneRepository(target syncTarget, repoURL string) error {
+	logPath := helpers.GetRepositoryLogPath(r.options.BaseDir, r.relativePath(target.Path))
+	logFile, err := helpers.CreateRepositoryLog(logPath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	var output io.Writer = io.Discard
+	if r.options.ShowGitOutput {
+		output = os.Stderr
+	}
+	runner := helpers.LoggingGitRunner{Runner: r.deps.Git, Log: logFile}
+	if args := r.sharedObjectArgs(target); args != nil {
+		err = helpers.CloneRepository(runner, output, r.cloneStatus, repoURL, filepath.Dir(target.Path), filepath.Base(target.Path), r.options.Token, append(args, r.options.GitArgs...)...)
+		if err == nil {
+			return nil
+		}
+		r.notify(LevelWarning, "Failed to clone %s with shared objects, downloading it: %v", target.Name, err)
+	}
+	err = helpers.CloneRepository(runner, output, r.cloneStatus, repoURL, filepath.Dir(target.Path), filepath.Base(target.Path), r.options.Token, r.options.GitArgs...)
+	if err != nil {
+		return fmt.Errorf("%w (git output in %s)", err, logPath)
+	}
+	return nil
+}
+
+/*
+cloneStatus reports the status lines of helpers.CloneRepository as messages of the run.
+*/
+func (r *syncRun) cloneStatus(warning bool, message string) {
+	if warning {
+		r.notify(LevelWarning, "%s", message)
+	} else {
+		r.notify(LevelInfo, "%s", message)
+	}
+}
+
+/*
+pruneRefs fetches an existing clone with --prune --prune-tags and lists the branches
+and tags deleted upstream in the summary. A failed fetch fails the repository,
+as its clone was not updated.
+*/
+func (r *syncRun) pruneRefs(target syncTarget) error {
+	deleted, err := helpers.FetchPrune(r.deps.Git, target.Path)
+	if len(deleted) > 0 || err != nil {
+		r.audit.Record("prune-refs", r.relativePath(target.Path), strings.Join(deleted, ","), err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", target.Name, err)
+	}
+	if len(deleted) > 0 {
+		r.notify(LevelNotice, "Deleted %d refs in %s that were deleted upstream", len(deleted), target.Name)
+		r.summary.addPrunedRefs(r.relativePath(target.Path), deleted)
+	}
+	return nil
+}
+
+/*
+checkStale reports repositories without upstream activity beyond the StaleAfter threshold.
+Repositories for which the provider reports no activity date are never flagged.
+*/
+func (r *syncRun) checkStale(target syncTarget) {
+	if r.options.StaleAfter <= 0 || target.LastActivity.IsZero() {
+		return
+	}
+	if time.Since(target.LastActivity) > r.options.StaleAfter {
+		r.summary.addStale(r.relativePath(target.Path), target.LastActivity)
+	}
+}
+
+/*
+applyGitConfig enforces the manifest's git_config values in a clone.
+*/
+func (r *syncRun) applyGitConfig(target syncTarget) {
+	if len(r.options.GitConfig) == 0 {
+		return
+	}
+	changed, err := helpers.ApplyGitConfig(r.deps.Git, target.Path, r.options.GitConfig)
+	if len(changed) > 0 || err != nil {
+		r.audit.Record("git-config", r.relativePath(target.Path), strings.Join(changed, ","), err)
+	}
+	if err != nil {
+		r.notify(LevelError, "Failed to apply git config to %s: %v", target.Name, err)
+	}
+}
+
+/*
+syncDefaultBranch keeps an existing clone aligned with the upstream default branch.
+Detects default branch renames (e.g. master → main) and records migrated
+repositories in the run summary.
+*/
+func (r *syncRun) syncDefaultBranch(target syncTarget) {
+	previous, err := helpers.MigrateDefaultBranch(r.deps.Git, target.Path, target.DefaultBranch)
+	if previous != "" || err != nil {
+		r.audit.Record("switch-default-branch", r.relativePath(target.Path), previous+" -> "+target.DefaultBranch, err)
+	}
+	if err != nil {
+		r.notify(LevelError, "Failed to migrate default branch for %s: %v", target.Name, err)
+		return
+	}
+	if previous != "" {
+		r.notify(LevelWarning, "Default branch of %s changed from %s to %s", target.Name, previous, target.DefaultBranch)
+		r.summary.addMigratedBranch(target.Name, previous, target.DefaultBranch)
+	}
+}
+
+/*
+syncTrackingBranches repairs the upstreams of local branches and, with TrackAllBranches,
+creates local branches for the remote branches that have none.
+*/
+func (r *syncRun) syncTrackingBranches(target syncTarget) {
+	fixed, err := helpers.FixUpstreams(r.deps.Git, target.Path)
+	if len(fixed) > 0 || err != nil {
+		r.audit.Record("set-upstream", r.relativePath(target.Path), strings.Join(fixed, ","), err)
+	}
+	if err != nil {
+		r.notify(LevelError, "Failed to set upstream branches for %s: %v", target.Name, err)
+		return
+	}
+	if len(fixed) > 0 {
+		r.notify(LevelNotice, "Set upstream of %s in %s", strings.Join(fixed, ", "), target.Name)
+	}
+
+	if !r.options.TrackAllBranches {
+		return
+	}
+	created, err := helpers.TrackAllBranches(r.deps.Git, target.Path)
+	if len(created) > 0 || err != nil {
+		r.audit.Record("track-branches", r.relativePath(target.Path), strings.Join(created, ","), err)
+	}
+	if err != nil {
+		r.notify(LevelError, "Failed to create tracking branches for %s: %v", target.Name, err)
+		return
+	}
+	if len(created) > 0 {
+		r.notify(LevelNotice, "Created %d tracking branches in %s", len(created), target.Name)
+	}
+}
+
+/*
+checkoutRevision moves a clone to the revision its source or the workspace manifest pins it at.
+A pin that cannot be checked out fails the repository, as the clone would not match the source.
+*/
+func (r *syncRun) checkoutRevision(target syncTarget) error {
+	moved, err := helpers.CheckoutRevision(r.deps.Git, target.Path, target.Revision, target.PinBranch)
+	if moved || err != nil {
+		r.audit.Record("checkout", r.relativePath(target.Path), target.Revision, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check out %s: %w", target.Revision, err)
+	}
+	if moved && target.PinBranch != "" {
+		r.notify(LevelNotice, "Checked out %s at %s on branch %s", target.Name, target.Revision, target.PinBranch)
+	} else if moved {
+		r.notify(LevelNotice, "Checked out %s at %s", target.Name, target.Revision)
+	}
+	return nil
+}
+
+/*
+planRepository reports what syncRepository would do without changing anything.
+Used in --no-write mode to inspect a workspace (e.g. a production backup volume)
+with the guarantee that no clone, move or state update takes place.
+*/
+func (r *syncRun) planRepository(target syncTarget) {
+	relPath := r.relativePath(target.Path)
+
+	r.mu.Lock()
+	previous, known := r.state.Repositories[helpers.StateKey(r.provider, target.ID)]
+	r.seen[helpers.StateKey(r.provider, target.ID)] = true
+	r.mu.Unlock()
+
+	_, statErr := os.Stat(target.Path)
+	exists := statErr == nil
+
+	if known && previous.Path != relPath && !exists {
+		if _, err := os.Stat(filepath.Join(r.options.BaseDir, previous.Path)); err == nil {
+			r.notify(LevelNotice, "Would move: %s -> %s", previous.Path, relPath)
+			r.summary.addPlanned("move " + previous.Path + " -> " + relPath)
+			return
+		}
+	}
+	if !exists {
+		if target.Empty {
+			relPath += " (empty)"
+		}
+		r.notify(LevelNotice, "Would clone: %s", relPath)
+		r.summary.addPlanned("clone " + relPath)
+		return
+	}
+	r.notify(LevelInfo, "Present: %s", relPath)
+}
@@ -0,0 +1,578 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestCheckStale(t *testing.T) {
+	workspace := t.TempDir()
+	run := &syncRun{
+		options: models.SyncOptions{BaseDir: workspace, StaleAfter: 180 * 24 * time.Hour},
+		summary: &syncSummary{},
+	}
+
+	tests := []struct {
+		name         string
+		lastActivity time.Time
+	}{
+		{"old", time.Now().Add(-365 * 24 * time.Hour)},
+		{"recent", time.Now().Add(-24 * time.Hour)},
+		{"unknown", time.Time{}},
+	}
+	for _, tt := range tests {
+		run.checkStale(syncTarget{Name: tt.name, Path: filepath.Join(workspace, tt.name), LastActivity: tt.lastActivity})
+	}
+
+	if len(run.summary.stale) != 1 || run.summary.stale[0][:3] != "old" {
+		t.Errorf("stale = %v, want only the old repository", run.summary.stale)
+	}
+}
+
+func TestFilterTargetsByVisibility(t *testing.T) {
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{Visibility: []string{"internal", "private"}},
+		seen:     map[string]bool{},
+	}
+	targets := []syncTarget{
+		{ID: 1, Name: "handbook", Visibility: "internal"},
+		{ID: 2, Name: "website", Visibility: "public"},
+		{ID: 3, Name: "billing", Visibility: "private"},
+	}
+
+	included := run.filterTargets(targets)
+	if len(included) != 2 || included[0].Name != "handbook" || included[1].Name != "billing" {
+		t.Errorf("filterTargets() = %v, want handbook and billing", included)
+	}
+	if !run.seen["github:2"] {
+		t.Errorf("filtered repository should still count as seen")
+	}
+}
+
+func TestFilterTargetsInactive(t *testing.T) {
+	targets := []syncTarget{
+		{ID: 1, Name: "api"},
+		{ID: 2, Name: "legacy", Inactive: "archived"},
+		{ID: 3, Name: "locked", Inactive: "disabled"},
+		{ID: 4, Name: "old", Inactive: "pending deletion"},
+	}
+	tests := []struct {
+		name            string
+		includeInactive bool
+		noWrite         bool
+		want            []string
+	}{
+		{"skipped by default", false, false, []string{"api"}},
+		{"included on request", true, false, []string{"api", "legacy", "old"}},
+		{"planned", false, true, []string{"api"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &syncRun{
+				provider: "github",
+				options:  models.SyncOptions{IncludeInactive: tt.includeInactive, NoWrite: tt.noWrite},
+				seen:     map[string]bool{},
+				summary:  &syncSummary{},
+			}
+			var got []string
+			for _, target := range run.filterTargets(targets) {
+				got = append(got, target.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterTargets() = %v, want %v", got, tt.want)
+			}
+			if !run.seen["github:3"] {
+				t.Errorf("skipped repository should still count as seen")
+			}
+			reported := run.summary.skipped
+			if tt.noWrite {
+				reported = run.summary.planned
+			}
+			if len(reported)+len(got) != len(targets) {
+				t.Errorf("reported %v, want every skipped repository with its reason", reported)
+			}
+		})
+	}
+}
+
+func TestFilterTargetsSkipTopic(t *testing.T) {
+	targets := []syncTarget{
+		{ID: 1, Name: "api", Topics: []string{"payments"}},
+		{ID: 2, Name: "sandbox", Topics: []string{"Reposync-Skip"}},
+		{ID: 3, Name: "secret", Topics: []string{"no-mirror", "payments"}},
+	}
+	tests := []struct {
+		skipTopic string
+		want      []string
+	}{
+		{"reposync-skip", []string{"api", "secret"}},
+		{"no-mirror", []string{"api", "sandbox"}},
+		{"", []string{"api", "sandbox", "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.skipTopic, func(t *testing.T) {
+			run := &syncRun{provider: "github", options: models.SyncOptions{SkipTopic: tt.skipTopic}, seen: map[string]bool{}, summary: &syncSummary{}}
+			var got []string
+			for _, target := range run.filterTargets(targets) {
+				got = append(got, target.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterTargets() = %v, want %v", got, tt.want)
+			}
+			if len(run.seen) != len(targets)-len(got) || len(run.summary.skipped) != len(targets)-len(got) {
+				t.Errorf("opted-out repositories should count as seen and be reported, seen = %v, skipped = %v", run.seen, run.summary.skipped)
+			}
+		})
+	}
+}
+
+func TestFilterTargetsListingFilters(t *testing.T) {
+	now := time.Now()
+	targets := []syncTarget{
+		{ID: 1, Name: "api", Topics: []string{"Payments", "backend"}, Language: "Go", LastActivity: now.Add(-24 * time.Hour)},
+		{ID: 2, Name: "web", Topics: []string{"payments"}, Language: "TypeScript", LastActivity: now.Add(-24 * time.Hour)},
+		{ID: 3, Name: "legacy", Topics: []string{"payments", "backend"}, Language: "go", LastActivity: now.Add(-400 * 24 * time.Hour)},
+	}
+	tests := []struct {
+		name     string
+		provider string
+		options  models.SyncOptions
+		want     []string
+	}{
+		{"no filters", "github", models.SyncOptions{}, []string{"api", "web", "legacy"}},
+		{"all topics", "github", models.SyncOptions{Topics: []string{"payments", "Backend"}}, []string{"api", "legacy"}},
+		{"language", "github", models.SyncOptions{Language: "Go"}, []string{"api", "legacy"}},
+		{"active after", "github", models.SyncOptions{ActiveAfter: now.Add(-90 * 24 * time.Hour)}, []string{"api", "web"}},
+		{"language filtered by gitlab", "gitlab", models.SyncOptions{Language: "Rust"}, []string{"api", "web", "legacy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &syncRun{provider: tt.provider, options: tt.options, seen: map[string]bool{}, summary: &syncSummary{}}
+			var got []string
+			for _, target := range run.filterTargets(targets) {
+				got = append(got, target.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterTargets() = %v, want %v", got, tt.want)
+			}
+			if len(run.seen) != len(targets)-len(got) {
+				t.Errorf("filtered repositories should count as seen, seen = %v", run.seen)
+			}
+		})
+	}
+}
+
+func TestFilterDependents(t *testing.T) {
+	workspace := t.TempDir()
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{BaseDir: workspace, DependencyPaths: map[string]bool{"billing": true}},
+		seen:     map[string]bool{},
+		summary:  &syncSummary{},
+	}
+	targets := []syncTarget{
+		{ID: 1, Name: "billing", Path: filepath.Join(workspace, "billing")},
+		{ID: 2, Name: "web", Path: filepath.Join(workspace, "web")},
+	}
+	got := run.filterDependents(targets)
+	if len(got) != 1 || got[0].Name != "billing" {
+		t.Errorf("filterDependents() = %v, want only billing", got)
+	}
+	if !run.seen["github:2"] {
+		t.Errorf("filtered repository should count as seen")
+	}
+}
+
+func TestOrderTargets(t *testing.T) {
+	now := time.Now()
+	targets := []syncTarget{
+		{ID: 1, RemotePath: "acme/web", Size: 2 << 20, LastActivity: now.Add(-48 * time.Hour)},
+		{ID: 2, RemotePath: "acme/api", LastActivity: now},
+		{ID: 3, RemotePath: "acme/docs"}, // Size unknown
+		{ID: 4, RemotePath: "acme/cli", Size: 1 << 20, LastActivity: now.Add(-time.Hour)},
+	}
+	state := &models.State{Repositories: map[string]models.RepositoryState{
+		"github:2": {SizeBytes: 5 << 20}, // Recorded by the previous sync
+	}}
+
+	tests := []struct {
+		order string
+		want  []int64
+	}{
+		{"", []int64{1, 2, 3, 4}},
+		{"size-asc", []int64{4, 1, 2, 3}},
+		{"size-desc", []int64{2, 1, 4, 3}},
+		{"name", []int64{2, 4, 3, 1}},
+		{"activity", []int64{2, 4, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			run := &syncRun{provider: "github", options: models.SyncOptions{Order: tt.order}, state: state}
+			got := run.orderTargets(append([]syncTarget(nil), targets...))
+			for i, target := range got {
+				if target.ID != tt.want[i] {
+					t.Errorf("orderTargets() position %d = %d, want order %v", i, target.ID, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSyncAllPriority(t *testing.T) {
+	helpers.SetCloneAttempts(1)
+	t.Cleanup(func() { helpers.SetCloneAttempts(3) })
+
+	workspace := t.TempDir()
+	git := &fakeGitRunner{failures: map[string]int{"clone": 1}}
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{BaseDir: workspace, Concurrency: 1, Priority: []string{"acme/platform-*"}},
+		deps:     Dependencies{Git: git},
+		state:    &models.State{Repositories: map[string]models.RepositoryState{}},
+		seen:     map[string]bool{},
+		summary:  &syncSummary{},
+		claims:   map[string]pathClaim{},
+	}
+	targets := []syncTarget{
+		{ID: 1, Name: "web", RemotePath: "acme/web", Path: filepath.Join(workspace, "web")},
+		{ID: 2, Name: "platform-api", RemotePath: "acme/platform-api", Path: filepath.Join(workspace, "platform-api")},
+	}
+	run.syncAll(targets)
+
+	// The priority repository was synced first and failed, so web was never started
+	if len(run.summary.failed) != 1 || run.summary.failed[0] != "platform-api" {
+		t.Errorf("failed = %v, want the priority repository", run.summary.failed)
+	}
+	if len(run.summary.aborted) != 1 || run.summary.aborted[0] != "web" {
+		t.Errorf("aborted = %v, want web", run.summary.aborted)
+	}
+	if !run.seen["github:1"] {
+		t.Errorf("repository skipped by the abort should count as seen")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "web")); !os.IsNotExist(err) {
+		t.Errorf("web was cloned after the priority repository failed")
+	}
+}
+
+func TestSyncAllMaxFailures(t *testing.T) {
+	helpers.SetCloneAttempts(1)
+	t.Cleanup(func() { helpers.SetCloneAttempts(3) })
+
+	tests := []struct {
+		name        string
+		count       int
+		percent     int
+		wantAborted int
+	}{
+		{"no budget", 0, 0, 0},
+		{"count", 2, 0, 8},
+		{"percentage", 0, 30, 7}, // 30% of 10 is 3 failures
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			run := &syncRun{
+				provider: "github",
+				options:  models.SyncOptions{BaseDir: workspace, Concurrency: 1, MaxFailures: tt.count, MaxFailuresPercent: tt.percent},
+				deps:     Dependencies{Git: &fakeGitRunner{failures: map[string]int{"clone": 100}}},
+				state:    &models.State{Repositories: map[string]models.RepositoryState{}},
+				seen:     map[string]bool{},
+				summary:  &syncSummary{},
+				claims:   map[string]pathClaim{},
+			}
+			var targets []syncTarget
+			for i := 1; i <= 10; i++ {
+				name := fmt.Sprintf("repo-%d", i)
+				targets = append(targets, syncTarget{ID: int64(i), Name: name, RemotePath: "acme/" + name, Path: filepath.Join(workspace, name)})
+			}
+			run.syncAll(targets)
+
+			if got := len(run.summary.aborted); got != tt.wantAborted {
+				t.Errorf("aborted %d repositories, want %d", got, tt.wantAborted)
+			}
+			if got := len(run.summary.failed) + len(run.summary.aborted); got != 10 {
+				t.Errorf("%d repositories failed or aborted, want all 10", got)
+			}
+		})
+	}
+}
+
+func TestGroupTargets(t *testing.T) {
+	base := filepath.Join("work", "acme")
+	tests := []struct {
+		groupBy string
+		target  syncTarget
+		want    string
+	}{
+		{"topic", syncTarget{Path: filepath.Join(base, "api"), Topics: []string{"payments", "backend"}}, filepath.Join(base, "backend", "api")},
+		{"topic", syncTarget{Path: filepath.Join(base, "api")}, filepath.Join(base, "_ungrouped", "api")},
+		{"language", syncTarget{Path: filepath.Join(base, "sub", "lib"), Language: "C++"}, filepath.Join(base, "c++", "sub", "lib")},
+		{"language", syncTarget{Path: filepath.Join(base, "site"), Language: "Objective C/ARC"}, filepath.Join(base, "objective-c-arc", "site")},
+		{"visibility", syncTarget{Path: filepath.Join(base, "web"), Visibility: "internal"}, filepath.Join(base, "internal", "web")},
+		{"", syncTarget{Path: filepath.Join(base, "web"), Visibility: "internal"}, filepath.Join(base, "web")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.groupBy+" "+tt.want, func(t *testing.T) {
+			run := &syncRun{options: models.SyncOptions{BaseDir: base, GroupBy: tt.groupBy}}
+			if got := run.groupTargets([]syncTarget{tt.target})[0].Path; got != tt.want {
+				t.Errorf("groupTargets() path = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapTargets(t *testing.T) {
+	base := filepath.Join("work", "acme")
+	run := &syncRun{
+		provider: "gitlab",
+		options: models.SyncOptions{BaseDir: base, Remap: &models.Remap{
+			Repositories: map[string]string{"acme/legacy": "billing", "gitlab:acme/docs": "handbook/docs", "github:acme/web": "site"},
+			Paths:        map[string]string{"platform": "old-platform", "platform/backend": "backend"},
+		}},
+	}
+	tests := []struct {
+		remotePath string
+		path       string
+		want       string
+	}{
+		{"acme/legacy", "legacy", "billing"},
+		{"acme/docs", "docs", "handbook/docs"},
+		{"acme/web", "web", "web"},
+		{"acme/platform/backend/auth", "platform/backend/auth", "backend/auth"},
+		{"acme/platform/ui", "platform/ui", "old-platform/ui"},
+		{"acme/platform-tools", "platform-tools", "platform-tools"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remotePath, func(t *testing.T) {
+			target := syncTarget{RemotePath: tt.remotePath, Path: filepath.Join(base, filepath.FromSlash(tt.path))}
+			if got := run.relativePath(run.remapTargets([]syncTarget{target})[0].Path); got != tt.want {
+				t.Errorf("remapTargets() path = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinTargets(t *testing.T) {
+	run := &syncRun{provider: "github", options: models.SyncOptions{Pins: map[string]models.ManifestPin{
+		"acme/api":          {Revision: "v1.2.0"},
+		"github:acme/web":   {Revision: "abc123", Branch: "release"},
+		"gitlab:acme/tools": {Revision: "v3"},
+		"acme/lib":          {Revision: "v2"},
+	}}}
+	tests := []struct {
+		remotePath string
+		revision   string
+		want       syncTarget
+	}{
+		{"acme/api", "", syncTarget{RemotePath: "acme/api", Revision: "v1.2.0"}},
+		{"acme/web", "", syncTarget{RemotePath: "acme/web", Revision: "abc123", PinBranch: "release"}},
+		{"acme/tools", "", syncTarget{RemotePath: "acme/tools"}},
+		{"acme/lib", "main", syncTarget{RemotePath: "acme/lib", Revision: "v2"}},
+		{"acme/docs", "main", syncTarget{RemotePath: "acme/docs", Revision: "main"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remotePath, func(t *testing.T) {
+			got := run.pinTargets([]syncTarget{{RemotePath: tt.remotePath, Revision: tt.revision}})[0]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pinTargets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimPaths(t *testing.T) {
+	workspace := t.TempDir()
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{BaseDir: workspace},
+		state:    &models.State{Repositories: map[string]models.RepositoryState{"github:9": {Path: "Repo"}}},
+		summary:  &syncSummary{},
+		claims:   map[string]pathClaim{},
+	}
+	targets := []syncTarget{
+		{ID: 3, Path: filepath.Join(workspace, "repo")},
+		{ID: 9, Path: filepath.Join(workspace, "Repo")},
+		{ID: 5, Path: filepath.Join(workspace, "REPO")},
+		{ID: 7, Path: filepath.Join(workspace, "tools")},
+	}
+
+	claimed := run.claimPaths(targets)
+	want := []string{"repo-3", "Repo", "REPO-5", "tools"}
+	for i, target := range claimed {
+		if got := run.relativePath(target.Path); got != want[i] {
+			t.Errorf("path of repository %d = %q, want %q", target.ID, got, want[i])
+		}
+	}
+	if len(run.summary.renamed) != 2 {
+		t.Errorf("renamed = %v, want two entries", run.summary.renamed)
+	}
+
+	// Listing the same repository again does not count as a collision
+	if got := run.claimPaths(targets[1:2])[0].Path; got != targets[1].Path {
+		t.Errorf("path of a repository listed twice = %q, want %q", got, targets[1].Path)
+	}
+}
+
+func TestRecordRepositoryTiming(t *testing.T) {
+	workspace := t.TempDir()
+	run := &syncRun{
+		provider: "gitlab",
+		options:  models.SyncOptions{BaseDir: workspace},
+		state: &models.State{Repositories: map[string]models.RepositoryState{
+			"gitlab:1": {SyncDurationMS: 500},
+		}},
+		seen:    map[string]bool{},
+		summary: &syncSummary{},
+	}
+
+	for i := int64(1); i <= 12; i++ {
+		target := syncTarget{ID: i, Path: filepath.Join(workspace, fmt.Sprintf("repo-%02d", i))}
+		run.recordRepository(target, time.Duration(i)*time.Second, 1024)
+	}
+
+	if got := run.state.Repositories["gitlab:12"]; got.SyncDurationMS != 12000 || got.SizeBytes != 1024 {
+		t.Errorf("state = %+v, want duration and size recorded", got)
+	}
+	slowest := run.summary.slowest(slowestShown)
+	if len(slowest) != slowestShown || slowest[0] != "repo-12 (12s, 1.0 KiB)" {
+		t.Errorf("slowest = %v, want 10 entries starting with repo-12", slowest)
+	}
+	if last := slowest[len(slowest)-1]; last != "repo-03 (3s, 1.0 KiB)" {
+		t.Errorf("last slowest = %q, want repo-03", last)
+	}
+
+	run.summary = &syncSummary{}
+	run.recordRepository(syncTarget{ID: 1, Path: filepath.Join(workspace, "repo-01")}, 2*time.Second, 0)
+	if got := run.summary.slowest(1)[0]; got != "repo-01 (2s, 0 B, previously 1s)" {
+		t.Errorf("slowest = %q, want previous duration", got)
+	}
+}
+
+func TestSyncRepositoryEmpty(t *testing.T) {
+	tests := []struct {
+		name      string
+		empty     bool // Reported by the provider
+		hasCommit bool // Found in the clone
+		want      bool
+	}{
+		{"empty", true, false, true},
+		{"pushed since listing", true, true, false},
+		{"not reported empty", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			git := &fakeGitRunner{failures: map[string]int{}}
+			if !tt.hasCommit {
+				git.failures["rev-parse"] = 1
+			}
+			run := &syncRun{
+				provider: "github",
+				options:  models.SyncOptions{BaseDir: workspace},
+				deps:     Dependencies{Git: git},
+				state:    &models.State{Repositories: map[string]models.RepositoryState{}},
+				seen:     map[string]bool{},
+				summary:  &syncSummary{},
+			}
+
+			target := syncTarget{ID: 1, Name: "api", DefaultBranch: "main", Path: filepath.Join(workspace, "api"), Empty: tt.empty}
+			if err := run.syncRepository(target); err != nil {
+				t.Fatalf("syncRepository() error = %v", err)
+			}
+			if got := len(run.summary.emptyRepos) == 1; got != tt.want {
+				t.Errorf("listed as empty = %v, want %v", got, tt.want)
+			}
+			if _, ok := run.state.Repositories["github:1"]; !ok {
+				t.Errorf("repository not recorded in the state")
+			}
+		})
+	}
+}
+
+func TestSyncRepositoryPruneRefs(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "api", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git := &fakeGitRunner{outputs: map[string]string{
+		"fetch": "From https://github.com/acme/api\n - [deleted]         (none)     -> origin/feature\n - [deleted]         (none)     -> v1.0\n",
+	}}
+	run := &syncRun{
+		provider: "github",
+		options:  models.SyncOptions{BaseDir: workspace, PruneRefs: true},
+		deps:     Dependencies{Git: git},
+		state:    &models.State{Repositories: map[string]models.RepositoryState{}},
+		seen:     map[string]bool{},
+		summary:  &syncSummary{},
+	}
+
+	target := syncTarget{ID: 1, Name: "api", DefaultBranch: "main", Path: filepath.Join(workspace, "api")}
+	if err := run.syncRepository(target); err != nil {
+		t.Fatalf("syncRepository() error = %v", err)
+	}
+	if want := "fetch origin --prune --prune-tags"; !strings.Contains(strings.Join(git.commands(), "\n"), want) {
+		t.Errorf("git commands = %v, want %q", git.commands(), want)
+	}
+	if want := []string{"api (origin/feature, v1.0)"}; !reflect.DeepEqual(run.summary.prunedRefs, want) {
+		t.Errorf("pruned refs = %v, want %v", run.summary.prunedRefs, want)
+	}
+}
+
+func TestNewSyncTargetFromProviders(t *testing.T) {
+	bitbucket := models.BitbucketServerRepository{ID: 3, Slug: "api", Name: "API", Public: true}
+	bitbucket.Project.Key = "ACME"
+	bitbucket.Links.Clone = []models.BitbucketServerLink{{Name: "http", Href: "https://jdoe@bitbucket.example.com/scm/acme/api.git"}}
+
+	tests := []struct {
+		name         string
+		repository   models.Repository
+		wantRemote   string
+		wantInactive string
+		wantEmpty    bool
+		wantSize     int64
+	}{
+		{
+			name:         "github",
+			repository:   gitHubRepository(models.GitHubRepository{ID: 1, Name: "api", FullName: "acme/api", Size: 2, Disabled: true, Archived: true}),
+			wantRemote:   "acme/api",
+			wantInactive: "disabled",
+			wantSize:     2048,
+		},
+		{
+			name:         "gitlab",
+			repository:   gitLabRepository(models.GitLabRepository{ID: 2, Name: "API Server", Path: "api-server", PathWithNamespace: "acme/backend/api-server", MarkedForDeletionAt: "2026-01-01", Archived: true}),
+			wantRemote:   "acme/backend/api-server",
+			wantInactive: "pending deletion",
+			wantEmpty:    true,
+		},
+		{
+			name:       "bitbucket-server",
+			repository: bitbucketServerRepository(bitbucket),
+			wantRemote: "ACME/api",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newSyncTarget(tt.repository, "dir")
+			if target.RemotePath != tt.wantRemote || target.Inactive != tt.wantInactive || target.Empty != tt.wantEmpty || target.Size != tt.wantSize {
+				t.Errorf("newSyncTarget() = %+v, want remote %q, inactive %q, empty %v, size %d", target, tt.wantRemote, tt.wantInactive, tt.wantEmpty, tt.wantSize)
+			}
+		})
+	}
+
+	if got := bitbucketServerRepository(bitbucket).HTTPSURL; got != "https://bitbucket.example.com/scm/acme/api.git" {
+		t.Errorf("bitbucketServerRepository() HTTPSURL = %q, want it without the username", got)
+	}
+}
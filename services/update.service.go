@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	client "github.com/itszeeshan/reposync/client"
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+// latestReleaseURL is the GitHub API endpoint of the newest reposync release.
+const latestReleaseURL = "https://api.github.com/repos/itszeeshan/reposync/releases/latest"
+
+// checksumsAsset is the release file listing the SHA-256 checksum of every binary.
+const checksumsAsset = "checksums.txt"
+
+/*
+SelfUpdate replaces the running reposync binary with the latest release.
+The binary for this platform is downloaded next to the executable, verified against
+the release's checksums file and only then moved over the executable. With check,
+only reports whether a newer release exists. Installations managed by Homebrew or
+Scoop are left to their package manager.
+*/
+func SelfUpdate(current string, check bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the reposync executable: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to locate the reposync executable: %w", err)
+	}
+	return selfUpdate(http.DefaultClient, latestReleaseURL, current, executable, check)
+}
+
+/*
+selfUpdate implements SelfUpdate for a given release endpoint and executable path.
+*/
+func selfUpdate(doer client.HTTPDoer, releaseURL, current, executable string, check bool) error {
+	if manager := packageManager(executable); manager != "" {
+		return fmt.Errorf("reposync was installed with %s, update it there instead", manager)
+	}
+
+	var release models.Release
+	if err := getRelease(doer, releaseURL, &release); err != nil {
+		return err
+	}
+	if strings.TrimPrefix(release.TagName, "v") == strings.TrimPrefix(current, "v") {
+		Notify(LevelSuccess, "reposync %s is the latest release", current)
+		return nil
+	}
+	if check {
+		Notify(LevelWarning, "reposync %s is available (installed: %s), run reposync self-update to install it", release.TagName, current)
+		return nil
+	}
+
+	name := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	binaryURL, checksumsURL := "", ""
+	for _, asset := range release.Assets {
+		switch asset.Name {
+		case name:
+			binaryURL = asset.DownloadURL
+		case checksumsAsset:
+			checksumsURL = asset.DownloadURL
+		}
+	}
+	if binaryURL == "" || checksumsURL == "" {
+		return fmt.Errorf("release %s has no %s with a %s", release.TagName, name, checksumsAsset)
+	}
+
+	checksums, err := download(doer, checksumsURL)
+	if err != nil {
+		return err
+	}
+	binary, err := download(doer, binaryURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(binary, checksums, name); err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(executable, binary); err != nil {
+		return err
+	}
+	Notify(LevelSuccess, "Updated reposync %s to %s", current, release.TagName)
+	return nil
+}
+
+/*
+getRelease fetches the release metadata. The API is queried without a token, as
+releases of a public repository need none.
+*/
+func getRelease(doer client.HTTPDoer, releaseURL string, release *models.Release) error {
+	resp, err := client.RequestWithHeader(doer, "GET", releaseURL, "Accept", "application/vnd.github+json")
+	if err != nil {
+		return fmt.Errorf("failed to fetch the latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(release); err != nil {
+		return fmt.Errorf("failed to decode the latest release: %w", err)
+	}
+	return nil
+}
+
+/*
+download fetches a release asset into memory.
+*/
+func download(doer client.HTTPDoer, url string) ([]byte, error) {
+	resp, err := client.RequestWithHeader(doer, "GET", url, "Accept", "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return data, nil
+}
+
+/*
+releaseAssetName returns the name of the release binary for a platform,
+e.g. reposync_linux_amd64 or reposync_windows_amd64.exe.
+*/
+func releaseAssetName(goos, goarch string) string {
+	name := fmt.Sprintf("reposync_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+/*
+verifyChecksum compares the SHA-256 of data with its entry in a checksums file
+in sha256sum format ("<hex>  <name>" per line).
+*/
+func verifyChecksum(data, checksums []byte, name string) error {
+	sum := sha256.Sum256(data)
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("checksum mismatch for %s: the download is corrupt or was tampered with", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not listed in %s", name, checksumsAsset)
+}
+
+/*
+replaceExecutable swaps the executable for a new binary. The binary is written next
+to it and renamed over it, so an interrupted update never leaves a partial executable.
+Windows cannot overwrite a running executable, so it is moved aside first.
+*/
+func replaceExecutable(executable string, binary []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(executable), ".reposync-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write the update next to %s: %w", executable, err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(binary); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write the update: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write the update: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to make the update executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			return fmt.Errorf("failed to move the running executable aside: %w", err)
+		}
+	}
+	if err := os.Rename(temp.Name(), executable); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", executable, err)
+	}
+	return nil
+}
+
+/*
+packageManager names the package manager an executable was installed with, if any.
+*/
+func packageManager(executable string) string {
+	path := filepath.ToSlash(executable)
+	switch {
+	case strings.Contains(path, "/Cellar/") || strings.Contains(path, "/homebrew/") || strings.Contains(path, "/linuxbrew/"):
+		return "Homebrew (brew upgrade reposync)"
+	case strings.Contains(strings.ToLower(path), "/scoop/"):
+		return "Scoop (scoop update reposync)"
+	}
+	return ""
+}
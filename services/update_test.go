@@ -0,0 +1,82 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+)
+
+func newReleaseServer(t *testing.T, tag string, binary []byte, checksum string) *httptest.Server {
+	t.Helper()
+	name := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest":
+			json.NewEncoder(w).Encode(models.Release{TagName: tag, Assets: []models.ReleaseAsset{
+				{Name: name, DownloadURL: server.URL + "/download/" + name},
+				{Name: checksumsAsset, DownloadURL: server.URL + "/download/" + checksumsAsset},
+			}})
+		case "/download/" + name:
+			w.Write(binary)
+		case "/download/" + checksumsAsset:
+			w.Write([]byte(checksum + "  " + name + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSelfUpdate(t *testing.T) {
+	binary := []byte("new reposync binary")
+	sum := sha256.Sum256(binary)
+
+	tests := []struct {
+		name     string
+		current  string
+		check    bool
+		checksum string
+		want     string
+		wantErr  string
+	}{
+		{"updates", "v1.0.0", false, hex.EncodeToString(sum[:]), string(binary), ""},
+		{"check only", "v1.0.0", true, hex.EncodeToString(sum[:]), "old binary", ""},
+		{"up to date", "1.1.0", false, hex.EncodeToString(sum[:]), "old binary", ""},
+		{"checksum mismatch", "v1.0.0", false, strings.Repeat("0", 64), "old binary", "checksum mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newReleaseServer(t, "v1.1.0", binary, tt.checksum)
+			executable := filepath.Join(t.TempDir(), "reposync")
+			if err := os.WriteFile(executable, []byte("old binary"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := selfUpdate(server.Client(), server.URL+"/releases/latest", tt.current, executable, tt.check)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("selfUpdate() error = %v, want %q", err, tt.wantErr)
+			}
+			if data, _ := os.ReadFile(executable); string(data) != tt.want {
+				t.Errorf("executable = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfUpdatePackageManager(t *testing.T) {
+	err := selfUpdate(http.DefaultClient, "http://127.0.0.1:0", "v1.0.0", "/opt/homebrew/Cellar/reposync/1.0.0/bin/reposync", false)
+	if err == nil || !strings.Contains(err.Error(), "brew upgrade") {
+		t.Errorf("selfUpdate() error = %v, want a hint to use Homebrew", err)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+// viewFilterKeys are the repository attributes a view can be filtered by.
+var viewFilterKeys = []string{"team", "topic", "language", "visibility", "provider", "path"}
+
+/*
+viewFilter restricts a view to repositories whose attribute matches a value.
+*/
+type viewFilter struct {
+	key   string
+	value string
+}
+
+/*
+parseViewFilters parses key=value filters, e.g. team=payments or path=backend/*.
+*/
+func parseViewFilters(filters []string) ([]viewFilter, error) {
+	parsed := make([]viewFilter, 0, len(filters))
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || value == "" || !slices.Contains(viewFilterKeys, key) {
+			return nil, fmt.Errorf("invalid filter %q: use key=value with key one of %s", filter, strings.Join(viewFilterKeys, ", "))
+		}
+		if key == "path" {
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid path pattern %q: %w", value, err)
+			}
+		}
+		parsed = append(parsed, viewFilter{key: key, value: value})
+	}
+	return parsed, nil
+}
+
+/*
+CreateView builds or refreshes a view: a directory of symlinks to the clones of a
+workspace that match all filters. The clones stay in the workspace, so any number
+of views can arrange them differently without copying data. By default a view
+mirrors the workspace layout; with flat, links are named after the repository.
+Running it again for the same directory adds new matches and removes links to
+repositories that no longer match; anything else in the directory is left alone.
+*/
+func CreateView(workspace, viewDir string, filters []string, flat bool) error {
+	parsed, err := parseViewFilters(filters)
+	if err != nil {
+		return err
+	}
+	if workspace, err = filepath.Abs(workspace); err != nil {
+		return err
+	}
+	if viewDir, err = filepath.Abs(viewDir); err != nil {
+		return err
+	}
+	if relPath, err := filepath.Rel(workspace, viewDir); err == nil && filepath.IsLocal(relPath) {
+		return fmt.Errorf("view directory %s must be outside the workspace", viewDir)
+	}
+
+	state, err := helpers.LoadState(workspace)
+	if err != nil {
+		return err
+	}
+	if len(state.Repositories) == 0 {
+		return fmt.Errorf("no repositories recorded in %s: sync the workspace first", workspace)
+	}
+	var access models.AccessSnapshot
+	if _, err := readSnapshot(helpers.GetAccessSnapshotPath(workspace), &access); err != nil {
+		return err
+	}
+
+	links := viewLinks(workspace, state, access, parsed, flat)
+	removed, err := removeStaleViewLinks(workspace, viewDir, links)
+	if err != nil {
+		return err
+	}
+
+	created := 0
+	for _, link := range sortedKeys(links) {
+		target := links[link]
+		linkPath := filepath.Join(viewDir, filepath.FromSlash(link))
+		if existing, err := os.Readlink(linkPath); err == nil && existing == target {
+			continue
+		}
+		if _, err := os.Lstat(linkPath); err == nil {
+			Notify(LevelWarning, "Skipping %s: the path already exists in the view", link)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+			return fmt.Errorf("failed to create view directory: %w", err)
+		}
+		if err := os.Symlink(target, linkPath); err != nil {
+			return fmt.Errorf("failed to link %s: %w", link, err)
+		}
+		created++
+	}
+
+	Notify(LevelSuccess, "View %s: %d repositories (%d linked, %d removed)", viewDir, len(links), created, removed)
+	return nil
+}
+
+/*
+viewLinks selects the clones matching the filters.
+Returns the view-relative link paths with the absolute clone path each points to.
+Flat views name links after the repository and append the ID when names repeat.
+*/
+func viewLinks(workspace string, state *models.State, access models.AccessSnapshot, filters []viewFilter, flat bool) map[string]string {
+	keys := make([]string, 0, len(state.Repositories))
+	for key := range state.Repositories {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return state.Repositories[keys[i]].Path < state.Repositories[keys[j]].Path
+	})
+
+	links := map[string]string{}
+	for _, key := range keys {
+		entry := state.Repositories[key]
+		repoPath := filepath.Join(workspace, filepath.FromSlash(entry.Path))
+		if _, err := os.Stat(repoPath); err != nil {
+			continue // Clone no longer exists
+		}
+		if !matchesViewFilters(entry, repoPath, access, filters) {
+			continue
+		}
+
+		link := entry.Path
+		if flat {
+			link = path.Base(entry.Path)
+			if _, taken := links[link]; taken {
+				link = fmt.Sprintf("%s-%d", link, entry.ID)
+			}
+		}
+		links[link] = repoPath
+	}
+	return links
+}
+
+/*
+matchesViewFilters reports whether a repository matches all filters.
+Values are compared case-insensitively; path filters are glob patterns.
+*/
+func matchesViewFilters(entry models.RepositoryState, repoPath string, access models.AccessSnapshot, filters []viewFilter) bool {
+	for _, filter := range filters {
+		var values []string
+		switch filter.key {
+		case "team":
+			values = repositoryTeams(entry, repoPath, access)
+		case "topic":
+			values = entry.Topics
+		case "language":
+			values = []string{entry.Language}
+		case "visibility":
+			values = []string{entry.Visibility}
+		case "provider":
+			values = []string{entry.Provider}
+		case "path":
+			if matched, _ := path.Match(filter.value, entry.Path); !matched {
+				return false
+			}
+			continue
+		}
+		if !slices.ContainsFunc(values, func(value string) bool { return strings.EqualFold(value, filter.value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+repositoryTeams lists the teams a repository belongs to, by slug, name and full path.
+Teams come from the CODEOWNERS file of the clone (@org/team owners) and from the
+access snapshot taken with --with-settings: GitHub teams with access to the
+repository and GitLab subgroups containing it.
+*/
+func repositoryTeams(entry models.RepositoryState, repoPath string, access models.AccessSnapshot) []string {
+	var teams []string
+	addTeam := func(fullPath string) {
+		teams = append(teams, fullPath, path.Base(fullPath))
+	}
+
+	if _, rules, err := helpers.ReadCodeowners(repoPath); err == nil {
+		for _, rule := range rules {
+			for _, owner := range rule.Owners {
+				if name, ok := strings.CutPrefix(owner, "@"); ok && strings.Contains(name, "/") {
+					addTeam(name)
+				}
+			}
+		}
+	}
+
+	for _, team := range access.Teams {
+		member := strings.HasPrefix(entry.RemotePath, team.Path+"/") // GitLab subgroup
+		for _, permission := range team.Repositories {
+			member = member || permission.Repository == entry.RemotePath
+		}
+		if member {
+			addTeam(team.Path)
+			teams = append(teams, team.Name)
+		}
+	}
+	return teams
+}
+
+/*
+removeStaleViewLinks deletes the links of a view that are no longer part of it
+and the directories they leave empty. Only symlinks into the workspace are touched.
+*/
+func removeStaleViewLinks(workspace, viewDir string, links map[string]string) (int, error) {
+	if _, err := os.Stat(viewDir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	var stale []string
+	err := filepath.WalkDir(viewDir, func(linkPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			return nil
+		}
+		relTarget, err := filepath.Rel(workspace, target)
+		if err != nil || !filepath.IsLocal(relTarget) {
+			return nil // Not a link of ours
+		}
+		relLink, _ := filepath.Rel(viewDir, linkPath)
+		if links[filepath.ToSlash(relLink)] != target {
+			stale = append(stale, linkPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, linkPath := range stale {
+		if err := os.Remove(linkPath); err != nil {
+			return 0, fmt.Errorf("failed to remove %s: %w", linkPath, err)
+		}
+		// Removing a directory fails, as intended, once one that is not empty is reached
+		for directory := filepath.Dir(linkPath); directory != viewDir && os.Remove(directory) == nil; directory = filepath.Dir(directory) {
+		}
+	}
+	return len(stale), nil
+}
+
+/*
+sortedKeys returns the keys of a map in sorted order.
+*/
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
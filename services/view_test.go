@@ -0,0 +1,85 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	models "github.com/itszeeshan/reposync/constants/models"
+	helpers "github.com/itszeeshan/reposync/helpers"
+)
+
+func TestParseViewFilters(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantErr bool
+	}{
+		{"team=payments", false},
+		{"path=backend/*", false},
+		{"path=[", true},
+		{"owner=jdoe", true},
+		{"team=", true},
+		{"payments", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := parseViewFilters([]string{tt.filter})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseViewFilters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateView(t *testing.T) {
+	workspace := t.TempDir()
+	state := &models.State{Repositories: map[string]models.RepositoryState{
+		"github:1": {Provider: "github", ID: 1, Path: "api", RemotePath: "acme/api", Language: "Go"},
+		"github:2": {Provider: "github", ID: 2, Path: "ledger", RemotePath: "acme/ledger", Language: "Go"},
+		"github:3": {Provider: "github", ID: 3, Path: "web", RemotePath: "acme/web", Language: "TypeScript"},
+	}}
+	if err := helpers.SaveState(workspace, state); err != nil {
+		t.Fatal(err)
+	}
+	for _, repo := range []string{"api", "ledger", "web"} {
+		if err := os.MkdirAll(filepath.Join(workspace, repo, ".github"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// api is owned by payments through CODEOWNERS, ledger through the access snapshot
+	if err := os.WriteFile(filepath.Join(workspace, "api", ".github", "CODEOWNERS"), []byte("* @acme/payments\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	access := models.AccessSnapshot{Teams: []models.AccessTeam{
+		{Name: "Payments", Path: "payments", Repositories: []models.RepositoryPermission{{Repository: "acme/ledger", Permission: "push"}}},
+	}}
+	if err := helpers.WriteJSONReport(helpers.GetAccessSnapshotPath(workspace), access); err != nil {
+		t.Fatal(err)
+	}
+
+	viewDir := filepath.Join(t.TempDir(), "payments")
+	if err := CreateView(workspace, viewDir, []string{"team=payments"}, false); err != nil {
+		t.Fatalf("CreateView() error = %v", err)
+	}
+	for _, repo := range []string{"api", "ledger"} {
+		if target, err := os.Readlink(filepath.Join(viewDir, repo)); err != nil || target != filepath.Join(workspace, repo) {
+			t.Errorf("link %s = %q, %v, want it to point to the clone", repo, target, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(viewDir, "web")); !os.IsNotExist(err) {
+		t.Errorf("web should not be part of the view")
+	}
+
+	// Refreshing with a narrower filter removes the links that no longer match
+	if err := CreateView(workspace, viewDir, []string{"team=payments", "path=api"}, false); err != nil {
+		t.Fatalf("CreateView() refresh error = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(viewDir, "ledger")); !os.IsNotExist(err) {
+		t.Errorf("link to ledger was not removed")
+	}
+
+	if err := CreateView(workspace, filepath.Join(workspace, "views"), nil, false); err == nil {
+		t.Errorf("CreateView() inside the workspace succeeded")
+	}
+}